@@ -0,0 +1,111 @@
+// Command backfill starts the AI backfill of the bookmarks without AI data or embedding, like
+// the ones imported from Pocket or saved before the AI processing. The server queues the
+// bookmarks of the started backfills in throttled batches within the daily bookmark limit of
+// each user, and a backfill stopped midway resumes where it stopped.
+//
+// After a switch of the embedding model, running it with -all embeds every bookmark again with
+// the new model. The searches only use the vectors of the configured model meanwhile.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"slices"
+
+	"github.com/arashthr/pensive/internal/config"
+	"github.com/arashthr/pensive/internal/db"
+	"github.com/arashthr/pensive/internal/llm"
+	"github.com/arashthr/pensive/internal/logging"
+	"github.com/arashthr/pensive/internal/models"
+	"github.com/arashthr/pensive/internal/types"
+	"google.golang.org/genai"
+)
+
+func main() {
+	userId := flag.Int("user", 0, "backfill the bookmarks of this user")
+	all := flag.Bool("all", false, "backfill the bookmarks of every user")
+	pause := flag.Bool("pause", false, "pause the backfills instead of starting them")
+	dryRun := flag.Bool("dry-run", false, "log the bookmarks to backfill without starting anything")
+	flag.Parse()
+	if (*userId == 0) == !*all {
+		log.Fatalf("either -user or -all is required")
+	}
+
+	configs, err := config.LoadEnvConfig()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	logging.Init(configs)
+	defer logging.Sync()
+
+	if err := db.Migrate(configs.PSQL.PgConnectionString()); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+	pool, err := db.Open(configs.PSQL)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	backfillRepo := &models.AIBackfillRepo{Pool: pool}
+	// The bookmarks embedded with another model are backfilled too
+	var genAIClient *genai.Client
+	if configs.Embedding.Provider == llm.ProviderGemini {
+		if genAIClient, err = genai.NewClient(ctx, nil); err != nil {
+			log.Fatalf("failed to create Gemini client: %v", err)
+		}
+	}
+	backfillRepo.Embedder, err = llm.NewEmbedder(configs.Embedding.Provider, configs.Embedding.BaseURL,
+		configs.Embedding.APIKey, configs.Embedding.Model, configs.Embedding.Dimension, genAIClient)
+	if err != nil {
+		log.Fatalf("failed to create embedder: %v", err)
+	}
+
+	var user *types.UserId
+	if !*all {
+		id := types.UserId(*userId)
+		user = &id
+	}
+	var userIds []types.UserId
+	var missing map[types.UserId]int
+	if *pause && !*dryRun {
+		// Only the running backfills can be paused, the users may have nothing left to backfill
+		if user != nil {
+			userIds = []types.UserId{*user}
+		} else if userIds, err = backfillRepo.Running(ctx); err != nil {
+			log.Fatalf("failed to get running backfills: %v", err)
+		}
+	} else {
+		if missing, err = backfillRepo.Missing(ctx, user); err != nil {
+			log.Fatalf("failed to count bookmarks to backfill: %v", err)
+		}
+		for id := range missing {
+			userIds = append(userIds, id)
+		}
+		slices.Sort(userIds)
+	}
+
+	for _, id := range userIds {
+		switch {
+		case *dryRun:
+			logging.Logger.Infow("bookmarks to backfill", "user_id", id, "count", missing[id])
+		case *pause:
+			if err := backfillRepo.Pause(ctx, id); err != nil {
+				logging.Logger.Warnw("backfill not paused", "user_id", id, "error", err)
+				continue
+			}
+			logging.Logger.Infow("backfill paused", "user_id", id)
+		default:
+			backfill, err := backfillRepo.Start(ctx, id)
+			if err != nil {
+				logging.Sync()
+				log.Fatalf("failed to start backfill of user %d: %v", id, err)
+			}
+			logging.Logger.Infow("backfill started", "user_id", id, "count", missing[id], "queued", backfill.Queued)
+		}
+	}
+	logging.Logger.Infow("backfills checked", "users", len(userIds), "dry_run", *dryRun, "pause", *pause)
+}
@@ -0,0 +1,67 @@
+// Command canonicalize canonicalizes the links of the saved bookmarks again after the
+// canonicalization rules changed. Bookmarks of a user that end up with the same link are
+// merged, and the previous links keep resolving to the bookmarks as aliases.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/canonical"
+	"github.com/arashthr/pensive/internal/config"
+	"github.com/arashthr/pensive/internal/db"
+	"github.com/arashthr/pensive/internal/logging"
+	"github.com/arashthr/pensive/internal/models"
+	"github.com/arashthr/pensive/internal/storage"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "log the changes without applying them")
+	flag.Parse()
+
+	configs, err := config.LoadEnvConfig()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	logging.Init(configs)
+	defer logging.Sync()
+
+	canonicalizer, err := canonical.Load(configs.Canonical.RulesFile)
+	if err != nil {
+		log.Fatalf("failed to load canonical rules: %v", err)
+	}
+	if err := db.Migrate(configs.PSQL.PgConnectionString()); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+	pool, err := db.Open(configs.PSQL)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	bookmarkRepo := &models.BookmarkRepo{
+		Pool: pool,
+		SnapshotModel: &models.SnapshotRepo{
+			Pool:    pool,
+			Storage: storage.Local{Dir: configs.Storage.Dir},
+		},
+		Canonicalizer: canonicalizer,
+		FileModel: &models.FileRepo{
+			Pool:    pool,
+			Storage: storage.Local{Dir: configs.Storage.Dir},
+		},
+	}
+	ctx := loggercontext.WithLogger(context.Background(), logging.Logger)
+	stats, err := bookmarkRepo.Recanonicalize(ctx, *dryRun)
+	if stats != nil {
+		logging.Logger.Infow("links canonicalized", "checked", stats.Checked, "updated", stats.Updated,
+			"merged", stats.Merged, "dry_run", *dryRun)
+	}
+	if err != nil {
+		logging.Sync()
+		log.Fatalf("failed to canonicalize links: %v", err)
+	}
+}
@@ -28,12 +28,17 @@ import (
 	"github.com/arashthr/pensive/internal/auth"
 	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
 	"github.com/arashthr/pensive/internal/auth/context/usercontext"
+	"github.com/arashthr/pensive/internal/canonical"
 	"github.com/arashthr/pensive/internal/config"
 	"github.com/arashthr/pensive/internal/db"
+	"github.com/arashthr/pensive/internal/fetcher"
+	"github.com/arashthr/pensive/internal/llm"
 	"github.com/arashthr/pensive/internal/logging"
 	"github.com/arashthr/pensive/internal/models"
+	"github.com/arashthr/pensive/internal/secrets"
 	"github.com/arashthr/pensive/internal/service"
 	"github.com/arashthr/pensive/internal/service/importer"
+	"github.com/arashthr/pensive/internal/storage"
 	"github.com/arashthr/pensive/web"
 	"github.com/arashthr/pensive/web/views"
 	"github.com/go-chi/chi/v5"
@@ -91,12 +96,17 @@ type ServiceContainer struct {
 	ImportJobRepo       *models.ImportJobRepo
 	AuthTokenRepo       *models.AuthTokenService
 	PodcastScheduleRepo *models.PodcastScheduleRepo
+	TagRepo             *models.TagRepo
+	CollectionRepo      *models.CollectionRepo
+	HighlightRepo       *models.HighlightRepo
 
 	// Services
 	EmailService     *service.EmailService
 	UsersService     auth.Users
 	BookmarksService service.Bookmarks
 	HomeService      service.Home
+	HighlightService service.Highlights
+	DuplicateService service.Duplicates
 	ImporterService  service.Importer
 	UserService      service.User
 	ApiService       service.Api
@@ -105,6 +115,10 @@ type ServiceContainer struct {
 	ExtensionService auth.Extension
 	TelegramService  auth.Telegram
 	PodcastService   service.Podcast
+	LinkChecker      service.LinkChecker
+	FetchProfiles    service.FetchProfiles
+	AIJobs           service.AIJobs
+	AIBackfill       service.AIBackfill
 
 	// Import processor
 	ImportProcessor importer.ImportProcessor
@@ -116,6 +130,25 @@ func newServiceContainer(cfg *config.AppConfig, pool *pgxpool.Pool, ctx context.
 	if err != nil {
 		logging.Logger.Errorw("failed to create Gemini client", "error", err)
 	}
+	var languageModel llm.LLM
+	if cfg.LLM.Provider != llm.ProviderGemini || genAIClient != nil {
+		models, err := llm.ParseModels(cfg.LLM.Model, cfg.LLM.TaskModels)
+		if err != nil {
+			return nil, fmt.Errorf("parse llm models: %w", err)
+		}
+		languageModel, err = llm.New(cfg.LLM.Provider, cfg.LLM.BaseURL, cfg.LLM.APIKey, models, genAIClient)
+		if err != nil {
+			return nil, fmt.Errorf("create language model: %w", err)
+		}
+	}
+	var embedder llm.Embedder
+	if cfg.Embedding.Provider != llm.ProviderGemini || genAIClient != nil {
+		embedder, err = llm.NewEmbedder(cfg.Embedding.Provider, cfg.Embedding.BaseURL, cfg.Embedding.APIKey,
+			cfg.Embedding.Model, cfg.Embedding.Dimension, genAIClient)
+		if err != nil {
+			return nil, fmt.Errorf("create embedder: %w", err)
+		}
+	}
 
 	// Repositories
 	userRepo := &models.UserRepo{
@@ -132,9 +165,47 @@ func newServiceContainer(cfg *config.AppConfig, pool *pgxpool.Pool, ctx context.
 		Pool: pool,
 	}
 	stripeRepo := models.NewStripeRepo(cfg.Stripe.Key, pool)
+	snapshotRepo := &models.SnapshotRepo{
+		Pool:    pool,
+		Storage: storage.Local{Dir: cfg.Storage.Dir},
+	}
+	fileRepo := &models.FileRepo{
+		Pool:    pool,
+		Storage: storage.Local{Dir: cfg.Storage.Dir},
+	}
+	canonicalizer, err := canonical.Load(cfg.Canonical.RulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("load canonical rules: %w", err)
+	}
+	fetchers, err := fetcher.ParseChain(cfg.Fetch.Backends, cfg.Fetch.DomainBackends, cfg.Fetch.ScrapperURL, cfg.Fetch.HeadlessURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse fetch backends: %w", err)
+	}
+	fetchProfileRepo := &models.FetchProfileRepo{
+		Pool: pool,
+	}
+	if cfg.Fetch.ProfileKey != "" {
+		if fetchProfileRepo.Cipher, err = secrets.NewCipher(cfg.Fetch.ProfileKey); err != nil {
+			return nil, fmt.Errorf("load fetch profile key: %w", err)
+		}
+	}
+	aiJobRepo := &models.AIJobRepo{
+		Pool: pool,
+	}
+	aiBackfillRepo := &models.AIBackfillRepo{
+		Pool:     pool,
+		Embedder: embedder,
+	}
 	bookmarkRepo := &models.BookmarkRepo{
-		Pool:        pool,
-		GenAIClient: genAIClient,
+		Pool:          pool,
+		LLM:           languageModel,
+		Embedder:      embedder,
+		SnapshotModel: snapshotRepo,
+		Canonicalizer: canonicalizer,
+		FileModel:     fileRepo,
+		Fetchers:      fetchers,
+		FetchProfiles: fetchProfileRepo,
+		AIJobs:        aiJobRepo,
 	}
 	telegramRepo := &models.TelegramRepo{
 		Pool: pool,
@@ -146,6 +217,19 @@ func newServiceContainer(cfg *config.AppConfig, pool *pgxpool.Pool, ctx context.
 	podcastScheduleRepo := &models.PodcastScheduleRepo{
 		Pool: pool,
 	}
+	tagRepo := &models.TagRepo{
+		Pool: pool,
+	}
+	collectionRepo := &models.CollectionRepo{
+		Pool: pool,
+	}
+	highlightRepo := &models.HighlightRepo{
+		Pool: pool,
+	}
+	linkCheckRepo := &models.LinkCheckRepo{
+		Pool:       pool,
+		ArchiveURL: cfg.LinkCheck.ArchiveURL,
+	}
 
 	// Services
 	emailService := service.NewEmailService(cfg.SMTP)
@@ -161,6 +245,12 @@ func newServiceContainer(cfg *config.AppConfig, pool *pgxpool.Pool, ctx context.
 		TokenModel:           tokenRepo,
 		TelegramModel:        telegramRepo,
 		PodcastScheduleRepo:  podcastScheduleRepo,
+		SnapshotModel:        snapshotRepo,
+		FileModel:            fileRepo,
+		FetchProfileModel:    fetchProfileRepo,
+	}
+	if bookmarkRepo.AIAvailable() {
+		usersService.AIBackfillModel = aiBackfillRepo
 	}
 
 	// Initialize user service templates
@@ -174,33 +264,57 @@ func newServiceContainer(cfg *config.AppConfig, pool *pgxpool.Pool, ctx context.
 	usersService.Templates.Token = views.Must(views.ParseTemplate("user/token.gohtml"))
 	usersService.Templates.ProfileTab = views.Must(views.ParseTemplate("user/profile-tab.gohtml"))
 	usersService.Templates.TokensTab = views.Must(views.ParseTemplate("user/tokens-tab.gohtml"))
-	usersService.Templates.ImportExportTab = views.Must(views.ParseTemplate("user/import-export-tab.gohtml"))
+	usersService.Templates.ImportExportTab = views.Must(views.ParseTemplate("user/import-export-tab.gohtml", "user/enrich-library.gohtml"))
+	usersService.Templates.EnrichLibrary = views.Must(views.ParseTemplate("user/enrich-library.gohtml"))
 	usersService.Templates.DataManagementTab = views.Must(views.ParseTemplate("user/data-management-tab.gohtml"))
-	usersService.Templates.PreferencesTab = views.Must(views.ParseTemplate("user/preferences-tab.gohtml"))
+	usersService.Templates.PreferencesTab = views.Must(views.ParseTemplate("user/preferences-tab.gohtml", "user/fetch-profiles.gohtml"))
+	usersService.Templates.FetchProfiles = views.Must(views.ParseTemplate("user/fetch-profiles.gohtml"))
 	usersService.Templates.Subscribe = views.Must(views.ParseTemplate("user/subscribe.gohtml", "tailwind.gohtml"))
 	usersService.Templates.PasswordlessNew = views.Must(views.ParseTemplate("passwordless-signup.gohtml", "tailwind.gohtml"))
 	usersService.Templates.PasswordlessSignIn = views.Must(views.ParseTemplate("passwordless-signin.gohtml", "tailwind.gohtml"))
 	usersService.Templates.PasswordlessCheckEmail = views.Must(views.ParseTemplate("passwordless-check-email.gohtml", "tailwind.gohtml"))
 
 	bookmarksService := service.Bookmarks{
-		BookmarkModel: bookmarkRepo,
+		BookmarkModel:   bookmarkRepo,
+		TagModel:        tagRepo,
+		CollectionModel: collectionRepo,
+		HighlightModel:  highlightRepo,
+		SnapshotModel:   snapshotRepo,
+		FileModel:       fileRepo,
+		LinkCheckModel:  linkCheckRepo,
+		AIJobModel:      aiJobRepo,
 	}
 	bookmarksService.Templates.New = views.Must(views.ParseTemplate("bookmarks/new.gohtml", "tailwind.gohtml"))
 	bookmarksService.Templates.Edit = views.Must(views.ParseTemplate("bookmarks/edit.gohtml", "tailwind.gohtml", "bookmarks/markdown.gohtml"))
 	bookmarksService.Templates.Markdown = views.Must(views.ParseTemplate("bookmarks/markdown.gohtml", "tailwind.gohtml"))
 	bookmarksService.Templates.MarkdownNotAvailable = views.Must(views.ParseTemplate("bookmarks/markdown-not-available.gohtml", "tailwind.gohtml"))
+	bookmarksService.Templates.Versions = views.Must(views.ParseTemplate("bookmarks/versions.gohtml", "tailwind.gohtml"))
 
 	homeService := service.Home{
-		BookmarkModel: bookmarkRepo,
+		BookmarkModel:   bookmarkRepo,
+		TagModel:        tagRepo,
+		CollectionModel: collectionRepo,
 	}
 	homeService.Templates.Home = views.Must(views.ParseTemplate("home/home.gohtml", "tailwind.gohtml", "home/recent-results.gohtml"))
 	homeService.Templates.SearchResults = views.Must(views.ParseTemplate("home/search-results.gohtml", "tailwind.gohtml"))
 	homeService.Templates.RecentResults = views.Must(views.ParseTemplate("home/recent-results.gohtml", "tailwind.gohtml"))
 	homeService.Templates.ChatAnswer = views.Must(views.ParseTemplate("home/chat-answer.gohtml"))
 
+	highlightService := service.Highlights{
+		HighlightModel: highlightRepo,
+	}
+	highlightService.Templates.Index = views.Must(views.ParseTemplate("highlights/index.gohtml", "tailwind.gohtml"))
+
+	duplicateService := service.Duplicates{
+		BookmarkModel: bookmarkRepo,
+	}
+	duplicateService.Templates.Index = views.Must(views.ParseTemplate("duplicates/index.gohtml", "tailwind.gohtml"))
+
 	importerService := service.Importer{
 		ImportJobModel: importJobRepo,
 		BookmarkModel:  bookmarkRepo,
+		HighlightModel: highlightRepo,
+		SnapshotModel:  snapshotRepo,
 	}
 	importerService.Templates.PocketImport = views.Must(views.ParseTemplate("user/pocket-import.gohtml", "tailwind.gohtml"))
 	importerService.Templates.ImportProcessing = views.Must(views.ParseTemplate("user/import-processing.gohtml", "tailwind.gohtml"))
@@ -214,7 +328,12 @@ func newServiceContainer(cfg *config.AppConfig, pool *pgxpool.Pool, ctx context.
 	}
 
 	apiService := service.Api{
-		BookmarkModel: bookmarkRepo,
+		BookmarkModel:   bookmarkRepo,
+		TagModel:        tagRepo,
+		CollectionModel: collectionRepo,
+		HighlightModel:  highlightRepo,
+		LinkCheckModel:  linkCheckRepo,
+		AIJobModel:      aiJobRepo,
 	}
 
 	tokenService := service.Token{
@@ -247,7 +366,7 @@ func newServiceContainer(cfg *config.AppConfig, pool *pgxpool.Pool, ctx context.
 		PodcastScheduleRepo: podcastScheduleRepo,
 		UserRepo:            userRepo,
 		EmailService:        emailService,
-		GenAIClient:         genAIClient,
+		LLM:                 languageModel,
 		GCPProjectID:        cfg.Podcast.GCPProjectID,
 		ServiceAccountPath:  cfg.Podcast.ServiceAccountPath,
 		TelegramToken:       cfg.Telegram.Token,
@@ -273,12 +392,17 @@ func newServiceContainer(cfg *config.AppConfig, pool *pgxpool.Pool, ctx context.
 		ImportJobRepo:       importJobRepo,
 		AuthTokenRepo:       authTokenRepo,
 		PodcastScheduleRepo: podcastScheduleRepo,
+		TagRepo:             tagRepo,
+		CollectionRepo:      collectionRepo,
+		HighlightRepo:       highlightRepo,
 
 		// Services
 		EmailService:     emailService,
 		UsersService:     usersService,
 		BookmarksService: bookmarksService,
 		HomeService:      homeService,
+		HighlightService: highlightService,
+		DuplicateService: duplicateService,
 		ImporterService:  importerService,
 		UserService:      userService,
 		ApiService:       apiService,
@@ -287,6 +411,10 @@ func newServiceContainer(cfg *config.AppConfig, pool *pgxpool.Pool, ctx context.
 		ExtensionService: extensionService,
 		TelegramService:  telegramService,
 		PodcastService:   podcastService,
+		LinkChecker:      service.LinkChecker{LinkCheckModel: linkCheckRepo},
+		FetchProfiles:    service.FetchProfiles{FetchProfileModel: fetchProfileRepo},
+		AIJobs:           service.AIJobs{AIJobModel: aiJobRepo, BookmarkModel: bookmarkRepo},
+		AIBackfill:       service.AIBackfill{AIBackfillModel: aiBackfillRepo, UserModel: userRepo},
 
 		// Import processor
 		ImportProcessor: importProcessor,
@@ -310,6 +438,23 @@ func run(cfg *config.AppConfig, pool *pgxpool.Pool) error {
 	go container.PodcastService.StartScheduler(ctx)
 	go container.PodcastService.StartDailyScheduler(ctx)
 
+	// Start AI processing of the bookmarks in background
+	if container.BookmarkRepo.AIAvailable() {
+		go container.AIJobs.Start(ctx)
+		go container.AIBackfill.Start(ctx)
+	}
+	go func() {
+		indexCtx := loggercontext.WithLogger(ctx, logging.Logger.With("flow", "embedding-index"))
+		if err := container.BookmarkRepo.EnsureEmbeddingIndex(indexCtx); err != nil {
+			logging.Logger.Errorw("failed to create embedding index", "error", err)
+		}
+	}()
+
+	// Start dead-link checker in background
+	if cfg.LinkCheck.Enabled {
+		go container.LinkChecker.Start(ctx)
+	}
+
 	// Create routes with the service container
 	r := Routes(cfg, container)
 
@@ -344,6 +489,9 @@ func Routes(cfg *config.AppConfig, c *ServiceContainer) *chi.Mux {
 		r.Use(LoggerMiddleware(cfg.Environment == "production", "internal"))
 		r.Use(adminMw.AuthAdmin)
 		r.Post("/podcast/trigger", c.PodcastService.TriggerEpisode)
+		r.Get("/fetch-profiles", c.FetchProfiles.List)
+		r.Post("/fetch-profiles", c.FetchProfiles.Save)
+		r.Delete("/fetch-profiles/{id}", c.FetchProfiles.Delete)
 	})
 
 	// API Routes
@@ -373,13 +521,28 @@ func Routes(cfg *config.AppConfig, c *ServiceContainer) *chi.Mux {
 			r.Route("/bookmarks", func(r chi.Router) {
 				r.Get("/", c.ApiService.IndexAPI)
 				r.Post("/", c.ApiService.CreateAPI)
+				r.Post("/upload", c.ApiService.UploadAPI)
 				r.Delete("/", c.ApiService.DeleteByLinkAPI)
 				r.Get("/check", c.ApiService.CheckBookmarkByLinkAPI)
 				r.Get("/{id}", c.ApiService.GetAPI)
 				r.Put("/{id}", c.ApiService.UpdateAPI)
 				r.Delete("/{id}", c.ApiService.DeleteAPI)
+				r.Get("/{id}/highlights", c.ApiService.BookmarkHighlightsAPI)
+				r.Post("/{id}/highlights", c.ApiService.CreateBookmarkHighlightAPI)
+				r.Post("/{id}/refresh", c.ApiService.RefreshAPI)
+				r.Post("/{id}/ai/retry", c.ApiService.RetryAIProcessingAPI)
+				r.Get("/{id}/versions", c.ApiService.VersionsAPI)
 				r.Get("/search", c.ApiService.SearchAPI)
 			})
+			r.Post("/documents", c.ApiService.UploadAPI)
+			r.Route("/highlights", func(r chi.Router) {
+				r.Get("/", c.ApiService.HighlightsAPI)
+				r.Post("/", c.ApiService.CreateHighlightAPI)
+				r.Put("/{highlightId}", c.ApiService.UpdateHighlightAPI)
+				r.Delete("/{highlightId}", c.ApiService.DeleteHighlightAPI)
+			})
+			r.Get("/tags", c.ApiService.TagsAPI)
+			r.Get("/collections", c.ApiService.CollectionsAPI)
 		})
 	})
 
@@ -448,6 +611,16 @@ func Routes(cfg *config.AppConfig, c *ServiceContainer) *chi.Mux {
 			r.Get("/search", c.HomeService.Search)
 			r.Post("/ask", c.HomeService.AskQuestion)
 		})
+		r.Route("/highlights", func(r chi.Router) {
+			r.Use(umw.RequireUser)
+			r.Get("/", c.HighlightService.Index)
+		})
+		r.Route("/duplicates", func(r chi.Router) {
+			r.Use(umw.RequireUser)
+			r.Get("/", c.DuplicateService.Index)
+			r.Post("/merge", c.DuplicateService.Merge)
+			r.Post("/dismiss", c.DuplicateService.Dismiss)
+		})
 		r.Route("/users", func(r chi.Router) {
 			r.Post("/", c.UsersService.Create)
 			// Auth
@@ -458,6 +631,9 @@ func Routes(cfg *config.AppConfig, c *ServiceContainer) *chi.Mux {
 				r.Get("/me", c.UsersService.CurrentUser)
 				r.Get("/tab-content", c.UsersService.TabContent)
 				r.Post("/preferences", c.UsersService.SavePreferences)
+				r.Post("/preferences/snapshots", c.UsersService.SaveSnapshotPreferences)
+				r.Post("/preferences/fetch-profiles", c.UsersService.SaveFetchProfile)
+				r.Post("/preferences/fetch-profiles/delete", c.UsersService.DeleteFetchProfile)
 				r.Post("/delete-token", c.UsersService.DeleteToken)
 				r.Post("/delete-content", c.UsersService.DeleteAllContent)
 				r.Post("/delete-account", c.UsersService.DeleteAccount)
@@ -468,6 +644,8 @@ func Routes(cfg *config.AppConfig, c *ServiceContainer) *chi.Mux {
 				r.Get("/pocket-import", c.ImporterService.PocketImport)
 				r.Post("/pocket-import", c.ImporterService.ProcessImport)
 				r.Post("/export", c.ImporterService.ProcessExport)
+				r.Post("/enrich-library", c.UsersService.StartEnrichLibrary)
+				r.Post("/enrich-library/pause", c.UsersService.PauseEnrichLibrary)
 				r.Get("/import-status", c.ImporterService.ImportStatus)
 			})
 
@@ -508,6 +686,7 @@ func Routes(cfg *config.AppConfig, c *ServiceContainer) *chi.Mux {
 				// For routes that are accessible by user
 				r.Use(umw.RequireUser)
 				r.Post("/", c.BookmarksService.Create)
+				r.Post("/upload", c.BookmarksService.Upload)
 				r.Get("/new", c.BookmarksService.New)
 
 				// TODO: Remove when new addon version is released
@@ -520,6 +699,21 @@ func Routes(cfg *config.AppConfig, c *ServiceContainer) *chi.Mux {
 				r.Get("/{id}/markdown", c.BookmarksService.GetBookmarkMarkdown)
 				r.Get("/{id}/markdown-content", c.BookmarksService.GetBookmarkMarkdownHTMX)
 				r.Post("/{id}/report", c.BookmarksService.ReportBookmark)
+				r.Post("/{id}/state", c.BookmarksService.UpdateState)
+				r.Post("/{id}/note", c.BookmarksService.UpdateNote)
+				r.Post("/{id}/tags", c.BookmarksService.AddTags)
+				r.Post("/{id}/tags/delete", c.BookmarksService.RemoveTag)
+				r.Post("/{id}/collections", c.BookmarksService.AddToCollection)
+				r.Post("/{id}/collections/delete", c.BookmarksService.RemoveFromCollection)
+				r.Post("/{id}/highlights", c.BookmarksService.AddHighlight)
+				r.Post("/{id}/highlights/{highlightId}", c.BookmarksService.UpdateHighlight)
+				r.Post("/{id}/highlights/{highlightId}/delete", c.BookmarksService.DeleteHighlight)
+				r.Get("/{id}/snapshot", c.BookmarksService.ViewSnapshot)
+				r.Post("/{id}/snapshot", c.BookmarksService.CaptureSnapshot)
+				r.Get("/{id}/file", c.BookmarksService.DownloadFile)
+				r.Post("/{id}/refresh", c.BookmarksService.RefreshContent)
+				r.Post("/{id}/ai/retry", c.BookmarksService.RetryAIProcessing)
+				r.Get("/{id}/versions", c.BookmarksService.Versions)
 			})
 		})
 
@@ -288,17 +288,22 @@ document.addEventListener('DOMContentLoaded', async () => {
           bookmarkId = responseData.Id;
         }
         const successMessage = pageContent.htmlContent ? 'Page saved with content!' : 'Page saved successfully!';
-        updateStatus('saved', successMessage);
         // Switch to saved state UI
         document.getElementById('actions').style.display = 'none';
         actionsSaved.style.display = 'flex';
 
-        // Auto-hide success message after 2 seconds
-        setTimeout(() => {
-          if (isBookmarked) {
-            updateStatus('saved', 'Saved to your library');
-          }
-        }, 2000);
+        if (responseData.PossibleDuplicate) {
+          // Keep the warning visible, the user may want to merge the bookmarks
+          updateStatus('saved', `Saved. ${responseData.PossibleDuplicate.Message}`);
+        } else {
+          updateStatus('saved', successMessage);
+          // Auto-hide success message after 2 seconds
+          setTimeout(() => {
+            if (isBookmarked) {
+              updateStatus('saved', 'Saved to your library');
+            }
+          }, 2000);
+        }
       } else {
         const contentType = response.headers.get('Content-Type');
         let errorMessage = 'Failed to save page';
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
 	"net/url"
@@ -33,6 +34,10 @@ type BookmarkResponse struct {
 	Excerpt       string     `json:"excerpt"`
 	CreatedAt     time.Time  `json:"createdAt"`
 	PublishedTime *time.Time `json:"publishedTime,omitempty"`
+	// Set when the same content was already saved from another link
+	PossibleDuplicate *struct {
+		Message string `json:"message"`
+	} `json:"possibleDuplicate,omitempty"`
 }
 
 type SearchResult struct {
@@ -46,6 +51,7 @@ type SearchResult struct {
 
 type SearchResponse struct {
 	Bookmarks []SearchResult `json:"bookmarks"`
+	Mode      string         `json:"mode"` // keyword when the search fell back to it
 }
 
 func StartBot(telegramToken string, endpoint string, pool *pgxpool.Pool) {
@@ -63,6 +69,15 @@ func StartBot(telegramToken string, endpoint string, pool *pgxpool.Pool) {
 	}
 
 	b.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypePrefix, startHandler)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/tags", bot.MatchTypePrefix, listTagsHandler)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/tag", bot.MatchTypePrefix, tagHandler)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/untag", bot.MatchTypePrefix, tagHandler)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/collection", bot.MatchTypePrefix, collectionHandler)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/keyword", bot.MatchTypePrefix, searchModeHandler)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/semantic", bot.MatchTypePrefix, searchModeHandler)
+	for _, command := range []string{"/unread", "/reading", "/archived", "/starred"} {
+		b.RegisterHandler(bot.HandlerTypeMessageText, command, bot.MatchTypeExact, readingListHandler)
+	}
 
 	b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypePrefix, handleMessage)
 	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "", bot.MatchTypePrefix, handleCallbackQuery)
@@ -77,7 +92,7 @@ func startHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if userAPITokens[chatId] != "" {
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "✅ Your account is already connected!\n\nYou can now:\n• Send links to save them instantly\n• Search your bookmarks by typing keywords\n• Get AI summaries of saved content",
+			Text:   "✅ Your account is already connected!\n\nYou can now:\n• Send links to save them instantly\n• Search your bookmarks by typing keywords\n• Get AI summaries of saved content\n• See your reading list with /unread, /reading, /archived and /starred\n• Browse tags with /tags\n• Search by words only with /keyword or by meaning with /semantic",
 		})
 		return
 	}
@@ -187,10 +202,30 @@ func handleMessage(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if link != "" {
 		saveBookmark(ctx, b, update.Message.Chat.ID, link)
 	} else {
-		searchBookmarks(ctx, b, update.Message.Chat.ID, msg)
+		searchBookmarks(ctx, b, update.Message.Chat.ID, msg, "hybrid")
 	}
 }
 
+// searchModeHandler handles `/keyword <query>` and `/semantic <query>`, the searches by words
+// only and by meaning only. Other messages search both.
+func searchModeHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !isUserAuthenticated(update.Message.From.ID) {
+		handleMessage(ctx, b, update)
+		return
+	}
+
+	command, query, _ := strings.Cut(update.Message.Text, " ")
+	if query = strings.TrimSpace(query); query == "" {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    update.Message.Chat.ID,
+			Text:      fmt.Sprintf("🔍 <b>Usage</b>\n\n<code>%s your search</code>", command),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+	searchBookmarks(ctx, b, update.Message.Chat.ID, query, strings.TrimPrefix(command, "/"))
+}
+
 func saveBookmark(ctx context.Context, b *bot.Bot, chatID int64, link string) {
 	reqBody, _ := json.Marshal(map[string]string{"link": link})
 	req, err := http.NewRequest("POST", apiEndpoint+"/api/v1/bookmarks", bytes.NewBuffer(reqBody))
@@ -238,15 +273,24 @@ func saveBookmark(ctx context.Context, b *bot.Bot, chatID int64, link string) {
 	}
 	logging.Logger.Infow("Saved bookmark", "id", bookmark.Id, "title", bookmark.Title)
 
+	text := fmt.Sprintf("✅ <b>Saved successfully!</b>\n\n<a href=\"%s\">%s</a>", bookmark.Link, bookmark.Title)
+	if bookmark.PossibleDuplicate != nil {
+		text += "\n\n⚠️ " + html.EscapeString(bookmark.PossibleDuplicate.Message)
+	}
 	b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    chatID,
-		Text:      fmt.Sprintf("✅ <b>Saved successfully!</b>\n\n<a href=\"%s\">%s</a>", bookmark.Link, bookmark.Title),
+		Text:      text,
 		ParseMode: models.ParseModeHTML,
 		ReplyMarkup: &models.InlineKeyboardMarkup{
 			InlineKeyboard: [][]models.InlineKeyboardButton{
 				{
 					{Text: "🗑 Delete", CallbackData: "delete|" + bookmark.Id},
 					{Text: "📄 Summary", CallbackData: "summary|" + bookmark.Id},
+					{Text: "🏷 Tags", CallbackData: "tags|" + bookmark.Id},
+				},
+				{
+					{Text: "📦 Archive", CallbackData: "archive|" + bookmark.Id},
+					{Text: "⭐ Star", CallbackData: "star|" + bookmark.Id},
 				},
 			},
 		},
@@ -256,8 +300,11 @@ func saveBookmark(ctx context.Context, b *bot.Bot, chatID int64, link string) {
 	})
 }
 
-func searchBookmarks(ctx context.Context, b *bot.Bot, chatID int64, query string) {
-	req, err := http.NewRequest("GET", apiEndpoint+"/api/v1/bookmarks/search?query="+urlQueryEscape(query), nil)
+// searchSyntaxHelp is the search syntax shared with the web and the API
+const searchSyntaxHelp = `Search words and <code>"exact phrases"</code>, exclude with <code>-word</code>, and filter with <code>site:example.com</code>, <code>tag:go</code>, <code>source:web</code>, <code>lang:en</code>, <code>before:2024-03</code>, <code>after:2024-01-15</code> and <code>is:unread</code>.`
+
+func searchBookmarks(ctx context.Context, b *bot.Bot, chatID int64, query, mode string) {
+	req, err := http.NewRequest("GET", apiEndpoint+"/api/v1/bookmarks/search?query="+urlQueryEscape(query)+"&mode="+mode, nil)
 	if err != nil {
 		logging.Logger.Errorw("failed to create search request", "error", err, "query", query, "chatID", chatID)
 		return
@@ -276,6 +323,18 @@ func searchBookmarks(ctx context.Context, b *bot.Bot, chatID int64, query string
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusBadRequest {
+		var errResp struct {
+			Message string `json:"errorMessage"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      "🔍 <b>Invalid search</b>\n\n" + html.EscapeString(errResp.Message) + "\n\n" + searchSyntaxHelp,
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
 	if resp.StatusCode != http.StatusOK {
 		logging.Logger.Errorw("failed to search bookmarks", "status", resp.Status, "query", query, "chatID", chatID)
 		b.SendMessage(ctx, &bot.SendMessageParams{
@@ -295,7 +354,7 @@ func searchBookmarks(ctx context.Context, b *bot.Bot, chatID int64, query string
 	if len(result.Bookmarks) == 0 {
 		b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:    chatID,
-			Text:      fmt.Sprintf("🔍 <b>No results found</b>\n\nNo bookmarks match <i>\"%s\"</i>\n\nTry:\n• Different keywords\n• Broader search terms\n• Check spelling", query),
+			Text:      fmt.Sprintf("🔍 <b>No results found</b>\n\nNo bookmarks match <i>\"%s\"</i>\n\nTry:\n• Different keywords\n• Broader search terms\n• Check spelling", html.EscapeString(query)),
 			ParseMode: models.ParseModeHTML,
 		})
 		return
@@ -303,6 +362,9 @@ func searchBookmarks(ctx context.Context, b *bot.Bot, chatID int64, query string
 
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("🔍 <b>Search Results</b> (%d found)\n\n", len(result.Bookmarks)))
+	if result.Mode != "" && result.Mode != mode {
+		sb.WriteString("<i>Searching by meaning is unavailable, these results match your words only.</i>\n\n")
+	}
 
 	for i, r := range result.Bookmarks {
 		if i >= 10 { // Limit to 10 results to avoid long messages
@@ -346,8 +408,9 @@ func handleCallbackQuery(ctx context.Context, b *bot.Bot, update *models.Update)
 	}
 
 	data := update.CallbackQuery.Data
-	parts := strings.Split(data, "|")
-	if len(parts) != 2 {
+	// Callback data is `action|bookmarkID` with an optional argument, e.g. `addtag|id|tag`
+	parts := strings.SplitN(data, "|", 3)
+	if len(parts) < 2 {
 		logging.Logger.Errorw("invalid callback data", "data", data)
 		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -363,6 +426,16 @@ func handleCallbackQuery(ctx context.Context, b *bot.Bot, update *models.Update)
 		deleteBookmark(ctx, b, update, bookmarkID)
 	case "summary":
 		getSummary(ctx, b, update, bookmarkID)
+	case "archive":
+		updateState(ctx, b, update, bookmarkID, map[string]any{"readingStatus": "archived"}, "📦 Archived")
+	case "star":
+		updateState(ctx, b, update, bookmarkID, map[string]any{"starred": true}, "⭐ Starred")
+	case "tags":
+		showTags(ctx, b, update, bookmarkID)
+	case "addtag":
+		if len(parts) == 3 {
+			acceptSuggestedTag(ctx, b, update, bookmarkID, parts[2])
+		}
 	}
 }
 
@@ -0,0 +1,47 @@
+package telegram
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/arashthr/pensive/internal/logging"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// updateState changes the reading status or the star of a bookmark, e.g. {"readingStatus": "archived"}
+func updateState(ctx context.Context, b *bot.Bot, update *models.Update, bookmarkID string, body map[string]any, done string) {
+	userId := update.CallbackQuery.From.ID
+	logging.Logger.Debugw("Updating bookmark state", "id", bookmarkID, "user_id", userId, "state", body)
+	err := callAPI(userId, http.MethodPut, "/api/v1/bookmarks/"+url.PathEscape(bookmarkID), body, nil)
+	if err != nil {
+		logging.Logger.Errorw("failed to update bookmark state", "error", err, "ID", bookmarkID)
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Failed to update bookmark",
+			ShowAlert:       true,
+		})
+		return
+	}
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            done,
+	})
+}
+
+// readingListHandler handles `/unread`, `/reading`, `/archived` and `/starred`
+func readingListHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	userId := update.Message.From.ID
+	if !isUserAuthenticated(userId) {
+		handleMessage(ctx, b, update)
+		return
+	}
+
+	command := update.Message.Text[1:]
+	query := url.Values{"status": {command}}
+	if command == "starred" {
+		query = url.Values{"starred": {"true"}}
+	}
+	listBookmarks(ctx, b, userId, update.Message.Chat.ID, query, command)
+}
@@ -0,0 +1,386 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/arashthr/pensive/internal/logging"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Telegram limits the callback data of inline buttons to 64 bytes
+const maxCallbackDataLength = 64
+
+type BookmarkDetailsResponse struct {
+	BookmarkResponse
+	Tags          []string `json:"tags"`
+	Collections   []string `json:"collections"`
+	SuggestedTags []string `json:"suggestedTags"`
+}
+
+type TagsResponse struct {
+	Tags []struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	} `json:"tags"`
+}
+
+type BookmarksResponse struct {
+	Bookmarks []BookmarkResponse `json:"bookmarks"`
+}
+
+// callAPI sends a request to the Pensive API on behalf of the chat user and decodes the response into out
+func callAPI(userId int64, method, path string, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request body: %w", err)
+		}
+		reqBody = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequest(method, apiEndpoint+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+userAPITokens[userId])
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Message string `json:"errorMessage"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		if errResp.Message != "" {
+			return fmt.Errorf("%s", errResp.Message)
+		}
+		return fmt.Errorf("server error: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func getBookmarkDetails(userId int64, bookmarkID string) (*BookmarkDetailsResponse, error) {
+	var details BookmarkDetailsResponse
+	err := callAPI(userId, http.MethodGet, "/api/v1/bookmarks/"+url.PathEscape(bookmarkID), nil, &details)
+	if err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// addTags adds the tags to the bookmark while keeping the existing ones
+func addTags(userId int64, bookmarkID string, tags []string) (*BookmarkDetailsResponse, error) {
+	details, err := getBookmarkDetails(userId, bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string][]string{"tags": append(details.Tags, tags...)}
+	var updated BookmarkDetailsResponse
+	err = callAPI(userId, http.MethodPut, "/api/v1/bookmarks/"+url.PathEscape(bookmarkID), body, &updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func removeTag(userId int64, bookmarkID string, tag string) (*BookmarkDetailsResponse, error) {
+	details, err := getBookmarkDetails(userId, bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(details.Tags))
+	for _, t := range details.Tags {
+		if !strings.EqualFold(t, strings.TrimSpace(tag)) {
+			tags = append(tags, t)
+		}
+	}
+	var updated BookmarkDetailsResponse
+	err = callAPI(userId, http.MethodPut, "/api/v1/bookmarks/"+url.PathEscape(bookmarkID), map[string][]string{"tags": tags}, &updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// tagsMessage renders the tags of a bookmark with buttons to accept the AI suggestions
+func tagsMessage(details *BookmarkDetailsResponse) (string, *models.InlineKeyboardMarkup) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🏷 <b>Tags</b> for <a href=\"%s\">%s</a>\n\n", details.Link, html.EscapeString(details.Title)))
+	if len(details.Tags) == 0 {
+		sb.WriteString("<i>No tags yet.</i>\n")
+	} else {
+		for _, tag := range details.Tags {
+			sb.WriteString("#" + html.EscapeString(tag) + " ")
+		}
+		sb.WriteString("\n")
+	}
+	if len(details.Collections) > 0 {
+		sb.WriteString("\n📁 " + html.EscapeString(strings.Join(details.Collections, ", ")) + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("\nAdd your own with <code>/tag %s name, other</code>", details.Id))
+
+	var rows [][]models.InlineKeyboardButton
+	var row []models.InlineKeyboardButton
+	for _, tag := range details.SuggestedTags {
+		data := "addtag|" + details.Id + "|" + tag
+		if len(data) > maxCallbackDataLength {
+			continue
+		}
+		row = append(row, models.InlineKeyboardButton{Text: "+ " + tag, CallbackData: data})
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return sb.String(), nil
+	}
+	return sb.String(), &models.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func showTags(ctx context.Context, b *bot.Bot, update *models.Update, bookmarkID string) {
+	userId := update.CallbackQuery.From.ID
+	details, err := getBookmarkDetails(userId, bookmarkID)
+	if err != nil {
+		logging.Logger.Errorw("failed to get bookmark tags", "error", err, "ID", bookmarkID)
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Failed to get tags",
+			ShowAlert:       true,
+		})
+		return
+	}
+
+	text, markup := tagsMessage(details)
+	params := &bot.SendMessageParams{
+		ChatID:    update.CallbackQuery.Message.Message.Chat.ID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		LinkPreviewOptions: &models.LinkPreviewOptions{
+			IsDisabled: bot.True(),
+		},
+	}
+	if markup != nil {
+		params.ReplyMarkup = markup
+	}
+	b.SendMessage(ctx, params)
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+}
+
+func acceptSuggestedTag(ctx context.Context, b *bot.Bot, update *models.Update, bookmarkID string, tag string) {
+	userId := update.CallbackQuery.From.ID
+	details, err := addTags(userId, bookmarkID, []string{tag})
+	if err != nil {
+		logging.Logger.Errorw("failed to add suggested tag", "error", err, "ID", bookmarkID, "tag", tag)
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Failed to add tag",
+			ShowAlert:       true,
+		})
+		return
+	}
+
+	text, markup := tagsMessage(details)
+	params := &bot.EditMessageTextParams{
+		ChatID:    update.CallbackQuery.Message.Message.Chat.ID,
+		MessageID: update.CallbackQuery.Message.Message.ID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		LinkPreviewOptions: &models.LinkPreviewOptions{
+			IsDisabled: bot.True(),
+		},
+	}
+	if markup != nil {
+		params.ReplyMarkup = markup
+	}
+	b.EditMessageText(ctx, params)
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            "✅ Tagged " + tag,
+	})
+}
+
+// tagHandler handles `/tag <bookmark id> name, other` and `/untag <bookmark id> name`
+func tagHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	userId := update.Message.From.ID
+	if !isUserAuthenticated(userId) {
+		handleMessage(ctx, b, update)
+		return
+	}
+
+	command, args, _ := strings.Cut(update.Message.Text, " ")
+	bookmarkID, tagList, _ := strings.Cut(strings.TrimSpace(args), " ")
+	if bookmarkID == "" || strings.TrimSpace(tagList) == "" {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      fmt.Sprintf("🏷 <b>Usage</b>\n\n<code>%s &lt;bookmark id&gt; name, other</code>", command),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	var details *BookmarkDetailsResponse
+	var err error
+	if command == "/untag" {
+		details, err = removeTag(userId, bookmarkID, tagList)
+	} else {
+		details, err = addTags(userId, bookmarkID, strings.Split(tagList, ","))
+	}
+	if err != nil {
+		logging.Logger.Errorw("failed to update tags", "error", err, "ID", bookmarkID, "chatID", chatID)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      "❌ <b>Failed to update tags</b>\n\n" + html.EscapeString(err.Error()),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	text, markup := tagsMessage(details)
+	params := &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		LinkPreviewOptions: &models.LinkPreviewOptions{
+			IsDisabled: bot.True(),
+		},
+	}
+	if markup != nil {
+		params.ReplyMarkup = markup
+	}
+	b.SendMessage(ctx, params)
+}
+
+// listTagsHandler handles `/tags`, and `/tags <name>` to list the bookmarks with a tag
+func listTagsHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	userId := update.Message.From.ID
+	if !isUserAuthenticated(userId) {
+		handleMessage(ctx, b, update)
+		return
+	}
+
+	_, tag, _ := strings.Cut(update.Message.Text, " ")
+	tag = strings.TrimSpace(tag)
+	if tag != "" {
+		listBookmarks(ctx, b, userId, chatID, url.Values{"tag": {tag}}, "tagged #"+tag)
+		return
+	}
+
+	var result TagsResponse
+	if err := callAPI(userId, http.MethodGet, "/api/v1/tags", nil, &result); err != nil {
+		logging.Logger.Errorw("failed to list tags", "error", err, "chatID", chatID)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      "❌ <b>Failed to list tags</b>\n\n" + html.EscapeString(err.Error()),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+	if len(result.Tags) == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      "🏷 <b>No tags yet</b>\n\nTap the Tags button on a saved bookmark to add some.",
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🏷 <b>Your tags</b>\n\n")
+	for _, t := range result.Tags {
+		sb.WriteString(fmt.Sprintf("#%s (%d)\n", html.EscapeString(t.Name), t.Count))
+	}
+	sb.WriteString("\nUse <code>/tags name</code> to see the bookmarks with a tag.")
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      sb.String(),
+		ParseMode: models.ParseModeHTML,
+	})
+}
+
+// collectionHandler handles `/collection <name>` to list the bookmarks of a collection
+func collectionHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	userId := update.Message.From.ID
+	if !isUserAuthenticated(userId) {
+		handleMessage(ctx, b, update)
+		return
+	}
+
+	_, name, _ := strings.Cut(update.Message.Text, " ")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      "📁 <b>Usage</b>\n\n<code>/collection name</code>",
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+	listBookmarks(ctx, b, userId, chatID, url.Values{"collection": {name}}, "in "+name)
+}
+
+// listBookmarks sends the first page of bookmarks matching the query. label describes the filter, e.g. "tagged #go"
+func listBookmarks(ctx context.Context, b *bot.Bot, userId int64, chatID int64, query url.Values, label string) {
+	var result BookmarksResponse
+	if err := callAPI(userId, http.MethodGet, "/api/v1/bookmarks?"+query.Encode(), nil, &result); err != nil {
+		logging.Logger.Errorw("failed to list bookmarks", "error", err, "query", query.Encode(), "chatID", chatID)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      "❌ <b>Failed to list bookmarks</b>\n\n" + html.EscapeString(err.Error()),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+	if len(result.Bookmarks) == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      fmt.Sprintf("🔍 <b>No bookmarks</b> %s", html.EscapeString(label)),
+			ParseMode: models.ParseModeHTML,
+		})
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📚 <b>Bookmarks</b> %s\n\n", html.EscapeString(label)))
+	for i, bookmark := range result.Bookmarks {
+		sb.WriteString(fmt.Sprintf("<b>%d.</b> <a href=\"%s\">%s</a>\n", i+1, bookmark.Link, html.EscapeString(bookmark.Title)))
+	}
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      sb.String(),
+		ParseMode: models.ParseModeHTML,
+		LinkPreviewOptions: &models.LinkPreviewOptions{
+			IsDisabled: bot.True(),
+		},
+	})
+}
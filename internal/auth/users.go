@@ -15,9 +15,11 @@ import (
 	"github.com/arashthr/pensive/internal/auth/context/usercontext"
 	"github.com/arashthr/pensive/internal/config"
 	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/fetcher"
 	"github.com/arashthr/pensive/internal/logging"
 	"github.com/arashthr/pensive/internal/models"
 	"github.com/arashthr/pensive/internal/service"
+	"github.com/arashthr/pensive/internal/types"
 	"github.com/arashthr/pensive/web"
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
@@ -41,6 +43,8 @@ type Users struct {
 		ImportExportTab        web.Template
 		DataManagementTab      web.Template
 		PreferencesTab         web.Template
+		FetchProfiles          web.Template
+		EnrichLibrary          web.Template
 		PasswordlessNew        web.Template
 		PasswordlessSignIn     web.Template
 		PasswordlessCheckEmail web.Template
@@ -53,6 +57,10 @@ type Users struct {
 	TokenModel           *models.TokenRepo
 	TelegramModel        *models.TelegramRepo
 	PodcastScheduleRepo  *models.PodcastScheduleRepo
+	SnapshotModel        *models.SnapshotRepo
+	FileModel            *models.FileRepo
+	FetchProfileModel    *models.FetchProfileRepo
+	AIBackfillModel      *models.AIBackfillRepo // Libraries can't be enriched when nil
 }
 
 func (u Users) New(w http.ResponseWriter, r *http.Request) {
@@ -429,6 +437,9 @@ func (u Users) TabContent(w http.ResponseWriter, r *http.Request) {
 		Tokens         []models.ApiToken
 		Preferences    *models.SummaryPreferences
 		TelegramLinked bool
+		SnapshotFormat types.SnapshotFormat
+		fetchProfilesData
+		enrichLibraryData
 	}
 	data.Email = user.Email
 	data.IsSubscribed = user.IsSubscriptionPremium()
@@ -468,6 +479,24 @@ func (u Users) TabContent(w http.ResponseWriter, r *http.Request) {
 			_, err := u.TelegramModel.GetChatIdByUserId(user.ID)
 			data.TelegramLinked = err == nil
 		}
+
+		data.SnapshotFormat, err = u.SnapshotModel.PreferredFormat(r.Context(), user.ID)
+		if err != nil {
+			logger.Errorw("get snapshot format", "error", err)
+			data.SnapshotFormat = types.SnapshotFormatNone
+		}
+
+		data.FetchProfiles, err = u.FetchProfileModel.List(r.Context(), &user.ID)
+		if err != nil {
+			logger.Errorw("list fetch profiles", "error", err)
+		}
+		data.CookiesEnabled = u.FetchProfileModel.CookiesEnabled()
+	}
+
+	if tab == "import-export" {
+		if err := u.loadEnrichLibrary(r.Context(), user, &data.enrichLibraryData); err != nil {
+			logger.Errorw("get library enrichment", "error", err)
+		}
 	}
 
 	w.Header().Set("Content-Type", "text/html")
@@ -609,6 +638,26 @@ func (u Users) SavePreferences(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// SaveSnapshotPreferences handles POST /users/preferences/snapshots to choose how new bookmarks
+// are captured for offline reading
+func (u Users) SaveSnapshotPreferences(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+
+	format, ok := types.ParseSnapshotFormat(r.FormValue("snapshot_format"))
+	if !ok {
+		http.Error(w, "Invalid snapshot format", http.StatusBadRequest)
+		return
+	}
+	if err := u.SnapshotModel.SetPreferredFormat(user.ID, format); err != nil {
+		logger.Errorw("set snapshot format", "error", err)
+		http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("saved snapshot preferences", "user_id", user.ID, "format", format)
+	w.WriteHeader(http.StatusOK)
+}
+
 type UserMiddleware struct {
 	SessionService *models.SessionRepo
 }
@@ -697,6 +746,18 @@ func (u Users) DeleteAllContent(w http.ResponseWriter, r *http.Request) {
 
 	logger.Infow("Content delete requested", "user_id", user.ID)
 
+	// Snapshot and document files are not removed by the cascade
+	if err := u.SnapshotModel.DeleteAllByUserId(ctx, user.ID); err != nil {
+		logger.Errorw("delete user snapshots", "error", err, "user_id", user.ID)
+		http.Error(w, "Failed to delete content", http.StatusInternalServerError)
+		return
+	}
+	if err := u.FileModel.DeleteAllByUserId(ctx, user.ID); err != nil {
+		logger.Errorw("delete user files", "error", err, "user_id", user.ID)
+		http.Error(w, "Failed to delete content", http.StatusInternalServerError)
+		return
+	}
+
 	// Deleting from library_items will be cascaded to library_content
 	_, err := u.UserService.Pool.Exec(ctx, `DELETE FROM library_items WHERE user_id = $1`, user.ID)
 	// err = deleteUserContent(ctx, tx)
@@ -720,6 +781,18 @@ func (u Users) DeleteAccount(w http.ResponseWriter, r *http.Request) {
 
 	logger.Infow("User delete requested", "user_id", user.ID)
 
+	// Snapshot and document files are not removed by the cascade
+	if err := u.SnapshotModel.DeleteAllByUserId(ctx, user.ID); err != nil {
+		logger.Errorw("delete user snapshots", "error", err, "user_id", user.ID)
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+	if err := u.FileModel.DeleteAllByUserId(ctx, user.ID); err != nil {
+		logger.Errorw("delete user files", "error", err, "user_id", user.ID)
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
 	// Deleting from library_items will be cascaded to library_content
 	_, err := u.UserService.Pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, user.ID)
 	// err = deleteUserContent(ctx, tx)
@@ -1139,3 +1212,153 @@ func validateTurnstileToken(token string, secretKey string, remoteIP string) err
 
 	return nil
 }
+
+// fetchProfilesData is what the fetch profiles section of the preferences tab shows
+type fetchProfilesData struct {
+	FetchProfiles     []models.FetchProfile
+	CookiesEnabled    bool
+	FetchProfileError string
+}
+
+// SaveFetchProfile handles POST /users/preferences/fetch-profiles to set how the pages of a
+// site are fetched for the user. It answers with the updated list of profiles.
+func (u Users) SaveFetchProfile(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+
+	var data fetchProfilesData
+	profile := models.FetchProfile{
+		UserId:           &user.ID,
+		Domain:           r.FormValue("domain"),
+		UserAgent:        r.FormValue("user_agent"),
+		Cookies:          r.FormValue("cookies"),
+		IncludeSelectors: r.FormValue("include_selectors"),
+		ExcludeSelectors: r.FormValue("exclude_selectors"),
+	}
+	headers, err := fetcher.ParseHeaders(r.FormValue("headers"))
+	if err == nil {
+		profile.Headers = headers
+		err = u.FetchProfileModel.Save(r.Context(), &profile)
+	}
+	if err != nil {
+		if !errors.Is(err, errors.ErrInvalidFetchProfile) && !errors.Is(err, errors.ErrCookiesDisabled) {
+			logger.Errorw("save fetch profile", "error", err)
+			http.Error(w, "Failed to save site settings", http.StatusInternalServerError)
+			return
+		}
+		data.FetchProfileError = err.Error()
+	} else {
+		logger.Infow("saved fetch profile", "user_id", user.ID, "domain", profile.Domain)
+	}
+	u.renderFetchProfiles(w, r, data)
+}
+
+// DeleteFetchProfile handles POST /users/preferences/fetch-profiles/delete
+func (u Users) DeleteFetchProfile(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid site settings", http.StatusBadRequest)
+		return
+	}
+	err = u.FetchProfileModel.Delete(r.Context(), &user.ID, id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("delete fetch profile", "error", err)
+		http.Error(w, "Failed to remove site settings", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("deleted fetch profile", "user_id", user.ID, "id", id)
+	u.renderFetchProfiles(w, r, fetchProfilesData{})
+}
+
+func (u Users) renderFetchProfiles(w http.ResponseWriter, r *http.Request, data fetchProfilesData) {
+	user := usercontext.User(r.Context())
+	profiles, err := u.FetchProfileModel.List(r.Context(), &user.ID)
+	if err != nil {
+		loggercontext.Logger(r.Context()).Errorw("list fetch profiles", "error", err)
+		http.Error(w, "Failed to get site settings", http.StatusInternalServerError)
+		return
+	}
+	data.FetchProfiles = profiles
+	data.CookiesEnabled = u.FetchProfileModel.CookiesEnabled()
+	w.Header().Set("Content-Type", "text/html")
+	u.Templates.FetchProfiles.Execute(w, r, data)
+}
+
+// enrichLibraryData is what the library enrichment section of the import/export tab shows
+type enrichLibraryData struct {
+	AIAvailable    bool
+	Backfill       *models.AIBackfill // Nil until the user enriches their library
+	Missing        int                // Bookmarks without AI data or embedding
+	RemainingToday int                // Bookmarks that can still be enriched today
+	EnrichError    string
+}
+
+// StartEnrichLibrary handles POST /users/enrich-library to generate the AI data and embeddings
+// of the bookmarks that have none. A paused enrichment resumes where it stopped.
+func (u Users) StartEnrichLibrary(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+
+	var data enrichLibraryData
+	if u.AIBackfillModel == nil {
+		data.EnrichError = "AI processing is not available"
+	} else if _, err := u.AIBackfillModel.Start(r.Context(), user.ID); err != nil {
+		logger.Errorw("start library enrichment", "error", err)
+		http.Error(w, "Failed to enrich the library", http.StatusInternalServerError)
+		return
+	} else {
+		logger.Infow("started library enrichment", "user_id", user.ID)
+	}
+	u.renderEnrichLibrary(w, r, data)
+}
+
+// PauseEnrichLibrary handles POST /users/enrich-library/pause. The bookmarks already queued are
+// still processed.
+func (u Users) PauseEnrichLibrary(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+
+	if u.AIBackfillModel != nil {
+		err := u.AIBackfillModel.Pause(r.Context(), user.ID)
+		if err != nil && !errors.Is(err, errors.ErrNotFound) {
+			logger.Errorw("pause library enrichment", "error", err)
+			http.Error(w, "Failed to pause the enrichment", http.StatusInternalServerError)
+			return
+		}
+		logger.Infow("paused library enrichment", "user_id", user.ID)
+	}
+	u.renderEnrichLibrary(w, r, enrichLibraryData{})
+}
+
+func (u Users) renderEnrichLibrary(w http.ResponseWriter, r *http.Request, data enrichLibraryData) {
+	user := usercontext.User(r.Context())
+	if err := u.loadEnrichLibrary(r.Context(), user, &data); err != nil {
+		loggercontext.Logger(r.Context()).Errorw("get library enrichment", "error", err)
+		http.Error(w, "Failed to get the library enrichment", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	u.Templates.EnrichLibrary.Execute(w, r, data)
+}
+
+func (u Users) loadEnrichLibrary(ctx gocontext.Context, user *models.User, data *enrichLibraryData) error {
+	if u.AIBackfillModel == nil {
+		return nil
+	}
+	data.AIAvailable = true
+	backfill, err := u.AIBackfillModel.Get(ctx, user.ID)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		return err
+	}
+	data.Backfill = backfill
+	missing, err := u.AIBackfillModel.Missing(ctx, &user.ID)
+	if err != nil {
+		return err
+	}
+	data.Missing = missing[user.ID]
+	data.RemainingToday, err = u.AIBackfillModel.RemainingBackfill(ctx, user)
+	return err
+}
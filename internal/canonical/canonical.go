@@ -0,0 +1,317 @@
+// Package canonical turns the many links of a page (tracking parameters, mobile and AMP
+// versions, short links) into the one link a bookmark is saved with
+package canonical
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Rule changes the links of a site, on top of the steps done for every link
+type Rule struct {
+	Hosts        []string `json:"hosts"`                   // Domains the rule is for, their subdomains included
+	Host         string   `json:"host,omitempty"`          // Replaces the host, like the main site of a mobile one
+	PathPattern  string   `json:"path_pattern,omitempty"`  // Regexp matched against the path
+	Rewrite      string   `json:"rewrite,omitempty"`       // New path for PathPattern, it may have a query and use $1, $2...
+	KeepParams   []string `json:"keep_params,omitempty"`   // When set, the other query parameters are dropped
+	DropParams   []string `json:"drop_params,omitempty"`   // Dropped in addition to the tracking parameters
+	KeepFragment bool     `json:"keep_fragment,omitempty"` // For sites that route with the fragment
+	AMP          bool     `json:"amp,omitempty"`           // The AMP pages of the site end with /amp or have an amp parameter
+
+	path *regexp.Regexp
+}
+
+// Config is the pipeline of a Canonicalizer. Load reads extra rules from a JSON file of the same shape.
+type Config struct {
+	TrackingParams []string `json:"tracking_params"` // Dropped on every site, a trailing "*" matches a prefix
+	Shorteners     []string `json:"shorteners"`      // Hosts whose links are replaced by the page they redirect to
+	Rules          []Rule   `json:"rules"`
+}
+
+// Default is used when no rules file is configured
+var Default = Config{
+	TrackingParams: []string{
+		"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "yclid", "igshid",
+		"mc_cid", "mc_eid", "_hsenc", "_hsmi", "mkt_tok", "ref", "ref_src", "ref_url",
+	},
+	Shorteners: []string{
+		"t.co", "bit.ly", "buff.ly", "dlvr.it", "goo.gl", "ow.ly", "tinyurl.com", "is.gd",
+		"lnkd.in", "trib.al", "rebrand.ly", "shorturl.at", "tiny.cc", "cutt.ly", "amzn.to",
+	},
+	Rules: []Rule{
+		{Hosts: []string{"youtu.be"}, Host: "www.youtube.com", PathPattern: `^/([\w-]+)$`, Rewrite: "/watch?v=$1", DropParams: []string{"feature", "si"}},
+		{Hosts: []string{"youtube.com"}, Host: "www.youtube.com", DropParams: []string{"feature", "si", "pp"}},
+		{Hosts: []string{"mobile.twitter.com"}, Host: "twitter.com", DropParams: []string{"s", "t"}},
+		{Hosts: []string{"twitter.com", "x.com"}, DropParams: []string{"s", "t"}},
+		{Hosts: []string{"en.m.wikipedia.org"}, Host: "en.wikipedia.org"},
+		{Hosts: []string{"medium.com"}, DropParams: []string{"source", "sk"}},
+		{Hosts: []string{"news.ycombinator.com"}, KeepParams: []string{"id", "p"}},
+		{Hosts: []string{"amazon.com"}, PathPattern: `^(?:/[^/]+)?/(?:dp|gp/product)/(\w+).*$`, Rewrite: "/dp/$1", KeepParams: []string{}},
+		{Hosts: []string{"amp.theguardian.com"}, Host: "www.theguardian.com"},
+		{Hosts: []string{"independent.co.uk"}, AMP: true},
+	},
+}
+
+var std = mustNew(Default)
+
+// Canonicalizer computes the canonical links. The nil Canonicalizer uses the Default config.
+type Canonicalizer struct {
+	trackingParams   map[string]bool
+	trackingPrefixes []string
+	shorteners       map[string]bool
+	rules            []Rule
+}
+
+// New returns the Canonicalizer of the config
+func New(cfg Config) (*Canonicalizer, error) {
+	c := &Canonicalizer{
+		trackingParams: make(map[string]bool),
+		shorteners:     make(map[string]bool),
+	}
+	for _, param := range cfg.TrackingParams {
+		param = strings.ToLower(param)
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			c.trackingPrefixes = append(c.trackingPrefixes, prefix)
+		} else {
+			c.trackingParams[param] = true
+		}
+	}
+	for _, host := range cfg.Shorteners {
+		c.shorteners[strings.ToLower(host)] = true
+	}
+	for i, rule := range cfg.Rules {
+		if len(rule.Hosts) == 0 {
+			return nil, fmt.Errorf("rule %d has no hosts", i)
+		}
+		if rule.PathPattern != "" {
+			re, err := regexp.Compile(rule.PathPattern)
+			if err != nil {
+				return nil, fmt.Errorf("compile path pattern of rule %d: %w", i, err)
+			}
+			rule.path = re
+		}
+		c.rules = append(c.rules, rule)
+	}
+	return c, nil
+}
+
+func mustNew(cfg Config) *Canonicalizer {
+	c, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Load returns a Canonicalizer with the Default config extended by the rules file. The rules
+// of the file come first, so they take over the default rules of the same hosts.
+func Load(rulesFile string) (*Canonicalizer, error) {
+	if rulesFile == "" {
+		return std, nil
+	}
+	data, err := os.ReadFile(rulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("read canonical rules: %w", err)
+	}
+	var extra Config
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return nil, fmt.Errorf("parse canonical rules: %w", err)
+	}
+	cfg := Config{
+		TrackingParams: append(extra.TrackingParams, Default.TrackingParams...),
+		Shorteners:     append(extra.Shorteners, Default.Shorteners...),
+		Rules:          append(extra.Rules, Default.Rules...),
+	}
+	return New(cfg)
+}
+
+// Link returns the canonical form of the link
+func (c *Canonicalizer) Link(link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("parse link to canonicalize: %w", err)
+	}
+	return c.URL(u), nil
+}
+
+// URL returns the canonical form of the URL, without fetching it. The URL is not modified.
+func (c *Canonicalizer) URL(original *url.URL) string {
+	if c == nil {
+		c = std
+	}
+	u := unwrapAMP(normalize(original))
+
+	rule := c.rule(u.Hostname())
+	if rule != nil && rule.Host != "" {
+		u.Host = rule.Host
+	}
+	query := u.Query()
+	if rule != nil && rule.path != nil && rule.path.MatchString(u.Path) {
+		rewritten, err := url.Parse(rule.path.ReplaceAllString(u.Path, rule.Rewrite))
+		if err == nil {
+			u.Path = rewritten.Path
+			for k, v := range rewritten.Query() {
+				query[k] = v
+			}
+		}
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	// Other sites may have real pages at /amp, their AMP pages are found by their declared link
+	if rule != nil && rule.AMP {
+		u.Path = strings.TrimSuffix(u.Path, "/amp")
+		query.Del("amp")
+	}
+	u.RawPath = ""
+
+	for k := range query {
+		if !c.keepParam(rule, k) {
+			query.Del(k)
+		}
+	}
+	// Encode sorts the parameters by name
+	u.RawQuery = query.Encode()
+
+	// Fragments are mostly anchors in the page, except on sites routing with "#!" or "#/"
+	if rule == nil || !rule.KeepFragment {
+		if !strings.HasPrefix(u.Fragment, "!") && !strings.HasPrefix(u.Fragment, "/") {
+			u.Fragment = ""
+		}
+	}
+	u.RawFragment = ""
+	return u.String()
+}
+
+// Resolve returns the canonical link of a fetched page. finalURL is where the redirects ended,
+// and declared is the <link rel="canonical"> of the page. Short links are replaced by the page
+// they redirect to, and the declared link is used when it is on the same site.
+func (c *Canonicalizer) Resolve(original, finalURL *url.URL, declared string) string {
+	if c == nil {
+		c = std
+	}
+	page := original
+	if finalURL != nil && c.IsShortener(original.Hostname()) {
+		page = finalURL
+	}
+	if declared != "" {
+		if d, err := page.Parse(strings.TrimSpace(declared)); err == nil && sameArticle(unwrapAMP(normalize(page)), d) {
+			page = d
+		}
+	}
+	return c.URL(page)
+}
+
+// IsShortener tells if the links of the host only redirect to other pages
+func (c *Canonicalizer) IsShortener(host string) bool {
+	if c == nil {
+		c = std
+	}
+	return c.shorteners[strings.TrimPrefix(strings.ToLower(host), "www.")]
+}
+
+func (c *Canonicalizer) rule(host string) *Rule {
+	for i, rule := range c.rules {
+		for _, domain := range rule.Hosts {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return &c.rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Canonicalizer) keepParam(rule *Rule, name string) bool {
+	lower := strings.ToLower(name)
+	if rule != nil {
+		if rule.KeepParams != nil {
+			for _, keep := range rule.KeepParams {
+				if keep == name {
+					return true
+				}
+			}
+			return false
+		}
+		for _, drop := range rule.DropParams {
+			if drop == name {
+				return false
+			}
+		}
+	}
+	if c.trackingParams[lower] {
+		return false
+	}
+	for _, prefix := range c.trackingPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize returns a copy of the URL on https, with the host in lowercase and without the default port
+func normalize(original *url.URL) *url.URL {
+	u := *original
+	u.User = nil
+	// It's 2025! We don't expect the URL to be http
+	u.Scheme = "https"
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	u.Host = host
+	return &u
+}
+
+// unwrapAMP returns the page an AMP cache or viewer URL is a copy of
+func unwrapAMP(u *url.URL) *url.URL {
+	var rest string
+	switch host := u.Hostname(); {
+	case strings.HasSuffix(host, ".cdn.ampproject.org"):
+		// https://example-com.cdn.ampproject.org/c/s/example.com/article
+		rest = strings.TrimPrefix(strings.TrimPrefix(u.Path, "/v"), "/c")
+	case strings.HasPrefix(host, "www.google.") || strings.HasPrefix(host, "google."):
+		if !strings.HasPrefix(u.Path, "/amp/") {
+			return u
+		}
+		// https://www.google.com/amp/s/example.com/article
+		rest = strings.TrimPrefix(u.Path, "/amp")
+	default:
+		return u
+	}
+	rest = strings.TrimPrefix(strings.TrimPrefix(rest, "/s"), "/")
+	origin, err := url.Parse("https://" + rest)
+	if err != nil || origin.Host == "" || !strings.Contains(origin.Host, ".") {
+		return u
+	}
+	origin.RawQuery = u.RawQuery
+	origin.Fragment = u.Fragment
+	return normalize(origin)
+}
+
+// sameArticle tells if the canonical link declared by the page can be trusted: it must be on
+// the same site, and not the home page of an article
+func sameArticle(page, declared *url.URL) bool {
+	if declared.Scheme != "http" && declared.Scheme != "https" {
+		return false
+	}
+	if site(page.Hostname()) != site(declared.Hostname()) {
+		return false
+	}
+	isRoot := func(u *url.URL) bool { return strings.Trim(u.Path, "/") == "" }
+	return isRoot(page) || !isRoot(declared)
+}
+
+// site is the registrable domain of the host, like example.co.uk for www.example.co.uk
+func site(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
@@ -0,0 +1,43 @@
+package canonical
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Declared returns the href of the <link rel="canonical"> in the head of the page, or "" when
+// the page doesn't declare one
+func Declared(page []byte) string {
+	tokenizer := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "body":
+				return ""
+			case "link":
+				var rel, href string
+				for hasAttr {
+					var key, value []byte
+					key, value, hasAttr = tokenizer.TagAttr()
+					switch string(key) {
+					case "rel":
+						rel = string(value)
+					case "href":
+						href = string(value)
+					}
+				}
+				for _, r := range strings.Fields(strings.ToLower(rel)) {
+					if r == "canonical" && href != "" {
+						return href
+					}
+				}
+			}
+		}
+	}
+}
@@ -54,6 +54,43 @@ type PodcastConfig struct {
 	ServiceAccountPath string // path to service-account.json; used in prod
 }
 
+type StorageConfig struct {
+	Dir string // Root directory of the local storage backend
+}
+
+type LinkCheckConfig struct {
+	Enabled    bool
+	ArchiveURL string // Fallback for broken links, "{url}" is replaced by the link
+}
+
+type CanonicalConfig struct {
+	RulesFile string // JSON file of extra canonicalization rules, the defaults are used without it
+}
+
+type FetchConfig struct {
+	Backends       string // Backends tried in order, like "go-readability,scrapper"
+	DomainBackends string // Orders of some domains, like "medium.com=headless,go-readability;x.com=headless"
+	ScrapperURL    string // Base URL of the Node scrapper service
+	HeadlessURL    string // Endpoint of the headless rendering service
+	ProfileKey     string // Base64 key encrypting the cookies of fetch profiles, cookies can't be saved without it
+}
+
+type LLMConfig struct {
+	Provider   string // gemini, openai, ollama or fake
+	BaseURL    string // API of the openai and ollama providers
+	APIKey     string // Key of the openai provider
+	Model      string // Model of every task, the provider's default when empty
+	TaskModels string // Models of some tasks, like "summarize=llama3.1:8b;podcast=llama3.1:70b"
+}
+
+type EmbeddingConfig struct {
+	Provider  string // gemini, openai, ollama or fake
+	BaseURL   string // API of the openai and ollama providers
+	APIKey    string // Key of the openai provider
+	Model     string // The provider's default when empty
+	Dimension int    // Required for the models that are not a default, 0 uses the default dimension
+}
+
 type TelegramLoggerConfig struct {
 	Token  string
 	ChatID string
@@ -88,6 +125,12 @@ type AppConfig struct {
 	GitHub    GitHubOAuthConfig
 	Google    GoogleOAuthConfig
 	Podcast   PodcastConfig
+	Storage   StorageConfig
+	LinkCheck LinkCheckConfig
+	Canonical CanonicalConfig
+	Fetch     FetchConfig
+	LLM       LLMConfig
+	Embedding EmbeddingConfig
 }
 
 func LoadEnvConfig(envFiles ...string) (*AppConfig, error) {
@@ -171,6 +214,47 @@ func LoadEnvConfig(envFiles ...string) (*AppConfig, error) {
 		ServiceAccountPath: GetEnvWithDefault("GCP_SERVICE_ACCOUNT_PATH", "/app/credentials/service-account.json"),
 	}
 
+	cfg.Storage = StorageConfig{
+		Dir: GetEnvWithDefault("STORAGE_DIR", "uploads/storage"),
+	}
+
+	cfg.LinkCheck = LinkCheckConfig{
+		Enabled:    GetEnvWithDefault("LINK_CHECK_ENABLED", "true") == "true",
+		ArchiveURL: GetEnvWithDefault("LINK_ARCHIVE_URL", "https://web.archive.org/web/{url}"),
+	}
+
+	cfg.Canonical = CanonicalConfig{
+		RulesFile: GetEnvWithDefault("CANONICAL_RULES_FILE", ""),
+	}
+
+	cfg.Fetch = FetchConfig{
+		Backends:       GetEnvWithDefault("FETCH_BACKENDS", "go-readability"),
+		DomainBackends: GetEnvWithDefault("FETCH_DOMAIN_BACKENDS", ""),
+		ScrapperURL:    GetEnvWithDefault("SCRAPPER_URL", ""),
+		HeadlessURL:    GetEnvWithDefault("HEADLESS_URL", ""),
+		ProfileKey:     GetEnvWithDefault("FETCH_PROFILE_KEY", ""),
+	}
+
+	cfg.LLM = LLMConfig{
+		Provider:   GetEnvWithDefault("LLM_PROVIDER", "gemini"),
+		BaseURL:    GetEnvWithDefault("LLM_BASE_URL", ""),
+		APIKey:     GetEnvWithDefault("LLM_API_KEY", ""),
+		Model:      GetEnvWithDefault("LLM_MODEL", ""),
+		TaskModels: GetEnvWithDefault("LLM_TASK_MODELS", ""),
+	}
+
+	embeddingDimension, err := strconv.Atoi(GetEnvWithDefault("EMBEDDING_DIMENSION", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("parse EMBEDDING_DIMENSION: %w", err)
+	}
+	cfg.Embedding = EmbeddingConfig{
+		Provider:  GetEnvWithDefault("EMBEDDING_PROVIDER", "gemini"),
+		BaseURL:   GetEnvWithDefault("EMBEDDING_BASE_URL", ""),
+		APIKey:    GetEnvWithDefault("EMBEDDING_API_KEY", ""),
+		Model:     GetEnvWithDefault("EMBEDDING_MODEL", ""),
+		Dimension: embeddingDimension,
+	}
+
 	return &cfg, nil
 }
 
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS collection_items;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS library_item_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_library_items_user_starred;
+DROP INDEX IF EXISTS idx_library_items_user_reading_status;
+
+ALTER TABLE library_items
+    DROP COLUMN IF EXISTS starred,
+    DROP COLUMN IF EXISTS reading_status;
@@ -0,0 +1,7 @@
+ALTER TABLE library_items
+    ADD COLUMN reading_status TEXT NOT NULL DEFAULT 'unread'
+        CHECK (reading_status IN ('unread', 'reading', 'archived')),
+    ADD COLUMN starred BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_library_items_user_reading_status ON library_items(user_id, reading_status);
+CREATE INDEX idx_library_items_user_starred ON library_items(user_id) WHERE starred;
@@ -0,0 +1,21 @@
+-- Passages selected by the user, anchored to library_contents.content by rune offsets.
+-- prefix and suffix keep some surrounding text so a highlight can be re-anchored if the content changes.
+CREATE TABLE highlights (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    library_item_id TEXT NOT NULL REFERENCES library_items(id) ON DELETE CASCADE,
+    text TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    prefix TEXT NOT NULL DEFAULT '',
+    suffix TEXT NOT NULL DEFAULT '',
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    search_vector tsvector GENERATED ALWAYS AS (immutable_to_tsvector(text || ' ' || note)) STORED,
+    CHECK (start_offset >= 0 AND end_offset > start_offset)
+);
+
+CREATE INDEX idx_highlights_library_item_id ON highlights(library_item_id, start_offset);
+CREATE INDEX idx_highlights_user_id_created_at ON highlights(user_id, created_at DESC);
+CREATE INDEX idx_highlights_search_vector ON highlights USING GIN(search_vector);
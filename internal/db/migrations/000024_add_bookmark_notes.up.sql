@@ -0,0 +1,9 @@
+-- Personal markdown note of the user on a bookmark
+ALTER TABLE library_contents ADD COLUMN note TEXT NOT NULL DEFAULT '';
+
+-- Rebuild the search vector so the note is searchable together with the content
+DROP INDEX IF EXISTS search_vector_idx;
+ALTER TABLE library_contents DROP COLUMN search_vector;
+ALTER TABLE library_contents ADD COLUMN search_vector tsvector
+  GENERATED ALWAYS AS (immutable_to_tsvector(title || ' ' || excerpt || ' ' || content || ' ' || note)) STORED;
+CREATE INDEX search_vector_idx ON library_contents USING GIN(search_vector);
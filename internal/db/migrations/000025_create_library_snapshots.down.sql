@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS snapshot_format;
+DROP TABLE IF EXISTS library_snapshots;
@@ -0,0 +1,17 @@
+-- Offline copy of the saved page. The file itself lives in the storage backend under storage_key.
+CREATE TABLE library_snapshots (
+    library_item_id TEXT PRIMARY KEY REFERENCES library_items(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format TEXT NOT NULL CHECK (format IN ('html', 'warc')),
+    storage_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    assets INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_library_snapshots_user_id ON library_snapshots(user_id);
+
+-- How new bookmarks of the user are captured, users opt in from their preferences
+ALTER TABLE users ADD COLUMN snapshot_format TEXT NOT NULL DEFAULT 'none'
+  CHECK (snapshot_format IN ('none', 'html', 'warc'));
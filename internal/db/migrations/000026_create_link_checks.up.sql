@@ -0,0 +1,15 @@
+-- Last probe of each saved link by the dead-link checker
+CREATE TABLE link_checks (
+    library_item_id TEXT PRIMARY KEY REFERENCES library_items(id) ON DELETE CASCADE,
+    status_code INTEGER NOT NULL DEFAULT 0, -- 0 when the request failed before a response
+    final_url TEXT NOT NULL DEFAULT '',
+    redirects INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    broken BOOLEAN NOT NULL DEFAULT false,
+    checked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    next_check_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_link_checks_next_check_at ON link_checks(next_check_at);
+CREATE INDEX idx_link_checks_broken ON link_checks(library_item_id) WHERE broken;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS library_content_versions;
+ALTER TABLE library_contents DROP COLUMN IF EXISTS fetched_at;
@@ -0,0 +1,16 @@
+-- When the current content of the bookmark was fetched
+ALTER TABLE library_contents ADD COLUMN fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+UPDATE library_contents lc SET fetched_at = li.created_at FROM library_items li WHERE li.id = lc.id;
+
+-- Previous contents of a bookmark, kept when the page is fetched again
+CREATE TABLE library_content_versions (
+    id SERIAL PRIMARY KEY,
+    library_item_id TEXT NOT NULL REFERENCES library_items(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    excerpt TEXT NOT NULL,
+    content TEXT NOT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL, -- When this content was fetched
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW() -- When it was replaced by a newer one
+);
+
+CREATE INDEX idx_library_content_versions_library_item_id ON library_content_versions(library_item_id, fetched_at DESC);
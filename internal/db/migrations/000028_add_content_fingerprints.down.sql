@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS duplicate_dismissals;
+ALTER TABLE library_contents DROP COLUMN IF EXISTS simhash;
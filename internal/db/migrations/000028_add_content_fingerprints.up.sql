@@ -0,0 +1,13 @@
+-- Simhash of the content to find the same article saved from different links.
+-- NULL until computed, 0 when the content is too short to be fingerprinted.
+ALTER TABLE library_contents ADD COLUMN simhash BIGINT;
+
+-- Pairs of bookmarks the user marked as not being duplicates, library_item_id < other_item_id
+CREATE TABLE duplicate_dismissals (
+    library_item_id TEXT NOT NULL REFERENCES library_items(id) ON DELETE CASCADE,
+    other_item_id TEXT NOT NULL REFERENCES library_items(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (library_item_id, other_item_id)
+);
+
+CREATE INDEX idx_duplicate_dismissals_other_item_id ON duplicate_dismissals(other_item_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_library_items_user_id_link;
+DROP TABLE IF EXISTS library_item_aliases;
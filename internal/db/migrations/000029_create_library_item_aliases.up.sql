@@ -0,0 +1,13 @@
+-- Other links of a bookmark, like the link it was saved from before canonicalization
+-- or the link of a bookmark merged into it. They resolve to the bookmark on lookups.
+CREATE TABLE library_item_aliases (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    link TEXT NOT NULL,
+    library_item_id TEXT NOT NULL REFERENCES library_items(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, link)
+);
+
+CREATE INDEX idx_library_item_aliases_library_item_id ON library_item_aliases(library_item_id);
+
+CREATE INDEX idx_library_items_user_id_link ON library_items(user_id, link);
@@ -0,0 +1,7 @@
+-- Captions of video bookmarks with their timing and chapters, to show the transcript with
+-- timestamps. The text of the transcript is also the content of the bookmark.
+CREATE TABLE library_transcripts (
+    library_item_id TEXT PRIMARY KEY REFERENCES library_items(id) ON DELETE CASCADE,
+    transcript JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,13 @@
+-- Original file of bookmarks saved from a document, like a PDF link or an uploaded file.
+-- The file itself lives in the storage backend under storage_key.
+CREATE TABLE library_files (
+    library_item_id TEXT PRIMARY KEY REFERENCES library_items(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_library_files_user_id ON library_files(user_id);
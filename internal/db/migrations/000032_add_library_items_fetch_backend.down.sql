@@ -0,0 +1 @@
+ALTER TABLE library_items DROP COLUMN fetch_backend;
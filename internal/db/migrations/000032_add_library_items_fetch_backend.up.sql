@@ -0,0 +1,3 @@
+-- The fetch backend that got the page (go-readability, scrapper or headless), NULL when the
+-- content came from the browser extension, a site extractor or an upload
+ALTER TABLE library_items ADD COLUMN fetch_backend TEXT;
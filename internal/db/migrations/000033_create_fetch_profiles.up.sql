@@ -0,0 +1,18 @@
+-- How the pages of a domain are fetched. Profiles without a user are set by the admins and
+-- apply to everyone, a user's own profile for the domain is used before them.
+CREATE TABLE fetch_profiles (
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    domain TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    headers JSONB NOT NULL DEFAULT '{}',
+    -- The Cookie header, encrypted with FETCH_PROFILE_KEY
+    cookies_encrypted BYTEA,
+    include_selectors TEXT NOT NULL DEFAULT '',
+    exclude_selectors TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_fetch_profiles_user_id_domain ON fetch_profiles(user_id, domain) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_fetch_profiles_domain ON fetch_profiles(domain) WHERE user_id IS NULL;
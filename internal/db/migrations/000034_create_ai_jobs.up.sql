@@ -0,0 +1,31 @@
+CREATE TYPE ai_job_status AS ENUM (
+    'pending',
+    'processing',
+    'done',
+    'failed'
+);
+
+-- AI processing of the bookmarks: the summary, markdown and tags ('enrich') and the embedding
+-- ('embedding'). Failed jobs are retried with a backoff until max_attempts.
+CREATE TABLE ai_jobs (
+    id              SERIAL PRIMARY KEY,
+    library_item_id TEXT NOT NULL REFERENCES library_items(id) ON DELETE CASCADE,
+    user_id         INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind            TEXT NOT NULL,
+    status          ai_job_status NOT NULL DEFAULT 'pending',
+    -- Content to process, like the HTML of the page, the saved text is used when NULL.
+    -- It is cleared once the job is done.
+    input           TEXT,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    max_attempts    INTEGER NOT NULL DEFAULT 5,
+    run_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error      TEXT,
+    started_at      TIMESTAMPTZ,
+    completed_at    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (library_item_id, kind)
+);
+
+CREATE INDEX idx_ai_jobs_due ON ai_jobs (run_at) WHERE status = 'pending';
+CREATE INDEX idx_ai_jobs_user_id ON ai_jobs (user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE daily_ai_limits DROP COLUMN IF EXISTS backfill_count;
+DROP TABLE IF EXISTS ai_backfills;
+DROP TYPE IF EXISTS ai_backfill_status;
@@ -0,0 +1,24 @@
+CREATE TYPE ai_backfill_status AS ENUM (
+    'running',
+    'paused',
+    'done'
+);
+
+-- Backfill of the AI data and embeddings of the bookmarks saved before the AI processing, or
+-- imported without it. Bookmarks are queued in batches in the order of their id, after cursor,
+-- so a backfill resumes where it stopped.
+CREATE TABLE ai_backfills (
+    user_id      INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    status       ai_backfill_status NOT NULL DEFAULT 'running',
+    last_item_id TEXT NOT NULL DEFAULT '',
+    queued       INTEGER NOT NULL DEFAULT 0,
+    started_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_ai_backfills_running ON ai_backfills (updated_at) WHERE status = 'running';
+
+-- Bookmarks summarized by backfills count against the daily bookmark limit of the user, with
+-- the bookmarks saved that day
+ALTER TABLE daily_ai_limits ADD COLUMN backfill_count INT NOT NULL DEFAULT 0;
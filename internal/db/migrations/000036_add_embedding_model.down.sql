@@ -0,0 +1,24 @@
+-- Only the embeddings of the previous default model fit the previous column
+DO $$
+DECLARE
+    idx TEXT;
+BEGIN
+    FOR idx IN
+        SELECT indexname FROM pg_indexes
+        WHERE tablename = 'library_contents' AND indexname LIKE 'content\_embedding\_hnsw\_%'
+    LOOP
+        EXECUTE format('DROP INDEX IF EXISTS %I', idx);
+    END LOOP;
+END $$;
+
+UPDATE library_contents
+SET content_embedding = NULL
+WHERE embedding_model IS DISTINCT FROM 'gemini-embedding-001' OR embedding_dimension IS DISTINCT FROM 768;
+
+ALTER TABLE library_contents DROP COLUMN IF EXISTS embedding_dimension;
+ALTER TABLE library_contents DROP COLUMN IF EXISTS embedding_model;
+ALTER TABLE library_contents ALTER COLUMN content_embedding TYPE vector(768);
+
+CREATE INDEX content_embedding_hnsw_idx ON library_contents
+USING hnsw (content_embedding vector_cosine_ops)
+WITH (m = 16, ef_construction = 64);
@@ -0,0 +1,19 @@
+-- Embeddings are stored with the model and dimension that made them, so an instance can switch
+-- embedding models. Each model has its own partial HNSW index over the vectors of that model,
+-- created by the server when the model is configured. Searches only compare vectors of the
+-- configured model.
+DROP INDEX IF EXISTS content_embedding_hnsw_idx;
+
+ALTER TABLE library_contents ALTER COLUMN content_embedding TYPE vector;
+ALTER TABLE library_contents ADD COLUMN embedding_model TEXT;
+ALTER TABLE library_contents ADD COLUMN embedding_dimension INTEGER;
+
+UPDATE library_contents
+SET embedding_model = 'gemini-embedding-001', embedding_dimension = 768
+WHERE content_embedding IS NOT NULL;
+
+-- The index of the previous default model, named like the ones created by the server
+CREATE INDEX content_embedding_hnsw_gemini_embedding_001_768 ON library_contents
+USING hnsw ((content_embedding::vector(768)) vector_cosine_ops)
+WITH (m = 16, ef_construction = 64)
+WHERE embedding_model = 'gemini-embedding-001' AND embedding_dimension = 768;
@@ -0,0 +1,16 @@
+-- The overviews of the bookmarks become their embedding again
+ALTER TABLE library_contents ADD COLUMN content_embedding vector;
+
+UPDATE library_contents lc
+SET content_embedding = ch.embedding,
+    embedding_model = ch.embedding_model,
+    embedding_dimension = ch.embedding_dimension
+FROM library_chunks ch
+WHERE ch.library_item_id = lc.id AND ch.position = 0;
+
+DROP TABLE IF EXISTS library_chunks;
+
+CREATE INDEX content_embedding_hnsw_gemini_embedding_001_768 ON library_contents
+USING hnsw ((content_embedding::vector(768)) vector_cosine_ops)
+WITH (m = 16, ef_construction = 64)
+WHERE embedding_model = 'gemini-embedding-001' AND embedding_dimension = 768;
@@ -0,0 +1,49 @@
+-- Passages of the bookmarks with their embeddings, so the semantic search and the answers find
+-- what is in the middle of long articles. The first passage of a bookmark is its overview: the
+-- note, summary and excerpt. The passages replace the single embedding of library_contents,
+-- which now only records the model the passages of the bookmark were embedded with.
+CREATE TABLE library_chunks (
+    id BIGSERIAL PRIMARY KEY,
+    library_item_id TEXT NOT NULL REFERENCES library_items(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    embedding vector NOT NULL,
+    embedding_model TEXT NOT NULL,
+    embedding_dimension INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (library_item_id, position)
+);
+
+CREATE INDEX idx_library_chunks_user_id ON library_chunks(user_id);
+
+-- The embeddings of the bookmarks are kept as their overview until they are embedded again
+INSERT INTO library_chunks (library_item_id, user_id, position, content, embedding, embedding_model, embedding_dimension)
+SELECT li.id, li.user_id, 0, COALESCE(NULLIF(li.ai_summary, ''), NULLIF(li.ai_excerpt, ''), li.excerpt, ''),
+    lc.content_embedding, lc.embedding_model, lc.embedding_dimension
+FROM library_items li
+JOIN library_contents lc ON lc.id = li.id
+WHERE lc.content_embedding IS NOT NULL AND li.user_id IS NOT NULL;
+
+-- Every bookmark is embedded again in passages by the backfill
+UPDATE library_contents SET embedding_model = NULL, embedding_dimension = NULL;
+
+DO $$
+DECLARE
+    idx TEXT;
+BEGIN
+    FOR idx IN
+        SELECT indexname FROM pg_indexes
+        WHERE tablename = 'library_contents' AND indexname LIKE 'content\_embedding\_hnsw\_%'
+    LOOP
+        EXECUTE format('DROP INDEX IF EXISTS %I', idx);
+    END LOOP;
+END $$;
+
+ALTER TABLE library_contents DROP COLUMN content_embedding;
+
+-- The index of the default model, named like the ones created by the server
+CREATE INDEX library_chunks_hnsw_gemini_embedding_001_768 ON library_chunks
+USING hnsw ((embedding::vector(768)) vector_cosine_ops)
+WITH (m = 16, ef_construction = 64)
+WHERE embedding_model = 'gemini-embedding-001' AND embedding_dimension = 768;
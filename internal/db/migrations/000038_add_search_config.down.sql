@@ -0,0 +1,20 @@
+DROP INDEX IF EXISTS idx_library_contents_note_trgm;
+DROP INDEX IF EXISTS idx_library_contents_content_trgm;
+DROP INDEX IF EXISTS idx_library_items_title_trgm;
+DROP EXTENSION IF EXISTS pg_trgm;
+
+DROP INDEX IF EXISTS idx_highlights_search_vector;
+ALTER TABLE highlights DROP COLUMN search_vector;
+ALTER TABLE highlights ADD COLUMN search_vector tsvector
+  GENERATED ALWAYS AS (immutable_to_tsvector(text || ' ' || note)) STORED;
+CREATE INDEX idx_highlights_search_vector ON highlights USING GIN(search_vector);
+ALTER TABLE highlights DROP COLUMN search_config;
+
+DROP INDEX IF EXISTS search_vector_idx;
+ALTER TABLE library_contents DROP COLUMN search_vector;
+ALTER TABLE library_contents ADD COLUMN search_vector tsvector
+  GENERATED ALWAYS AS (immutable_to_tsvector(title || ' ' || excerpt || ' ' || content || ' ' || note)) STORED;
+CREATE INDEX search_vector_idx ON library_contents USING GIN(search_vector);
+ALTER TABLE library_contents DROP COLUMN search_config;
+
+DROP FUNCTION IF EXISTS search_config(TEXT);
@@ -0,0 +1,67 @@
+-- Full-text search in the language of each bookmark. The text search configuration of a
+-- bookmark comes from the language of its page, and simple, which only lowercases the words, is
+-- used for the languages Postgres can't stem and the pages without a language.
+CREATE FUNCTION search_config(lang TEXT) RETURNS regconfig AS $$
+    SELECT (CASE split_part(replace(lower(COALESCE(lang, '')), '_', '-'), '-', 1)
+        WHEN 'ar' THEN 'arabic'
+        WHEN 'ca' THEN 'catalan'
+        WHEN 'da' THEN 'danish'
+        WHEN 'de' THEN 'german'
+        WHEN 'el' THEN 'greek'
+        WHEN 'en' THEN 'english'
+        WHEN 'es' THEN 'spanish'
+        WHEN 'eu' THEN 'basque'
+        WHEN 'fi' THEN 'finnish'
+        WHEN 'fr' THEN 'french'
+        WHEN 'ga' THEN 'irish'
+        WHEN 'hi' THEN 'hindi'
+        WHEN 'hu' THEN 'hungarian'
+        WHEN 'hy' THEN 'armenian'
+        WHEN 'id' THEN 'indonesian'
+        WHEN 'it' THEN 'italian'
+        WHEN 'lt' THEN 'lithuanian'
+        WHEN 'nb' THEN 'norwegian'
+        WHEN 'ne' THEN 'nepali'
+        WHEN 'nl' THEN 'dutch'
+        WHEN 'nn' THEN 'norwegian'
+        WHEN 'no' THEN 'norwegian'
+        WHEN 'pt' THEN 'portuguese'
+        WHEN 'ro' THEN 'romanian'
+        WHEN 'ru' THEN 'russian'
+        WHEN 'sr' THEN 'serbian'
+        WHEN 'sv' THEN 'swedish'
+        WHEN 'ta' THEN 'tamil'
+        WHEN 'tr' THEN 'turkish'
+        WHEN 'yi' THEN 'yiddish'
+        ELSE 'simple'
+    END)::regconfig
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE library_contents ADD COLUMN search_config regconfig NOT NULL DEFAULT 'simple';
+ALTER TABLE highlights ADD COLUMN search_config regconfig NOT NULL DEFAULT 'simple';
+
+-- Rebuild the search vectors with the configuration of their bookmark
+DROP INDEX IF EXISTS search_vector_idx;
+ALTER TABLE library_contents DROP COLUMN search_vector;
+UPDATE library_contents lc SET search_config = search_config(li.article_lang)
+FROM library_items li
+WHERE li.id = lc.id;
+ALTER TABLE library_contents ADD COLUMN search_vector tsvector
+  GENERATED ALWAYS AS (to_tsvector(search_config, title || ' ' || excerpt || ' ' || content || ' ' || note)) STORED;
+CREATE INDEX search_vector_idx ON library_contents USING GIN(search_vector);
+
+DROP INDEX IF EXISTS idx_highlights_search_vector;
+ALTER TABLE highlights DROP COLUMN search_vector;
+UPDATE highlights h SET search_config = lc.search_config
+FROM library_contents lc
+WHERE lc.id = h.library_item_id;
+ALTER TABLE highlights ADD COLUMN search_vector tsvector
+  GENERATED ALWAYS AS (to_tsvector(search_config, text || ' ' || note)) STORED;
+CREATE INDEX idx_highlights_search_vector ON highlights USING GIN(search_vector);
+
+-- Chinese, Japanese, Thai and the other scripts without spaces between the words have no words
+-- to index, so their searches match parts of the text with these trigram indexes
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX idx_library_items_title_trgm ON library_items USING GIN(title gin_trgm_ops);
+CREATE INDEX idx_library_contents_content_trgm ON library_contents USING GIN(content gin_trgm_ops);
+CREATE INDEX idx_library_contents_note_trgm ON library_contents USING GIN(note gin_trgm_ops);
@@ -0,0 +1,75 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// maxUnzippedSize is the most that is read from the entries of an EPUB or DOCX file, so a
+// small archive can't expand to fill the memory
+const maxUnzippedSize = 200 << 20
+
+// archive reads the entries of the zip container of EPUB and DOCX files
+type archive struct {
+	files     map[string]*zip.File
+	remaining int64
+}
+
+func openArchive(data []byte) (*archive, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	a := &archive{files: make(map[string]*zip.File, len(r.File)), remaining: maxUnzippedSize}
+	for _, f := range r.File {
+		a.files[f.Name] = f
+	}
+	return a, nil
+}
+
+func (a *archive) has(name string) bool {
+	_, ok := a.files[name]
+	return ok
+}
+
+// read returns the content of the entry
+func (a *archive) read(name string) ([]byte, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in archive", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, a.remaining+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if int64(len(data)) > a.remaining {
+		return nil, fmt.Errorf("archive content is larger than %d bytes", maxUnzippedSize)
+	}
+	a.remaining -= int64(len(data))
+	return data, nil
+}
+
+// zipType tells if the data is an EPUB or DOCX file, from the entries of the archive
+func zipType(data []byte) string {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return ""
+	}
+	a, err := openArchive(data)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case a.has("META-INF/container.xml"):
+		return ContentTypeEPUB
+	case a.has("word/document.xml"):
+		return ContentTypeDOCX
+	}
+	return ""
+}
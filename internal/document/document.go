@@ -0,0 +1,189 @@
+// Package document extracts the text of files saved as bookmarks, such as PDF papers, either
+// fetched from a link or uploaded by the user. Markdown, HTML, EPUB and DOCX files can be
+// uploaded too.
+package document
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/arashthr/pensive/internal/errors"
+)
+
+const (
+	MaxFileSize = 50 << 20 // Larger files are rejected
+	linkPrefix  = "urn:sha256:"
+)
+
+// Supported document types
+const (
+	ContentTypePDF      = "application/pdf"
+	ContentTypeMarkdown = "text/markdown"
+	ContentTypeHTML     = "text/html"
+	ContentTypeEPUB     = "application/epub+zip"
+	ContentTypeDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+// knownTypes maps the declared content types and the file extensions to the supported types
+var knownTypes = map[string]string{
+	ContentTypePDF:          ContentTypePDF,
+	ContentTypeMarkdown:     ContentTypeMarkdown,
+	"text/x-markdown":       ContentTypeMarkdown,
+	ContentTypeHTML:         ContentTypeHTML,
+	"application/xhtml+xml": ContentTypeHTML,
+	ContentTypeEPUB:         ContentTypeEPUB,
+	ContentTypeDOCX:         ContentTypeDOCX,
+	".pdf":                  ContentTypePDF,
+	".md":                   ContentTypeMarkdown,
+	".markdown":             ContentTypeMarkdown,
+	".html":                 ContentTypeHTML,
+	".htm":                  ContentTypeHTML,
+	".xhtml":                ContentTypeHTML,
+	".epub":                 ContentTypeEPUB,
+	".docx":                 ContentTypeDOCX,
+}
+
+// File is a document file with its name and type
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Document is the text of a file with its metadata
+type Document struct {
+	Title         string
+	Author        string
+	PublishedTime *time.Time
+	Language      string
+	Pages         int
+	Text          string // Paragraphs separated by blank lines
+	Content       string // HTML of the document, rendered from the text when empty
+}
+
+// Extract returns the document in the file. The type of the file is detected from its content
+// when the declared type is missing or generic.
+func Extract(file File) (*Document, error) {
+	var doc *Document
+	var err error
+	switch DetectType(file) {
+	case ContentTypePDF:
+		doc, err = PDF(file.Data)
+	case ContentTypeMarkdown:
+		doc, err = Markdown(file.Data)
+	case ContentTypeHTML:
+		doc, err = HTML(file.Data, file.ContentType)
+	case ContentTypeEPUB:
+		doc, err = EPUB(file.Data)
+	case ContentTypeDOCX:
+		doc, err = DOCX(file.Data)
+	default:
+		return nil, fmt.Errorf("%w: %s", errors.ErrUnsupportedFile, file.ContentType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(doc.Text) == "" {
+		return nil, errors.ErrNoText
+	}
+	if doc.Title == "" && doc.Content != "" {
+		doc.Title = headingTitle(doc.Content)
+	}
+	if doc.Title == "" {
+		doc.Title = strings.TrimSuffix(file.Name, path.Ext(file.Name))
+	}
+	return doc, nil
+}
+
+// DetectType returns the type of the file. Browsers often send documents, like Markdown
+// files, as generic types, so the extension and then the content are used when the declared
+// type is not a supported one.
+func DetectType(file File) string {
+	contentType, _, _ := mime.ParseMediaType(file.ContentType)
+	if IsPDF(contentType, file.Data) {
+		return ContentTypePDF
+	}
+	if t, ok := knownTypes[contentType]; ok {
+		return t
+	}
+	if t, ok := knownTypes[strings.ToLower(path.Ext(file.Name))]; ok {
+		return t
+	}
+	if t := zipType(file.Data); t != "" {
+		return t
+	}
+	if sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(file.Data)); sniffed == ContentTypeHTML {
+		return ContentTypeHTML
+	}
+	return contentType
+}
+
+// Extension returns the file extension of the supported type, empty for the other types
+func Extension(contentType string) string {
+	switch contentType {
+	case ContentTypePDF:
+		return ".pdf"
+	case ContentTypeMarkdown:
+		return ".md"
+	case ContentTypeHTML:
+		return ".html"
+	case ContentTypeEPUB:
+		return ".epub"
+	case ContentTypeDOCX:
+		return ".docx"
+	}
+	return ""
+}
+
+// IsPDF tells if the response is a PDF file, from its type or its first bytes
+func IsPDF(contentType string, data []byte) bool {
+	contentType, _, _ = mime.ParseMediaType(contentType)
+	// The header may come after some garbage in the first kilobyte
+	return contentType == ContentTypePDF || bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-"))
+}
+
+// Link is the stable link of an uploaded file. The same file always gets the same link, so it
+// is saved once.
+func Link(data []byte) string {
+	sum := sha256.Sum256(data)
+	return linkPrefix + hex.EncodeToString(sum[:])
+}
+
+// IsLink tells if the link is the link of an uploaded file rather than a web page
+func IsLink(link string) bool {
+	return strings.HasPrefix(link, linkPrefix)
+}
+
+// HTML returns the content of the document, or renders the text as paragraphs
+func (d *Document) HTML() string {
+	if d.Content != "" {
+		return d.Content
+	}
+	var b strings.Builder
+	for _, paragraph := range strings.Split(d.Text, "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(paragraph))
+		}
+	}
+	return b.String()
+}
+
+// metadataDate reads the dates of the EPUB, DOCX and front matter metadata, from a full
+// timestamp down to a year
+func metadataDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", time.DateTime, time.DateOnly, "2006-01", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
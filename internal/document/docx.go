@@ -0,0 +1,204 @@
+package document
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/arashthr/pensive/internal/extractor"
+)
+
+var headingStyleRe = regexp.MustCompile(`^(?i)heading ?([1-9])$`)
+
+// docxCore is docProps/core.xml, the metadata of the document
+type docxCore struct {
+	Title    string `xml:"title"`
+	Creator  string `xml:"creator"`
+	Created  string `xml:"created"`
+	Language string `xml:"language"`
+}
+
+// docxStyles is word/styles.xml. The names of the built-in styles are in English whatever the
+// language of Word, unlike their ids.
+type docxStyles struct {
+	Styles []struct {
+		Id   string `xml:"styleId,attr"`
+		Name struct {
+			Val string `xml:"val,attr"`
+		} `xml:"name"`
+	} `xml:"style"`
+}
+
+// docxParagraph is a paragraph of the body with its style id
+type docxParagraph struct {
+	text  strings.Builder
+	style string
+	list  bool
+}
+
+// DOCX reads the paragraphs of a Word document. Headings and list items keep their structure,
+// headers, footers and comments are left out.
+func DOCX(data []byte) (*Document, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{}
+	if core, err := a.read("docProps/core.xml"); err == nil {
+		var meta docxCore
+		if err := xml.Unmarshal(core, &meta); err == nil {
+			doc.Title = strings.TrimSpace(meta.Title)
+			doc.Author = strings.TrimSpace(meta.Creator)
+			doc.Language = strings.TrimSpace(meta.Language)
+			if published, ok := metadataDate(meta.Created); ok {
+				doc.PublishedTime = &published
+			}
+		}
+	}
+	levels := map[string]int{}
+	if stylesXML, err := a.read("word/styles.xml"); err == nil {
+		var styles docxStyles
+		if err := xml.Unmarshal(stylesXML, &styles); err == nil {
+			for _, s := range styles.Styles {
+				if level := headingLevel(s.Name.Val); level > 0 {
+					levels[s.Id] = level
+				}
+			}
+		}
+	}
+	body, err := a.read("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	paragraphs, err := docxParagraphs(body)
+	if err != nil {
+		return nil, fmt.Errorf("parse docx: %w", err)
+	}
+
+	var content strings.Builder
+	inList := false
+	for _, p := range paragraphs {
+		text := strings.TrimSpace(p.text.String())
+		if text == "" {
+			continue
+		}
+		if inList && !p.list {
+			content.WriteString("</ul>\n")
+		}
+		if !inList && p.list {
+			content.WriteString("<ul>\n")
+		}
+		inList = p.list
+		level, ok := levels[p.style]
+		if !ok {
+			level = headingLevel(p.style)
+		}
+		switch {
+		case p.list:
+			fmt.Fprintf(&content, "<li>%s</li>\n", html.EscapeString(text))
+		case level > 0:
+			fmt.Fprintf(&content, "<h%d>%s</h%d>\n", level, html.EscapeString(text), level)
+		default:
+			fmt.Fprintf(&content, "<p>%s</p>\n", html.EscapeString(text))
+		}
+	}
+	if inList {
+		content.WriteString("</ul>\n")
+	}
+	doc.Content = content.String()
+	doc.Text = extractor.Text(doc.Content)
+	return doc, nil
+}
+
+// docxParagraphs reads the paragraphs of word/document.xml in order. The paragraphs of text
+// boxes come before the paragraph they are anchored in.
+func docxParagraphs(body []byte) ([]*docxParagraph, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var paragraphs, open []*docxParagraph
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return paragraphs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var current *docxParagraph
+		if len(open) > 0 {
+			current = open[len(open)-1]
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				open = append(open, &docxParagraph{})
+			case "pStyle":
+				if current != nil {
+					current.style = attrValue(t, "val")
+				}
+			case "numPr":
+				if current != nil {
+					current.list = true
+				}
+			case "t":
+				inText = true
+			case "tab":
+				if current != nil {
+					current.text.WriteString("\t")
+				}
+			case "br", "cr":
+				if current != nil {
+					current.text.WriteString(" ")
+				}
+			case "Fallback":
+				// Alternate content repeats what the preferred choice already has
+				if err := decoder.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if current != nil {
+					paragraphs = append(paragraphs, current)
+					open = open[:len(open)-1]
+				}
+			}
+		case xml.CharData:
+			if inText && current != nil {
+				current.text.Write(t)
+			}
+		}
+	}
+}
+
+// headingLevel returns the level of the heading style, like "heading 2" or "Heading2", and 0
+// for the other styles. The title is a top-level heading.
+func headingLevel(style string) int {
+	if strings.EqualFold(style, "title") {
+		return 1
+	}
+	m := headingStyleRe.FindStringSubmatch(style)
+	if m == nil {
+		return 0
+	}
+	level, _ := strconv.Atoi(m[1])
+	return min(level, 6)
+}
+
+func attrValue(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package document
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/arashthr/pensive/internal/extractor"
+	"golang.org/x/net/html"
+)
+
+// epubContainer is META-INF/container.xml, it points to the package document
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the package document (OPF) with the metadata of the book and the order of
+// its chapters
+type epubPackage struct {
+	Metadata struct {
+		Title    []string `xml:"title"`
+		Creator  []string `xml:"creator"`
+		Date     []string `xml:"date"`
+		Language []string `xml:"language"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		Id         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IdRef  string `xml:"idref,attr"`
+		Linear string `xml:"linear,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// EPUB reads the chapters of the book in reading order. The table of contents and the items
+// outside the reading order, like footnote pages, are left out.
+func EPUB(data []byte) (*Document, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return nil, err
+	}
+	containerXML, err := a.read("META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	var container epubContainer
+	if err := xml.Unmarshal(containerXML, &container); err != nil {
+		return nil, fmt.Errorf("parse epub container: %w", err)
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, fmt.Errorf("epub has no package document")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	opf, err := a.read(opfPath)
+	if err != nil {
+		return nil, err
+	}
+	var pkg epubPackage
+	if err := xml.Unmarshal(opf, &pkg); err != nil {
+		return nil, fmt.Errorf("parse epub package: %w", err)
+	}
+
+	doc := &Document{
+		Title:    first(pkg.Metadata.Title),
+		Author:   strings.Join(pkg.Metadata.Creator, ", "),
+		Language: first(pkg.Metadata.Language),
+	}
+	if published, ok := metadataDate(first(pkg.Metadata.Date)); ok {
+		doc.PublishedTime = &published
+	}
+
+	items := make(map[string]int, len(pkg.Manifest))
+	for i, item := range pkg.Manifest {
+		items[item.Id] = i
+	}
+	var content strings.Builder
+	for _, ref := range pkg.Spine {
+		i, ok := items[ref.IdRef]
+		if !ok || ref.Linear == "no" {
+			continue
+		}
+		item := pkg.Manifest[i]
+		if item.MediaType != "application/xhtml+xml" && item.MediaType != ContentTypeHTML || strings.Contains(item.Properties, "nav") {
+			continue
+		}
+		// The links in the package are relative to it and may be escaped
+		href, err := url.PathUnescape(strings.SplitN(item.Href, "#", 2)[0])
+		if err != nil {
+			href = item.Href
+		}
+		chapter, err := a.read(path.Join(path.Dir(opfPath), href))
+		if err != nil {
+			return nil, err
+		}
+		body, err := chapterBody(chapter)
+		if err != nil {
+			return nil, fmt.Errorf("parse epub chapter %s: %w", href, err)
+		}
+		fmt.Fprintf(&content, "<section>%s</section>\n", body)
+	}
+	doc.Content = content.String()
+	doc.Text = extractor.Text(doc.Content)
+	return doc, nil
+}
+
+// chapterBody returns the HTML inside the <body> of a chapter
+func chapterBody(chapter []byte) (string, error) {
+	root, err := html.Parse(bytes.NewReader(chapter))
+	if err != nil {
+		return "", err
+	}
+	var find func(n *html.Node) *html.Node
+	find = func(n *html.Node) *html.Node {
+		if n.Type == html.ElementNode && n.Data == "body" {
+			return n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if found := find(c); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	body := find(root)
+	if body == nil {
+		return "", nil
+	}
+	var b bytes.Buffer
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+func first(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/arashthr/pensive/internal/extractor"
+	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// HTML extracts the article of an HTML file, like a page saved from the browser. The charset
+// is taken from the content type, or from the page itself.
+func HTML(data []byte, contentType string) (*Document, error) {
+	reader, err := charset.NewReader(bytes.NewReader(data), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("decode html: %w", err)
+	}
+	page, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("decode html: %w", err)
+	}
+
+	// Without a link, the relative links of the page stay relative
+	article, err := readability.FromReader(bytes.NewReader(page), &url.URL{})
+	if err != nil || strings.TrimSpace(article.TextContent) == "" {
+		// Short documents may have nothing readability considers an article
+		return &Document{Title: article.Title, Language: article.Language, Text: extractor.Text(string(page))}, nil
+	}
+	doc := &Document{
+		Title:         article.Title,
+		Author:        article.Byline,
+		Language:      article.Language,
+		PublishedTime: article.PublishedTime,
+		Content:       article.Content,
+		Text:          extractor.Text(article.Content),
+	}
+	return doc, nil
+}
+
+// headingTitle returns the text of the first <h1> of the content
+func headingTitle(content string) string {
+	root, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	var find func(n *html.Node) *html.Node
+	find = func(n *html.Node) *html.Node {
+		if n.Type == html.ElementNode && n.Data == "h1" {
+			return n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if found := find(c); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	h1 := find(root)
+	if h1 == nil {
+		return ""
+	}
+	var b strings.Builder
+	var text func(n *html.Node)
+	text = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			text(c)
+		}
+	}
+	text(h1)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,63 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/arashthr/pensive/internal/extractor"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdown renders GitHub flavored Markdown. Raw HTML in the file is left out.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM, extension.Footnote))
+
+// Markdown renders the Markdown file. The title, author, date and language are read from the
+// YAML front matter, when the file has one.
+func Markdown(data []byte) (*Document, error) {
+	doc := &Document{}
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+	body := frontMatter(text, doc)
+
+	var b bytes.Buffer
+	if err := markdown.Convert([]byte(body), &b); err != nil {
+		return nil, fmt.Errorf("render markdown: %w", err)
+	}
+	doc.Content = b.String()
+	doc.Text = extractor.Text(doc.Content)
+	return doc, nil
+}
+
+// frontMatter reads the metadata in the front matter into the document and returns the rest of
+// the file. Only the top-level "key: value" lines are read, which is all the metadata needs.
+func frontMatter(text string, doc *Document) string {
+	if !strings.HasPrefix(text, "---\n") {
+		return text
+	}
+	// The newline is kept so an empty front matter also matches
+	header, body, ok := strings.Cut(text[3:], "\n---\n")
+	if !ok {
+		return text
+	}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			doc.Title = value
+		case "author":
+			doc.Author = value
+		case "date":
+			if published, ok := metadataDate(value); ok {
+				doc.PublishedTime = &published
+			}
+		case "lang", "language":
+			doc.Language = value
+		}
+	}
+	return body
+}
@@ -0,0 +1,255 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	maxPDFPages = 1000
+	// Glyphs further apart than this part of the font size are separate words
+	wordGap = 0.15
+	// Lines further apart than this many times the font size start a new paragraph
+	paragraphGap = 1.6
+)
+
+var digitsRe = regexp.MustCompile(`\d+`)
+
+// ligatures are split back into letters, so the words can be searched
+var ligatures = strings.NewReplacer("ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl", "ﬅ", "st", "ﬆ", "st")
+
+// line is a line of text on a page, at the height y from the bottom of the page
+type line struct {
+	text     string
+	y        float64
+	fontSize float64
+}
+
+// PDF extracts the text and metadata of a PDF file. The text is rebuilt from the position of
+// the glyphs, with running headers, footers and page numbers removed.
+func PDF(data []byte) (doc *Document, err error) {
+	// The reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("read pdf: %v", r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open pdf: %w", err)
+	}
+
+	doc = &Document{Pages: r.NumPage()}
+	info := r.Trailer().Key("Info")
+	doc.Title = cleanMetadata(info.Key("Title").Text())
+	doc.Author = cleanMetadata(info.Key("Author").Text())
+	if published, ok := pdfDate(info.Key("CreationDate").Text()); ok {
+		doc.PublishedTime = &published
+	}
+
+	var pages [][]line
+	for i := 1; i <= min(doc.Pages, maxPDFPages); i++ {
+		pages = append(pages, pageLines(r.Page(i)))
+	}
+	if doc.Title == "" && len(pages) > 0 {
+		doc.Title = titleLine(pages[0])
+	}
+	running := runningLines(pages)
+	var paragraphs []string
+	for _, lines := range pages {
+		paragraphs = append(paragraphs, pageParagraphs(lines, running)...)
+	}
+	doc.Text = strings.Join(paragraphs, "\n\n")
+	return doc, nil
+}
+
+// pageLines groups the glyphs of the page into lines, in the order they are drawn
+func pageLines(page pdf.Page) []line {
+	if page.V.IsNull() {
+		return nil
+	}
+	var lines []line
+	var b strings.Builder
+	var current line
+	end := 0.0 // Where the last glyph ended
+	flush := func() {
+		if text := strings.Join(strings.Fields(ligatures.Replace(b.String())), " "); text != "" {
+			current.text = text
+			lines = append(lines, current)
+		}
+		b.Reset()
+	}
+	for _, glyph := range page.Content().Text {
+		if isLineFeedGlyph(glyph) {
+			continue
+		}
+		size := math.Max(glyph.FontSize, 1)
+		switch {
+		case b.Len() == 0:
+			current = line{y: glyph.Y, fontSize: size}
+		case math.Abs(glyph.Y-current.y) > size/2 || glyph.X < end-size:
+			// A new line, or text placed back to the left like in the next column
+			flush()
+			current = line{y: glyph.Y, fontSize: size}
+		case glyph.X-end > size*wordGap:
+			b.WriteByte(' ')
+		}
+		if strings.TrimSpace(glyph.S) == "" {
+			b.WriteByte(' ')
+		} else {
+			b.WriteString(glyph.S)
+		}
+		end = glyph.X + glyph.W
+		current.fontSize = math.Max(current.fontSize, size)
+	}
+	flush()
+	return lines
+}
+
+// titleLine returns the line in the largest font of the page, when it stands out from the text
+func titleLine(lines []line) string {
+	sizes := map[float64]int{}
+	var title *line
+	for i := range lines {
+		l := &lines[i]
+		sizes[math.Round(l.fontSize)] += len(l.text)
+		if title == nil || l.fontSize > title.fontSize {
+			title = l
+		}
+	}
+	// The size most of the text is in
+	body, count := 0.0, 0
+	for size, n := range sizes {
+		if n > count {
+			body, count = size, n
+		}
+	}
+	if title == nil || title.fontSize < body*1.2 || utf8.RuneCountInString(title.text) > 200 {
+		return ""
+	}
+	return title.text
+}
+
+// isLineFeedGlyph tells if the glyph is the line feed the reader adds after each TJ operator.
+// It goes through the encoding of the font like the text, so it is not always "\n".
+func isLineFeedGlyph(glyph pdf.Text) bool {
+	if glyph.W != 0 {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(glyph.S)
+	return unicode.IsControl(r) || r == utf8.RuneError || r == 'Ω'
+}
+
+// runningLines finds the headers and footers repeated on most pages. Page numbers are ignored
+// when comparing them.
+func runningLines(pages [][]line) map[string]bool {
+	if len(pages) < 3 {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, lines := range pages {
+		seen := map[string]bool{}
+		for _, i := range []int{0, 1, len(lines) - 2, len(lines) - 1} {
+			if i < 0 || i >= len(lines) {
+				continue
+			}
+			key := runningKey(lines[i].text)
+			if !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+	running := map[string]bool{}
+	for key, count := range counts {
+		if count > len(pages)/2 {
+			running[key] = true
+		}
+	}
+	return running
+}
+
+func runningKey(text string) string {
+	return digitsRe.ReplaceAllString(strings.ToLower(text), "#")
+}
+
+// pageParagraphs joins the lines of the page into paragraphs. A paragraph ends at a larger
+// gap between the lines or a change of the font size, like after a heading.
+func pageParagraphs(lines []line, running map[string]bool) []string {
+	var paragraphs []string
+	paragraph := ""
+	var previous *line
+	for i := range lines {
+		l := &lines[i]
+		edge := i < 2 || i >= len(lines)-2
+		if (edge && running[runningKey(l.text)]) || isPageNumber(l.text) {
+			continue
+		}
+		if previous != nil {
+			gap := previous.y - l.y
+			if gap <= 0 || gap > previous.fontSize*paragraphGap || math.Abs(previous.fontSize-l.fontSize) > 1 {
+				paragraphs = append(paragraphs, paragraph)
+				paragraph = ""
+			}
+		}
+		paragraph = joinLine(paragraph, l.text)
+		previous = l
+	}
+	if paragraph != "" {
+		paragraphs = append(paragraphs, paragraph)
+	}
+	return paragraphs
+}
+
+// joinLine appends the line to the paragraph, joining the words hyphenated at the end of the line
+func joinLine(paragraph, text string) string {
+	if paragraph == "" {
+		return text
+	}
+	first, _ := utf8.DecodeRuneInString(text)
+	if strings.HasSuffix(paragraph, "-") && unicode.IsLower(first) {
+		return strings.TrimSuffix(paragraph, "-") + text
+	}
+	return paragraph + " " + text
+}
+
+func isPageNumber(text string) bool {
+	text = strings.Trim(strings.ToLower(text), "-– ")
+	text = strings.TrimPrefix(text, "page ")
+	return text != "" && strings.Trim(text, "0123456789 of/") == ""
+}
+
+func cleanMetadata(s string) string {
+	s = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s))
+	// Placeholders left by the tools that made the file
+	switch strings.ToLower(s) {
+	case "untitled", "unknown", "microsoft word":
+		return ""
+	}
+	return s
+}
+
+// pdfDate reads a date like D:20240102150405+01'00'. The parts after the year are optional.
+func pdfDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "D:")
+	s = strings.ReplaceAll(s, "'", "")
+	for _, layout := range []string{"20060102150405Z0700", "20060102150405Z", "20060102150405", "200601021504", "20060102", "200601", "2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -7,6 +7,43 @@ var (
 	ErrEmailTaken = errors.New("email address is already in use")
 	ErrInvalidUrl = errors.New("controller: url is invalid")
 
+	// Tags and collections
+	ErrInvalidLabel = errors.New("invalid tag or collection name")
+
+	// Highlights
+	ErrQuoteNotFound    = errors.New("highlighted text not found in the bookmark content")
+	ErrHighlightTooLong = errors.New("highlight or note is too long")
+
+	// Notes
+	ErrNoteTooLong = errors.New("note is too long")
+
+	// Content refresh
+	ErrPageInaccessible = errors.New("page content inaccessible")
+	ErrRefreshTooSoon   = errors.New("content was refreshed moments ago")
+
+	// Fetching pages
+	ErrBlockedAddress     = errors.New("link points to a private or local address")
+	ErrTooManyRedirects   = errors.New("too many redirects")
+	ErrPageTooLarge       = errors.New("page is too large")
+	ErrUnsupportedContent = errors.New("unsupported content type")
+	ErrFetchTimeout       = errors.New("page took too long to respond")
+
+	// Fetch profiles
+	ErrInvalidFetchProfile = errors.New("invalid fetch profile")
+	ErrCookiesDisabled     = errors.New("cookies can't be saved, no encryption key is configured")
+
+	// Documents
+	ErrUnsupportedFile = errors.New("unsupported file type")
+	ErrFileTooLarge    = errors.New("file is too large")
+	ErrNoText          = errors.New("file has no text, it may be a scanned document")
+
+	// AI processing
+	ErrAIUnavailable = errors.New("AI processing is not available")
+
+	// Search
+	ErrInvalidSearchQuery  = errors.New("invalid search query")
+	ErrInvalidSearchCursor = errors.New("invalid search cursor")
+
 	// Stripe
 	ErrNoStripeCustomer = errors.New("stripe customer not found")
 
@@ -3,6 +3,7 @@ package errors
 import "errors"
 
 var (
-	Is = errors.Is
-	As = errors.As
+	Is   = errors.Is
+	As   = errors.As
+	Join = errors.Join
 )
@@ -0,0 +1,102 @@
+package extractor
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/go-readability"
+)
+
+// ArXiv extracts the abstract of arXiv papers, from the abstract page or the PDF link
+type ArXiv struct{}
+
+type arXivFeed struct {
+	Entries []struct {
+		Id        string    `xml:"id"`
+		Title     string    `xml:"title"`
+		Summary   string    `xml:"summary"`
+		Published time.Time `xml:"published"`
+		Authors   []struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Links []struct {
+			Href  string `xml:"href,attr"`
+			Title string `xml:"title,attr"`
+		} `xml:"link"`
+		Category struct {
+			Term string `xml:"term,attr"`
+		} `xml:"primary_category"`
+	} `xml:"entry"`
+}
+
+func (ArXiv) Name() string { return "arxiv" }
+
+func (ArXiv) Match(u *url.URL) bool {
+	return hostIs(u, "arxiv.org") && arXivId(u) != ""
+}
+
+func (ArXiv) Extract(ctx context.Context, u *url.URL, fetch Fetch) (Result, error) {
+	link := "https://export.arxiv.org/api/query?id_list=" + url.QueryEscape(arXivId(u))
+	body, err := get(ctx, fetch, link, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("get arxiv paper: %w", err)
+	}
+	article, err := parseArXiv(body)
+	return Result{Article: article}, err
+}
+
+// arXivId returns the id in /abs/{id} or /pdf/{id}, old ids like hep-th/9901001 have a slash
+func arXivId(u *url.URL) string {
+	segments := pathSegments(u)
+	if len(segments) < 2 || (segments[0] != "abs" && segments[0] != "pdf") {
+		return ""
+	}
+	return strings.TrimSuffix(strings.Join(segments[1:], "/"), ".pdf")
+}
+
+func parseArXiv(body []byte) (readability.Article, error) {
+	var feed arXivFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return readability.Article{}, fmt.Errorf("parse arxiv feed: %w", err)
+	}
+	// Unknown ids come back as an entry without an id
+	if len(feed.Entries) == 0 || feed.Entries[0].Id == "" {
+		return readability.Article{}, fmt.Errorf("arxiv paper not found")
+	}
+	entry := feed.Entries[0]
+	title := strings.Join(strings.Fields(entry.Title), " ")
+	summary := strings.Join(strings.Fields(entry.Summary), " ")
+
+	var authors []string
+	for _, a := range entry.Authors {
+		authors = append(authors, a.Name)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p>%s</p>\n", escape(strings.Join(authors, ", ")))
+	if entry.Category.Term != "" {
+		fmt.Fprintf(&b, "<p>%s</p>\n", escape(entry.Category.Term))
+	}
+	fmt.Fprintf(&b, "<h2>Abstract</h2>\n<p>%s</p>\n", escape(summary))
+	for _, l := range entry.Links {
+		if l.Title == "pdf" {
+			fmt.Fprintf(&b, "<p><a href=\"%s\">PDF</a></p>\n", escape(l.Href))
+		}
+	}
+
+	article := readability.Article{
+		Title:    title,
+		Byline:   strings.Join(authors, ", "),
+		Content:  b.String(),
+		Excerpt:  excerpt(summary),
+		SiteName: "arXiv",
+		Language: "en",
+	}
+	if !entry.Published.IsZero() {
+		article.PublishedTime = &entry.Published
+	}
+	return article, nil
+}
@@ -0,0 +1,176 @@
+// Package extractor gets the content of sites that generic readability handles poorly, like
+// discussion threads and code hosts, from their APIs
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arashthr/pensive/internal/fetcher"
+	"github.com/arashthr/pensive/internal/transcript"
+	"github.com/go-shiori/go-readability"
+)
+
+const (
+	MaxResponseSize = 10 << 20 // Larger API responses are cut
+	fetchTimeout    = 20 * time.Second
+	excerptLength   = 200
+	userAgent       = "Mozilla/5.0 (compatible; Pensive/1.0; +https://getpensive.com)"
+)
+
+// Extractor gets the article of the links it matches
+type Extractor interface {
+	// Name identifies the extractor in the logs
+	Name() string
+	// Match tells if the extractor handles the link
+	Match(u *url.URL) bool
+	// Extract builds the article of the link. Only Title and Content (HTML) are required, the
+	// text content and excerpt are derived from the content when they are empty.
+	Extract(ctx context.Context, u *url.URL, fetch Fetch) (Result, error)
+}
+
+// Result is the article of a link. For videos it has the transcript, which is also the text
+// content of the article.
+type Result struct {
+	readability.Article
+	Transcript *transcript.Transcript
+}
+
+// Fetch performs the request and returns the body of a successful response
+type Fetch func(req *http.Request) ([]byte, error)
+
+// Registry picks the extractor of a link. The first extractor that matches the link is used.
+type Registry struct {
+	Extractors []Extractor
+	Fetch      Fetch // HTTPFetch when nil
+}
+
+// Default has the built-in extractors
+var Default = &Registry{
+	Extractors: []Extractor{
+		HackerNews{},
+		Reddit{},
+		GitHub{},
+		ArXiv{},
+		StackOverflow{},
+		YouTube{},
+	},
+}
+
+// Find returns the extractor of the link, or nil when the generic extraction should be used.
+// The nil Registry uses the Default extractors.
+func (r *Registry) Find(u *url.URL) Extractor {
+	if r == nil {
+		r = Default
+	}
+	for _, e := range r.Extractors {
+		if e.Match(u) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Extract builds the article of the link with the extractor
+func (r *Registry) Extract(ctx context.Context, e Extractor, u *url.URL) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	result, err := e.Extract(ctx, u, r.fetch())
+	if err != nil {
+		return Result{}, fmt.Errorf("extract with %s: %w", e.Name(), err)
+	}
+	if result.TextContent == "" {
+		result.TextContent = Text(result.Content)
+	}
+	if strings.TrimSpace(result.TextContent) == "" {
+		return Result{}, fmt.Errorf("extract with %s: no content", e.Name())
+	}
+	if result.Excerpt == "" {
+		result.Excerpt = excerpt(result.TextContent)
+	}
+	return result, nil
+}
+
+func (r *Registry) fetch() Fetch {
+	if r != nil && r.Fetch != nil {
+		return r.Fetch
+	}
+	return HTTPFetch
+}
+
+// client refuses private addresses, as some links, like the captions of a page, come from
+// the pages users save
+var client = fetcher.NewClient(fetchTimeout)
+
+// HTTPFetch performs the request with a client that only reaches public addresses
+func HTTPFetch(req *http.Request) ([]byte, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("perform request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s returned status %d", req.URL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	return body, nil
+}
+
+// get fetches the link with the context of the extraction
+func get(ctx context.Context, fetch Fetch, link string, header map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return fetch(req)
+}
+
+// excerpt is the start of the text, cut at a word
+func excerpt(text string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= excerptLength {
+		return string(runes)
+	}
+	start := string(runes[:excerptLength])
+	if cut := strings.LastIndex(start, " "); cut > 0 {
+		start = start[:cut]
+	}
+	return start + "…"
+}
+
+// pathSegments returns the non-empty segments of the path
+func pathSegments(u *url.URL) []string {
+	var segments []string
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// hostIs tells if the host of the URL is the domain or one of its subdomains
+func hostIs(u *url.URL, domains ...string) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
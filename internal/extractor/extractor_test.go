@@ -0,0 +1,200 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixtureFetch answers the requests with the files of testdata, by the URL requested
+func fixtureFetch(t *testing.T, files map[string]string) Fetch {
+	t.Helper()
+	return func(req *http.Request) ([]byte, error) {
+		file, ok := files[req.URL.String()]
+		if !ok {
+			return nil, fmt.Errorf("%s returned status 404", req.URL)
+		}
+		return os.ReadFile(filepath.Join("testdata", file))
+	}
+}
+
+func readFixture(t *testing.T, file string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func mustParse(t *testing.T, link string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		link string
+		want string // Name of the extractor, empty for the generic extraction
+	}{
+		{"https://news.ycombinator.com/item?id=8863", "hackernews"},
+		{"https://news.ycombinator.com/item", ""},
+		{"https://news.ycombinator.com/news", ""},
+		{"https://www.reddit.com/r/golang/comments/abc123/what_is_your_favorite_go_proverb/", "reddit"},
+		{"https://old.reddit.com/comments/abc123", "reddit"},
+		{"https://www.reddit.com/r/golang/", ""},
+		{"https://github.com/golang/go", "github"},
+		{"https://github.com/golang/go/issues/1", ""},
+		{"https://github.com/topics/go", ""},
+		{"https://arxiv.org/abs/1706.03762", "arxiv"},
+		{"https://arxiv.org/pdf/1706.03762v7.pdf", "arxiv"},
+		{"https://arxiv.org/list/cs.CL/recent", ""},
+		{"https://stackoverflow.com/questions/37334119/how-to-delete-an-element-from-a-slice-in-golang", "stackoverflow"},
+		{"https://stackoverflow.com/q/37334119", "stackoverflow"},
+		{"https://stackoverflow.com/questions/tagged/go", ""},
+		{"https://example.com/article", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			var got string
+			if e := Default.Find(mustParse(t, tt.link)); e != nil {
+				got = e.Name()
+			}
+			if got != tt.want {
+				t.Errorf("Find(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIds(t *testing.T) {
+	tests := []struct {
+		name string
+		id   func(u *url.URL) string
+		link string
+		want string
+	}{
+		{"hackernews", func(u *url.URL) string { return fmt.Sprint(hackerNewsId(u)) }, "https://news.ycombinator.com/item?id=8863", "8863"},
+		{"hackernews invalid", func(u *url.URL) string { return fmt.Sprint(hackerNewsId(u)) }, "https://news.ycombinator.com/item?id=-1", "0"},
+		{"reddit subreddit", redditPostId, "https://www.reddit.com/r/golang/comments/abc123/title/", "abc123"},
+		{"reddit short", redditPostId, "https://www.reddit.com/comments/abc123", "abc123"},
+		{"reddit user page", redditPostId, "https://www.reddit.com/user/someone/comments/", ""},
+		{"arxiv abstract", arXivId, "https://arxiv.org/abs/1706.03762v7", "1706.03762v7"},
+		{"arxiv pdf", arXivId, "https://arxiv.org/pdf/1706.03762.pdf", "1706.03762"},
+		{"arxiv old id", arXivId, "https://arxiv.org/abs/hep-th/9901001", "hep-th/9901001"},
+		{"stackoverflow question", func(u *url.URL) string { return fmt.Sprint(stackOverflowId(u)) }, "https://stackoverflow.com/questions/37334119/slug", "37334119"},
+		{"stackoverflow share", func(u *url.URL) string { return fmt.Sprint(stackOverflowId(u)) }, "https://stackoverflow.com/q/37334119/123", "37334119"},
+		{"stackoverflow not a question", func(u *url.URL) string { return fmt.Sprint(stackOverflowId(u)) }, "https://stackoverflow.com/questions/tagged", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.id(mustParse(t, tt.link)); got != tt.want {
+				t.Errorf("id of %q = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryExtract(t *testing.T) {
+	tests := []struct {
+		link     string
+		files    map[string]string
+		title    string
+		contains []string // In the text content
+		missing  []string // Not in the text content
+	}{
+		{
+			link: "https://news.ycombinator.com/item?id=8863",
+			files: map[string]string{
+				hackerNewsAPI + "/item/8863.json": "hackernews_item_8863.json",
+				hackerNewsAPI + "/item/9224.json": "hackernews_item_9224.json",
+				hackerNewsAPI + "/item/8952.json": "hackernews_item_8952.json",
+				hackerNewsAPI + "/item/8917.json": "hackernews_item_8917.json",
+			},
+			title:    "My YC app: Dropbox - Throw away your USB drive",
+			contains: []string{"104 points by dhouston", "Top comments", "curlftpfs", "Congratulations on the launch"},
+		},
+		{
+			link: "https://www.reddit.com/r/golang/comments/abc123/what_is_your_favorite_go_proverb/",
+			files: map[string]string{
+				"https://www.reddit.com/comments/abc123.json?raw_json=1&sort=top&depth=1&limit=10": "reddit_post.json",
+			},
+			title:    "What is your favorite Go proverb?",
+			contains: []string{"Posted in r/golang by u/gopher42", "clear is better than clever", "A little copying"},
+			missing:  []string{"Please read the rules"},
+		},
+		{
+			link: "https://github.com/golang/go",
+			files: map[string]string{
+				gitHubAPI + "/repos/golang/go":        "github_repo.json",
+				gitHubAPI + "/repos/golang/go/readme": "github_readme.html",
+			},
+			title:    "golang/go",
+			contains: []string{"The Go programming language", "120000 stars", "The Go Programming Language", "open source programming language"},
+		},
+		{
+			link: "https://arxiv.org/abs/1706.03762",
+			files: map[string]string{
+				"https://export.arxiv.org/api/query?id_list=1706.03762": "arxiv_1706.03762.xml",
+			},
+			title:    "Attention Is All You Need",
+			contains: []string{"Ashish Vaswani, Noam Shazeer", "cs.CL", "the Transformer"},
+		},
+		{
+			link: "https://stackoverflow.com/questions/37334119/how-to-delete-an-element-from-a-slice-in-golang",
+			files: map[string]string{
+				stackExchangeAPI + "/questions/37334119?site=stackoverflow&filter=withbody":                                          "stackoverflow_question.json",
+				stackExchangeAPI + "/questions/37334119/answers?site=stackoverflow&filter=withbody&sort=votes&order=desc&pagesize=5": "stackoverflow_answers.json",
+			},
+			title:    "How do I remove an element from a slice in Go?",
+			contains: []string{"Asked by Gopher & Friends", "Accepted answer", "slices.Delete", "append(s[:i]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			registry := &Registry{Extractors: Default.Extractors, Fetch: fixtureFetch(t, tt.files)}
+			u := mustParse(t, tt.link)
+			e := registry.Find(u)
+			if e == nil {
+				t.Fatalf("no extractor for %q", tt.link)
+			}
+			result, err := registry.Extract(context.Background(), e, u)
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if result.Title != tt.title {
+				t.Errorf("title = %q, want %q", result.Title, tt.title)
+			}
+			if result.Excerpt == "" {
+				t.Error("excerpt is empty")
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(result.TextContent, s) {
+					t.Errorf("text content misses %q:\n%s", s, result.TextContent)
+				}
+			}
+			for _, s := range tt.missing {
+				if strings.Contains(result.TextContent, s) {
+					t.Errorf("text content has %q:\n%s", s, result.TextContent)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryExtractFailure(t *testing.T) {
+	registry := &Registry{Extractors: Default.Extractors, Fetch: fixtureFetch(t, nil)}
+	u := mustParse(t, "https://news.ycombinator.com/item?id=1")
+	if _, err := registry.Extract(context.Background(), HackerNews{}, u); err == nil {
+		t.Error("Extract succeeded without a response")
+	}
+}
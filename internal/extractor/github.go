@@ -0,0 +1,99 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/go-readability"
+)
+
+const gitHubAPI = "https://api.github.com"
+
+// gitHubPages are the first path segments of github.com that are not users or organizations
+var gitHubPages = map[string]bool{
+	"about": true, "apps": true, "collections": true, "enterprise": true, "explore": true,
+	"features": true, "marketplace": true, "orgs": true, "pricing": true, "settings": true,
+	"sponsors": true, "topics": true, "trending": true, "login": true, "join": true,
+}
+
+// GitHub extracts the README of GitHub repositories
+type GitHub struct{}
+
+type gitHubRepo struct {
+	FullName    string    `json:"full_name"`
+	Description string    `json:"description"`
+	Homepage    string    `json:"homepage"`
+	Language    string    `json:"language"`
+	Stars       int       `json:"stargazers_count"`
+	Topics      []string  `json:"topics"`
+	CreatedAt   time.Time `json:"created_at"`
+	Owner       struct {
+		Login     string `json:"login"`
+		AvatarURL string `json:"avatar_url"`
+	} `json:"owner"`
+}
+
+func (GitHub) Name() string { return "github" }
+
+// Match accepts the home page of a repository, github.com/{owner}/{repo}
+func (GitHub) Match(u *url.URL) bool {
+	segments := pathSegments(u)
+	return strings.EqualFold(u.Hostname(), "github.com") && len(segments) == 2 && !gitHubPages[strings.ToLower(segments[0])]
+}
+
+func (GitHub) Extract(ctx context.Context, u *url.URL, fetch Fetch) (Result, error) {
+	segments := pathSegments(u)
+	repoPath := url.PathEscape(segments[0]) + "/" + url.PathEscape(strings.TrimSuffix(segments[1], ".git"))
+	header := map[string]string{"X-GitHub-Api-Version": "2022-11-28"}
+
+	body, err := get(ctx, fetch, gitHubAPI+"/repos/"+repoPath, header)
+	if err != nil {
+		return Result{}, fmt.Errorf("get github repository: %w", err)
+	}
+	var repo gitHubRepo
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return Result{}, fmt.Errorf("parse github repository: %w", err)
+	}
+
+	// The README is rendered to HTML by GitHub. Without one, the description is the content.
+	header["Accept"] = "application/vnd.github.html+json"
+	readme, _ := get(ctx, fetch, gitHubAPI+"/repos/"+repoPath+"/readme", header)
+	return Result{Article: gitHubArticle(&repo, string(readme))}, nil
+}
+
+func gitHubArticle(repo *gitHubRepo, readme string) readability.Article {
+	var b strings.Builder
+	if repo.Description != "" {
+		fmt.Fprintf(&b, "<p>%s</p>\n", escape(repo.Description))
+	}
+	var facts []string
+	if repo.Language != "" {
+		facts = append(facts, escape(repo.Language))
+	}
+	facts = append(facts, fmt.Sprintf("%d stars", repo.Stars))
+	if len(repo.Topics) > 0 {
+		facts = append(facts, escape(strings.Join(repo.Topics, ", ")))
+	}
+	fmt.Fprintf(&b, "<p>%s</p>\n", strings.Join(facts, " · "))
+	if repo.Homepage != "" {
+		fmt.Fprintf(&b, "<p><a href=\"%s\">%s</a></p>\n", escape(repo.Homepage), escape(repo.Homepage))
+	}
+	b.WriteString(readme)
+
+	article := readability.Article{
+		Title:    repo.FullName,
+		Byline:   repo.Owner.Login,
+		Content:  b.String(),
+		Excerpt:  repo.Description,
+		SiteName: "GitHub",
+		Image:    repo.Owner.AvatarURL,
+	}
+	if !repo.CreatedAt.IsZero() {
+		article.PublishedTime = &repo.CreatedAt
+	}
+	return article
+}
@@ -0,0 +1,116 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/go-readability"
+)
+
+const hackerNewsComments = 10 // Top-level comments kept with the story, in the order of the site
+
+const hackerNewsAPI = "https://hacker-news.firebaseio.com/v0"
+
+// HackerNews extracts Hacker News stories with their top comments
+type HackerNews struct{}
+
+type hackerNewsItem struct {
+	Id      int    `json:"id"`
+	Type    string `json:"type"`
+	By      string `json:"by"`
+	Time    int64  `json:"time"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Text    string `json:"text"` // HTML
+	Score   int    `json:"score"`
+	Kids    []int  `json:"kids"`
+	Deleted bool   `json:"deleted"`
+	Dead    bool   `json:"dead"`
+}
+
+func (HackerNews) Name() string { return "hackernews" }
+
+func (HackerNews) Match(u *url.URL) bool {
+	return hostIs(u, "news.ycombinator.com") && u.Path == "/item" && hackerNewsId(u) != 0
+}
+
+func (HackerNews) Extract(ctx context.Context, u *url.URL, fetch Fetch) (Result, error) {
+	story, err := hackerNewsGet(ctx, fetch, hackerNewsId(u))
+	if err != nil {
+		return Result{}, err
+	}
+	if story.Deleted || story.Dead {
+		return Result{}, fmt.Errorf("hacker news item %d is deleted", story.Id)
+	}
+	var comments []hackerNewsItem
+	for _, kid := range story.Kids {
+		if len(comments) == hackerNewsComments {
+			break
+		}
+		comment, err := hackerNewsGet(ctx, fetch, kid)
+		if err != nil {
+			return Result{}, err
+		}
+		if !comment.Deleted && !comment.Dead && comment.Text != "" {
+			comments = append(comments, *comment)
+		}
+	}
+	return Result{Article: hackerNewsArticle(story, comments)}, nil
+}
+
+func hackerNewsId(u *url.URL) int {
+	id, err := strconv.Atoi(u.Query().Get("id"))
+	if err != nil || id <= 0 {
+		return 0
+	}
+	return id
+}
+
+func hackerNewsGet(ctx context.Context, fetch Fetch, id int) (*hackerNewsItem, error) {
+	body, err := get(ctx, fetch, fmt.Sprintf("%s/item/%d.json", hackerNewsAPI, id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("get hacker news item %d: %w", id, err)
+	}
+	var item *hackerNewsItem
+	if err := json.Unmarshal(body, &item); err != nil {
+		return nil, fmt.Errorf("parse hacker news item %d: %w", id, err)
+	}
+	// Missing items are returned as null
+	if item == nil {
+		return nil, fmt.Errorf("hacker news item %d not found", id)
+	}
+	return item, nil
+}
+
+func hackerNewsArticle(story *hackerNewsItem, comments []hackerNewsItem) readability.Article {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p>%d points by %s</p>\n", story.Score, escape(story.By))
+	if story.URL != "" {
+		fmt.Fprintf(&b, "<p><a href=\"%s\">%s</a></p>\n", escape(story.URL), escape(story.URL))
+	}
+	if story.Text != "" {
+		fmt.Fprintf(&b, "<div>%s</div>\n", story.Text)
+	}
+	if len(comments) > 0 {
+		b.WriteString("<h2>Top comments</h2>\n")
+		for _, c := range comments {
+			fmt.Fprintf(&b, "<blockquote><p><strong>%s</strong></p><div>%s</div></blockquote>\n", escape(c.By), c.Text)
+		}
+	}
+
+	published := time.Unix(story.Time, 0).UTC()
+	return readability.Article{
+		Title:         story.Title,
+		Byline:        story.By,
+		Content:       b.String(),
+		Excerpt:       excerpt(Text(story.Text)),
+		SiteName:      "Hacker News",
+		Language:      "en",
+		PublishedTime: &published,
+	}
+}
@@ -0,0 +1,160 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReddit(t *testing.T) {
+	article, err := parseReddit(readFixture(t, "reddit_post.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Byline != "gopher42" || article.SiteName != "Reddit" {
+		t.Errorf("byline %q, site %q", article.Byline, article.SiteName)
+	}
+	if want := time.Unix(1700000000, 0).UTC(); article.PublishedTime == nil || !article.PublishedTime.Equal(want) {
+		t.Errorf("published time = %v, want %v", article.PublishedTime, want)
+	}
+	// Stickied comments and the "more" placeholders are left out
+	if n := strings.Count(article.Content, "<blockquote>"); n != 1 {
+		t.Errorf("%d comments, want 1", n)
+	}
+
+	for _, body := range []string{`[]`, `[{"data": {"children": []}}]`, `not json`} {
+		if _, err := parseReddit([]byte(body)); err == nil {
+			t.Errorf("parseReddit(%s) succeeded", body)
+		}
+	}
+}
+
+func TestParseArXiv(t *testing.T) {
+	article, err := parseArXiv(readFixture(t, "arxiv_1706.03762.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "Attention Is All You Need" {
+		t.Errorf("title = %q", article.Title)
+	}
+	if article.Byline != "Ashish Vaswani, Noam Shazeer" {
+		t.Errorf("byline = %q", article.Byline)
+	}
+	if !strings.Contains(article.Content, `<a href="http://arxiv.org/pdf/1706.03762v7">PDF</a>`) {
+		t.Errorf("content misses the PDF link:\n%s", article.Content)
+	}
+	if !strings.HasPrefix(article.Excerpt, "The dominant sequence transduction models") {
+		t.Errorf("excerpt = %q", article.Excerpt)
+	}
+	if want := time.Date(2017, 6, 12, 17, 57, 34, 0, time.UTC); article.PublishedTime == nil || !article.PublishedTime.Equal(want) {
+		t.Errorf("published time = %v, want %v", article.PublishedTime, want)
+	}
+
+	if _, err := parseArXiv(readFixture(t, "arxiv_unknown.xml")); err == nil {
+		t.Error("parseArXiv of an unknown id succeeded")
+	}
+}
+
+func TestParseStackOverflow(t *testing.T) {
+	article, err := parseStackOverflow(readFixture(t, "stackoverflow_question.json"), readFixture(t, "stackoverflow_answers.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Byline != "Gopher & Friends" {
+		t.Errorf("byline = %q", article.Byline)
+	}
+	accepted := strings.Index(article.Content, "Accepted answer")
+	other := strings.Index(article.Content, "<h2>Answer</h2>")
+	if accepted < 0 || other < accepted {
+		t.Errorf("answers are not in the order of their votes:\n%s", article.Content)
+	}
+
+	if _, err := parseStackOverflow([]byte(`{"items": []}`), []byte(`{"items": []}`)); err == nil {
+		t.Error("parseStackOverflow without a question succeeded")
+	}
+}
+
+func TestHackerNewsArticle(t *testing.T) {
+	tests := []struct {
+		name     string
+		story    hackerNewsItem
+		comments []hackerNewsItem
+		contains []string
+		missing  []string
+	}{
+		{
+			name:     "link",
+			story:    hackerNewsItem{Title: "Show HN", By: "pg", Score: 10, URL: "https://example.com/?a=1&b=2"},
+			contains: []string{`<a href="https://example.com/?a=1&amp;b=2">`},
+			missing:  []string{"Top comments"},
+		},
+		{
+			name:     "ask with comments",
+			story:    hackerNewsItem{Title: "Ask HN", By: "<script>", Text: "<p>Question?</p>"},
+			comments: []hackerNewsItem{{By: "someone", Text: "<p>Answer</p>"}},
+			contains: []string{"by &lt;script&gt;", "<div><p>Question?</p></div>", "Top comments", "<strong>someone</strong>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article := hackerNewsArticle(&tt.story, tt.comments)
+			if article.Title != tt.story.Title || article.SiteName != "Hacker News" {
+				t.Errorf("title %q, site %q", article.Title, article.SiteName)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(article.Content, s) {
+					t.Errorf("content misses %q:\n%s", s, article.Content)
+				}
+			}
+			for _, s := range tt.missing {
+				if strings.Contains(article.Content, s) {
+					t.Errorf("content has %q:\n%s", s, article.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestGitHubArticle(t *testing.T) {
+	tests := []struct {
+		name     string
+		repo     gitHubRepo
+		readme   string
+		contains []string
+		missing  []string
+	}{
+		{
+			name:     "readme",
+			repo:     gitHubRepo{FullName: "golang/go", Description: "The Go programming language", Language: "Go", Stars: 3, Topics: []string{"go", "compiler"}},
+			readme:   "<article><h1>Go</h1></article>",
+			contains: []string{"<p>Go · 3 stars · go, compiler</p>", "<article><h1>Go</h1></article>"},
+		},
+		{
+			name:     "without readme",
+			repo:     gitHubRepo{FullName: "someone/empty", Description: "<b>Nothing</b>"},
+			contains: []string{"<p>&lt;b&gt;Nothing&lt;/b&gt;</p>", "<p>0 stars</p>"},
+			missing:  []string{"<article>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article := gitHubArticle(&tt.repo, tt.readme)
+			if article.Title != tt.repo.FullName || article.Excerpt != tt.repo.Description {
+				t.Errorf("title %q, excerpt %q", article.Title, article.Excerpt)
+			}
+			if article.PublishedTime != nil {
+				t.Errorf("published time = %v, want none", article.PublishedTime)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(article.Content, s) {
+					t.Errorf("content misses %q:\n%s", s, article.Content)
+				}
+			}
+			for _, s := range tt.missing {
+				if strings.Contains(article.Content, s) {
+					t.Errorf("content has %q:\n%s", s, article.Content)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/go-readability"
+)
+
+const redditComments = 10 // Top comments kept with the post
+
+// Reddit extracts Reddit posts with their top comments
+type Reddit struct{}
+
+type redditListing struct {
+	Data struct {
+		Children []struct {
+			Kind string      `json:"kind"`
+			Data redditThing `json:"data"`
+		} `json:"children"`
+	} `json:"data"`
+}
+
+// redditThing is a post (t3) or a comment (t1)
+type redditThing struct {
+	Title         string  `json:"title"`
+	Author        string  `json:"author"`
+	Subreddit     string  `json:"subreddit"`
+	SelftextHTML  string  `json:"selftext_html"`
+	BodyHTML      string  `json:"body_html"`
+	URL           string  `json:"url"`
+	IsSelf        bool    `json:"is_self"`
+	Score         int     `json:"score"`
+	CreatedUTC    float64 `json:"created_utc"`
+	Stickied      bool    `json:"stickied"`
+	SubredditName string  `json:"subreddit_name_prefixed"`
+}
+
+func (Reddit) Name() string { return "reddit" }
+
+func (Reddit) Match(u *url.URL) bool {
+	return hostIs(u, "reddit.com") && redditPostId(u) != ""
+}
+
+func (Reddit) Extract(ctx context.Context, u *url.URL, fetch Fetch) (Result, error) {
+	// raw_json keeps the HTML of the post unescaped
+	link := fmt.Sprintf("https://www.reddit.com/comments/%s.json?raw_json=1&sort=top&depth=1&limit=%d",
+		url.PathEscape(redditPostId(u)), redditComments)
+	body, err := get(ctx, fetch, link, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("get reddit post: %w", err)
+	}
+	article, err := parseReddit(body)
+	return Result{Article: article}, err
+}
+
+// redditPostId returns the id of the post in /r/{subreddit}/comments/{id}/... or /comments/{id}
+func redditPostId(u *url.URL) string {
+	segments := pathSegments(u)
+	for i, s := range segments {
+		if s == "comments" && i+1 < len(segments) && (i == 0 || i == 2 && segments[0] == "r") {
+			return segments[i+1]
+		}
+	}
+	return ""
+}
+
+func parseReddit(body []byte) (readability.Article, error) {
+	var listings []redditListing
+	if err := json.Unmarshal(body, &listings); err != nil {
+		return readability.Article{}, fmt.Errorf("parse reddit post: %w", err)
+	}
+	if len(listings) == 0 || len(listings[0].Data.Children) == 0 {
+		return readability.Article{}, fmt.Errorf("reddit post not found")
+	}
+	post := listings[0].Data.Children[0].Data
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p>Posted in %s by u/%s · %d points</p>\n", escape(post.SubredditName), escape(post.Author), post.Score)
+	if !post.IsSelf && post.URL != "" {
+		fmt.Fprintf(&b, "<p><a href=\"%s\">%s</a></p>\n", escape(post.URL), escape(post.URL))
+	}
+	if post.SelftextHTML != "" {
+		b.WriteString(post.SelftextHTML + "\n")
+	}
+
+	var comments []redditThing
+	if len(listings) > 1 {
+		for _, child := range listings[1].Data.Children {
+			// Other kinds are the "load more comments" placeholders
+			if child.Kind != "t1" || child.Data.Stickied || child.Data.BodyHTML == "" {
+				continue
+			}
+			comments = append(comments, child.Data)
+			if len(comments) == redditComments {
+				break
+			}
+		}
+	}
+	if len(comments) > 0 {
+		b.WriteString("<h2>Top comments</h2>\n")
+		for _, c := range comments {
+			fmt.Fprintf(&b, "<blockquote><p><strong>u/%s</strong> · %d points</p>%s</blockquote>\n", escape(c.Author), c.Score, c.BodyHTML)
+		}
+	}
+
+	published := time.Unix(int64(post.CreatedUTC), 0).UTC()
+	return readability.Article{
+		Title:         post.Title,
+		Byline:        post.Author,
+		Content:       b.String(),
+		Excerpt:       excerpt(Text(post.SelftextHTML)),
+		SiteName:      "Reddit",
+		PublishedTime: &published,
+	}, nil
+}
@@ -0,0 +1,111 @@
+package extractor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-shiori/go-readability"
+)
+
+const (
+	stackExchangeAPI     = "https://api.stackexchange.com/2.3"
+	stackOverflowAnswers = 5 // Answers kept with the question, the most voted first
+)
+
+// StackOverflow extracts Stack Overflow questions with their best answers
+type StackOverflow struct{}
+
+type stackExchangePost struct {
+	Title        string   `json:"title"` // HTML escaped
+	Body         string   `json:"body"`  // HTML
+	Score        int      `json:"score"`
+	IsAccepted   bool     `json:"is_accepted"`
+	Tags         []string `json:"tags"`
+	CreationDate int64    `json:"creation_date"`
+	Owner        struct {
+		DisplayName string `json:"display_name"` // HTML escaped
+	} `json:"owner"`
+}
+
+type stackExchangeResponse struct {
+	Items []stackExchangePost `json:"items"`
+}
+
+func (StackOverflow) Name() string { return "stackoverflow" }
+
+func (StackOverflow) Match(u *url.URL) bool {
+	return hostIs(u, "stackoverflow.com") && stackOverflowId(u) != 0
+}
+
+func (StackOverflow) Extract(ctx context.Context, u *url.URL, fetch Fetch) (Result, error) {
+	id := stackOverflowId(u)
+	question, err := get(ctx, fetch,
+		fmt.Sprintf("%s/questions/%d?site=stackoverflow&filter=withbody", stackExchangeAPI, id), nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("get stack overflow question: %w", err)
+	}
+	answers, err := get(ctx, fetch,
+		fmt.Sprintf("%s/questions/%d/answers?site=stackoverflow&filter=withbody&sort=votes&order=desc&pagesize=%d",
+			stackExchangeAPI, id, stackOverflowAnswers), nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("get stack overflow answers: %w", err)
+	}
+	article, err := parseStackOverflow(question, answers)
+	return Result{Article: article}, err
+}
+
+// stackOverflowId returns the id in /questions/{id}/{slug}, or /q/{id} of the share links
+func stackOverflowId(u *url.URL) int {
+	segments := pathSegments(u)
+	if len(segments) < 2 || (segments[0] != "questions" && segments[0] != "q") {
+		return 0
+	}
+	id, err := strconv.Atoi(segments[1])
+	if err != nil || id <= 0 {
+		return 0
+	}
+	return id
+}
+
+func parseStackOverflow(questionBody, answersBody []byte) (readability.Article, error) {
+	var questions, answers stackExchangeResponse
+	if err := json.Unmarshal(questionBody, &questions); err != nil {
+		return readability.Article{}, fmt.Errorf("parse stack overflow question: %w", err)
+	}
+	if err := json.Unmarshal(answersBody, &answers); err != nil {
+		return readability.Article{}, fmt.Errorf("parse stack overflow answers: %w", err)
+	}
+	if len(questions.Items) == 0 {
+		return readability.Article{}, fmt.Errorf("stack overflow question not found")
+	}
+	question := questions.Items[0]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p>Asked by %s · %d votes · %s</p>\n",
+		question.Owner.DisplayName, question.Score, escape(strings.Join(question.Tags, ", ")))
+	b.WriteString(question.Body + "\n")
+	for _, a := range answers.Items {
+		heading := "Answer"
+		if a.IsAccepted {
+			heading = "Accepted answer"
+		}
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<p>By %s · %d votes</p>\n%s\n", heading, a.Owner.DisplayName, a.Score, a.Body)
+	}
+
+	published := time.Unix(question.CreationDate, 0).UTC()
+	return readability.Article{
+		Title:         html.UnescapeString(question.Title),
+		Byline:        html.UnescapeString(question.Owner.DisplayName),
+		Content:       b.String(),
+		Excerpt:       excerpt(Text(question.Body)),
+		SiteName:      "Stack Overflow",
+		Language:      "en",
+		PublishedTime: &published,
+	}, nil
+}
@@ -0,0 +1,76 @@
+package extractor
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+// blockElements start on a new line in the text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "ul": true, "ol": true, "pre": true,
+	"blockquote": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"tr": true, "td": true, "th": true, "table": true, "hr": true, "section": true, "article": true,
+	"dd": true, "dt": true,
+}
+
+// Text returns the text of the HTML, with the paragraphs on separate lines
+func Text(content string) string {
+	doc, err := nethtml.Parse(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+	var b strings.Builder
+	var walk func(n *nethtml.Node, pre bool)
+	walk = func(n *nethtml.Node, pre bool) {
+		switch n.Type {
+		case nethtml.TextNode:
+			if pre {
+				b.WriteString(n.Data)
+			} else {
+				text := strings.Join(strings.Fields(n.Data), " ")
+				if text != "" && text[0] != n.Data[0] {
+					text = " " + text
+				}
+				if text != "" && text[len(text)-1] != n.Data[len(n.Data)-1] {
+					text += " "
+				}
+				b.WriteString(text)
+			}
+			return
+		case nethtml.ElementNode:
+			switch n.Data {
+			case "script", "style", "head":
+				return
+			case "pre":
+				pre = true
+			}
+		}
+		block := n.Type == nethtml.ElementNode && blockElements[n.Data]
+		if block {
+			b.WriteString("\n\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, pre)
+		}
+		if block {
+			b.WriteString("\n\n")
+		}
+	}
+	walk(doc, false)
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(blankLinesRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// escape is html.EscapeString, named for the article builders
+func escape(s string) string {
+	return html.EscapeString(s)
+}
@@ -0,0 +1,247 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/arashthr/pensive/internal/transcript"
+	"github.com/go-shiori/go-readability"
+)
+
+var youTubeIdRe = regexp.MustCompile(`^[\w-]{11}$`)
+
+// youTubePlayer is the part of the player response in the watch page that is used
+type youTubePlayer struct {
+	PlayabilityStatus struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	} `json:"playabilityStatus"`
+	VideoDetails struct {
+		VideoId          string `json:"videoId"`
+		Title            string `json:"title"`
+		Author           string `json:"author"`
+		ShortDescription string `json:"shortDescription"`
+		Thumbnail        struct {
+			Thumbnails []struct {
+				URL   string `json:"url"`
+				Width int    `json:"width"`
+			} `json:"thumbnails"`
+		} `json:"thumbnail"`
+	} `json:"videoDetails"`
+	Microformat struct {
+		Renderer struct {
+			PublishDate string `json:"publishDate"`
+		} `json:"playerMicroformatRenderer"`
+	} `json:"microformat"`
+	Captions struct {
+		Renderer struct {
+			CaptionTracks []struct {
+				BaseURL      string `json:"baseUrl"`
+				LanguageCode string `json:"languageCode"`
+				Kind         string `json:"kind"` // "asr" for the automatic captions
+			} `json:"captionTracks"`
+		} `json:"playerCaptionsTracklistRenderer"`
+	} `json:"captions"`
+}
+
+// YouTube extracts YouTube videos with their transcript. Videos without captions get their
+// description as the content.
+type YouTube struct{}
+
+func (YouTube) Name() string { return "youtube" }
+
+func (YouTube) Match(u *url.URL) bool {
+	return youTubeId(u) != ""
+}
+
+func (YouTube) Extract(ctx context.Context, u *url.URL, fetch Fetch) (Result, error) {
+	id := youTubeId(u)
+	page, err := get(ctx, fetch, "https://www.youtube.com/watch?v="+id, map[string]string{
+		"Accept-Language": "en-US,en;q=0.9",
+		// Skips the cookie consent page served in the EU
+		"Cookie": "CONSENT=YES+1",
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("get youtube watch page: %w", err)
+	}
+	player, err := parseYouTubePlayer(page)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var t *transcript.Transcript
+	if link, language := youTubeCaptions(player); link != "" {
+		captions, err := get(ctx, fetch, link, nil)
+		if err != nil {
+			return Result{}, fmt.Errorf("get youtube captions: %w", err)
+		}
+		segments, err := transcript.Parse(captions)
+		if err != nil {
+			return Result{}, fmt.Errorf("parse youtube captions: %w", err)
+		}
+		t = &transcript.Transcript{
+			Provider: transcript.ProviderYouTube,
+			VideoURL: "https://www.youtube.com/watch?v=" + id,
+			EmbedURL: "https://www.youtube.com/embed/" + id,
+			Language: language,
+			Segments: segments,
+			Chapters: transcript.ParseChapters(player.VideoDetails.ShortDescription),
+		}
+	}
+	return youTubeResult(player, t), nil
+}
+
+// youTubeId returns the id of the video in the watch, short, embed and youtu.be links
+func youTubeId(u *url.URL) string {
+	segments := pathSegments(u)
+	var id string
+	switch {
+	case strings.EqualFold(u.Hostname(), "youtu.be") && len(segments) == 1:
+		id = segments[0]
+	case hostIs(u, "youtube.com", "youtube-nocookie.com") && u.Path == "/watch":
+		id = u.Query().Get("v")
+	case hostIs(u, "youtube.com", "youtube-nocookie.com") && len(segments) == 2:
+		switch segments[0] {
+		case "shorts", "embed", "live", "v":
+			id = segments[1]
+		}
+	}
+	if !youTubeIdRe.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+func parseYouTubePlayer(page []byte) (*youTubePlayer, error) {
+	marker := []byte("ytInitialPlayerResponse = ")
+	start := bytes.Index(page, marker)
+	if start == -1 {
+		return nil, fmt.Errorf("youtube player response not found")
+	}
+	// The object is followed by more script, the decoder stops at its end
+	var player youTubePlayer
+	if err := json.NewDecoder(bytes.NewReader(page[start+len(marker):])).Decode(&player); err != nil {
+		return nil, fmt.Errorf("parse youtube player response: %w", err)
+	}
+	if player.VideoDetails.VideoId == "" {
+		return nil, fmt.Errorf("youtube video unavailable: %s", player.PlayabilityStatus.Reason)
+	}
+	return &player, nil
+}
+
+// youTubeCaptions returns the link of the captions in WebVTT and their language. Captions
+// written for the video are preferred over the automatic ones.
+func youTubeCaptions(player *youTubePlayer) (string, string) {
+	tracks := player.Captions.Renderer.CaptionTracks
+	if len(tracks) == 0 {
+		return "", ""
+	}
+	best := tracks[0]
+	for _, t := range tracks {
+		if t.Kind != "asr" {
+			best = t
+			break
+		}
+	}
+	link, err := url.Parse(best.BaseURL)
+	if err != nil {
+		return "", ""
+	}
+	query := link.Query()
+	query.Set("fmt", "vtt")
+	link.RawQuery = query.Encode()
+	return link.String(), best.LanguageCode
+}
+
+func youTubeResult(player *youTubePlayer, t *transcript.Transcript) Result {
+	details := player.VideoDetails
+	description := strings.TrimSpace(details.ShortDescription)
+
+	var b strings.Builder
+	var text strings.Builder
+	if description != "" {
+		for _, line := range strings.Split(description, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				fmt.Fprintf(&b, "<p>%s</p>\n", escape(line))
+			}
+		}
+		text.WriteString(description + "\n\n")
+	}
+	if t != nil {
+		b.WriteString("<h2>Transcript</h2>\n")
+		for _, section := range t.Sections() {
+			if section.Title != "" {
+				fmt.Fprintf(&b, "<h3>%s</h3>\n", escape(section.Title))
+			}
+			for _, p := range section.Paragraphs {
+				fmt.Fprintf(&b, "<p>[%s] %s</p>\n", p.Timestamp(), escape(p.Text))
+			}
+		}
+		text.WriteString(t.Text())
+	}
+
+	result := Result{
+		Article: readability.Article{
+			Title:       details.Title,
+			Byline:      details.Author,
+			Content:     b.String(),
+			TextContent: strings.TrimSpace(text.String()),
+			Excerpt:     excerpt(description),
+			SiteName:    "YouTube",
+		},
+		Transcript: t,
+	}
+	if t != nil {
+		result.Language = t.Language
+	}
+	width := 0
+	for _, thumbnail := range details.Thumbnail.Thumbnails {
+		if thumbnail.Width > width {
+			result.Image, width = thumbnail.URL, thumbnail.Width
+		}
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if published, err := time.Parse(layout, player.Microformat.Renderer.PublishDate); err == nil {
+			result.PublishedTime = &published
+			break
+		}
+	}
+	return result
+}
+
+// PageTranscript returns the transcript of the video in the page, from the caption tracks of
+// its <video> element. It returns nil when the page has no captions.
+func (r *Registry) PageTranscript(ctx context.Context, page []byte, pageURL *url.URL, language string) (*transcript.Transcript, error) {
+	track := transcript.PickTrack(transcript.Tracks(page, pageURL), language)
+	if track == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	captions, err := get(ctx, r.fetch(), track.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get video captions: %w", err)
+	}
+	segments, err := transcript.Parse(captions)
+	if err != nil {
+		return nil, fmt.Errorf("parse video captions: %w", err)
+	}
+	video := track.Video
+	if video == "" {
+		video = pageURL.String()
+	}
+	return &transcript.Transcript{
+		Provider: transcript.ProviderHTML5,
+		VideoURL: video,
+		EmbedURL: track.Video,
+		Language: track.Language,
+		Segments: segments,
+	}, nil
+}
@@ -0,0 +1,122 @@
+package extractor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arashthr/pensive/internal/transcript"
+)
+
+func TestYouTubeId(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://www.youtube.com/watch?v=aqz-KE-bpKQ&t=42s", "aqz-KE-bpKQ"},
+		{"https://m.youtube.com/watch?v=aqz-KE-bpKQ", "aqz-KE-bpKQ"},
+		{"https://youtu.be/aqz-KE-bpKQ?si=share", "aqz-KE-bpKQ"},
+		{"https://www.youtube.com/shorts/aqz-KE-bpKQ", "aqz-KE-bpKQ"},
+		{"https://www.youtube-nocookie.com/embed/aqz-KE-bpKQ", "aqz-KE-bpKQ"},
+		{"https://www.youtube.com/live/aqz-KE-bpKQ", "aqz-KE-bpKQ"},
+		{"https://www.youtube.com/watch?v=short", ""},
+		{"https://www.youtube.com/channel/UC1234567890", ""},
+		{"https://www.youtube.com/playlist?list=PL123", ""},
+		{"https://example.com/watch?v=aqz-KE-bpKQ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			if got := youTubeId(mustParse(t, tt.link)); got != tt.want {
+				t.Errorf("youTubeId(%q) = %q, want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYouTubePlayer(t *testing.T) {
+	player, err := parseYouTubePlayer(readFixture(t, "youtube_watch.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	details := player.VideoDetails
+	if details.VideoId != "aqz-KE-bpKQ" || details.Title != "Cooking pasta with Go" || details.Author != "Gopher Kitchen" {
+		t.Errorf("video details = %+v", details)
+	}
+	if n := len(player.Captions.Renderer.CaptionTracks); n != 2 {
+		t.Errorf("%d caption tracks, want 2", n)
+	}
+
+	tests := []struct {
+		name string
+		page string
+	}{
+		{"no player", "<html><body>Sign in to confirm you're not a bot</body></html>"},
+		{"unavailable", `<script>var ytInitialPlayerResponse = {"playabilityStatus":{"status":"ERROR","reason":"Video unavailable"}};</script>`},
+		{"truncated", `<script>var ytInitialPlayerResponse = {"videoDetails":{"videoId":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseYouTubePlayer([]byte(tt.page)); err == nil {
+				t.Error("parseYouTubePlayer succeeded")
+			}
+		})
+	}
+}
+
+func TestYouTubeCaptions(t *testing.T) {
+	player, err := parseYouTubePlayer(readFixture(t, "youtube_watch.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The captions written for the video are preferred over the automatic ones, in WebVTT
+	link, language := youTubeCaptions(player)
+	if want := "https://www.youtube.com/api/timedtext?fmt=vtt&lang=en-GB&v=aqz-KE-bpKQ"; link != want {
+		t.Errorf("link = %q, want %q", link, want)
+	}
+	if language != "en-GB" {
+		t.Errorf("language = %q, want en-GB", language)
+	}
+
+	player.Captions.Renderer.CaptionTracks = player.Captions.Renderer.CaptionTracks[:1]
+	if link, language = youTubeCaptions(player); language != "en" || !strings.Contains(link, "kind=asr") {
+		t.Errorf("automatic captions: link %q, language %q", link, language)
+	}
+
+	player.Captions.Renderer.CaptionTracks = nil
+	if link, _ = youTubeCaptions(player); link != "" {
+		t.Errorf("link without captions = %q", link)
+	}
+}
+
+func TestYouTubeExtract(t *testing.T) {
+	registry := &Registry{Extractors: Default.Extractors, Fetch: fixtureFetch(t, map[string]string{
+		"https://www.youtube.com/watch?v=aqz-KE-bpKQ":                            "youtube_watch.html",
+		"https://www.youtube.com/api/timedtext?fmt=vtt&lang=en-GB&v=aqz-KE-bpKQ": "youtube_captions.vtt",
+	})}
+	u := mustParse(t, "https://youtu.be/aqz-KE-bpKQ")
+	result, err := registry.Extract(context.Background(), YouTube{}, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Title != "Cooking pasta with Go" || result.SiteName != "YouTube" || result.Language != "en-GB" {
+		t.Errorf("title %q, site %q, language %q", result.Title, result.SiteName, result.Language)
+	}
+	if result.Image != "https://i.ytimg.com/vi/aqz-KE-bpKQ/maxresdefault.jpg" {
+		t.Errorf("image = %q, want the widest thumbnail", result.Image)
+	}
+	if want := time.Date(2024, 3, 5, 16, 0, 0, 0, time.UTC); result.PublishedTime == nil || !result.PublishedTime.Equal(want) {
+		t.Errorf("published time = %v, want %v", result.PublishedTime, want)
+	}
+	if result.Transcript == nil {
+		t.Fatal("no transcript")
+	}
+	if result.Transcript.Provider != transcript.ProviderYouTube || len(result.Transcript.Segments) != 3 || len(result.Transcript.Chapters) != 3 {
+		t.Errorf("transcript = %+v", result.Transcript)
+	}
+	for _, s := range []string{"cooking & code", "Cooking\n\n[0:05] First, boil the water.", "[1:00] Thanks for watching!"} {
+		if !strings.Contains(result.TextContent, s) {
+			t.Errorf("text content misses %q:\n%s", s, result.TextContent)
+		}
+	}
+}
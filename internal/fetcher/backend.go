@@ -0,0 +1,309 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/go-shiori/go-readability"
+)
+
+// Names of the backends, recorded with the bookmarks they fetched
+const (
+	BackendReadability = "go-readability"
+	BackendScrapper    = "scrapper"
+	BackendHeadless    = "headless"
+)
+
+// Backend gets the page of a link
+type Backend interface {
+	Name() string
+	Fetch(ctx context.Context, link string) (*Result, error)
+}
+
+// Result is what a backend got for a link. Article is set by the backends that extract the
+// article themselves, go-readability extracts it from the page otherwise.
+type Result struct {
+	Page    *Page
+	Article *readability.Article
+}
+
+// serviceClient talks to the scrapper and headless services, which usually run next to the
+// server on a private network
+var serviceClient = &http.Client{Timeout: Timeout}
+
+// Direct fetches the page from the server itself
+type Direct struct {
+	Fetcher *Fetcher // Pages when nil
+}
+
+func (Direct) Name() string { return BackendReadability }
+
+func (d Direct) Fetch(ctx context.Context, link string) (*Result, error) {
+	f := d.Fetcher
+	if f == nil {
+		f = Pages
+	}
+	page, _, err := f.Get(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Page: page}, nil
+}
+
+// Scrapper is the Node service in scrapper/, it extracts the article with Mozilla Readability
+type Scrapper struct {
+	URL string // Base URL of the service
+}
+
+// scrapperArticle is the article sent by the scrapper, as built by Mozilla Readability
+type scrapperArticle struct {
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	Byline        string `json:"byline"`
+	Content       string `json:"content"`
+	TextContent   string `json:"textContent"`
+	Excerpt       string `json:"excerpt"`
+	SiteName      string `json:"siteName"`
+	Lang          string `json:"lang"`
+	PublishedTime string `json:"publishedTime"`
+}
+
+func (Scrapper) Name() string { return BackendScrapper }
+
+func (s Scrapper) Fetch(ctx context.Context, link string) (*Result, error) {
+	// The scrapper checks the redirects against private addresses too, CheckLink only sees the link
+	request := map[string]any{"url": link, "publicOnly": true}
+	// The scrapper fetches the page itself, with the headers and cookies of the profile
+	if u, err := url.Parse(link); err == nil {
+		if header := ProfileFrom(ctx).header(u); header != nil {
+			request["headers"] = header
+		}
+	}
+	body, _, err := postService(ctx, strings.TrimSuffix(s.URL, "/")+"/fetch", link, request)
+	var statusErr StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("scrapper: %w: %s", errors.ErrBlockedAddress, link)
+	} else if err != nil {
+		return nil, fmt.Errorf("scrapper: %w", err)
+	}
+	var a scrapperArticle
+	if err := json.Unmarshal(body, &a); err != nil {
+		return nil, fmt.Errorf("scrapper: decode article: %w", err)
+	}
+	if strings.TrimSpace(a.TextContent) == "" {
+		return nil, fmt.Errorf("scrapper: no content")
+	}
+	final, err := url.Parse(a.URL)
+	if err != nil || (final.Scheme != "http" && final.Scheme != "https") {
+		final, _ = url.Parse(link)
+	}
+	article := &readability.Article{
+		Title:       a.Title,
+		Byline:      a.Byline,
+		Content:     a.Content,
+		TextContent: a.TextContent,
+		Excerpt:     a.Excerpt,
+		SiteName:    a.SiteName,
+		Language:    a.Lang,
+	}
+	if published, err := time.Parse(time.RFC3339, a.PublishedTime); err == nil {
+		article.PublishedTime = &published
+	}
+	return &Result{Page: &Page{URL: final, StatusCode: http.StatusOK, ContentType: "text/html"}, Article: article}, nil
+}
+
+// Headers of the answers of the headless service, telling where the rendered page came from
+const (
+	headerFinalURL   = "X-Final-Url"   // The link after the redirects
+	headerStatusCode = "X-Status-Code" // Status of the page at the final link
+)
+
+// Headless renders the page in a headless browser, for pages that build their content with
+// JavaScript. The service gets {"url": link} and answers with the HTML of the rendered page,
+// like the /content endpoint of browserless, and with the final link and status of the page in
+// the X-Final-Url and X-Status-Code headers. The browser follows the redirects on its own, so
+// the page is only accepted when its final link passes CheckLink too.
+type Headless struct {
+	URL string // Endpoint of the service
+}
+
+func (Headless) Name() string { return BackendHeadless }
+
+func (h Headless) Fetch(ctx context.Context, link string) (*Result, error) {
+	body, header, err := postService(ctx, h.URL, link, map[string]any{"url": link})
+	if err != nil {
+		return nil, fmt.Errorf("headless: %w", err)
+	}
+	final, err := url.Parse(header.Get(headerFinalURL))
+	if err != nil || header.Get(headerFinalURL) == "" {
+		return nil, fmt.Errorf("headless: the service did not send the final link in %s", headerFinalURL)
+	}
+	if err := CheckLink(ctx, final.String()); err != nil {
+		return nil, fmt.Errorf("headless: %w", err)
+	}
+	status, err := strconv.Atoi(header.Get(headerStatusCode))
+	if err != nil {
+		return nil, fmt.Errorf("headless: the service did not send the status of the page in %s", headerStatusCode)
+	}
+	if status < 200 || status >= 300 {
+		return nil, fmt.Errorf("headless: %w", StatusError{StatusCode: status, URL: final.String()})
+	}
+	return &Result{Page: &Page{URL: final, StatusCode: status, ContentType: "text/html", Body: body}}, nil
+}
+
+// postService sends the request for the link to a fetch service. The service fetches the link
+// itself, so the link is checked against private addresses first. It returns the body and the
+// headers of the answer.
+func postService(ctx context.Context, endpoint, link string, request map[string]any) ([]byte, http.Header, error) {
+	if err := CheckLink(ctx, link); err != nil {
+		return nil, nil, err
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode request: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := serviceClient.Do(req)
+	if err != nil {
+		if isTimeout(err) {
+			return nil, nil, fmt.Errorf("%w: %w", errors.ErrFetchTimeout, err)
+		}
+		return nil, nil, fmt.Errorf("perform request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, StatusError{StatusCode: resp.StatusCode, URL: link}
+	}
+	// The scrapper sends the HTML and the text of the article
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 2*MaxPageSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response: %w", err)
+	}
+	if len(body) > 2*MaxPageSize {
+		return nil, nil, fmt.Errorf("%w: over %d bytes", errors.ErrPageTooLarge, 2*MaxPageSize)
+	}
+	return body, resp.Header, nil
+}
+
+// CheckLink fails for links that are not http(s) or whose host resolves to a private address.
+// The services fetch the links on their own, so this is checked before handing links to them.
+// It doesn't cover the redirects and the later resolutions of the host: the scrapper checks
+// them itself, and the final link of the headless browser is checked again.
+func CheckLink(ctx context.Context, link string) error {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: %q", errors.ErrInvalidUrl, link)
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		if Blocked(addr) {
+			return fmt.Errorf("%w: %s", errors.ErrBlockedAddress, addr)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if Blocked(addr) {
+			return fmt.Errorf("%w: %s", errors.ErrBlockedAddress, addr)
+		}
+	}
+	return nil
+}
+
+// Chain is the order the backends are tried in. Domains can have their own order, it applies
+// to their subdomains too.
+type Chain struct {
+	Backends []Backend
+	Domains  map[string][]Backend
+}
+
+// DefaultChain only fetches pages from the server
+var DefaultChain = &Chain{Backends: []Backend{Direct{}}}
+
+// For returns the backends to try for the link, in order. The nil Chain is the DefaultChain.
+func (c *Chain) For(u *url.URL) []Backend {
+	if c == nil {
+		c = DefaultChain
+	}
+	host := strings.ToLower(u.Hostname())
+	best := ""
+	for domain := range c.Domains {
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(best) {
+			best = domain
+		}
+	}
+	if best != "" {
+		return c.Domains[best]
+	}
+	return c.Backends
+}
+
+// ParseChain builds the chain from the configuration: the default order, like
+// "go-readability,scrapper", and the orders of some domains, like
+// "medium.com=headless,go-readability;example.org=scrapper". The scrapper and headless
+// backends can only be used when their URL is set.
+func ParseChain(order, domains, scrapperURL, headlessURL string) (*Chain, error) {
+	available := map[string]Backend{BackendReadability: Direct{}}
+	if scrapperURL != "" {
+		available[BackendScrapper] = Scrapper{URL: scrapperURL}
+	}
+	if headlessURL != "" {
+		available[BackendHeadless] = Headless{URL: headlessURL}
+	}
+	parse := func(names string) ([]Backend, error) {
+		var backends []Backend
+		for _, name := range strings.Split(names, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			backend, ok := available[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown or unconfigured fetch backend %q", name)
+			}
+			backends = append(backends, backend)
+		}
+		if len(backends) == 0 {
+			return nil, fmt.Errorf("no fetch backends in %q", names)
+		}
+		return backends, nil
+	}
+
+	chain := &Chain{Domains: map[string][]Backend{}}
+	var err error
+	if chain.Backends, err = parse(order); err != nil {
+		return nil, err
+	}
+	for _, rule := range strings.Split(domains, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		domain, names, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("fetch backends of a domain should be like domain=backend,backend: %q", rule)
+		}
+		if chain.Domains[strings.ToLower(strings.TrimSpace(domain))], err = parse(names); err != nil {
+			return nil, err
+		}
+	}
+	return chain, nil
+}
@@ -0,0 +1,80 @@
+package fetcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arashthr/pensive/internal/errors"
+)
+
+func TestHeadlessFetch(t *testing.T) {
+	// Public addresses, so the links are checked without resolving any host
+	const link = "http://93.184.215.14/article"
+	tests := []struct {
+		name     string
+		header   map[string]string
+		wantURL  string
+		wantErr  error
+		anyError bool
+	}{
+		{
+			name:    "redirected",
+			header:  map[string]string{"X-Final-Url": "https://93.184.215.14/final", "X-Status-Code": "200"},
+			wantURL: "https://93.184.215.14/final",
+		},
+		{
+			name:    "redirected to a private address",
+			header:  map[string]string{"X-Final-Url": "http://169.254.169.254/latest/meta-data", "X-Status-Code": "200"},
+			wantErr: errors.ErrBlockedAddress,
+		},
+		{
+			name:     "missing final link",
+			header:   map[string]string{"X-Status-Code": "200"},
+			anyError: true,
+		},
+		{
+			name:     "missing status",
+			header:   map[string]string{"X-Final-Url": link},
+			anyError: true,
+		},
+		{
+			name:     "page not found",
+			header:   map[string]string{"X-Final-Url": link, "X-Status-Code": "404"},
+			anyError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range tt.header {
+					w.Header().Set(key, value)
+				}
+				w.Write([]byte("<html><body><p>Rendered</p></body></html>"))
+			}))
+			defer service.Close()
+
+			result, err := Headless{URL: service.URL}.Fetch(context.Background(), link)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Fetch error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.anyError:
+				if err == nil {
+					t.Fatalf("Fetch succeeded, want an error")
+				}
+			case err != nil:
+				t.Fatalf("Fetch: %v", err)
+			default:
+				if got := result.Page.URL.String(); got != tt.wantURL {
+					t.Errorf("page URL = %q, want %q", got, tt.wantURL)
+				}
+				if result.Page.StatusCode != http.StatusOK || len(result.Page.Body) == 0 {
+					t.Errorf("page = %+v", result.Page)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// Package fetcher gets the pages of the links users save. Connections to private, loopback and
+// link-local addresses are refused, and every fetch is bounded in time, size and redirects.
+// Pages the server can't read can be fetched by other backends, like a headless browser.
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/arashthr/pensive/internal/errors"
+)
+
+const (
+	Timeout         = 45 * time.Second // Whole fetch, with all the redirects
+	RequestTimeout  = 15 * time.Second // Each request of the redirect chain
+	MaxRedirects    = 10               // HTTP redirects and meta refresh tags together
+	MaxMetaRefresh  = 3
+	MaxPageSize     = 10 << 20
+	MaxDocumentSize = 50 << 20 // Same as the upload limit of documents
+	userAgent       = "Mozilla/5.0"
+)
+
+var metaRefreshRe = regexp.MustCompile(`(?i)<meta[^>]+http-equiv=["']?refresh["']?[^>]+content=["']?\d+;\s*url=([^"'>]+)["']?`)
+
+// PageTypes are the content types of the pages that can be saved, with the largest body
+// accepted for each
+var PageTypes = map[string]int64{
+	"text/html":             MaxPageSize,
+	"application/xhtml+xml": MaxPageSize,
+	"text/plain":            MaxPageSize,
+	"application/pdf":       MaxDocumentSize,
+}
+
+// genericTypes say nothing about the content, the type is sniffed from the body instead
+var genericTypes = map[string]bool{
+	"":                         true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+}
+
+// StatusError is returned when the page answers with a non-2xx status
+type StatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// Page is a fetched page
+type Page struct {
+	URL         *url.URL // Where the redirects ended
+	StatusCode  int
+	Header      http.Header
+	ContentType string // Media type, sniffed from the body when the server sends none
+	Body        []byte
+	Redirects   int
+}
+
+// Fetcher follows the redirects of a link and reads the page within the limits
+type Fetcher struct {
+	// Types are the accepted content types with the largest body of each. Other types fail
+	// with ErrUnsupportedContent.
+	Types map[string]int64
+	// Probe only checks that the link answers. Any type is accepted, and bodies are only read,
+	// up to MaxPageSize, to follow meta refresh tags.
+	Probe bool
+}
+
+// Pages fetches the pages that are saved
+var Pages = &Fetcher{Types: PageTypes}
+
+// Links checks that links still answer
+var Links = &Fetcher{Probe: true}
+
+// client does not follow redirects, Get follows them to limit and time each one
+var client = &http.Client{
+	Transport: transport,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Get fetches the page at the link, following HTTP redirects and meta refresh tags. The
+// number of redirects is also returned when the fetch fails.
+func (f *Fetcher) Get(ctx context.Context, link string) (*Page, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	redirects, refreshes := 0, 0
+	for {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, redirects, fmt.Errorf("%w: %q", errors.ErrInvalidUrl, link)
+		}
+		page, next, err := f.request(ctx, u)
+		if err != nil {
+			if isTimeout(err) {
+				return nil, redirects, fmt.Errorf("%w: %w", errors.ErrFetchTimeout, err)
+			}
+			return nil, redirects, err
+		}
+		if next == nil && isHTML(page) {
+			if m := metaRefreshRe.FindSubmatch(page.Body); m != nil {
+				if next, err = page.URL.Parse(strings.TrimSpace(string(m[1]))); err != nil {
+					return nil, redirects, fmt.Errorf("parse meta refresh link: %w", err)
+				}
+				refreshes++
+				if refreshes > MaxMetaRefresh {
+					return nil, redirects, fmt.Errorf("%w: more than %d meta refresh tags", errors.ErrTooManyRedirects, MaxMetaRefresh)
+				}
+			}
+		}
+		if next == nil {
+			page.Redirects = redirects
+			return page, redirects, nil
+		}
+		redirects++
+		if redirects > MaxRedirects {
+			return nil, redirects, fmt.Errorf("%w: stopped after %d redirects", errors.ErrTooManyRedirects, MaxRedirects)
+		}
+		link = next.String()
+	}
+}
+
+// request performs one request of the chain. It returns the link of the redirect, or the page.
+func (f *Fetcher) request(ctx context.Context, u *url.URL) (*Page, *url.URL, error) {
+	ctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Upgrade-Insecure-Requests", "1")
+	ProfileFrom(ctx).apply(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if location := resp.Header.Get("Location"); resp.StatusCode >= 300 && resp.StatusCode < 400 && location != "" {
+		next, err := u.Parse(location)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse redirect link: %w", err)
+		}
+		return nil, next, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, StatusError{StatusCode: resp.StatusCode, URL: u.String()}
+	}
+
+	page := &Page{URL: u, StatusCode: resp.StatusCode, Header: resp.Header}
+	page.ContentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	// The first bytes are read before the type is known, to sniff it when it is missing
+	head, err := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response body: %w", err)
+	}
+	if genericTypes[page.ContentType] {
+		page.ContentType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+	}
+
+	limit, ok := f.Types[page.ContentType]
+	switch {
+	case f.Probe && !isHTML(page):
+		return page, nil, nil
+	case f.Probe:
+		limit = MaxPageSize
+	case !ok:
+		return nil, nil, fmt.Errorf("%w: %s", errors.ErrUnsupportedContent, page.ContentType)
+	case resp.ContentLength > limit:
+		return nil, nil, fmt.Errorf("%w: %d bytes", errors.ErrPageTooLarge, resp.ContentLength)
+	}
+	rest, err := io.ReadAll(io.LimitReader(resp.Body, limit+1-int64(len(head))))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response body: %w", err)
+	}
+	page.Body = append(head, rest...)
+	if int64(len(page.Body)) > limit {
+		if !f.Probe {
+			return nil, nil, fmt.Errorf("%w: over %d bytes", errors.ErrPageTooLarge, limit)
+		}
+		page.Body = page.Body[:limit]
+	}
+	return page, nil, nil
+}
+
+// isHTML tells if the page may have meta refresh tags
+func isHTML(page *Page) bool {
+	return page.ContentType == "text/html" || page.ContentType == "application/xhtml+xml"
+}
@@ -0,0 +1,275 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/arashthr/pensive/internal/errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/http/httpguts"
+)
+
+const maxProfileField = 8 << 10 // Longest user agent, header value, cookie or selector list
+
+// forbiddenHeaders are set by the fetcher or the transport. The cookies have their own field.
+var forbiddenHeaders = map[string]bool{
+	"Host":                true,
+	"Connection":          true,
+	"Content-Length":      true,
+	"Transfer-Encoding":   true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Upgrade":             true,
+	"Keep-Alive":          true,
+	"Accept-Encoding":     true,
+	"Proxy-Connection":    true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// Profile is how the pages of a domain and its subdomains are fetched: the user agent,
+// headers and cookies sent with the requests, and the parts of the page kept or removed
+// before the article is extracted. The requests to other hosts, like after a redirect to
+// another site, don't get the headers and cookies.
+type Profile struct {
+	Domain    string
+	UserAgent string
+	Headers   map[string]string
+	Cookies   string // Like the Cookie header: "name=value; other=value"
+	// CSS selectors, one per line or separated by commas. Only the elements matching Include
+	// are kept when there are some, then the elements matching Exclude are removed.
+	Include string
+	Exclude string
+}
+
+type profileKey struct{}
+
+// WithProfile returns the context that makes the backends fetch with the profile
+func WithProfile(ctx context.Context, p *Profile) context.Context {
+	return context.WithValue(ctx, profileKey{}, p)
+}
+
+// ProfileFrom returns the profile of the context, nil when there is none
+func ProfileFrom(ctx context.Context) *Profile {
+	p, _ := ctx.Value(profileKey{}).(*Profile)
+	return p
+}
+
+// Matches tells if the link is on the domain of the profile or one of its subdomains
+func (p *Profile) Matches(u *url.URL) bool {
+	if p == nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == p.Domain || strings.HasSuffix(host, "."+p.Domain)
+}
+
+// header returns the headers of the profile for the link, nil when the link is not on its domain
+func (p *Profile) header(u *url.URL) map[string]string {
+	if !p.Matches(u) {
+		return nil
+	}
+	header := map[string]string{}
+	for name, value := range p.Headers {
+		header[name] = value
+	}
+	if p.UserAgent != "" {
+		header["User-Agent"] = p.UserAgent
+	}
+	if p.Cookies != "" {
+		header["Cookie"] = p.Cookies
+	}
+	return header
+}
+
+// apply sets the headers of the profile on the request
+func (p *Profile) apply(req *http.Request) {
+	for name, value := range p.header(req.URL) {
+		req.Header.Set(name, value)
+	}
+}
+
+// NormalizeDomain returns the lowercase host of the domain, which may be given as a link
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if strings.Contains(domain, "://") {
+		u, err := url.Parse(domain)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid domain %q", errors.ErrInvalidFetchProfile, domain)
+		}
+		domain = u.Hostname()
+	}
+	domain = strings.TrimSuffix(strings.TrimPrefix(domain, "*."), ".")
+	if domain == "" || len(domain) > 253 || strings.ContainsAny(domain, "/:@ ") || !strings.Contains(domain, ".") {
+		return "", fmt.Errorf("%w: invalid domain %q", errors.ErrInvalidFetchProfile, domain)
+	}
+	return domain, nil
+}
+
+// Validate checks the profile before it is saved
+func (p *Profile) Validate() error {
+	if _, err := NormalizeDomain(p.Domain); err != nil {
+		return err
+	}
+	for _, value := range []string{p.UserAgent, p.Cookies} {
+		if len(value) > maxProfileField || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("%w: invalid user agent or cookies", errors.ErrInvalidFetchProfile)
+		}
+	}
+	for name, value := range p.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || forbiddenHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("%w: header %q can't be set", errors.ErrInvalidFetchProfile, name)
+		}
+		if len(value) > maxProfileField || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("%w: invalid value of header %q", errors.ErrInvalidFetchProfile, name)
+		}
+	}
+	for _, selectors := range []string{p.Include, p.Exclude} {
+		if len(selectors) > maxProfileField {
+			return fmt.Errorf("%w: too many selectors", errors.ErrInvalidFetchProfile)
+		}
+		if _, err := parseSelectors(selectors); err != nil {
+			return fmt.Errorf("%w: %w", errors.ErrInvalidFetchProfile, err)
+		}
+	}
+	return nil
+}
+
+// ParseHeaders reads headers written one per line, like "Authorization: Bearer token"
+func ParseHeaders(text string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%w: headers should be like \"Name: value\", got %q", errors.ErrInvalidFetchProfile, line)
+		}
+		headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// parseSelectors compiles the selectors, one per line or separated by commas. It returns nil
+// when there are none.
+func parseSelectors(selectors string) (cascadia.SelectorGroup, error) {
+	var lines []string
+	for _, line := range strings.Split(selectors, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	group, err := cascadia.ParseGroup(strings.Join(lines, ", "))
+	if err != nil {
+		return nil, fmt.Errorf("invalid selectors: %w", err)
+	}
+	return group, nil
+}
+
+// HasSelectors tells if the profile changes the pages
+func (p *Profile) HasSelectors() bool {
+	return p != nil && (strings.TrimSpace(p.Include) != "" || strings.TrimSpace(p.Exclude) != "")
+}
+
+// SelectPage applies the selectors of the profile to the HTML page
+func (p *Profile) SelectPage(page []byte) ([]byte, error) {
+	if !p.HasSelectors() {
+		return page, nil
+	}
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("parse page: %w", err)
+	}
+	if err := p.selectNodes(doc); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := html.Render(&b, doc); err != nil {
+		return nil, fmt.Errorf("render page: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// SelectContent applies the selectors of the profile to the HTML of an article
+func (p *Profile) SelectContent(content string) (string, error) {
+	if !p.HasSelectors() {
+		return content, nil
+	}
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("parse content: %w", err)
+	}
+	if err := p.selectNodes(doc); err != nil {
+		return "", err
+	}
+	body := cascadia.Query(doc, cascadia.MustCompile("body"))
+	if body == nil {
+		return "", nil
+	}
+	var b strings.Builder
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return "", fmt.Errorf("render content: %w", err)
+		}
+	}
+	return b.String(), nil
+}
+
+// selectNodes keeps the elements matching the include selectors in the body, in the order of
+// the page, then removes the elements matching the exclude selectors. The page is left as is
+// when no element matches the include selectors, as they may be out of date.
+func (p *Profile) selectNodes(doc *html.Node) error {
+	include, err := parseSelectors(p.Include)
+	if err != nil {
+		return err
+	}
+	exclude, err := parseSelectors(p.Exclude)
+	if err != nil {
+		return err
+	}
+	body := cascadia.Query(doc, cascadia.MustCompile("body"))
+	if include != nil && body != nil {
+		var kept []*html.Node
+		matched := map[*html.Node]bool{}
+		for _, n := range cascadia.QueryAll(body, include) {
+			matched[n] = true
+			// The elements inside a kept element come with it
+			inside := false
+			for a := n.Parent; a != nil && !inside; a = a.Parent {
+				inside = matched[a]
+			}
+			if !inside {
+				kept = append(kept, n)
+			}
+		}
+		if len(kept) > 0 {
+			for _, n := range kept {
+				n.Parent.RemoveChild(n)
+			}
+			for body.FirstChild != nil {
+				body.RemoveChild(body.FirstChild)
+			}
+			for _, n := range kept {
+				body.AppendChild(n)
+			}
+		}
+	}
+	if exclude != nil {
+		for _, n := range cascadia.QueryAll(doc, exclude) {
+			if n.Parent != nil {
+				n.Parent.RemoveChild(n)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// EmbeddingKind is what a text is embedded as. Some models embed the documents and the queries
+// searching them differently.
+type EmbeddingKind int
+
+const (
+	EmbedDocument EmbeddingKind = iota
+	EmbedQuery
+)
+
+// Embedder turns texts into vectors. Vectors of different models or dimensions can't be
+// compared, so they are stored with the model and dimension that made them.
+type Embedder interface {
+	Name() string
+	Model() string
+	Dimension() int
+	Embed(ctx context.Context, kind EmbeddingKind, title, text string) ([]float32, error)
+}
+
+// Default embedding models of the providers and their dimensions
+var defaultEmbeddingModels = map[string]struct {
+	model     string
+	dimension int
+}{
+	ProviderGemini: {"gemini-embedding-001", 768},
+	ProviderOpenAI: {"text-embedding-3-small", 1536},
+	ProviderOllama: {"nomic-embed-text", 768},
+	ProviderFake:   {"fake-embedding", 768},
+}
+
+// NewEmbedder builds the embedding provider from the configuration. The dimension is required
+// for the models that are not a default of their provider, and 0 uses the default dimension.
+func NewEmbedder(provider, baseURL, apiKey, model string, dimension int, gemini *genai.Client) (Embedder, error) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if provider == "" {
+		provider = ProviderGemini
+	}
+	defaults, ok := defaultEmbeddingModels[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding provider %q", provider)
+	}
+	if model = strings.TrimSpace(model); model == "" {
+		model = defaults.model
+	}
+	if dimension == 0 {
+		if model != defaults.model {
+			return nil, fmt.Errorf("the dimension of embedding model %q is required", model)
+		}
+		dimension = defaults.dimension
+	}
+	if dimension < 0 || dimension > maxEmbeddingDimension {
+		return nil, fmt.Errorf("embedding dimension should be between 1 and %d, got %d", maxEmbeddingDimension, dimension)
+	}
+
+	base := embedder{provider: provider, model: model, dimension: dimension}
+	switch provider {
+	case ProviderGemini:
+		if gemini == nil {
+			return nil, fmt.Errorf("gemini embeddings need GEMINI_API_KEY")
+		}
+		return GeminiEmbedder{embedder: base, Client: gemini}, nil
+	case ProviderOpenAI:
+		if baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+		return OpenAIEmbedder{embedder: base, BaseURL: strings.TrimSuffix(baseURL, "/"), APIKey: apiKey}, nil
+	case ProviderOllama:
+		if baseURL == "" {
+			baseURL = "http://localhost:11434"
+		}
+		return OllamaEmbedder{embedder: base, BaseURL: strings.TrimSuffix(baseURL, "/")}, nil
+	}
+	return FakeEmbedder{embedder: base}, nil
+}
+
+// maxEmbeddingDimension is the largest dimension of the vectors pgvector can index with HNSW
+const maxEmbeddingDimension = 2000
+
+// embedder holds what every embedding provider has
+type embedder struct {
+	provider  string
+	model     string
+	dimension int
+}
+
+func (e embedder) Name() string   { return e.provider }
+func (e embedder) Model() string  { return e.model }
+func (e embedder) Dimension() int { return e.dimension }
+
+// check fails for the vectors that don't have the dimension of the model
+func (e embedder) check(vector []float32) ([]float32, error) {
+	if len(vector) != e.dimension {
+		return nil, fmt.Errorf("embedding of %s has %d dimensions instead of %d", e.model, len(vector), e.dimension)
+	}
+	return vector, nil
+}
+
+// GeminiEmbedder embeds with the Gemini API
+type GeminiEmbedder struct {
+	embedder
+	Client *genai.Client
+}
+
+func (g GeminiEmbedder) Embed(ctx context.Context, kind EmbeddingKind, title, text string) ([]float32, error) {
+	dimension := int32(g.dimension)
+	config := genai.EmbedContentConfig{
+		OutputDimensionality: &dimension,
+		TaskType:             "RETRIEVAL_DOCUMENT",
+		Title:                title,
+	}
+	if kind == EmbedQuery {
+		config.TaskType = "RETRIEVAL_QUERY"
+		config.Title = ""
+	}
+	result, err := g.Client.Models.EmbedContent(ctx, g.model, genai.Text(text), &config)
+	if err != nil {
+		return nil, fmt.Errorf("embed with gemini: %w", err)
+	}
+	if len(result.Embeddings) == 0 {
+		return nil, fmt.Errorf("embed with gemini: no embedding returned")
+	}
+	return g.check(result.Embeddings[0].Values)
+}
+
+// OpenAIEmbedder embeds with an API compatible with the embeddings of OpenAI
+type OpenAIEmbedder struct {
+	embedder
+	BaseURL string // Like https://api.openai.com/v1
+	APIKey  string // Not sent when empty
+}
+
+func (o OpenAIEmbedder) Embed(ctx context.Context, kind EmbeddingKind, title, text string) ([]float32, error) {
+	request := map[string]any{
+		"model": o.model,
+		"input": documentText(kind, title, text),
+	}
+	// Only the models that can shorten their vectors accept a dimension
+	if defaults := defaultEmbeddingModels[ProviderOpenAI]; o.model != defaults.model || o.dimension != defaults.dimension {
+		request["dimensions"] = o.dimension
+	}
+	var response struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := postJSON(ctx, o.BaseURL+"/embeddings", o.APIKey, request, &response); err != nil {
+		return nil, fmt.Errorf("embed with openai: %w", err)
+	}
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("embed with openai: no embedding returned")
+	}
+	return o.check(response.Data[0].Embedding)
+}
+
+// OllamaEmbedder embeds with a local Ollama server
+type OllamaEmbedder struct {
+	embedder
+	BaseURL string // Like http://localhost:11434
+}
+
+func (o OllamaEmbedder) Embed(ctx context.Context, kind EmbeddingKind, title, text string) ([]float32, error) {
+	request := map[string]any{
+		"model": o.model,
+		"input": documentText(kind, title, text),
+	}
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := postJSON(ctx, o.BaseURL+"/api/embed", "", request, &response); err != nil {
+		return nil, fmt.Errorf("embed with ollama: %w", err)
+	}
+	if len(response.Embeddings) == 0 {
+		return nil, fmt.Errorf("embed with ollama: no embedding returned")
+	}
+	return o.check(response.Embeddings[0])
+}
+
+// FakeEmbedder embeds without calling any model, for tests and offline use. The same text
+// always gets the same unit vector.
+type FakeEmbedder struct {
+	embedder
+}
+
+func (f FakeEmbedder) Embed(ctx context.Context, kind EmbeddingKind, title, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	vector := make([]float32, f.dimension)
+	seed := sha256.Sum256([]byte(documentText(kind, title, text)))
+	var norm float64
+	for i := range vector {
+		block := sha256.Sum256(append(seed[:], byte(i), byte(i>>8)))
+		value := float64(binary.BigEndian.Uint32(block[:4]))/math.MaxUint32*2 - 1
+		vector[i] = float32(value)
+		norm += value * value
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector, nil
+}
+
+// documentText puts the title of the documents before their text for the models that only take
+// a text
+func documentText(kind EmbeddingKind, title, text string) string {
+	if kind == EmbedDocument && title != "" {
+		return title + "\n\n" + text
+	}
+	return text
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Fake answers without calling any model, so the AI processing can run in tests and offline.
+// The same prompt always gets the same answer, and the summaries follow the format asked by
+// the summarization prompt of the bookmarks.
+type Fake struct{}
+
+func (Fake) Name() string { return ProviderFake }
+
+func (Fake) Model(task Task) string { return "fake-" + string(task) }
+
+func (Fake) Generate(ctx context.Context, task Task, prompt string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(prompt))
+	id := hex.EncodeToString(sum[:4])
+	switch task {
+	case TaskSummarize:
+		return fmt.Sprintf(`===MARKDOWN===
+# Fake markdown %[1]s
+
+Content of the page.
+===END MARKDOWN===
+
+===SUMMARY===
+Fake summary %[1]s.
+===END SUMMARY===
+
+===EXCERPT===
+Fake excerpt %[1]s.
+===END EXCERPT===
+
+===TAGS===
+fake,tag-%[1]s
+===END TAGS===`, id), nil
+	case TaskPodcast:
+		return fmt.Sprintf("Fake podcast script %[1]s.\n\n[ARTICLE_BREAK]\n\nEnd of the fake podcast %[1]s.", id), nil
+	}
+	return fmt.Sprintf("Fake answer %s.", id), nil
+}
@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestFakeGenerate(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		task Task
+		want *regexp.Regexp
+	}{
+		{TaskSummarize, regexp.MustCompile(`(?s)^===MARKDOWN===\n# Fake markdown [0-9a-f]{8}\n.*===SUMMARY===\nFake summary [0-9a-f]{8}\.\n===END SUMMARY===.*===TAGS===\nfake,tag-[0-9a-f]{8}\n===END TAGS===$`)},
+		{TaskPodcast, regexp.MustCompile(`^Fake podcast script [0-9a-f]{8}\.\n\n\[ARTICLE_BREAK\]\n\nEnd of the fake podcast [0-9a-f]{8}\.$`)},
+		{TaskRAG, regexp.MustCompile(`^Fake answer [0-9a-f]{8}\.$`)},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.task), func(t *testing.T) {
+			answer, err := Fake{}.Generate(ctx, tt.task, "prompt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want.MatchString(answer) {
+				t.Errorf("answer does not match %s:\n%s", tt.want, answer)
+			}
+			again, _ := Fake{}.Generate(ctx, tt.task, "prompt")
+			if again != answer {
+				t.Errorf("same prompt, different answers:\n%s\n%s", answer, again)
+			}
+			other, _ := Fake{}.Generate(ctx, tt.task, "another prompt")
+			if other == answer {
+				t.Error("different prompts, same answer")
+			}
+		})
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := (Fake{}).Generate(cancelled, TaskRAG, "prompt"); err == nil {
+		t.Error("Generate succeeded with a cancelled context")
+	}
+}
+
+func TestFakeEmbed(t *testing.T) {
+	embedder, err := NewEmbedder(ProviderFake, "", "", "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if embedder.Model() != "fake-embedding" || embedder.Dimension() != 768 {
+		t.Errorf("model %q, dimension %d", embedder.Model(), embedder.Dimension())
+	}
+	ctx := context.Background()
+	vector, err := embedder.Embed(ctx, EmbedDocument, "Title", "Text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vector) != 768 {
+		t.Fatalf("%d dimensions, want 768", len(vector))
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-4 {
+		t.Errorf("norm %f, want a unit vector", math.Sqrt(norm))
+	}
+
+	again, _ := embedder.Embed(ctx, EmbedDocument, "Title", "Text")
+	query, _ := embedder.Embed(ctx, EmbedQuery, "", "Title\n\nText")
+	other, _ := embedder.Embed(ctx, EmbedDocument, "Title", "Other text")
+	if !slices.Equal(vector, again) {
+		t.Error("same text, different vectors")
+	}
+	// The title is part of the text of the documents
+	if !slices.Equal(vector, query) {
+		t.Error("the document and the query of the same text have different vectors")
+	}
+	if slices.Equal(vector, other) {
+		t.Error("different texts, same vector")
+	}
+}
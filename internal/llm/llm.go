@@ -0,0 +1,111 @@
+// Package llm generates text and embeddings with the models of the instance: Gemini, an
+// OpenAI-compatible API, a local Ollama or a fake model for tests. Each task can use its own
+// language model, like a small model for the summaries and a larger one for the podcast scripts.
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Task is what the text is generated for
+type Task string
+
+const (
+	TaskSummarize Task = "summarize" // Markdown, summary, excerpt and tags of the bookmarks
+	TaskRAG       Task = "rag"       // Answers to the questions about the library
+	TaskPodcast   Task = "podcast"   // Scripts of the podcasts
+)
+
+// Names of the providers, set with LLM_PROVIDER
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+	ProviderFake   = "fake"
+)
+
+// Timeout is how long a generation may take, the podcast scripts take the longest
+const Timeout = 5 * time.Minute
+
+// maxResponseSize is the largest response read from the HTTP providers
+const maxResponseSize = 8 << 20
+
+// httpClient talks to the OpenAI-compatible and Ollama APIs
+var httpClient = &http.Client{Timeout: Timeout}
+
+// LLM generates text for a task with the model set for it
+type LLM interface {
+	Name() string
+	Model(task Task) string
+	Generate(ctx context.Context, task Task, prompt string) (string, error)
+}
+
+// Models are the models of the tasks. Tasks without a model use the default model of the
+// provider.
+type Models map[Task]string
+
+// model returns the model of the task, or fallback when none is set
+func (m Models) model(task Task, fallback string) string {
+	if name := strings.TrimSpace(m[task]); name != "" {
+		return name
+	}
+	return fallback
+}
+
+// New builds the provider from the configuration. The base URL and API key are only used by the
+// HTTP providers, and the Gemini client only by Gemini.
+func New(provider, baseURL, apiKey string, models Models, gemini *genai.Client) (LLM, error) {
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case ProviderGemini, "":
+		if gemini == nil {
+			return nil, fmt.Errorf("gemini provider needs GEMINI_API_KEY")
+		}
+		return Gemini{Client: gemini, Models: models}, nil
+	case ProviderOpenAI:
+		if baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+		return OpenAI{BaseURL: strings.TrimSuffix(baseURL, "/"), APIKey: apiKey, Models: models}, nil
+	case ProviderOllama:
+		if baseURL == "" {
+			baseURL = "http://localhost:11434"
+		}
+		return Ollama{BaseURL: strings.TrimSuffix(baseURL, "/"), Models: models}, nil
+	case ProviderFake:
+		return Fake{}, nil
+	}
+	return nil, fmt.Errorf("unknown llm provider %q", provider)
+}
+
+// ParseModels reads the models of the tasks from the configuration: a model for every task, and
+// the models of some tasks, like "summarize=llama3.1:8b;podcast=llama3.1:70b"
+func ParseModels(model, taskModels string) (Models, error) {
+	models := Models{}
+	if model = strings.TrimSpace(model); model != "" {
+		for _, task := range []Task{TaskSummarize, TaskRAG, TaskPodcast} {
+			models[task] = model
+		}
+	}
+	for _, rule := range strings.Split(taskModels, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		task, name, ok := strings.Cut(rule, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("model of a task should be like task=model: %q", rule)
+		}
+		switch t := Task(strings.ToLower(strings.TrimSpace(task))); t {
+		case TaskSummarize, TaskRAG, TaskPodcast:
+			models[t] = strings.TrimSpace(name)
+		default:
+			return nil, fmt.Errorf("unknown llm task %q", task)
+		}
+	}
+	return models, nil
+}
@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Gemini generates text with the Gemini API
+type Gemini struct {
+	Client *genai.Client
+	Models Models
+}
+
+func (Gemini) Name() string { return ProviderGemini }
+
+func (g Gemini) Model(task Task) string { return g.Models.model(task, "gemini-3-flash-preview") }
+
+func (g Gemini) Generate(ctx context.Context, task Task, prompt string) (string, error) {
+	result, err := g.Client.Models.GenerateContent(ctx, g.Model(task), genai.Text(prompt), nil)
+	if err != nil {
+		return "", fmt.Errorf("generate content with gemini: %w", err)
+	}
+	return result.Text(), nil
+}
+
+// OpenAI generates text with an API compatible with the chat completions of OpenAI, like
+// OpenAI itself, vLLM, LM Studio or OpenRouter
+type OpenAI struct {
+	BaseURL string // Like https://api.openai.com/v1
+	APIKey  string // Not sent when empty
+	Models  Models
+}
+
+func (OpenAI) Name() string { return ProviderOpenAI }
+
+func (o OpenAI) Model(task Task) string { return o.Models.model(task, "gpt-4o-mini") }
+
+func (o OpenAI) Generate(ctx context.Context, task Task, prompt string) (string, error) {
+	request := map[string]any{
+		"model":    o.Model(task),
+		"messages": []map[string]string{{"role": "user", "content": prompt}},
+	}
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := postJSON(ctx, o.BaseURL+"/chat/completions", o.APIKey, request, &response); err != nil {
+		return "", fmt.Errorf("generate content with openai: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("generate content with openai: no choices returned")
+	}
+	return response.Choices[0].Message.Content, nil
+}
+
+// Ollama generates text with a local Ollama server
+type Ollama struct {
+	BaseURL string // Like http://localhost:11434
+	Models  Models
+}
+
+func (Ollama) Name() string { return ProviderOllama }
+
+func (o Ollama) Model(task Task) string { return o.Models.model(task, "llama3.1") }
+
+func (o Ollama) Generate(ctx context.Context, task Task, prompt string) (string, error) {
+	request := map[string]any{
+		"model":  o.Model(task),
+		"prompt": prompt,
+		"stream": false,
+	}
+	var response struct {
+		Response string `json:"response"`
+	}
+	if err := postJSON(ctx, o.BaseURL+"/api/generate", "", request, &response); err != nil {
+		return "", fmt.Errorf("generate content with ollama: %w", err)
+	}
+	return response.Response, nil
+}
+
+// postJSON sends the request to the endpoint and decodes the response into response
+func postJSON(ctx context.Context, endpoint, apiKey string, request, response any) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("perform request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// The error of the API, like a missing model, is in the body
+		message := strings.TrimSpace(string(body))
+		if len(message) > 300 {
+			message = message[:300] + "..."
+		}
+		return fmt.Errorf("status %d: %s", resp.StatusCode, message)
+	}
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/llm"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AIBackfillStatus string
+
+const (
+	AIBackfillStatusRunning AIBackfillStatus = "running"
+	AIBackfillStatusPaused  AIBackfillStatus = "paused"
+	AIBackfillStatusDone    AIBackfillStatus = "done"
+)
+
+const (
+	aiBackfillBatchSize = 10 // Bookmarks queued by a backfill at a time
+	aiBackfillMaxQueued = 20 // A backfill waits while the user has this many jobs waiting or running
+)
+
+// AIBackfill generates the AI data and embeddings of the bookmarks of a user that have none,
+// like the ones imported from Pocket or saved before the AI processing. The bookmarks embedded
+// with another model than the configured one are embedded again.
+type AIBackfill struct {
+	UserId      types.UserId
+	Status      AIBackfillStatus
+	LastItemId  string // The bookmarks are queued in the order of their id, after this one
+	Queued      int
+	StartedAt   time.Time
+	CompletedAt *time.Time
+	UpdatedAt   time.Time
+}
+
+type AIBackfillRepo struct {
+	Pool     *pgxpool.Pool
+	Embedder llm.Embedder // Only the AI data is backfilled when nil
+}
+
+const aiBackfillColumns = `user_id, status, last_item_id, queued, started_at, completed_at, updated_at`
+
+// needsAIBackfill selects the bookmarks with content but without AI data, or without an
+// embedding of the model. It is used with library_items as li and library_contents as lc, and
+// the model and dimension are the parameters n and n+1, an empty model when there is none.
+func needsAIBackfill(n int) string {
+	return fmt.Sprintf(`lc.content <> '' AND (li.ai_summary IS NULL OR ($%[1]d <> '' AND
+		(lc.embedding_model IS DISTINCT FROM $%[1]d OR lc.embedding_dimension IS DISTINCT FROM $%[2]d)))`, n, n+1)
+}
+
+// dailyLimitUsed counts the bookmarks saved today and the ones summarized by a backfill today,
+// they share the daily bookmark limit of the user. The embeddings are cheap, they are not
+// counted. It is used with the user as $1, the day as $2 and its start and end as $3 and $4.
+const dailyLimitUsed = `
+	(SELECT COUNT(*) FROM library_items WHERE user_id = $1 AND created_at >= $3 AND created_at < $4) +
+	COALESCE((SELECT backfill_count FROM daily_ai_limits WHERE user_id = $1 AND day = $2), 0)`
+
+// embedding returns the model and dimension of the embeddings for needsAIBackfill
+func (r *AIBackfillRepo) embedding() (string, int) {
+	if r.Embedder == nil {
+		return "", 0
+	}
+	return r.Embedder.Model(), r.Embedder.Dimension()
+}
+
+// Start starts the backfill of the user. A paused backfill resumes where it stopped, and a done
+// one starts over to pick up the bookmarks missed since.
+func (r *AIBackfillRepo) Start(ctx context.Context, userId types.UserId) (*AIBackfill, error) {
+	rows, err := r.Pool.Query(ctx, `
+		INSERT INTO ai_backfills (user_id)
+		VALUES ($1)
+		ON CONFLICT (user_id) DO UPDATE
+			SET status       = 'running',
+			    last_item_id = CASE WHEN ai_backfills.status = 'done' THEN '' ELSE ai_backfills.last_item_id END,
+			    queued       = CASE WHEN ai_backfills.status = 'done' THEN 0 ELSE ai_backfills.queued END,
+			    started_at   = CASE WHEN ai_backfills.status = 'done' THEN NOW() ELSE ai_backfills.started_at END,
+			    completed_at = NULL,
+			    updated_at   = NOW()
+		RETURNING `+aiBackfillColumns, userId)
+	if err != nil {
+		return nil, fmt.Errorf("start ai backfill: %w", err)
+	}
+	backfill, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[AIBackfill])
+	if err != nil {
+		return nil, fmt.Errorf("collect started ai backfill: %w", err)
+	}
+	return &backfill, nil
+}
+
+// Pause stops queuing the bookmarks of the user, the queued ones are still processed
+func (r *AIBackfillRepo) Pause(ctx context.Context, userId types.UserId) error {
+	tag, err := r.Pool.Exec(ctx, `
+		UPDATE ai_backfills
+		SET status = 'paused', updated_at = NOW()
+		WHERE user_id = $1 AND status = 'running'`, userId)
+	if err != nil {
+		return fmt.Errorf("pause ai backfill: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+// Get returns the backfill of the user
+func (r *AIBackfillRepo) Get(ctx context.Context, userId types.UserId) (*AIBackfill, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT `+aiBackfillColumns+`
+		FROM ai_backfills
+		WHERE user_id = $1`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("get ai backfill: %w", err)
+	}
+	backfill, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[AIBackfill])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, fmt.Errorf("collect ai backfill: %w", err)
+	}
+	return &backfill, nil
+}
+
+// Running returns the users with a running backfill, the ones that waited the longest first
+func (r *AIBackfillRepo) Running(ctx context.Context) ([]types.UserId, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT user_id FROM ai_backfills
+		WHERE status = 'running'
+		ORDER BY updated_at`)
+	if err != nil {
+		return nil, fmt.Errorf("get running ai backfills: %w", err)
+	}
+	userIds, err := pgx.CollectRows(rows, pgx.RowTo[types.UserId])
+	if err != nil {
+		return nil, fmt.Errorf("collect running ai backfills: %w", err)
+	}
+	return userIds, nil
+}
+
+// Missing counts the bookmarks of the user without AI data or embedding. With a nil user, it
+// counts them for every user.
+func (r *AIBackfillRepo) Missing(ctx context.Context, userId *types.UserId) (map[types.UserId]int, error) {
+	embeddingModel, embeddingDimension := r.embedding()
+	rows, err := r.Pool.Query(ctx, `
+		SELECT li.user_id, COUNT(*)
+		FROM library_items li
+		JOIN library_contents lc ON lc.id = li.id
+		WHERE ($1::int IS NULL OR li.user_id = $1) AND `+needsAIBackfill(2)+`
+		GROUP BY li.user_id`, userId, embeddingModel, embeddingDimension)
+	if err != nil {
+		return nil, fmt.Errorf("count bookmarks missing ai data: %w", err)
+	}
+	defer rows.Close()
+	missing := map[types.UserId]int{}
+	for rows.Next() {
+		var id types.UserId
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("scan bookmarks missing ai data: %w", err)
+		}
+		missing[id] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("count bookmarks missing ai data: %w", err)
+	}
+	return missing, nil
+}
+
+// RemainingBackfill returns how many bookmarks the backfill of the user can still summarize
+// today, what is left of the daily bookmark limit
+func (r *AIBackfillRepo) RemainingBackfill(ctx context.Context, user *User) (int, error) {
+	day := aiLimitDay()
+	var used int
+	err := r.Pool.QueryRow(ctx, `SELECT `+dailyLimitUsed, user.ID, day, day, day.AddDate(0, 0, 1)).Scan(&used)
+	if err != nil {
+		return 0, fmt.Errorf("get used daily limit: %w", err)
+	}
+	return max(dailyBookmarkLimit(user)-used, 0), nil
+}
+
+// QueueBatch queues the next bookmarks of the running backfill of the user. It waits while the
+// user has many jobs waiting, and stops for the day once the daily bookmark limit of the user
+// is used by the bookmarks saved and summarized that day. The saves of the user are not
+// limited by the backfill, it only takes what they leave of the limit.
+// The backfill is done once every bookmark was queued. It returns the number of queued
+// bookmarks, and nothing happens when another server is queuing the same backfill.
+func (r *AIBackfillRepo) QueueBatch(ctx context.Context, user *User) (int, error) {
+	tx, err := r.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var lastItemId string
+	err = tx.QueryRow(ctx, `
+		SELECT last_item_id FROM ai_backfills
+		WHERE user_id = $1 AND status = 'running'
+		FOR UPDATE SKIP LOCKED`, user.ID).Scan(&lastItemId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("lock ai backfill: %w", err)
+	}
+
+	day := aiLimitDay()
+	var queued, used int
+	err = tx.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM ai_jobs WHERE user_id = $1 AND status IN ('pending', 'processing')),
+			`+dailyLimitUsed, user.ID, day, day, day.AddDate(0, 0, 1)).Scan(&queued, &used)
+	if err != nil {
+		return 0, fmt.Errorf("get ai backfill limits: %w", err)
+	}
+	limit := min(aiBackfillBatchSize, aiBackfillMaxQueued-queued)
+	quota := dailyBookmarkLimit(user) - used
+	if limit <= 0 {
+		return 0, nil
+	}
+
+	embeddingModel, embeddingDimension := r.embedding()
+	rows, err := tx.Query(ctx, `
+		SELECT li.id, li.ai_summary IS NULL
+		FROM library_items li
+		JOIN library_contents lc ON lc.id = li.id
+		WHERE li.user_id = $1 AND li.id > $2 AND `+needsAIBackfill(4)+`
+		ORDER BY li.id
+		LIMIT $3`, user.ID, lastItemId, limit, embeddingModel, embeddingDimension)
+	if err != nil {
+		return 0, fmt.Errorf("get bookmarks to backfill: %w", err)
+	}
+	type item struct {
+		id          types.BookmarkId
+		needsAIData bool
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (item, error) {
+		var i item
+		err := row.Scan(&i.id, &i.needsAIData)
+		return i, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("collect bookmarks to backfill: %w", err)
+	}
+
+	queuedItems, summaries := 0, 0
+	for _, i := range items {
+		// The embedding is queued after the AI data is generated
+		kind := AIJobKindEmbedding
+		if i.needsAIData {
+			if summaries >= quota {
+				break
+			}
+			kind = AIJobKindEnrich
+			summaries++
+		}
+		if err := insertAIJob(ctx, tx, i.id, kind, "", false); err != nil {
+			return 0, err
+		}
+		lastItemId = string(i.id)
+		queuedItems++
+	}
+
+	status := AIBackfillStatusRunning
+	if len(items) < limit && queuedItems == len(items) {
+		status = AIBackfillStatusDone
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE ai_backfills
+		SET status       = $2,
+		    last_item_id = $3,
+		    queued       = queued + $4,
+		    completed_at = CASE WHEN $2 = 'done'::ai_backfill_status THEN NOW() END,
+		    updated_at   = NOW()
+		WHERE user_id = $1`, user.ID, status, lastItemId, queuedItems)
+	if err != nil {
+		return 0, fmt.Errorf("update ai backfill: %w", err)
+	}
+	if summaries > 0 {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO daily_ai_limits (user_id, day, backfill_count)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, day) DO UPDATE
+			SET backfill_count = daily_ai_limits.backfill_count + EXCLUDED.backfill_count,
+			    updated_at = NOW()`, user.ID, day, summaries)
+		if err != nil {
+			return 0, fmt.Errorf("increment backfill count: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit transaction: %w", err)
+	}
+	return queuedItems, nil
+}
+
+func dailyBookmarkLimit(user *User) int {
+	if user.IsSubscriptionPremium() {
+		return PremiumUserDailyLimit
+	}
+	return FreeUserDailyLimit
+}
+
+// aiLimitDay is the day of the daily AI limits
+func aiLimitDay() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
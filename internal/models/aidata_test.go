@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/arashthr/pensive/internal/llm"
+)
+
+// The AI data of a bookmark is read from the answer of the fake model, like from a real one
+func TestPromptToGetAIDataWithFake(t *testing.T) {
+	model := &BookmarkRepo{LLM: llm.Fake{}}
+	ctx := context.Background()
+	data, err := model.promptToGetAIData(ctx, "<article><h1>Title</h1><p>Text</p></article>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Markdown == "" || data.Summary == "" || data.Excerpt == "" || data.Tags == "" {
+		t.Fatalf("missing AI data: %+v", data)
+	}
+	id := strings.TrimPrefix(data.Tags, "fake,tag-")
+	want := aiDataResponseType{
+		Markdown: "# Fake markdown " + id + "\n\nContent of the page.",
+		Summary:  "Fake summary " + id + ".",
+		Excerpt:  "Fake excerpt " + id + ".",
+		Tags:     "fake,tag-" + id,
+	}
+	if *data != want {
+		t.Errorf("AI data = %+v, want %+v", *data, want)
+	}
+}
@@ -0,0 +1,244 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AIJobStatus string
+
+const (
+	AIJobStatusPending    AIJobStatus = "pending"
+	AIJobStatusProcessing AIJobStatus = "processing"
+	AIJobStatusDone       AIJobStatus = "done"
+	AIJobStatusFailed     AIJobStatus = "failed"
+)
+
+// Kinds of AI jobs, a bookmark has at most one job of each kind
+const (
+	AIJobKindEnrich    = "enrich"    // Summary, excerpt, tags and markdown, then the embedding
+	AIJobKindEmbedding = "embedding" // Embedding of the bookmark, like after its note changes
+)
+
+const (
+	// AIJobMaxAttempts is the number of attempts before a job is marked as failed
+	AIJobMaxAttempts = 5
+	// AIJobTimeout is how long a job may run. Jobs left processing longer, like after a crash or
+	// a deploy, are retried.
+	AIJobTimeout    = 10 * time.Minute
+	aiJobBackoff    = time.Minute // Wait after the first failure, doubled after each one
+	aiJobMaxBackoff = 6 * time.Hour
+)
+
+type AIJob struct {
+	Id          int
+	BookmarkId  types.BookmarkId `db:"library_item_id"`
+	UserId      types.UserId
+	Kind        string
+	Status      AIJobStatus
+	Input       *string
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time // When a pending job is run next
+	LastError   *string
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type AIJobRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const aiJobColumns = `id, library_item_id, user_id, kind, status, input, attempts, max_attempts, run_at,
+	last_error, started_at, completed_at, created_at, updated_at`
+
+// Enqueue schedules the job of the bookmark to run now. A job of the same kind that is waiting
+// or running is replaced, so the latest content is processed. The input is the content to
+// process, the previous input or the saved text is used when it is empty.
+func (r *AIJobRepo) Enqueue(ctx context.Context, bookmarkId types.BookmarkId, kind string, input string) error {
+	return insertAIJob(ctx, r.Pool, bookmarkId, kind, input, true)
+}
+
+// insertAIJob queues the job of the bookmark on the pool or in a transaction. A job of the same
+// kind that is waiting or running is only replaced with replaceRunning.
+func insertAIJob(ctx context.Context, db execer, bookmarkId types.BookmarkId, kind string, input string, replaceRunning bool) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO ai_jobs (library_item_id, user_id, kind, input, max_attempts)
+		SELECT id, user_id, $2, NULLIF($3, ''), $4
+		FROM library_items
+		WHERE id = $1
+		ON CONFLICT (library_item_id, kind) DO UPDATE
+			SET status       = 'pending',
+			    input        = COALESCE(EXCLUDED.input, ai_jobs.input),
+			    attempts     = 0,
+			    run_at       = NOW(),
+			    last_error   = NULL,
+			    completed_at = NULL,
+			    updated_at   = NOW()
+			WHERE $5 OR ai_jobs.status NOT IN ('pending', 'processing')`,
+		bookmarkId, kind, input, AIJobMaxAttempts, replaceRunning)
+	if err != nil {
+		return fmt.Errorf("enqueue ai job: %w", err)
+	}
+	return nil
+}
+
+// Retry runs the failed jobs of the bookmark again. When none failed, the bookmark is
+// processed again from its saved content, unless it is already waiting or running.
+func (r *AIJobRepo) Retry(ctx context.Context, bookmarkId types.BookmarkId) error {
+	tag, err := r.Pool.Exec(ctx, `
+		UPDATE ai_jobs
+		SET status       = 'pending',
+		    attempts     = 0,
+		    run_at       = NOW(),
+		    last_error   = NULL,
+		    completed_at = NULL,
+		    updated_at   = NOW()
+		WHERE library_item_id = $1 AND status = 'failed'`, bookmarkId)
+	if err != nil {
+		return fmt.Errorf("retry ai jobs: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	return insertAIJob(ctx, r.Pool, bookmarkId, AIJobKindEnrich, "", false)
+}
+
+// Claim picks up to limit due jobs and marks them as processing. Jobs claimed by another
+// server are skipped.
+func (r *AIJobRepo) Claim(ctx context.Context, limit int) ([]AIJob, error) {
+	rows, err := r.Pool.Query(ctx, `
+		UPDATE ai_jobs
+		SET status     = 'processing',
+		    attempts   = attempts + 1,
+		    started_at = NOW(),
+		    updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM ai_jobs
+			WHERE status = 'pending' AND run_at <= NOW()
+			ORDER BY run_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+aiJobColumns, limit)
+	if err != nil {
+		return nil, fmt.Errorf("claim ai jobs: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByName[AIJob])
+	if err != nil {
+		return nil, fmt.Errorf("collect claimed ai jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// Done marks the job as done. Nothing changes when the job was enqueued again while it ran,
+// so the new content is processed too.
+func (r *AIJobRepo) Done(ctx context.Context, job AIJob) error {
+	_, err := r.Pool.Exec(ctx, `
+		UPDATE ai_jobs
+		SET status       = 'done',
+		    input        = NULL,
+		    last_error   = NULL,
+		    completed_at = NOW(),
+		    updated_at   = NOW()
+		WHERE id = $1 AND status = 'processing' AND started_at = $2`, job.Id, job.StartedAt)
+	if err != nil {
+		return fmt.Errorf("mark ai job done: %w", err)
+	}
+	return nil
+}
+
+// Fail records the error of the attempt. The job is retried later with a backoff, or marked as
+// failed once it reached its max attempts. It returns true when the job failed for good.
+func (r *AIJobRepo) Fail(ctx context.Context, job AIJob, jobErr error) (bool, error) {
+	var status AIJobStatus
+	err := r.Pool.QueryRow(ctx, `
+		UPDATE ai_jobs
+		SET status       = CASE
+		                       WHEN attempts >= max_attempts THEN 'failed'::ai_job_status
+		                       ELSE 'pending'::ai_job_status
+		                   END,
+		    last_error   = $2,
+		    run_at       = $3,
+		    completed_at = CASE WHEN attempts >= max_attempts THEN NOW() END,
+		    updated_at   = NOW()
+		WHERE id = $1 AND status = 'processing' AND started_at = $4
+		RETURNING status`,
+		job.Id, jobErr.Error(), nextAIJobAttempt(job.Attempts, time.Now()), job.StartedAt,
+	).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("mark ai job failed: %w", err)
+	}
+	return status == AIJobStatusFailed, nil
+}
+
+// ReapTimedOut frees the jobs left processing longer than AIJobTimeout, like after a crash, so
+// they are retried
+func (r *AIJobRepo) ReapTimedOut(ctx context.Context) (int64, error) {
+	tag, err := r.Pool.Exec(ctx, `
+		UPDATE ai_jobs
+		SET status       = CASE
+		                       WHEN attempts >= max_attempts THEN 'failed'::ai_job_status
+		                       ELSE 'pending'::ai_job_status
+		                   END,
+		    last_error   = 'timed out',
+		    run_at       = NOW(),
+		    completed_at = CASE WHEN attempts >= max_attempts THEN NOW() END,
+		    updated_at   = NOW()
+		WHERE status = 'processing'
+		  AND started_at < NOW() - $1::interval`, AIJobTimeout.String())
+	if err != nil {
+		return 0, fmt.Errorf("reap timed-out ai jobs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ForBookmark returns the AI jobs of the bookmark
+func (r *AIJobRepo) ForBookmark(ctx context.Context, bookmarkId types.BookmarkId) ([]AIJob, error) {
+	rows, err := r.Pool.Query(ctx, `
+		SELECT `+aiJobColumns+`
+		FROM ai_jobs
+		WHERE library_item_id = $1
+		ORDER BY kind DESC`, bookmarkId)
+	if err != nil {
+		return nil, fmt.Errorf("get ai jobs of bookmark: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByName[AIJob])
+	if err != nil {
+		return nil, fmt.Errorf("collect ai jobs of bookmark: %w", err)
+	}
+	return jobs, nil
+}
+
+// AIProcessingStatus sums up the jobs of a bookmark: failed when one failed for good, then
+// processing, pending and done. It is empty when the bookmark has no jobs.
+func AIProcessingStatus(jobs []AIJob) AIJobStatus {
+	var status AIJobStatus
+	rank := map[AIJobStatus]int{"": 0, AIJobStatusDone: 1, AIJobStatusPending: 2, AIJobStatusProcessing: 3, AIJobStatusFailed: 4}
+	for _, job := range jobs {
+		if rank[job.Status] > rank[status] {
+			status = job.Status
+		}
+	}
+	return status
+}
+
+// nextAIJobAttempt schedules the retry of a job that failed its nth attempt
+func nextAIJobAttempt(attempts int, now time.Time) time.Time {
+	backoff := aiJobBackoff
+	for i := 1; i < attempts && backoff < aiJobMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return now.Add(min(backoff, aiJobMaxBackoff))
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+)
+
+// RecanonicalizeStats is the outcome of Recanonicalize
+type RecanonicalizeStats struct {
+	Checked int // Bookmarks whose link was canonicalized again
+	Updated int // Bookmarks saved with a new link
+	Merged  int // Bookmarks merged into another one with the same link
+}
+
+// addAlias makes the canonical link resolve to the bookmark in GetByLink
+func (model *BookmarkRepo) addAlias(ctx context.Context, userId types.UserId, link string, id types.BookmarkId) error {
+	_, err := model.Pool.Exec(ctx, `
+		INSERT INTO library_item_aliases (user_id, link, library_item_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, link) DO UPDATE SET library_item_id = EXCLUDED.library_item_id`,
+		userId, link, id)
+	if err != nil {
+		return fmt.Errorf("add link alias: %w", err)
+	}
+	return nil
+}
+
+// Recanonicalize canonicalizes the links of all the bookmarks again, for bookmarks saved before
+// the rules changed. The previous links are kept as aliases. Bookmarks of a user that end up with
+// the same link are merged into the oldest one. With dryRun, the changes are only logged.
+func (model *BookmarkRepo) Recanonicalize(ctx context.Context, dryRun bool) (*RecanonicalizeStats, error) {
+	logger := loggercontext.Logger(ctx)
+
+	rows, err := model.Pool.Query(ctx, `
+		SELECT id, user_id, link, created_at
+		FROM library_items
+		ORDER BY user_id, created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("query bookmark links: %w", err)
+	}
+	type item struct {
+		Id        types.BookmarkId
+		UserId    types.UserId
+		Link      string
+		CreatedAt time.Time
+	}
+	items, err := pgx.CollectRows(rows, pgx.RowToStructByName[item])
+	if err != nil {
+		return nil, fmt.Errorf("collect bookmark links: %w", err)
+	}
+
+	type key struct {
+		userId types.UserId
+		link   string
+	}
+	var keys []key
+	groups := make(map[key][]item)
+	stats := &RecanonicalizeStats{}
+	for _, it := range items {
+		link, err := model.Canonicalizer.Link(it.Link)
+		if err != nil {
+			logger.Warnw("skip bookmark with invalid link", "error", err, "bookmark_id", it.Id, "link", it.Link)
+			continue
+		}
+		stats.Checked++
+		k := key{userId: it.UserId, link: link}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], it)
+	}
+
+	for _, k := range keys {
+		// Items are sorted by creation, the oldest bookmark is kept
+		group := groups[k]
+		keep := group[0]
+		for _, other := range group[1:] {
+			logger.Infow("merge bookmarks with the same link", "keep_id", keep.Id, "remove_id", other.Id,
+				"link", k.link, "removed_link", other.Link, "user_id", k.userId, "dry_run", dryRun)
+			if !dryRun {
+				if err := model.Merge(ctx, keep.Id, other.Id); err != nil {
+					return stats, fmt.Errorf("merge %s into %s: %w", other.Id, keep.Id, err)
+				}
+			}
+			stats.Merged++
+		}
+		if keep.Link == k.link {
+			continue
+		}
+		logger.Infow("update bookmark link", "bookmark_id", keep.Id, "link", keep.Link, "canonical", k.link,
+			"user_id", k.userId, "dry_run", dryRun)
+		if !dryRun {
+			if err := model.updateLink(ctx, keep.UserId, keep.Id, keep.Link, k.link); err != nil {
+				return stats, err
+			}
+		}
+		stats.Updated++
+	}
+	return stats, nil
+}
+
+// updateLink saves the bookmark with the new link, and keeps the previous one as an alias
+func (model *BookmarkRepo) updateLink(ctx context.Context, userId types.UserId, id types.BookmarkId, oldLink, link string) error {
+	tx, err := model.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE library_items SET link = $1 WHERE id = $2`, link, id)
+	if err != nil {
+		return fmt.Errorf("update bookmark link: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO library_item_aliases (user_id, link, library_item_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, link) DO UPDATE SET library_item_id = EXCLUDED.library_item_id`,
+		userId, oldLink, id)
+	if err != nil {
+		return fmt.Errorf("keep previous link as alias: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
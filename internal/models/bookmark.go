@@ -2,27 +2,36 @@ package models
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/canonical"
+	"github.com/arashthr/pensive/internal/document"
 	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/extractor"
+	"github.com/arashthr/pensive/internal/fetcher"
+	"github.com/arashthr/pensive/internal/llm"
 	"github.com/arashthr/pensive/internal/logging"
+	"github.com/arashthr/pensive/internal/searchquery"
+	"github.com/arashthr/pensive/internal/transcript"
 	"github.com/arashthr/pensive/internal/types"
 	"github.com/arashthr/pensive/internal/validations"
 	"github.com/go-shiori/go-readability"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pgvector/pgvector-go"
-	"google.golang.org/genai"
 )
 
 type BookmarkSource = int
@@ -68,8 +77,12 @@ type Bookmark struct {
 	AIExcerpt        *string
 	AITags           *string
 	ExtractionMethod types.ExtractionMethod
+	FetchBackend     *string // Fetch backend that got the page, when the server fetched it
 	CreatedAt        time.Time
 	PublishedTime    *time.Time
+	ReadingStatus    types.ReadingStatus
+	Starred          bool
+	Broken           bool `db:"-"` // From the dead-link checker, only set in listings
 }
 
 type BookmarkWithContent struct {
@@ -78,8 +91,16 @@ type BookmarkWithContent struct {
 }
 
 type BookmarkRepo struct {
-	Pool        *pgxpool.Pool
-	GenAIClient *genai.Client
+	Pool          *pgxpool.Pool
+	SnapshotModel *SnapshotRepo
+	Canonicalizer *canonical.Canonicalizer // The default rules are used when nil
+	Extractors    *extractor.Registry      // The built-in site extractors are used when nil
+	FileModel     *FileRepo                // Original documents are not kept when nil
+	Fetchers      *fetcher.Chain           // Pages are only fetched by the server when nil
+	FetchProfiles *FetchProfileRepo        // Pages are fetched without profiles when nil
+	AIJobs        *AIJobRepo               // Bookmarks are not processed by AI when nil
+	Embedder      llm.Embedder             // Bookmarks are not embedded when nil
+	LLM           llm.LLM                  // Summaries and answers, bookmarks are not processed by AI when nil
 }
 
 // TODO: Add validation of the db query inputs (Like Id)
@@ -108,10 +129,10 @@ func (model *BookmarkRepo) CreateWithContent(
 		return nil, fmt.Errorf("parse URL in create bookmark: %w", err)
 	}
 
-	canonicalizedLink := validations.CanonicalURL(parsedURL)
+	canonicalizedLink := model.Canonicalizer.URL(parsedURL)
 
 	// Check if the link already exists
-	existingBookmark, err := model.GetByLink(user.ID, canonicalizedLink)
+	existingBookmark, err := model.getByCanonicalLink(ctx, user.ID, canonicalizedLink)
 	if err != nil {
 		if !errors.Is(err, errors.ErrNotFound) {
 			return nil, fmt.Errorf("failed to collect row: %w", err)
@@ -137,14 +158,40 @@ func (model *BookmarkRepo) CreateWithContent(
 	}
 
 	// Fallback to the original method of fetching the page
-	article, err := fetchLink(ctx, link)
+	article, err := model.fetchLink(ctx, user.ID, link)
 	if err != nil {
 		logger.Warnw("Failed to fetch page on server", "error", err, "link", link)
 		// Neither Readability nor the extension provided content
 		// So we can't create a bookmark
 		if bookmarkRequest == nil {
 			logger.Warnw("Page content inaccessible", "link", link, "user_id", user.ID)
-			return nil, fmt.Errorf("page content inaccessible")
+			return nil, fmt.Errorf("%w: %w", errors.ErrPageInaccessible, err)
+		}
+	}
+
+	// The content sent along is not used for videos and documents, see below
+	if err == nil && (extractionMethod == types.ExtractionMethodServer || article.Transcript != nil || article.File != nil) {
+		extractionMethod = article.extractionMethod()
+	}
+
+	// Short links and the canonical link declared by the page are only known once it's fetched.
+	// The link it was saved from is kept as an alias.
+	var aliases []string
+	if err == nil {
+		if resolved := model.Canonicalizer.Resolve(parsedURL, article.URL, article.Canonical); resolved != canonicalizedLink {
+			existingBookmark, err := model.getByCanonicalLink(ctx, user.ID, resolved)
+			if err == nil {
+				if err := model.addAlias(ctx, user.ID, canonicalizedLink, existingBookmark.Id); err != nil {
+					logger.Warnw("Failed to add link alias", "error", err, "link", canonicalizedLink, "bookmark_id", existingBookmark.Id)
+				}
+				return existingBookmark, nil
+			}
+			if !errors.Is(err, errors.ErrNotFound) {
+				return nil, fmt.Errorf("get bookmark by resolved link: %w", err)
+			}
+			logger.Infow("Link canonicalized after fetching", "link", canonicalizedLink, "canonical", resolved)
+			aliases = append(aliases, canonicalizedLink)
+			canonicalizedLink = resolved
 		}
 	}
 
@@ -171,8 +218,10 @@ func (model *BookmarkRepo) CreateWithContent(
 		}
 	}
 
-	// If HTML content is provided, use it instead of what Readability fetched
-	if htmlContent != "" {
+	// If HTML content is provided, use it instead of what Readability fetched. The transcript of
+	// a video is kept, the page only has the description. So is the text of a document, the
+	// extension only sees the viewer of the browser.
+	if htmlContent != "" && article.Transcript == nil && article.File == nil {
 		logger.Infow("Using provided content from extension", "link", link, "htmlSize", len(htmlContent), "readabilityHtmlSize", len(article.Content))
 		textContent := allTagsRemoved(htmlContent)
 		article.TextContent = textContent
@@ -184,13 +233,28 @@ func (model *BookmarkRepo) CreateWithContent(
 	if article.Title == "" {
 		article.Title = parsedURL.String()
 	}
+	return model.insert(ctx, user, source, canonicalizedLink, article, extractionMethod, aliases, htmlContent)
+}
 
+// insert saves the new bookmark with its content, then generates its AI data and snapshot in
+// the background. The aliases are the other links of the bookmark.
+func (model *BookmarkRepo) insert(
+	ctx context.Context,
+	user *User,
+	source BookmarkSource,
+	link string,
+	article fetchedArticle,
+	extractionMethod types.ExtractionMethod,
+	aliases []string,
+	htmlContent string,
+) (*Bookmark, error) {
+	logger := loggercontext.Logger(ctx)
 	bookmarkId := strings.ToLower(rand.Text())[:8]
 	inputBookmark := Bookmark{
 		Id:               types.BookmarkId(bookmarkId),
 		UserId:           user.ID,
 		Title:            validations.CleanUpText(article.Title),
-		Link:             canonicalizedLink,
+		Link:             link,
 		Excerpt:          validations.CleanUpText(article.Excerpt),
 		ImageUrl:         article.Image,
 		PublishedTime:    article.PublishedTime,
@@ -198,6 +262,7 @@ func (model *BookmarkRepo) CreateWithContent(
 		SiteName:         article.SiteName,
 		Source:           sourceMapping[source],
 		ExtractionMethod: extractionMethod,
+		ReadingStatus:    types.ReadingStatusUnread,
 	}
 
 	if inputBookmark.ImageUrl != "" {
@@ -207,7 +272,7 @@ func (model *BookmarkRepo) CreateWithContent(
 		}
 	}
 
-	_, err = model.Pool.Exec(ctx, `
+	_, err := model.Pool.Exec(ctx, `
 		WITH inserted_bookmark AS (
 			INSERT INTO library_items (
 				id,
@@ -220,54 +285,198 @@ func (model *BookmarkRepo) CreateWithContent(
 				article_lang,
 				site_name,
 				published_time,
-				extraction_method
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+				extraction_method,
+				fetch_backend
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($14, ''))
 		)
-		INSERT INTO library_contents (id, title, excerpt, content)
-		VALUES ($1, $4, $6, $12);`,
+		INSERT INTO library_contents (id, title, excerpt, content, simhash, search_config)
+		VALUES ($1, $4, $6, $12, $13, search_config($8));`,
 		inputBookmark.Id, user.ID, inputBookmark.Link, inputBookmark.Title, inputBookmark.Source, inputBookmark.Excerpt,
 		inputBookmark.ImageUrl, inputBookmark.ArticleLang, inputBookmark.SiteName, inputBookmark.PublishedTime,
-		inputBookmark.ExtractionMethod, article.TextContent)
+		inputBookmark.ExtractionMethod, article.TextContent, fingerprint(article.TextContent), article.Backend)
 	if err != nil {
 		return nil, fmt.Errorf("bookmark create: %w", err)
 	}
+	for _, alias := range aliases {
+		if err := model.addAlias(ctx, user.ID, alias, inputBookmark.Id); err != nil {
+			logger.Warnw("Failed to add link alias", "error", err, "link", alias, "bookmark_id", inputBookmark.Id)
+		}
+	}
+	if article.Transcript != nil {
+		if err := saveTranscript(ctx, model.Pool, inputBookmark.Id, article.Transcript); err != nil {
+			logger.Warnw("Failed to save transcript", "error", err, "bookmark_id", inputBookmark.Id)
+		}
+	}
+	if article.File != nil && model.FileModel != nil {
+		if err := model.FileModel.Save(ctx, user.ID, inputBookmark.Id, *article.File); err != nil {
+			logger.Warnw("Failed to save original file", "error", err, "bookmark_id", inputBookmark.Id)
+		}
+	}
 
 	// Generate AI content for all users except for imports (like Pocket)
-	if source != Pocket && model.GenAIClient != nil {
+	if source != Pocket && model.AIAvailable() {
 		// TODO: Should I also put content in db?
 		contentForMarkdown := article.TextContent
 		if article.Content != "" {
 			contentForMarkdown = article.Content
 		}
-		// Generate the markdown content using Gemini
-		go model.generateAIData(ctx, article.Title, contentForMarkdown, link, bookmarkId)
+		// The markdown, summary and embedding are generated by the AI jobs worker
+		model.enqueueAIJob(ctx, inputBookmark.Id, AIJobKindEnrich, contentForMarkdown)
+	}
+
+	// Keep an offline copy of the page, except for imports that would fetch the whole library at once.
+	// Documents are kept as they are.
+	if source != Pocket && model.SnapshotModel != nil && article.File == nil {
+		snapCtx := loggercontext.WithLogger(context.Background(), logger)
+		go func() {
+			format, err := model.SnapshotModel.PreferredFormat(snapCtx, user.ID)
+			if err != nil {
+				logger.Warnw("Failed to get snapshot format", "error", err, "user_id", user.ID)
+				return
+			}
+			if format == types.SnapshotFormatNone {
+				return
+			}
+			err = model.SnapshotModel.Capture(snapCtx, format, user.ID, inputBookmark.Id, link, htmlContent)
+			if err != nil {
+				logger.Warnw("Failed to capture snapshot", "error", err, "link", link, "bookmark_id", bookmarkId)
+			}
+		}()
 	}
 
 	return &inputBookmark, nil
 }
 
-func fetchLink(ctx context.Context, link string) (readability.Article, error) {
+// fetchedArticle is the article of a fetched page, with the links needed to canonicalize it
+type fetchedArticle struct {
+	readability.Article
+	URL       *url.URL // Where the redirects ended
+	Canonical string   // The <link rel="canonical"> of the page
+	Extractor string   // Name of the site extractor, empty when readability was used
+	Backend   string   // Fetch backend that got the page, empty for the site extractors
+	// Transcript of a video, its text is the text content of the article
+	Transcript *transcript.Transcript
+	// Original document, like a PDF, when the link is not a web page
+	File *document.File
+}
+
+// extractionMethod is the server-side method the article was extracted with
+func (a fetchedArticle) extractionMethod() types.ExtractionMethod {
+	if a.Extractor != "" {
+		return types.ExtractionMethodSiteExtractor
+	}
+	if a.File != nil {
+		return types.ExtractionMethodDocument
+	}
+	return types.ExtractionMethodServer
+}
+
+// fetchLink extracts the article of the link with the extractor of the site if there is one,
+// and with the fetch backends otherwise or when the extractor fails. The backends are tried in
+// order until one gets the content of the page, with the fetch profile of the user for the site.
+func (model *BookmarkRepo) fetchLink(ctx context.Context, userId types.UserId, link string) (fetchedArticle, error) {
+	logger := loggercontext.Logger(ctx)
+	u, err := url.Parse(link)
+	if err != nil {
+		return fetchedArticle{}, fmt.Errorf("%w: %w", errors.ErrInvalidUrl, err)
+	}
+	if e := model.Extractors.Find(u); e != nil {
+		logger.Infow("Extracting page with site extractor", "link", link, "extractor", e.Name())
+		result, err := model.Extractors.Extract(ctx, e, u)
+		if err == nil {
+			return fetchedArticle{Article: result.Article, URL: u, Extractor: e.Name(), Transcript: result.Transcript}, nil
+		}
+		logger.Warnw("Site extractor failed, falling back to readability", "error", err, "link", link, "extractor", e.Name())
+	}
+
+	profile, err := model.FetchProfiles.ForHost(ctx, userId, u.Hostname())
+	if err != nil {
+		logger.Warnw("Failed to get fetch profile", "error", err, "link", link)
+	}
+	if profile != nil {
+		logger.Infow("Fetching page with fetch profile", "link", link, "domain", profile.Domain)
+		ctx = fetcher.WithProfile(ctx, profile)
+	}
+
+	backends := model.Fetchers.For(u)
+	var errs []error
+	for i, backend := range backends {
+		logger.Infow("Fetching page content", "link", link, "backend", backend.Name())
+		article, err := model.fetchWith(ctx, backend, link)
+		// An empty page is kept only when no other backend is left to render it
+		if err == nil && strings.TrimSpace(article.TextContent) == "" && i < len(backends)-1 {
+			err = fmt.Errorf("no content")
+		}
+		if err == nil {
+			article.Backend = backend.Name()
+			return article, nil
+		}
+		logger.Warnw("Failed to fetch page", "error", err, "link", link, "backend", backend.Name())
+		errs = append(errs, fmt.Errorf("%s: %w", backend.Name(), err))
+		// The other backends would be sent to the same address
+		if errors.Is(err, errors.ErrBlockedAddress) || errors.Is(err, errors.ErrInvalidUrl) {
+			break
+		}
+	}
+	return fetchedArticle{}, fmt.Errorf("fetch page on server: %w", errors.Join(errs...))
+}
+
+// fetchWith gets the page with the backend and extracts its article
+func (model *BookmarkRepo) fetchWith(ctx context.Context, backend fetcher.Backend, link string) (fetchedArticle, error) {
 	logger := loggercontext.Logger(ctx)
-	logger.Infow("Fetching page content", "link", link)
-	resp, err := getPage(link)
+	result, err := backend.Fetch(ctx, link)
 	if err != nil {
-		logger.Warnw("Failed to fetch page", "error", err, "link", link)
-		return readability.Article{}, fmt.Errorf("fetch page on server: %w", err)
+		return fetchedArticle{}, err
+	}
+	finalURL, page := result.Page.URL, result.Page.Body
+	// The selectors of the profile only apply to the pages of its domain
+	profile := fetcher.ProfileFrom(ctx)
+	if !profile.Matches(finalURL) {
+		profile = nil
+	}
+	if result.Article != nil {
+		article := *result.Article
+		if profile.HasSelectors() {
+			if article.Content, err = profile.SelectContent(article.Content); err != nil {
+				return fetchedArticle{}, fmt.Errorf("apply fetch profile selectors: %w", err)
+			}
+			article.TextContent = extractor.Text(article.Content)
+		}
+		return fetchedArticle{Article: article, URL: finalURL}, nil
+	}
+	if document.IsPDF(result.Page.ContentType, page) {
+		logger.Infow("Extracting PDF document", "link", link, "size", len(page))
+		return documentArticle(document.File{
+			Name:        documentName(result.Page),
+			ContentType: document.ContentTypePDF,
+			Data:        page,
+		}, finalURL)
+	}
+
+	if page, err = profile.SelectPage(page); err != nil {
+		return fetchedArticle{}, fmt.Errorf("apply fetch profile selectors: %w", err)
 	}
-	defer resp.Body.Close()
-	finalURL := resp.Request.URL
 
 	// ******
 	// TODO: readability.Check
 	// ******
 
-	article, err := readability.FromReader(resp.Body, finalURL)
+	article, err := readability.FromReader(bytes.NewReader(page), finalURL)
 	// TODO: Check for the language
 	if err != nil {
-		logger.Warnw("Failed to parse page", "error", err, "link", link)
-		return readability.Article{}, fmt.Errorf("parse page on server: %w", err)
+		return fetchedArticle{}, fmt.Errorf("parse page: %w", err)
 	}
-	return article, nil
+	fetched := fetchedArticle{Article: article, URL: finalURL, Canonical: canonical.Declared(page)}
+
+	// The captions of a video in the page are more useful than the text around it
+	t, err := model.Extractors.PageTranscript(ctx, page, finalURL, article.Language)
+	if err != nil {
+		logger.Warnw("Failed to get video captions", "error", err, "link", link)
+	} else if t != nil {
+		fetched.Transcript = t
+		fetched.TextContent = strings.TrimSpace(article.TextContent + "\n\n" + t.Text())
+	}
+	return fetched, nil
 }
 
 func (model *BookmarkRepo) GetById(id types.BookmarkId) (*Bookmark, error) {
@@ -289,9 +498,10 @@ func (model *BookmarkRepo) GetById(id types.BookmarkId) (*Bookmark, error) {
 	return &bookmark, nil
 }
 
-func (model *BookmarkRepo) GetByUserId(userId types.UserId, page int) ([]Bookmark, int, bool, error) {
+func (model *BookmarkRepo) GetByUserId(userId types.UserId, page int, filter types.BookmarkFilter) ([]Bookmark, int, bool, error) {
+	conditions, args := filterConditions(filter, []any{userId})
 	row := model.Pool.QueryRow(context.Background(), `
-		SELECT COUNT(*) FROM library_items WHERE user_id = $1`, userId)
+		SELECT COUNT(*) FROM library_items li WHERE li.user_id = $1`+conditions, args...)
 	var count int
 	err := row.Scan(&count)
 	if err != nil {
@@ -305,14 +515,17 @@ func (model *BookmarkRepo) GetByUserId(userId types.UserId, page int) ([]Bookmar
 		return nil, 0, false, fmt.Errorf("page number out of range")
 	}
 	page -= 1
+	args = append(args, PageSize, page*PageSize)
 	rows, err := model.Pool.Query(context.Background(),
-		`SELECT id, title, link, excerpt, created_at
-		FROM library_items
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2
-		OFFSET $3
-		`, userId, PageSize, page*PageSize)
+		`SELECT li.id, li.title, li.link, li.excerpt, li.created_at, li.reading_status, li.starred,
+		       COALESCE(lck.broken, false)
+		FROM library_items li
+		LEFT JOIN link_checks lck ON lck.library_item_id = li.id
+		WHERE li.user_id = $1`+conditions+fmt.Sprintf(`
+		ORDER BY li.created_at DESC
+		LIMIT $%d
+		OFFSET $%d
+		`, len(args)-1, len(args)), args...)
 	if err != nil {
 		return nil, 0, false, fmt.Errorf("query bookmark by user id: %w", err)
 	}
@@ -322,7 +535,8 @@ func (model *BookmarkRepo) GetByUserId(userId types.UserId, page int) ([]Bookmar
 	// Iterate through the result set
 	for rows.Next() {
 		var bookmark Bookmark
-		err := rows.Scan(&bookmark.Id, &bookmark.Title, &bookmark.Link, &bookmark.Excerpt, &bookmark.CreatedAt)
+		err := rows.Scan(&bookmark.Id, &bookmark.Title, &bookmark.Link, &bookmark.Excerpt, &bookmark.CreatedAt,
+			&bookmark.ReadingStatus, &bookmark.Starred, &bookmark.Broken)
 		if err != nil {
 			return nil, 0, false, fmt.Errorf("scan bookmark: %w", err)
 		}
@@ -336,6 +550,44 @@ func (model *BookmarkRepo) GetByUserId(userId types.UserId, page int) ([]Bookmar
 	return bookmarks, count, morePages, nil
 }
 
+// filterConditions builds the extra WHERE conditions for the filter on the `li` (library_items) alias.
+// The filter values are appended to args and referenced by their position.
+func filterConditions(filter types.BookmarkFilter, args []any) (string, []any) {
+	var conditions strings.Builder
+	if filter.Tag != "" {
+		args = append(args, strings.ToLower(filter.Tag))
+		fmt.Fprintf(&conditions, `
+		AND EXISTS (
+			SELECT 1 FROM library_item_tags lit
+			JOIN tags t ON t.id = lit.tag_id
+			WHERE lit.library_item_id = li.id AND t.name = $%d)`, len(args))
+	}
+	if filter.Collection != "" {
+		args = append(args, filter.Collection)
+		fmt.Fprintf(&conditions, `
+		AND EXISTS (
+			SELECT 1 FROM collection_items ci
+			JOIN collections c ON c.id = ci.collection_id
+			WHERE ci.library_item_id = li.id AND c.name = $%d)`, len(args))
+	}
+	if filter.ReadingStatus != "" {
+		args = append(args, filter.ReadingStatus)
+		fmt.Fprintf(&conditions, `
+		AND li.reading_status = $%d`, len(args))
+	}
+	if filter.Starred {
+		conditions.WriteString(`
+		AND li.starred`)
+	}
+	if filter.Broken {
+		conditions.WriteString(`
+		AND EXISTS (
+			SELECT 1 FROM link_checks chk
+			WHERE chk.library_item_id = li.id AND chk.broken)`)
+	}
+	return conditions.String(), args
+}
+
 // GetRecentRandomByUserId fetches up to `limit` random bookmarks from the past `days` days
 // Returns the bookmarks with Title, AIExcerpt (or Excerpt as fallback)
 func (model *BookmarkRepo) GetRecentRandomByUserId(userId types.UserId, days int, limit int) ([]Bookmark, error) {
@@ -441,16 +693,26 @@ func (model *BookmarkRepo) GetAllTitlesInPeriod(userId types.UserId, days int) (
 	return articles, nil
 }
 
+// GetByLink returns the bookmark of the user saved with the link, or with another link that
+// has the same canonical form
 func (model *BookmarkRepo) GetByLink(userId types.UserId, link string) (*Bookmark, error) {
-	parsedURL, err := url.Parse(link)
+	canonicalLink, err := model.Canonicalizer.Link(link)
 	if err != nil {
-		return nil, fmt.Errorf("parse URL in get bookmark by link: %w", err)
+		return nil, fmt.Errorf("canonicalize link in get bookmark by link: %w", err)
 	}
-	cannonilizedLink := validations.CanonicalURL(parsedURL)
-	rows, err := model.Pool.Query(context.Background(),
-		`SELECT *
-		FROM library_items
-		WHERE user_id = $1 AND link = $2`, userId, cannonilizedLink)
+	return model.getByCanonicalLink(context.Background(), userId, canonicalLink)
+}
+
+// getByCanonicalLink returns the bookmark whose link or one of its aliases is the canonical link
+func (model *BookmarkRepo) getByCanonicalLink(ctx context.Context, userId types.UserId, link string) (*Bookmark, error) {
+	rows, err := model.Pool.Query(ctx,
+		`SELECT li.*
+		FROM library_items li
+		WHERE li.user_id = $1
+		  AND (li.link = $2 OR li.id IN (
+		      SELECT library_item_id FROM library_item_aliases WHERE user_id = $1 AND link = $2))
+		ORDER BY li.link = $2 DESC
+		LIMIT 1`, userId, link)
 	if err != nil {
 		return nil, fmt.Errorf("query bookmark by link: %w", err)
 	}
@@ -516,24 +778,77 @@ func cleanHTMLForLLM(htmlContent string) string {
 	return strings.TrimSpace(cleaned)
 }
 
-func (model *BookmarkRepo) generateAIData(ctx context.Context, title string, content string, link string, bookmarkId string) {
+// enqueueAIJob schedules the AI processing of the bookmark. A failure only loses the AI data,
+// so it is logged and the bookmark is kept.
+func (model *BookmarkRepo) enqueueAIJob(ctx context.Context, bookmarkId types.BookmarkId, kind string, input string) {
+	if !model.AIAvailable() || (kind == AIJobKindEmbedding && model.Embedder == nil) {
+		return
+	}
+	if err := model.AIJobs.Enqueue(ctx, bookmarkId, kind, input); err != nil {
+		loggercontext.Logger(ctx).Warnw("Failed to enqueue AI job", "error", err, "bookmark_id", bookmarkId, "kind", kind)
+	}
+}
+
+// AIAvailable tells if the bookmarks are processed by AI
+func (model *BookmarkRepo) AIAvailable() bool {
+	return model.AIJobs != nil && model.LLM != nil
+}
+
+// SemanticSearchAvailable reports whether the bookmarks can be searched by meaning
+func (model *BookmarkRepo) SemanticSearchAvailable() bool {
+	return model.Embedder != nil
+}
+
+// RetryAIProcessing runs the failed AI jobs of the bookmark again, or processes it again when
+// none failed
+func (model *BookmarkRepo) RetryAIProcessing(ctx context.Context, bookmarkId types.BookmarkId) error {
+	if !model.AIAvailable() {
+		return errors.ErrAIUnavailable
+	}
+	return model.AIJobs.Retry(ctx, bookmarkId)
+}
+
+// ProcessAIJob runs an AI job of the AI jobs worker
+func (model *BookmarkRepo) ProcessAIJob(ctx context.Context, job AIJob) error {
+	switch job.Kind {
+	case AIJobKindEnrich:
+		return model.generateAIData(ctx, job)
+	case AIJobKindEmbedding:
+		return model.updateEmbedding(ctx, job.BookmarkId)
+	}
+	return fmt.Errorf("unknown ai job kind %q", job.Kind)
+}
+
+// generateAIData generates the markdown, summary, excerpt and tags of the bookmark from the
+// input of the job, or from the saved text, then schedules its embedding
+func (model *BookmarkRepo) generateAIData(ctx context.Context, job AIJob) error {
 	logger := loggercontext.Logger(ctx)
-	// Use context.Background() since this runs in a goroutine and the request context may be canceled.
-	// Propagate the logger so nested calls (e.g. generateEmbedding) can retrieve it from context.
-	genCtx := loggercontext.WithLogger(context.Background(), logger)
+	var link, content string
+	err := model.Pool.QueryRow(ctx, `
+		SELECT li.link, lc.content
+		FROM library_items li
+		JOIN library_contents lc ON lc.id = li.id
+		WHERE li.id = $1`, job.BookmarkId).Scan(&link, &content)
+	if err != nil {
+		return fmt.Errorf("get bookmark content: %w", err)
+	}
+	if job.Input != nil {
+		content = *job.Input
+	}
 	// Clean up HTML content to reduce LLM costs
 	htmlContent := cleanHTMLForLLM(content)
 	// Log the duration of the function and size of the content
 	start := time.Now()
-	logger.Infow("calling Gemini for AI data extraction",
+	logger.Infow("calling the language model for AI data extraction",
 		"link", link,
+		"provider", model.LLM.Name(),
+		"model", model.LLM.Model(llm.TaskSummarize),
 		"content_size", len(htmlContent))
-	aiDataResponse, err := model.promptToGetAIData(htmlContent)
+	aiDataResponse, err := model.promptToGetAIData(ctx, htmlContent)
 	if err != nil {
-		logger.Warnw("Gemini AI data extraction failed", "error", err, "link", link)
-		return
+		return fmt.Errorf("extract AI data: %w", err)
 	}
-	logger.Infow("Gemini AI data extraction complete",
+	logger.Infow("AI data extraction complete",
 		"link", link,
 		"elapsed", time.Since(start).Round(time.Millisecond),
 		"markdown_size", len(aiDataResponse.Markdown),
@@ -541,39 +856,103 @@ func (model *BookmarkRepo) generateAIData(ctx context.Context, title string, con
 		"excerpt_size", len(aiDataResponse.Excerpt),
 		"tags", aiDataResponse.Tags)
 	// Update the bookmark with all AI-generated content in library_items table
-	_, err = model.Pool.Exec(genCtx, `
+	_, err = model.Pool.Exec(ctx, `
 		UPDATE library_items SET ai_summary = $1, ai_excerpt = $2, ai_tags = $3 WHERE id = $4`,
-		aiDataResponse.Summary, aiDataResponse.Excerpt, aiDataResponse.Tags, bookmarkId)
+		aiDataResponse.Summary, aiDataResponse.Excerpt, aiDataResponse.Tags, job.BookmarkId)
 	if err != nil {
-		logger.Warnw("Failed to update bookmark AI content", "error", err)
+		return fmt.Errorf("update bookmark AI content: %w", err)
 	}
 
 	// Also update the markdown content in library_contents table
-	_, err = model.Pool.Exec(genCtx, `
+	_, err = model.Pool.Exec(ctx, `
 		UPDATE library_contents SET ai_markdown = $1 WHERE id = $2`,
-		aiDataResponse.Markdown, bookmarkId)
+		aiDataResponse.Markdown, job.BookmarkId)
 	if err != nil {
-		logger.Warnw("Failed to update bookmark markdown content", "error", err)
+		return fmt.Errorf("update bookmark markdown content: %w", err)
+	}
+
+	// The embedding is its own job, so a failure there doesn't generate all of this again
+	if model.Embedder != nil {
+		if err := model.AIJobs.Enqueue(ctx, job.BookmarkId, AIJobKindEmbedding, ""); err != nil {
+			return fmt.Errorf("enqueue embedding: %w", err)
+		}
 	}
+	return nil
+}
 
-	// Generate and store embedding for the content
-	logger.Infow("generating embedding for bookmark", "link", link)
-	fullAIText := aiDataResponse.Markdown + "\n\n" + aiDataResponse.Excerpt + "\n\n" + aiDataResponse.Summary
-	embedding, err := model.generateEmbedding(genCtx, title, fullAIText)
+// updateEmbedding embeds the passages of the bookmark: its overview from the note of the user
+// and the AI-generated content, then its markdown. The passages replace the previous ones, and
+// it runs again whenever the note changes.
+func (model *BookmarkRepo) updateEmbedding(ctx context.Context, bookmarkId types.BookmarkId) error {
+	logger := loggercontext.Logger(ctx)
+	if model.Embedder == nil {
+		// Embeddings are off
+		return nil
+	}
+	var userId types.UserId
+	var title, link, markdown, excerpt, summary, note string
+	err := model.Pool.QueryRow(ctx, `
+		SELECT li.user_id, li.title, li.link, COALESCE(NULLIF(lc.ai_markdown, ''), lc.content),
+			COALESCE(li.ai_excerpt, ''), COALESCE(li.ai_summary, ''), lc.note
+		FROM library_items li
+		JOIN library_contents lc ON lc.id = li.id
+		WHERE li.id = $1`, bookmarkId).Scan(&userId, &title, &link, &markdown, &excerpt, &summary, &note)
 	if err != nil {
-		logger.Warnw("Failed to generate embedding", "error", err, "link", link)
-		return
+		return fmt.Errorf("get bookmark for embedding: %w", err)
+	}
+
+	passages := append(splitPassages(overviewText(note, excerpt, summary)), splitPassages(markdown)...)
+	logger.Infow("generating embeddings for bookmark", "link", link, "passages", len(passages))
+	embeddings := make([][]float32, len(passages))
+	for i, passage := range passages {
+		embeddings[i], err = model.generateEmbedding(ctx, title, passage)
+		if err != nil {
+			return fmt.Errorf("generate embedding of passage %d: %w", i, err)
+		}
 	}
 
-	// Convert to pgvector type and store
-	_, err = model.Pool.Exec(genCtx, `
-		UPDATE library_contents SET content_embedding = $1 WHERE id = $2`,
-		pgvector.NewVector(embedding), bookmarkId)
+	// Store the passages with the model that embedded them
+	tx, err := model.Pool.Begin(ctx)
 	if err != nil {
-		logger.Warnw("Failed to store embedding", "error", err, "link", link)
-	} else {
-		logger.Infow("embedding stored successfully", "link", link, "dimensions", len(embedding))
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, `DELETE FROM library_chunks WHERE library_item_id = $1`, bookmarkId)
+	if err != nil {
+		return fmt.Errorf("delete passages: %w", err)
+	}
+	for i, passage := range passages {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO library_chunks (library_item_id, user_id, position, content, embedding, embedding_model, embedding_dimension)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			bookmarkId, userId, i, passage, pgvector.NewVector(embeddings[i]), model.Embedder.Model(), model.Embedder.Dimension())
+		if err != nil {
+			return fmt.Errorf("store passage %d: %w", i, err)
+		}
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE library_contents
+		SET embedding_model = $1, embedding_dimension = $2
+		WHERE id = $3`,
+		model.Embedder.Model(), model.Embedder.Dimension(), bookmarkId)
+	if err != nil {
+		return fmt.Errorf("store embedding model: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	logger.Infow("embeddings stored successfully", "link", link, "passages", len(passages))
+	return nil
+}
+
+// overviewText builds the first passages of a bookmark, from the note of the user and the
+// AI-generated summary and excerpt
+func overviewText(note, excerpt, summary string) string {
+	parts := []string{summary, excerpt}
+	if note = strings.TrimSpace(note); note != "" {
+		parts = append([]string{"Notes: " + note}, parts...)
 	}
+	return strings.Join(parts, "\n\n")
 }
 
 type aiDataResponseType struct {
@@ -583,10 +962,10 @@ type aiDataResponseType struct {
 	Tags     string `json:"tags"`
 }
 
-// promptToGetAIData uses Gemini to convert HTML content to markdown format and generate additional AI content
-func (model *BookmarkRepo) promptToGetAIData(htmlContent string) (*aiDataResponseType, error) {
-	if model.GenAIClient == nil {
-		return nil, fmt.Errorf("GenAI client not initialized")
+// promptToGetAIData uses the language model to convert HTML content to markdown format and generate additional AI content
+func (model *BookmarkRepo) promptToGetAIData(ctx context.Context, htmlContent string) (*aiDataResponseType, error) {
+	if model.LLM == nil {
+		return nil, fmt.Errorf("language model not initialized")
 	}
 
 	// Limit content length to avoid excessive costs (roughly 8000 characters = ~2000 tokens)
@@ -655,20 +1034,12 @@ TAGS:
 HTML content to process:
 ` + htmlContent
 
-	ctx := context.Background()
-	result, err := model.GenAIClient.Models.GenerateContent(
-		ctx,
-		"gemini-3-flash-preview",
-		genai.Text(prompt),
-		nil,
-	)
+	responseText, err := model.LLM.Generate(ctx, llm.TaskSummarize, prompt)
 	if err != nil {
-		logging.Telegram.SendMessage(fmt.Sprintf("Failed to generate content with Gemini: %v", err))
-		return nil, fmt.Errorf("generate content with Gemini: %w", err)
+		logging.Telegram.SendMessage(fmt.Sprintf("Failed to generate content with %s: %v", model.LLM.Name(), err))
+		return nil, fmt.Errorf("generate content: %w", err)
 	}
 
-	responseText := result.Text()
-
 	// Parse the structured response
 	aiDataResponse := &aiDataResponseType{}
 
@@ -695,68 +1066,40 @@ HTML content to process:
 	return aiDataResponse, nil
 }
 
-// generateEmbedding generates a vector embedding for the given text using Google's embedding model
+// generateEmbedding generates a vector embedding for a passage with the embedding model
 func (model *BookmarkRepo) generateEmbedding(ctx context.Context, title, text string) ([]float32, error) {
 	logger := loggercontext.Logger(ctx)
 
-	if model.GenAIClient == nil {
-		return nil, fmt.Errorf("GenAI client not initialized")
-	}
-
-	// Limit content length to avoid API limits
-	// Google's gemini-embedding-001 supports up to 2,048 tokens, but we'll use ~2000 chars (~500 tokens) for efficiency
-	maxLength := 2000
-	if len(text) > maxLength {
-		// Take first 1000 and last 1000 chars to capture both intro and conclusion
-		text = text[:1000] + "..." + text[len(text)-1000:]
+	if model.Embedder == nil {
+		return nil, fmt.Errorf("embedding model not initialized")
 	}
 
-	// Use Google's gemini-embedding-001 model (768 dimensions)
-	var dimension int32 = 768
-	geminiConfigs := genai.EmbedContentConfig{
-		OutputDimensionality: &dimension,
-		TaskType:             "RETRIEVAL_DOCUMENT",
-		Title:                title,
-	}
+	// The text is a passage, short enough for every embedding model
 	embedStart := time.Now()
-	logger.Debugw("calling Gemini embedding API",
-		"model", "gemini-embedding-001",
+	logger.Debugw("calling embedding API",
+		"provider", model.Embedder.Name(),
+		"model", model.Embedder.Model(),
 		"title", title,
 		"text_size", len(text))
-	result, err := model.GenAIClient.Models.EmbedContent(
-		ctx,
-		"gemini-embedding-001",
-		genai.Text(text),
-		&geminiConfigs,
-	)
+	embedding, err := model.Embedder.Embed(ctx, llm.EmbedDocument, title, text)
 	if err != nil {
-		logging.Telegram.SendMessage(fmt.Sprintf("Failed to generate embedding with Gemini: %v", err))
+		logging.Telegram.SendMessage(fmt.Sprintf("Failed to generate embedding with %s: %v", model.Embedder.Name(), err))
 		logger.Warnw("Failed to generate embedding", "error", err, "title", title)
 		return nil, fmt.Errorf("generate embedding: %w", err)
 	}
 
-	if len(result.Embeddings) == 0 {
-		logging.Telegram.SendMessage("Empty embedding returned from Gemini")
-		return nil, fmt.Errorf("empty embedding returned")
-	}
-
-	// Extract the float32 values from the first embedding
-	if len(result.Embeddings[0].Values) == 0 {
-		return nil, fmt.Errorf("embedding has no values")
-	}
-
 	logger.Debugw("embedding generated",
 		"title", title,
 		"elapsed", time.Since(embedStart).Round(time.Millisecond),
-		"dimensions", len(result.Embeddings[0].Values))
-	return result.Embeddings[0].Values, nil
+		"dimensions", len(embedding))
+	return embedding, nil
 }
 
 func (model *BookmarkRepo) generateQueryEmbedding(ctx context.Context, query string) ([]float32, error) {
 	logger := loggercontext.Logger(ctx)
 
-	if model.GenAIClient == nil {
-		return nil, fmt.Errorf("GenAI client not initialized")
+	if model.Embedder == nil {
+		return nil, fmt.Errorf("embedding model not initialized")
 	}
 
 	// Limit query length to avoid API limits
@@ -765,47 +1108,88 @@ func (model *BookmarkRepo) generateQueryEmbedding(ctx context.Context, query str
 		query = query[:maxLength] + "..."
 	}
 
-	var dimension int32 = 768
-	geminiConfigs := genai.EmbedContentConfig{
-		OutputDimensionality: &dimension,
-		TaskType:             "RETRIEVAL_QUERY",
-	}
-	result, err := model.GenAIClient.Models.EmbedContent(
-		ctx,
-		"gemini-embedding-001",
-		genai.Text(query),
-		&geminiConfigs,
-	)
+	embedding, err := model.Embedder.Embed(ctx, llm.EmbedQuery, "", query)
 	if err != nil {
 		logger.Warnw("Failed to generate embedding for query", "error", err)
 		return nil, fmt.Errorf("generate query embedding: %w", err)
 	}
+	return embedding, nil
+}
 
-	if len(result.Embeddings) == 0 {
-		return nil, fmt.Errorf("empty query embedding returned")
+func (model *BookmarkRepo) Update(bookmark *Bookmark) error {
+	_, err := model.Pool.Exec(context.Background(),
+		`UPDATE library_items SET link = $1, title = $2 WHERE id = $3`,
+		bookmark.Link, bookmark.Title, bookmark.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("update bookmark: %w", err)
 	}
+	return nil
+}
 
-	// Extract the float32 values from the first embedding
-	if len(result.Embeddings[0].Values) == 0 {
-		return nil, fmt.Errorf("query embedding has no values")
+// GetNote returns the personal markdown note of the user on the bookmark
+func (model *BookmarkRepo) GetNote(id types.BookmarkId) (string, error) {
+	var note string
+	err := model.Pool.QueryRow(context.Background(), `
+		SELECT note FROM library_contents WHERE id = $1`, id).Scan(&note)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.ErrNotFound
+		}
+		return "", fmt.Errorf("get bookmark note: %w", err)
 	}
+	return note, nil
+}
 
-	return result.Embeddings[0].Values, nil
+// SetNote replaces the note on the bookmark. The note is part of the search vector, and the
+// embedding is regenerated in the background so semantic search and questions use it too.
+func (model *BookmarkRepo) SetNote(ctx context.Context, id types.BookmarkId, note string) error {
+	if utf8.RuneCountInString(note) > MaxNoteLength {
+		return errors.ErrNoteTooLong
+	}
+	tag, err := model.Pool.Exec(ctx, `
+		UPDATE library_contents SET note = $1 WHERE id = $2`, strings.TrimSpace(note), id)
+	if err != nil {
+		return fmt.Errorf("set bookmark note: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	model.enqueueAIJob(ctx, id, AIJobKindEmbedding, "")
+	return nil
+}
 
+// SetReadingStatus moves the bookmark to the given reading status
+func (model *BookmarkRepo) SetReadingStatus(id types.BookmarkId, status types.ReadingStatus) error {
+	_, err := model.Pool.Exec(context.Background(),
+		`UPDATE library_items SET reading_status = $1 WHERE id = $2`, status, id)
+	if err != nil {
+		return fmt.Errorf("set reading status: %w", err)
+	}
+	return nil
 }
 
-func (model *BookmarkRepo) Update(bookmark *Bookmark) error {
+func (model *BookmarkRepo) SetStarred(id types.BookmarkId, starred bool) error {
 	_, err := model.Pool.Exec(context.Background(),
-		`UPDATE library_items SET link = $1, title = $2 WHERE id = $3`,
-		bookmark.Link, bookmark.Title, bookmark.Id,
-	)
+		`UPDATE library_items SET starred = $1 WHERE id = $2`, starred, id)
 	if err != nil {
-		return fmt.Errorf("update bookmark: %w", err)
+		return fmt.Errorf("set starred: %w", err)
 	}
 	return nil
 }
 
 func (model *BookmarkRepo) Delete(id types.BookmarkId) error {
+	// The snapshot row is removed by the cascade, but its file has to be removed from the storage
+	if model.SnapshotModel != nil {
+		if err := model.SnapshotModel.Delete(context.Background(), id); err != nil {
+			return fmt.Errorf("delete bookmark snapshot: %w", err)
+		}
+	}
+	if model.FileModel != nil {
+		if err := model.FileModel.Delete(context.Background(), id); err != nil {
+			return fmt.Errorf("delete bookmark file: %w", err)
+		}
+	}
 	_, err := model.Pool.Exec(context.Background(),
 		`DELETE FROM library_items WHERE id = $1;`, id)
 	if err != nil {
@@ -829,88 +1213,192 @@ type SearchResult struct {
 	AITags    *string
 }
 
-func (model *BookmarkRepo) Search(user *User, query string) ([]SearchResult, error) {
-	// Sanitize and validate the search query
-	sanitizedQuery := sanitizeSearchQuery(query)
-	if sanitizedQuery == "" {
+// SearchPageSize is how many results a page of search results has
+const SearchPageSize = 10
+
+// maxSearchResults is how far the search results can be paged. Every page ranks the results
+// before it again, so deeper pages cost more.
+const maxSearchResults = 200
+
+// SearchPage is a page of the results of a search
+type SearchPage struct {
+	Results    []SearchResult
+	Mode       types.SearchMode    // The mode used, keyword when the search fell back to it
+	NextCursor string              // Cursor of the next page, empty on the last one
+	Facets     *types.SearchFacets // Only counted for the first page
+}
+
+// Search finds the bookmarks matching the query, in the syntax of the searchquery package. The
+// keyword search matches its words, the semantic search its meaning, and the hybrid search
+// fuses both rankings. Searches fall back to keywords when there are no embeddings, the query
+// only has filters or the embedding model fails, and the mode used is returned with the
+// results. The cursor of a page continues the same search, and the first page has an empty
+// cursor and the facets of the search. Invalid queries return errors.ErrInvalidSearchQuery and
+// invalid cursors errors.ErrInvalidSearchCursor.
+func (model *BookmarkRepo) Search(ctx context.Context, user *User, query string, filter types.BookmarkFilter, mode types.SearchMode, cursor string) (*SearchPage, error) {
+	offset, err := parseSearchCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := searchquery.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	page := &SearchPage{Results: []SearchResult{}, Mode: mode}
+	if parsed.IsEmpty() {
 		// Return empty results for empty queries instead of erroring
-		return []SearchResult{}, nil
+		return page, nil
 	}
 
-	// Try full-text search first (instant, keyword-based)
-	results, err := model.performFullTextSearch(user, sanitizedQuery)
+	// One more result than the page tells if there is a next page. The hybrid rankings are fused
+	// deeper for the later pages, which may move a result near the end of a page to the next one.
+	ranked, mode, err := model.rank(ctx, user, parsed, filter, mode, offset+SearchPageSize+1)
 	if err != nil {
-		// If full-text search fails, fall back to simple pattern matching
-		return model.performFallbackSearch(user, sanitizedQuery)
+		return nil, err
 	}
-
-	// If full-text search returns no results, try fallback search
-	if len(results) == 0 {
-		return model.performFallbackSearch(user, sanitizedQuery)
+	page.Mode = mode
+	if offset < len(ranked) {
+		page.Results = ranked[offset:min(len(ranked), offset+SearchPageSize)]
+	}
+	if next := offset + SearchPageSize; len(ranked) > next && next < maxSearchResults {
+		page.NextCursor = searchCursor(next)
 	}
 
-	return results, nil
+	if cursor == "" {
+		page.Facets, err = model.searchFacets(ctx, user, parsed, filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
 }
 
-// sanitizeSearchQuery cleans and validates search input
-func sanitizeSearchQuery(query string) string {
-	if query == "" {
-		return ""
+// rank returns the best limit results of the search in the mode, and the mode used
+func (model *BookmarkRepo) rank(ctx context.Context, user *User, query searchquery.Query, filter types.BookmarkFilter, mode types.SearchMode, limit int) ([]SearchResult, types.SearchMode, error) {
+	logger := loggercontext.Logger(ctx)
+
+	if model.Embedder == nil || !query.HasText() {
+		mode = types.SearchModeKeyword
+	}
+	if mode == types.SearchModeKeyword {
+		results, err := model.keywordSearch(user, query, filter, limit)
+		return results, mode, err
+	}
+
+	semantic, err := model.performVectorSearch(ctx, user, query, filter, limit)
+	if err != nil {
+		logger.Warnw("semantic search failed, searching keywords only", "error", err)
+		results, err := model.keywordSearch(user, query, filter, limit)
+		return results, types.SearchModeKeyword, err
+	}
+	if mode == types.SearchModeSemantic {
+		return semantic, mode, nil
 	}
 
-	// Trim whitespace and normalize
-	query = strings.TrimSpace(query)
-	if query == "" {
-		return ""
+	keyword, err := model.keywordSearch(user, query, filter, limit)
+	if err != nil {
+		return nil, mode, err
 	}
+	// The keyword results go first so their highlighted headlines are kept
+	return fuseRankings(limit, keyword, semantic), mode, nil
+}
 
-	// Remove potentially problematic tsquery operators and characters
-	// Keep alphanumeric, spaces, hyphens, and quotes for phrase searching
-	reg := regexp.MustCompile(`[^a-zA-Z0-9\s\-"']`)
-	query = reg.ReplaceAllString(query, " ")
+// Cursors of the search results are the position of the next result, encoded so the clients
+// don't depend on what they hold
+func searchCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
 
-	// Normalize multiple spaces to single spaces
-	spaceReg := regexp.MustCompile(`\s+`)
-	query = spaceReg.ReplaceAllString(query, " ")
+func parseSearchCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", errors.ErrInvalidSearchCursor, cursor)
+	}
+	offset, err := strconv.Atoi(string(decoded))
+	if err != nil || offset <= 0 || offset >= maxSearchResults {
+		return 0, fmt.Errorf("%w: %q", errors.ErrInvalidSearchCursor, cursor)
+	}
+	return offset, nil
+}
 
-	// Trim again after cleanup
-	query = strings.TrimSpace(query)
+// rrfK dampens the weight of the top ranks in the reciprocal rank fusion
+const rrfK = 60
+
+// fuseRankings merges rankings with reciprocal rank fusion: each result scores 1/(rrfK+rank) in
+// every ranking it is in. Results only need their order, so the scales of the full-text and
+// cosine ranks don't matter. The best limit results are kept.
+func fuseRankings(limit int, rankings ...[]SearchResult) []SearchResult {
+	fused := []SearchResult{}
+	positions := map[types.BookmarkId]int{}
+	for _, ranking := range rankings {
+		for i, result := range ranking {
+			score := float32(1 / float64(rrfK+i+1))
+			if position, ok := positions[result.Id]; ok {
+				fused[position].Rank += score
+				if fused[position].Headline == "" {
+					fused[position].Headline = result.Headline
+				}
+				continue
+			}
+			result.Rank = score
+			positions[result.Id] = len(fused)
+			fused = append(fused, result)
+		}
+	}
+	slices.SortStableFunc(fused, func(a, b SearchResult) int {
+		return cmp.Compare(b.Rank, a.Rank)
+	})
+	if len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return fused
+}
 
-	// Ensure we don't return empty string after sanitization
-	if query == "" {
-		return ""
+// keywordSearch returns the best limit bookmarks matching the words of the query, or only its
+// filters when it has no words
+func (model *BookmarkRepo) keywordSearch(user *User, query searchquery.Query, filter types.BookmarkFilter, limit int) ([]SearchResult, error) {
+	if !query.HasText() {
+		return model.performFilterSearch(user, query, filter, limit)
+	}
+	// Words of scripts without spaces can't be found in the search vectors
+	if query.Unsegmented() {
+		return model.performFallbackSearch(user, query, filter, limit)
 	}
 
-	return query
+	// Try full-text search first (instant, keyword-based)
+	results, err := model.performFullTextSearch(user, query, filter, limit)
+	if err != nil {
+		// If full-text search fails, fall back to simple pattern matching
+		return model.performFallbackSearch(user, query, filter, limit)
+	}
+
+	// If full-text search returns no results, try fallback search
+	if len(results) == 0 {
+		return model.performFallbackSearch(user, query, filter, limit)
+	}
+
+	return results, nil
 }
 
 // performFullTextSearch executes the full-text search using PostgreSQL's search capabilities
-func (model *BookmarkRepo) performFullTextSearch(user *User, query string) ([]SearchResult, error) {
+func (model *BookmarkRepo) performFullTextSearch(user *User, query searchquery.Query, filter types.BookmarkFilter, limit int) ([]SearchResult, error) {
+	conditions, args := filterConditions(filter, []any{textQuery(query), user.ID, limit})
+	queryConditions, args := searchConditions(query, args)
+	conditions += queryConditions
 	rows, err := model.Pool.Query(context.Background(), `
-		WITH search_terms AS (
-			SELECT string_agg(
-				CASE 
-					WHEN trim(lexeme) = '' THEN NULL
-					ELSE trim(lexeme) || ':*'
-				END, 
-				' & '
-			) AS tsquery_string
-			FROM unnest(string_to_array($1, ' ')) AS lexeme
-			WHERE trim(lexeme) != ''
-		),
-		search_query AS (
-			SELECT 
-				CASE 
-					WHEN st.tsquery_string IS NULL OR st.tsquery_string = '' THEN NULL
-					ELSE to_tsquery('english', st.tsquery_string)
-				END AS query
-			FROM search_terms st
+		-- The words are stemmed in the language of each bookmark, with its configuration
+		WITH search_query AS (
+			SELECT oid::regconfig AS config, to_tsquery(oid::regconfig, $1) AS query
+			FROM pg_ts_config
 		)
 		SELECT
 			CASE 
-				WHEN sq.query IS NOT NULL THEN 
-					ts_headline('english', lc.content, sq.query, 'MaxFragments=2, StartSel=<strong>, StopSel=</strong>')
-				ELSE lc.excerpt
+				WHEN lc.search_vector @@ sq.query THEN
+					ts_headline(lc.search_config, lc.content || E'\n\n' || lc.note, sq.query, 'MaxFragments=2, StartSel=<strong>, StopSel=</strong>')
+				ELSE ts_headline(lc.search_config, hl.text, sq.query, 'MaxFragments=2, StartSel=<strong>, StopSel=</strong>')
 			END AS headline,
 			li.id AS id,
 			li.title AS title,
@@ -918,22 +1406,26 @@ func (model *BookmarkRepo) performFullTextSearch(user *User, query string) ([]Se
 			li.excerpt AS excerpt,
 			li.image_url AS image_url,
 			li.created_at AS created_at,
-			CASE 
-				WHEN sq.query IS NOT NULL THEN ts_rank(lc.search_vector, sq.query)
-				ELSE 0.0
-			END AS rank,
+			COALESCE(ts_rank(lc.search_vector, sq.query), 0.0) + COALESCE(hl.rank, 0.0) AS rank,
 			li.ai_summary AS ai_summary,
 			li.ai_excerpt AS ai_excerpt,
 			li.ai_tags AS ai_tags
 		FROM library_items li
 		JOIN library_contents lc ON li.id = lc.id
-		CROSS JOIN search_query sq
+		JOIN search_query sq ON sq.config = lc.search_config
+		-- Highlights and their notes are searched together with the content
+		LEFT JOIN LATERAL (
+			SELECT
+				string_agg(h.text || ' ' || h.note, ' … ' ORDER BY h.start_offset) AS text,
+				max(ts_rank(h.search_vector, sq.query)) AS rank
+			FROM highlights h
+			WHERE h.library_item_id = li.id
+				AND h.search_vector @@ sq.query
+		) hl ON TRUE
 		WHERE li.user_id = $2
-			AND sq.query IS NOT NULL
-			AND lc.search_vector IS NOT NULL
-			AND lc.search_vector @@ sq.query
+			AND (lc.search_vector @@ sq.query OR hl.text IS NOT NULL)`+conditions+`
 		ORDER BY rank DESC, li.created_at DESC
-		LIMIT 10`, query, user.ID)
+		LIMIT $3`, args...)
 
 	if err != nil {
 		return nil, fmt.Errorf("full-text search failed: %w", err)
@@ -947,10 +1439,14 @@ func (model *BookmarkRepo) performFullTextSearch(user *User, query string) ([]Se
 	return results, nil
 }
 
-// performFallbackSearch performs a simpler pattern-based search when full-text search fails
-func (model *BookmarkRepo) performFallbackSearch(user *User, query string) ([]SearchResult, error) {
-	// Create a pattern for ILIKE search
-	pattern := "%" + strings.ReplaceAll(query, " ", "%") + "%"
+// performFallbackSearch performs a simpler pattern-based search when full-text search fails,
+// and for the scripts without spaces. The trigram indexes serve the patterns of the titles,
+// contents and notes.
+func (model *BookmarkRepo) performFallbackSearch(user *User, query searchquery.Query, filter types.BookmarkFilter, limit int) ([]SearchResult, error) {
+	pattern := textPattern(query.Text())
+	conditions, args := filterConditions(filter, []any{pattern, user.ID, limit})
+	queryConditions, args := searchConditions(query, args)
+	conditions += queryConditions
 
 	rows, err := model.Pool.Query(context.Background(), `
 		SELECT
@@ -962,11 +1458,12 @@ func (model *BookmarkRepo) performFallbackSearch(user *User, query string) ([]Se
 			li.image_url AS image_url,
 			li.created_at AS created_at,
 			CASE 
-				WHEN LOWER(li.title) ILIKE LOWER($1) THEN 1.0
-				WHEN LOWER(li.excerpt) ILIKE LOWER($1) THEN 0.8
-				WHEN LOWER(lc.content) ILIKE LOWER($1) THEN 0.6
-				WHEN LOWER(li.ai_summary) ILIKE LOWER($1) THEN 0.5
-				WHEN LOWER(li.ai_tags) ILIKE LOWER($1) THEN 0.3
+				WHEN li.title ILIKE $1 THEN 1.0
+				WHEN li.excerpt ILIKE $1 THEN 0.8
+				WHEN lc.note ILIKE $1 THEN 0.7
+				WHEN lc.content ILIKE $1 THEN 0.6
+				WHEN li.ai_summary ILIKE $1 THEN 0.5
+				WHEN li.ai_tags ILIKE $1 THEN 0.3
 				ELSE 0.1
 			END AS rank,
 			li.ai_summary AS ai_summary,
@@ -976,14 +1473,20 @@ func (model *BookmarkRepo) performFallbackSearch(user *User, query string) ([]Se
 		JOIN library_contents lc ON li.id = lc.id
 		WHERE li.user_id = $2
 			AND (
-				LOWER(li.title) ILIKE LOWER($1) OR
-				LOWER(li.excerpt) ILIKE LOWER($1) OR
-				LOWER(lc.content) ILIKE LOWER($1) OR
-				LOWER(COALESCE(li.ai_summary, '')) ILIKE LOWER($1) OR
-				LOWER(COALESCE(li.ai_tags, '')) ILIKE LOWER($1)
-			)
+				li.title ILIKE $1 OR
+				li.excerpt ILIKE $1 OR
+				lc.content ILIKE $1 OR
+				lc.note ILIKE $1 OR
+				COALESCE(li.ai_summary, '') ILIKE $1 OR
+				COALESCE(li.ai_tags, '') ILIKE $1 OR
+				EXISTS (
+					SELECT 1 FROM highlights h
+					WHERE h.library_item_id = li.id
+						AND (h.text ILIKE $1 OR h.note ILIKE $1)
+				)
+			)`+conditions+`
 		ORDER BY rank DESC, li.created_at DESC
-		LIMIT 10`, pattern, user.ID)
+		LIMIT $3`, args...)
 
 	if err != nil {
 		return nil, fmt.Errorf("fallback search failed: %w", err)
@@ -997,40 +1500,134 @@ func (model *BookmarkRepo) performFallbackSearch(user *User, query string) ([]Se
 	return results, nil
 }
 
-// performVectorSearch performs semantic similarity search using embeddings
-func (model *BookmarkRepo) performVectorSearch(ctx context.Context, user *User, query string) ([]SearchResult, error) {
+// performFilterSearch lists the newest bookmarks matching the filters of a query without words
+func (model *BookmarkRepo) performFilterSearch(user *User, query searchquery.Query, filter types.BookmarkFilter, limit int) ([]SearchResult, error) {
+	conditions, args := filterConditions(filter, []any{user.ID, limit})
+	queryConditions, args := searchConditions(query, args)
+	rows, err := model.Pool.Query(context.Background(), `
+		SELECT
+			COALESCE(li.excerpt, '(No excerpt available)') AS headline,
+			li.id AS id,
+			li.title AS title,
+			li.link AS link,
+			li.excerpt AS excerpt,
+			li.image_url AS image_url,
+			li.created_at AS created_at,
+			0.0::real AS rank,
+			li.ai_summary AS ai_summary,
+			li.ai_excerpt AS ai_excerpt,
+			li.ai_tags AS ai_tags
+		FROM library_items li
+		JOIN library_contents lc ON li.id = lc.id
+		WHERE li.user_id = $1`+conditions+queryConditions+`
+		ORDER BY li.created_at DESC
+		LIMIT $2`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("filter search failed: %w", err)
+	}
+
+	results, err := pgx.CollectRows(rows, pgx.RowToStructByName[SearchResult])
+	if err != nil {
+		return nil, fmt.Errorf("collect filter search rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// performVectorSearch returns the limit bookmarks most similar to the query, using embeddings.
+// Bookmarks are ranked by their best passage, which is their headline.
+func (model *BookmarkRepo) performVectorSearch(ctx context.Context, user *User, query searchquery.Query, filter types.BookmarkFilter, limit int) ([]SearchResult, error) {
+	passages, err := model.searchPassages(ctx, user, query.Text(), query, filter, limit*resultPassages)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SearchResult{}
+	seen := map[types.BookmarkId]bool{}
+	for _, passage := range passages {
+		if seen[passage.Id] {
+			continue
+		}
+		seen[passage.Id] = true
+		passage.Headline = headlineOfPassage(passage.Headline)
+		results = append(results, passage)
+		if len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// Passages retrieved by the semantic search and the answers. Some bookmarks have several of the
+// best passages, so more passages than results are retrieved.
+const (
+	resultPassages    = 5 // Passages of the semantic search for each result
+	ragPassages       = 20
+	ragSources        = 3 // Bookmarks the answers are based on
+	ragSourcePassages = 3 // Passages of each bookmark in the prompt
+)
+
+// hnsw.ef_search of pgvector, the most rows a scan of an HNSW index returns, by default and at most
+const (
+	defaultEfSearch = 40
+	maxEfSearch     = 1000
+)
+
+// searchPassages returns the passages most similar to the text, best first, of the bookmarks
+// matching the filters of the query. Each passage is a search result of its bookmark with the
+// passage as headline, as plain text.
+func (model *BookmarkRepo) searchPassages(ctx context.Context, user *User, text string, query searchquery.Query, filter types.BookmarkFilter, limit int) ([]SearchResult, error) {
 	logger := loggercontext.Logger(ctx)
 
 	// Generate embedding for the search query
-	queryEmbedding, err := model.generateQueryEmbedding(ctx, query)
+	queryEmbedding, err := model.generateQueryEmbedding(ctx, text)
 	if err != nil {
 		logger.Warnw("Failed to generate query embedding", "error", err)
 		return nil, fmt.Errorf("generate query embedding: %w", err)
 	}
 
-	// Search for similar bookmarks using cosine similarity
+	// Search for similar passages using cosine similarity
 	// The <=> operator in pgvector computes cosine distance (lower is more similar)
 	// We convert distance to similarity score for consistency with full-text search
-	rows, err := model.Pool.Query(ctx, `
+	// Only the vectors of the embedding model are compared, with the expression of its index
+	vector := embeddingVector(model.Embedder.Dimension())
+	conditions, args := filterConditions(filter, []any{
+		pgvector.NewVector(queryEmbedding), user.ID, model.Embedder.Model(), model.Embedder.Dimension(), limit,
+	})
+	queryConditions, args := searchConditions(query, args)
+
+	// The deeper pages of the results need more passages than the index returns by default
+	tx, err := model.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if limit > defaultEfSearch {
+		_, err = tx.Exec(ctx, `SELECT set_config('hnsw.ef_search', $1, true)`, strconv.Itoa(min(limit, maxEfSearch)))
+		if err != nil {
+			return nil, fmt.Errorf("set hnsw.ef_search: %w", err)
+		}
+	}
+	rows, err := tx.Query(ctx, `
 		SELECT
-			COALESCE(li.excerpt, lc.excerpt, '(No excerpt available)') AS headline,
+			ch.content AS headline,
 			li.id AS id,
 			li.title AS title,
 			li.link AS link,
 			li.excerpt AS excerpt,
 			li.image_url AS image_url,
 			li.created_at AS created_at,
-			(1 - (lc.content_embedding <=> $1)) AS rank,
+			(1 - (`+vector+` <=> $1)) AS rank,
 			li.ai_summary AS ai_summary,
 			li.ai_excerpt AS ai_excerpt,
 			li.ai_tags AS ai_tags
-		FROM library_items li
-		JOIN library_contents lc ON li.id = lc.id
-		WHERE li.user_id = $2
-			AND lc.content_embedding IS NOT NULL
-		ORDER BY lc.content_embedding <=> $1
-		LIMIT 10`, pgvector.NewVector(queryEmbedding), user.ID)
-
+		FROM library_chunks ch
+		JOIN library_items li ON li.id = ch.library_item_id
+		JOIN library_contents lc ON lc.id = li.id
+		WHERE ch.user_id = $2
+			AND ch.embedding_model = $3 AND ch.embedding_dimension = $4`+conditions+queryConditions+`
+		ORDER BY `+vector+` <=> $1
+		LIMIT $5`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("vector search failed: %w", err)
 	}
@@ -1039,6 +1636,9 @@ func (model *BookmarkRepo) performVectorSearch(ctx context.Context, user *User,
 	if err != nil {
 		return nil, fmt.Errorf("collect vector search rows: %w", err)
 	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit vector search: %w", err)
+	}
 
 	return results, nil
 }
@@ -1050,61 +1650,64 @@ type RAGResponse struct {
 }
 
 // AskQuestion uses RAG (Retrieval-Augmented Generation) to answer questions about bookmarks
-// It retrieves relevant bookmarks using semantic search, then uses Gemini to answer the question
+// It retrieves the relevant passages of the bookmarks using semantic search, then uses the
+// language model to answer the question
 func (model *BookmarkRepo) AskQuestion(ctx context.Context, user *User, question string) (*RAGResponse, error) {
 	logger := loggercontext.Logger(ctx)
 
-	if model.GenAIClient == nil {
-		return nil, fmt.Errorf("GenAI client not initialized")
+	if model.LLM == nil || model.Embedder == nil {
+		return nil, fmt.Errorf("language model or embeddings not initialized")
 	}
 
-	// Use vector search to find relevant bookmarks
-	relevantBookmarks, err := model.performVectorSearch(ctx, user, question)
+	// Use vector search to find the passages relevant to the question
+	passages, err := model.searchPassages(ctx, user, question, searchquery.Query{}, types.BookmarkFilter{}, ragPassages)
 	if err != nil {
 		logger.Warnw("Failed to retrieve relevant bookmarks", "error", err)
 		return nil, fmt.Errorf("retrieve relevant bookmarks: %w", err)
 	}
 
-	if len(relevantBookmarks) == 0 {
+	if len(passages) == 0 {
 		return &RAGResponse{
 			Answer:          "I couldn't find any relevant bookmarks to answer your question.",
 			SourceBookmarks: []SearchResult{},
 		}, nil
 	}
 
-	// Limit to top 5 most relevant bookmarks to avoid token limits
-	if len(relevantBookmarks) > 3 {
-		relevantBookmarks = relevantBookmarks[:3]
+	// Group the best passages by bookmark, keeping the few most relevant bookmarks to avoid
+	// token limits
+	relevantBookmarks := []SearchResult{}
+	bookmarkPassages := map[types.BookmarkId][]string{}
+	for _, passage := range passages {
+		if _, ok := bookmarkPassages[passage.Id]; !ok {
+			if len(relevantBookmarks) == ragSources {
+				continue
+			}
+			bookmark := passage
+			bookmark.Headline = headlineOfPassage(passage.Headline)
+			relevantBookmarks = append(relevantBookmarks, bookmark)
+		}
+		if len(bookmarkPassages[passage.Id]) < ragSourcePassages {
+			bookmarkPassages[passage.Id] = append(bookmarkPassages[passage.Id], passage.Headline)
+		}
 	}
 
-	// Fetch full content for the relevant bookmarks
 	var contexts []string
 	for i, bookmark := range relevantBookmarks {
-		content, err := model.GetBookmarkMarkdown(bookmark.Id)
+		source := fmt.Sprintf("[Source %d: %s]\nURL: %s\nPassages:\n%s\n", i+1, bookmark.Title, bookmark.Link,
+			strings.Join(bookmarkPassages[bookmark.Id], "\n[...]\n"))
+		note, err := model.GetNote(bookmark.Id)
 		if err != nil {
-			logger.Warnw("Failed to get bookmark content", "error", err, "bookmarkId", bookmark.Id)
-			continue
-		}
-
-		// Limit each content to 1000 chars to avoid excessive tokens
-		if len(content) > 1000 {
-			content = content[:1000] + "..."
+			logger.Warnw("Failed to get bookmark note", "error", err, "bookmarkId", bookmark.Id)
+		} else if note != "" {
+			if runes := []rune(note); len(runes) > 1000 {
+				note = string(runes[:1000]) + "..."
+			}
+			source += fmt.Sprintf("User's note: %s\n", note)
 		}
-
-		contexts = append(contexts, fmt.Sprintf(
-			"[Source %d: %s]\nURL: %s\nContent: %s\n",
-			i+1, bookmark.Title, bookmark.Link, content,
-		))
+		contexts = append(contexts, source)
 	}
 
-	if len(contexts) == 0 {
-		return &RAGResponse{
-			Answer:          "I found relevant bookmarks but couldn't access their content.",
-			SourceBookmarks: relevantBookmarks,
-		}, nil
-	}
-
-	// Build the prompt for Gemini
+	// Build the prompt for the language model
 	prompt := fmt.Sprintf(`You are a helpful assistant that answers questions based on the user's bookmarked content.
 
 Question: %s
@@ -1115,34 +1718,31 @@ Here are the relevant bookmarks from the user's library:
 
 Instructions:
 - Answer the question using ONLY the information provided in the bookmarks above
+- The passages are excerpts of the bookmarks, [...] separates passages that are not adjacent
 - Be concise and direct
 - If the bookmarks don't contain enough information to answer the question, say so
 - Cite your sources by mentioning the bookmark titles
+- The user's notes are their own commentary on a bookmark; use them to understand what they took from it
 - If multiple bookmarks provide relevant information, synthesize them into a coherent answer
 - Keep your answer under 300 words
 
 Answer:`, question, strings.Join(contexts, "\n---\n"))
 
-	logger.Infow("calling Gemini for RAG answer",
+	logger.Infow("calling the language model for RAG answer",
 		"question", question,
-		"model", "gemini-3-flash-preview",
+		"provider", model.LLM.Name(),
+		"model", model.LLM.Model(llm.TaskRAG),
 		"num_sources", len(contexts),
 		"prompt_size", len(prompt))
 
-	// Generate answer using Gemini
+	// Generate answer using the language model
 	ragStart := time.Now()
-	result, err := model.GenAIClient.Models.GenerateContent(
-		ctx,
-		"gemini-3-flash-preview",
-		genai.Text(prompt),
-		nil,
-	)
+	answer, err := model.LLM.Generate(ctx, llm.TaskRAG, prompt)
 	if err != nil {
 		logger.Warnw("Failed to generate RAG answer", "error", err)
 		return nil, fmt.Errorf("generate RAG answer: %w", err)
 	}
 
-	answer := result.Text()
 	logger.Infow("RAG answer generated",
 		"elapsed", time.Since(ragStart).Round(time.Millisecond),
 		"answer_length", len(answer))
@@ -1241,58 +1841,6 @@ func (model *BookmarkRepo) GetFullBookmark(id types.BookmarkId) (*BookmarkWithCo
 	return &bookmark, nil
 }
 
-var metaRefreshRe = regexp.MustCompile(`(?i)<meta[^>]+http-equiv=["']?refresh["']?[^>]+content=["']?\d+;\s*url=([^"'>]+)["']?`)
-
-func getPage(link string) (*http.Response, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", link, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-	req.Header.Set("User-Agent", "Mozilla/5.0")
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Upgrade-Insecure-Requests", "1")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to perform request: %w", err)
-	}
-
-	// Accept any 2xx status code
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response body: %w", err)
-	}
-	body := string(bodyBytes)
-
-	metaRefresh := metaRefreshRe.FindStringSubmatch(body)
-	if len(metaRefresh) > 0 {
-		redirectURL, err := url.Parse(metaRefresh[1])
-		if err != nil {
-			return nil, fmt.Errorf("parse redirect URL: %w", err)
-		}
-		redirectLink := redirectURL.String()
-		// If the redirect link is a relative link, join it with the host of the original link
-		if strings.Index(redirectLink, "/") == 0 {
-			parsedURL, err := url.Parse(link)
-			if err != nil {
-				return nil, fmt.Errorf("parse original link: %w", err)
-			}
-			redirectLink = parsedURL.Scheme + "://" + parsedURL.Host + redirectLink
-		}
-		return getPage(redirectLink)
-	}
-	resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-	return resp, nil
-}
-
 // checkRateLimit checks if a user has exceeded their bookmark limits
 func (model *BookmarkRepo) checkRateLimit(user *User) error {
 	// For unverified users, check total bookmark count (not daily)
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Collection struct {
+	Name  string
+	Count int // Number of bookmarks in this collection
+}
+
+type CollectionRepo struct {
+	Pool *pgxpool.Pool
+}
+
+// NormalizeCollectionNames trims and de-duplicates collection names. Unlike tags, the case is kept.
+func NormalizeCollectionNames(names []string) ([]string, error) {
+	return normalizeLabels(names, false)
+}
+
+// GetByUserId returns all the collections of a user with the number of bookmarks in each one
+func (c *CollectionRepo) GetByUserId(userId types.UserId) ([]Collection, error) {
+	rows, err := c.Pool.Query(context.Background(), `
+		SELECT c.name, COUNT(ci.library_item_id) AS count
+		FROM collections c
+		LEFT JOIN collection_items ci ON ci.collection_id = c.id
+		WHERE c.user_id = $1
+		GROUP BY c.id, c.name
+		ORDER BY c.name`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("query collections by user id: %w", err)
+	}
+	collections, err := pgx.CollectRows(rows, pgx.RowToStructByName[Collection])
+	if err != nil {
+		return nil, fmt.Errorf("collect collections: %w", err)
+	}
+	return collections, nil
+}
+
+// GetForBookmark returns the names of the collections the bookmark belongs to
+func (c *CollectionRepo) GetForBookmark(bookmarkId types.BookmarkId) ([]string, error) {
+	rows, err := c.Pool.Query(context.Background(), `
+		SELECT c.name
+		FROM collections c
+		JOIN collection_items ci ON ci.collection_id = c.id
+		WHERE ci.library_item_id = $1
+		ORDER BY c.name`, bookmarkId)
+	if err != nil {
+		return nil, fmt.Errorf("query collections for bookmark: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("collect collections for bookmark: %w", err)
+	}
+	return names, nil
+}
+
+// AddToBookmark puts the bookmark in the collections, creating the missing ones
+func (c *CollectionRepo) AddToBookmark(ctx context.Context, userId types.UserId, bookmarkId types.BookmarkId, names ...string) error {
+	names, err := NormalizeCollectionNames(names)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	tx, err := c.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := addToCollections(ctx, tx, userId, bookmarkId, names); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveFromBookmark takes the bookmark out of a collection. The collection itself is kept.
+func (c *CollectionRepo) RemoveFromBookmark(ctx context.Context, userId types.UserId, bookmarkId types.BookmarkId, name string) error {
+	_, err := c.Pool.Exec(ctx, `
+		DELETE FROM collection_items
+		WHERE library_item_id = $1
+		  AND collection_id = (SELECT id FROM collections WHERE user_id = $2 AND name = $3)`,
+		bookmarkId, userId, strings.TrimSpace(name))
+	if err != nil {
+		return fmt.Errorf("remove bookmark from collection: %w", err)
+	}
+	return nil
+}
+
+// SetForBookmark replaces all the collections of the bookmark with the given ones
+func (c *CollectionRepo) SetForBookmark(ctx context.Context, userId types.UserId, bookmarkId types.BookmarkId, names []string) error {
+	names, err := NormalizeCollectionNames(names)
+	if err != nil {
+		return err
+	}
+
+	tx, err := c.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM collection_items WHERE library_item_id = $1`, bookmarkId)
+	if err != nil {
+		return fmt.Errorf("clear bookmark collections: %w", err)
+	}
+	if err := addToCollections(ctx, tx, userId, bookmarkId, names); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+func addToCollections(ctx context.Context, tx pgx.Tx, userId types.UserId, bookmarkId types.BookmarkId, names []string) error {
+	for _, name := range names {
+		_, err := tx.Exec(ctx, `
+			WITH collection AS (
+				INSERT INTO collections (user_id, name) VALUES ($1, $2)
+				ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+				RETURNING id
+			)
+			INSERT INTO collection_items (collection_id, library_item_id)
+			SELECT id, $3 FROM collection
+			ON CONFLICT DO NOTHING`, userId, name, bookmarkId)
+		if err != nil {
+			return fmt.Errorf("add bookmark to collection %q: %w", name, err)
+		}
+	}
+	return nil
+}
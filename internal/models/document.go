@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/document"
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/fetcher"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/go-shiori/go-readability"
+)
+
+// documentArticle builds the article of a document file. The link is where the document was
+// downloaded from, nil for uploads.
+func documentArticle(file document.File, link *url.URL) (fetchedArticle, error) {
+	if len(file.Data) > document.MaxFileSize {
+		return fetchedArticle{}, errors.ErrFileTooLarge
+	}
+	doc, err := document.Extract(file)
+	if err != nil {
+		return fetchedArticle{}, fmt.Errorf("extract document: %w", err)
+	}
+	article := fetchedArticle{
+		Article: readability.Article{
+			Title:         doc.Title,
+			Byline:        doc.Author,
+			Content:       doc.HTML(),
+			TextContent:   doc.Text,
+			Language:      doc.Language,
+			PublishedTime: doc.PublishedTime,
+		},
+		URL:  link,
+		File: &file,
+	}
+	if link != nil {
+		article.SiteName = link.Hostname()
+	}
+	return article, nil
+}
+
+// documentName is the file name of a downloaded document, from the Content-Disposition header
+// or the last segment of the link
+func documentName(page *fetcher.Page) string {
+	if _, params, err := mime.ParseMediaType(page.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	return path.Base(page.URL.Path)
+}
+
+// CreateFromFile saves an uploaded document as a bookmark. The link of the bookmark is derived
+// from the content of the file, uploading the same file again returns the existing bookmark.
+func (model *BookmarkRepo) CreateFromFile(ctx context.Context, user *User, source BookmarkSource, file document.File) (*Bookmark, error) {
+	logger := loggercontext.Logger(ctx)
+	link := document.Link(file.Data)
+	existing, err := model.getByCanonicalLink(ctx, user.ID, link)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, errors.ErrNotFound) {
+		return nil, fmt.Errorf("get bookmark of file: %w", err)
+	}
+	if err := model.checkRateLimit(user); err != nil {
+		return nil, err
+	}
+
+	article, err := documentArticle(file, nil)
+	if err != nil {
+		return nil, err
+	}
+	if article.Excerpt == "" {
+		article.Excerpt = article.TextContent[:min(200, len(article.TextContent))]
+	}
+	logger.Infow("Creating bookmark from file", "filename", file.Name, "size", len(file.Data), "user_id", user.ID)
+	return model.insert(ctx, user, source, link, article, types.ExtractionMethodDocument, nil, "")
+}
@@ -0,0 +1,292 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/simhash"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// NearDuplicateDistance is the number of differing fingerprint bits up to which two
+	// bookmarks are likely the same article. Unrelated pages differ in about half of the bits.
+	NearDuplicateDistance = 4
+	DuplicatesPageSize    = 50
+	fingerprintBatchSize  = 200
+)
+
+// DuplicateMatch is a bookmark whose content is nearly the same as the one of another bookmark
+type DuplicateMatch struct {
+	Id        types.BookmarkId
+	Title     string
+	Link      string
+	CreatedAt time.Time
+	Distance  int
+}
+
+// DuplicatePair is two bookmarks of the user that are likely the same article
+type DuplicatePair struct {
+	Distance       int
+	Id             types.BookmarkId
+	Title          string
+	Link           string
+	SiteName       string
+	CreatedAt      time.Time
+	OtherId        types.BookmarkId
+	OtherTitle     string
+	OtherLink      string
+	OtherSiteName  string
+	OtherCreatedAt time.Time
+}
+
+// fingerprint is the simhash of the content as stored in the database
+func fingerprint(text string) int64 {
+	return int64(simhash.Fingerprint(text))
+}
+
+// contentFingerprint returns the fingerprint of the bookmark, and computes it for bookmarks
+// saved before fingerprints existed
+func (model *BookmarkRepo) contentFingerprint(ctx context.Context, id types.BookmarkId) (int64, error) {
+	var stored *int64
+	var content string
+	err := model.Pool.QueryRow(ctx, `
+		SELECT simhash, CASE WHEN simhash IS NULL THEN content ELSE '' END
+		FROM library_contents WHERE id = $1`, id).Scan(&stored, &content)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, errors.ErrNotFound
+		}
+		return 0, fmt.Errorf("get content fingerprint: %w", err)
+	}
+	if stored != nil {
+		return *stored, nil
+	}
+	hash := fingerprint(content)
+	_, err = model.Pool.Exec(ctx, `UPDATE library_contents SET simhash = $1 WHERE id = $2`, hash, id)
+	if err != nil {
+		return 0, fmt.Errorf("save content fingerprint: %w", err)
+	}
+	return hash, nil
+}
+
+// FingerprintMissing computes the fingerprints of the bookmarks of the user that were saved
+// before fingerprints existed, so they show up among the duplicates
+func (model *BookmarkRepo) FingerprintMissing(ctx context.Context, userId types.UserId) error {
+	for {
+		rows, err := model.Pool.Query(ctx, `
+			SELECT lc.id, lc.content
+			FROM library_contents lc
+			JOIN library_items li ON li.id = lc.id
+			WHERE li.user_id = $1 AND lc.simhash IS NULL
+			LIMIT $2`, userId, fingerprintBatchSize)
+		if err != nil {
+			return fmt.Errorf("query contents without fingerprint: %w", err)
+		}
+		type content struct {
+			Id      types.BookmarkId
+			Content string
+		}
+		contents, err := pgx.CollectRows(rows, pgx.RowToStructByName[content])
+		if err != nil {
+			return fmt.Errorf("collect contents without fingerprint: %w", err)
+		}
+		if len(contents) == 0 {
+			return nil
+		}
+
+		batch := &pgx.Batch{}
+		for _, c := range contents {
+			batch.Queue(`UPDATE library_contents SET simhash = $1 WHERE id = $2`, fingerprint(c.Content), c.Id)
+		}
+		if err := model.Pool.SendBatch(ctx, batch).Close(); err != nil {
+			return fmt.Errorf("save content fingerprints: %w", err)
+		}
+		loggercontext.Logger(ctx).Infow("content fingerprints computed", "user_id", userId, "count", len(contents))
+		if len(contents) < fingerprintBatchSize {
+			return nil
+		}
+	}
+}
+
+// NearDuplicate returns the other bookmark of the user that is most likely the same article as
+// the given one, or errors.ErrNotFound. Pairs the user dismissed are left out.
+func (model *BookmarkRepo) NearDuplicate(ctx context.Context, userId types.UserId, id types.BookmarkId) (*DuplicateMatch, error) {
+	hash, err := model.contentFingerprint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hash == 0 {
+		return nil, errors.ErrNotFound
+	}
+	rows, err := model.Pool.Query(ctx, `
+		SELECT li.id, li.title, li.link, li.created_at,
+		       bit_count((lc.simhash # $3)::bit(64))::int AS distance
+		FROM library_items li
+		JOIN library_contents lc ON lc.id = li.id
+		WHERE li.user_id = $1
+		  AND li.id <> $2
+		  AND lc.simhash IS NOT NULL AND lc.simhash <> 0
+		  AND bit_count((lc.simhash # $3)::bit(64)) <= $4
+		  AND NOT EXISTS (
+		      SELECT 1 FROM duplicate_dismissals d
+		      WHERE d.library_item_id = LEAST(li.id, $2) AND d.other_item_id = GREATEST(li.id, $2))
+		ORDER BY distance, li.created_at
+		LIMIT 1`, userId, id, hash, NearDuplicateDistance)
+	if err != nil {
+		return nil, fmt.Errorf("query near duplicate: %w", err)
+	}
+	match, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[DuplicateMatch])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, fmt.Errorf("collect near duplicate: %w", err)
+	}
+	return &match, nil
+}
+
+// GetDuplicates returns the pairs of bookmarks of the user that are likely the same article,
+// the closest first. Fingerprints are split in 5 bands of 13 bits: two fingerprints that differ
+// in at most 4 bits have at least one band in common, so only bookmarks sharing a band are compared.
+func (model *BookmarkRepo) GetDuplicates(userId types.UserId) ([]DuplicatePair, error) {
+	rows, err := model.Pool.Query(context.Background(), `
+		WITH items AS (
+			SELECT lc.id, lc.simhash
+			FROM library_contents lc
+			JOIN library_items li ON li.id = lc.id
+			WHERE li.user_id = $1 AND lc.simhash IS NOT NULL AND lc.simhash <> 0
+		), bands AS (
+			SELECT id, simhash, band, (simhash >> (13 * band)) & 8191 AS value
+			FROM items, generate_series(0, 4) AS band
+		), pairs AS (
+			SELECT DISTINCT a.id AS a_id, b.id AS b_id, bit_count((a.simhash # b.simhash)::bit(64))::int AS distance
+			FROM bands a
+			JOIN bands b ON b.band = a.band AND b.value = a.value AND a.id < b.id
+		)
+		SELECT p.distance,
+		       a.id, a.title, a.link, a.site_name, a.created_at,
+		       b.id AS other_id, b.title AS other_title, b.link AS other_link,
+		       b.site_name AS other_site_name, b.created_at AS other_created_at
+		FROM pairs p
+		JOIN library_items a ON a.id = p.a_id
+		JOIN library_items b ON b.id = p.b_id
+		WHERE p.distance <= $2
+		  AND NOT EXISTS (
+		      SELECT 1 FROM duplicate_dismissals d
+		      WHERE d.library_item_id = p.a_id AND d.other_item_id = p.b_id)
+		ORDER BY p.distance, GREATEST(a.created_at, b.created_at) DESC
+		LIMIT $3`, userId, NearDuplicateDistance, DuplicatesPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("query duplicates: %w", err)
+	}
+	pairs, err := pgx.CollectRows(rows, pgx.RowToStructByName[DuplicatePair])
+	if err != nil {
+		return nil, fmt.Errorf("collect duplicates: %w", err)
+	}
+	return pairs, nil
+}
+
+// DismissDuplicate records that the two bookmarks are not the same article
+func (model *BookmarkRepo) DismissDuplicate(ctx context.Context, id, otherId types.BookmarkId) error {
+	if otherId < id {
+		id, otherId = otherId, id
+	}
+	_, err := model.Pool.Exec(ctx, `
+		INSERT INTO duplicate_dismissals (library_item_id, other_item_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, id, otherId)
+	if err != nil {
+		return fmt.Errorf("dismiss duplicate: %w", err)
+	}
+	return nil
+}
+
+// Merge moves what the user added to the removed bookmark (tags, collections, highlights, note,
+// star and reading status) to the kept one, then deletes the removed bookmark. The link of the
+// removed bookmark becomes an alias of the kept one.
+func (model *BookmarkRepo) Merge(ctx context.Context, keepId, removeId types.BookmarkId) error {
+	if keepId == removeId {
+		return fmt.Errorf("merge bookmark with itself")
+	}
+	tx, err := model.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO library_item_tags (library_item_id, tag_id)
+		SELECT $1, tag_id FROM library_item_tags WHERE library_item_id = $2
+		ON CONFLICT DO NOTHING`, keepId, removeId)
+	if err != nil {
+		return fmt.Errorf("merge tags: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO collection_items (collection_id, library_item_id)
+		SELECT collection_id, $1 FROM collection_items WHERE library_item_id = $2
+		ON CONFLICT DO NOTHING`, keepId, removeId)
+	if err != nil {
+		return fmt.Errorf("merge collections: %w", err)
+	}
+	// A note on both is kept in full, even if together they are longer than a note can be
+	_, err = tx.Exec(ctx, `
+		UPDATE library_contents k
+		SET note = concat_ws(E'\n\n', NULLIF(k.note, ''), NULLIF(r.note, ''))
+		FROM library_contents r
+		WHERE k.id = $1 AND r.id = $2 AND r.note <> ''`, keepId, removeId)
+	if err != nil {
+		return fmt.Errorf("merge notes: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE library_items k
+		SET starred = k.starred OR r.starred,
+		    reading_status = CASE WHEN k.reading_status = 'unread' THEN r.reading_status ELSE k.reading_status END
+		FROM library_items r
+		WHERE k.id = $1 AND r.id = $2`, keepId, removeId)
+	if err != nil {
+		return fmt.Errorf("merge reading state: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE library_item_aliases SET library_item_id = $1 WHERE library_item_id = $2`, keepId, removeId)
+	if err != nil {
+		return fmt.Errorf("merge link aliases: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO library_item_aliases (user_id, link, library_item_id)
+		SELECT user_id, link, $1 FROM library_items WHERE id = $2
+		ON CONFLICT (user_id, link) DO UPDATE SET library_item_id = EXCLUDED.library_item_id`, keepId, removeId)
+	if err != nil {
+		return fmt.Errorf("keep merged link as alias: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE highlights SET library_item_id = $1 WHERE library_item_id = $2`, keepId, removeId)
+	if err != nil {
+		return fmt.Errorf("merge highlights: %w", err)
+	}
+	var content string
+	err = tx.QueryRow(ctx, `SELECT content FROM library_contents WHERE id = $1`, keepId).Scan(&content)
+	if err != nil {
+		return fmt.Errorf("get content to merge highlights: %w", err)
+	}
+	if err := reanchorHighlights(ctx, tx, keepId, content); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	if err := model.Delete(removeId); err != nil {
+		return fmt.Errorf("delete merged bookmark: %w", err)
+	}
+	// The note may have changed
+	model.enqueueAIJob(ctx, keepId, AIJobKindEmbedding, "")
+	return nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/jackc/pgx/v5"
+)
+
+var nonIdentifier = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Passages of the bookmarks are about passageSize characters, and overlap by passageOverlap
+// characters so a sentence cut at the end of a passage is whole in the next one. The passages
+// after maxPassages are not embedded, which bounds the cost of very long documents.
+const (
+	passageSize     = 1000
+	passageOverlap  = 200
+	maxPassages     = 40
+	passageHeadline = 300 // Characters of a passage shown as the headline of a result
+)
+
+// embeddingVector is the embedding of library_chunks as ch, cast to the dimension of the model.
+// Searches use this expression so the HNSW index of the model is used.
+func embeddingVector(dimension int) string {
+	return fmt.Sprintf("ch.embedding::vector(%d)", dimension)
+}
+
+// embeddingIndexName names the HNSW index of the vectors of a model, like
+// library_chunks_hnsw_gemini_embedding_001_768
+func embeddingIndexName(model string, dimension int) string {
+	name := strings.Trim(nonIdentifier.ReplaceAllString(strings.ToLower(model), "_"), "_")
+	suffix := fmt.Sprintf("_%d", dimension)
+	// Postgres names are up to 63 bytes
+	name = "library_chunks_hnsw_" + name
+	if len(name)+len(suffix) > 63 {
+		name = name[:63-len(suffix)]
+	}
+	return name + suffix
+}
+
+// EnsureEmbeddingIndex creates the HNSW index of the vectors of the embedding model, so an
+// instance switching models gets an index for the new vectors while the old ones are replaced.
+// It builds the index without locking the bookmarks, which may take a while on large libraries,
+// and builds again an index left invalid by a failed build.
+func (model *BookmarkRepo) EnsureEmbeddingIndex(ctx context.Context) error {
+	if model.Embedder == nil {
+		return nil
+	}
+	name, dimension := model.Embedder.Model(), model.Embedder.Dimension()
+	index := embeddingIndexName(name, dimension)
+	logger := loggercontext.Logger(ctx)
+	var valid bool
+	err := model.Pool.QueryRow(ctx, `
+		SELECT indisvalid FROM pg_index
+		WHERE indexrelid = to_regclass($1)`, index).Scan(&valid)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("check embedding index: %w", err)
+	}
+	if err == nil && valid {
+		return nil
+	}
+	// A failed or interrupted concurrent build leaves an invalid index, the searches don't use it
+	if err == nil {
+		logger.Warnw("dropping invalid embedding index", "index", index)
+		if _, err := model.Pool.Exec(ctx, `DROP INDEX CONCURRENTLY IF EXISTS `+index); err != nil {
+			return fmt.Errorf("drop invalid embedding index: %w", err)
+		}
+	}
+
+	logger.Infow("creating embedding index", "index", index, "model", name, "dimension", dimension)
+	_, err = model.Pool.Exec(ctx, fmt.Sprintf(`
+		CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON library_chunks
+		USING hnsw ((embedding::vector(%d)) vector_cosine_ops)
+		WITH (m = 16, ef_construction = 64)
+		WHERE embedding_model = '%s' AND embedding_dimension = %d`,
+		index, dimension, strings.ReplaceAll(name, "'", "''"), dimension))
+	if err != nil {
+		return fmt.Errorf("create embedding index: %w", err)
+	}
+	return nil
+}
+
+// splitPassages splits a text into overlapping passages to embed. Passages end at a paragraph,
+// line, sentence or word break when there is one in their second half.
+func splitPassages(text string) []string {
+	runes := []rune(strings.TrimSpace(text))
+	var passages []string
+	for start := 0; start < len(runes) && len(passages) < maxPassages; {
+		end := min(start+passageSize, len(runes))
+		if end < len(runes) {
+			end = passageBreak(runes, start+passageSize/2, end)
+		}
+		if passage := strings.TrimSpace(string(runes[start:end])); passage != "" {
+			passages = append(passages, passage)
+		}
+		if end == len(runes) {
+			break
+		}
+		// The next passage starts at a word of the overlap
+		next := max(end-passageOverlap, start+1)
+		for next < end && !unicode.IsSpace(runes[next-1]) {
+			next++
+		}
+		start = next
+	}
+	return passages
+}
+
+// passageBreak returns where a passage between from and to is best cut, to when it has no break
+func passageBreak(runes []rune, from, to int) int {
+	window := string(runes[from:to])
+	for _, separator := range []string{"\n\n", "\n", ". ", " "} {
+		if i := strings.LastIndex(window, separator); i > 0 {
+			return from + utf8.RuneCountInString(window[:i+len(separator)])
+		}
+	}
+	return to
+}
+
+// headlineOfPassage turns a passage into the headline of a search result. Headlines are HTML,
+// like the highlighted snippets of the full-text search.
+func headlineOfPassage(passage string) string {
+	passage = strings.Join(strings.Fields(passage), " ")
+	if runes := []rune(passage); len(runes) > passageHeadline {
+		passage = string(runes[:passageHeadline]) + "…"
+	}
+	return html.EscapeString(passage)
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/fetcher"
+	"github.com/arashthr/pensive/internal/secrets"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FetchProfile is how the pages of a domain are fetched for a user, or for everyone when it
+// is set by the admins
+type FetchProfile struct {
+	Id               int
+	UserId           *types.UserId // Nil for the profiles set by the admins
+	Domain           string
+	UserAgent        string
+	Headers          map[string]string
+	Cookies          string `db:"-"` // Only set when saving, the stored cookies are never shown
+	HasCookies       bool
+	IncludeSelectors string
+	ExcludeSelectors string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type FetchProfileRepo struct {
+	Pool   *pgxpool.Pool
+	Cipher *secrets.Cipher // Encrypts the cookies, profiles can't have cookies when nil
+}
+
+const fetchProfileColumns = `id, user_id, domain, user_agent, headers, cookies_encrypted IS NOT NULL AS has_cookies,
+	include_selectors, exclude_selectors, created_at, updated_at`
+
+// CookiesEnabled tells if profiles can have cookies
+func (repo *FetchProfileRepo) CookiesEnabled() bool {
+	return repo != nil && repo.Cipher != nil
+}
+
+// List returns the profiles of the user, or the profiles set by the admins for a nil user
+func (repo *FetchProfileRepo) List(ctx context.Context, userId *types.UserId) ([]FetchProfile, error) {
+	rows, err := repo.Pool.Query(ctx, `
+		SELECT `+fetchProfileColumns+`
+		FROM fetch_profiles
+		WHERE user_id IS NOT DISTINCT FROM $1
+		ORDER BY domain`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("query fetch profiles: %w", err)
+	}
+	profiles, err := pgx.CollectRows(rows, pgx.RowToStructByName[FetchProfile])
+	if err != nil {
+		return nil, fmt.Errorf("collect fetch profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// Save creates the profile of the domain, or replaces it when the user (or the admins for a
+// nil user) already has one
+func (repo *FetchProfileRepo) Save(ctx context.Context, profile *FetchProfile) error {
+	domain, err := fetcher.NormalizeDomain(profile.Domain)
+	if err != nil {
+		return err
+	}
+	profile.Domain = domain
+	profile.UserAgent = strings.TrimSpace(profile.UserAgent)
+	profile.Cookies = strings.TrimSpace(profile.Cookies)
+	if profile.Headers == nil {
+		profile.Headers = map[string]string{}
+	}
+	if err := profile.fetcherProfile().Validate(); err != nil {
+		return err
+	}
+
+	var cookies []byte
+	if profile.Cookies != "" {
+		if !repo.CookiesEnabled() {
+			return errors.ErrCookiesDisabled
+		}
+		if cookies, err = repo.Cipher.Seal([]byte(profile.Cookies)); err != nil {
+			return fmt.Errorf("encrypt cookies: %w", err)
+		}
+	}
+
+	// The two kinds of profiles have their own unique index
+	conflict := `(user_id, domain) WHERE user_id IS NOT NULL`
+	if profile.UserId == nil {
+		conflict = `(domain) WHERE user_id IS NULL`
+	}
+	err = repo.Pool.QueryRow(ctx, `
+		INSERT INTO fetch_profiles (user_id, domain, user_agent, headers, cookies_encrypted, include_selectors, exclude_selectors)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT `+conflict+` DO UPDATE SET
+			user_agent = EXCLUDED.user_agent,
+			headers = EXCLUDED.headers,
+			cookies_encrypted = EXCLUDED.cookies_encrypted,
+			include_selectors = EXCLUDED.include_selectors,
+			exclude_selectors = EXCLUDED.exclude_selectors,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at`,
+		profile.UserId, profile.Domain, profile.UserAgent, profile.Headers, cookies,
+		profile.IncludeSelectors, profile.ExcludeSelectors,
+	).Scan(&profile.Id, &profile.CreatedAt, &profile.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("save fetch profile: %w", err)
+	}
+	profile.HasCookies = cookies != nil
+	return nil
+}
+
+// Delete removes the profile of the user, or a profile set by the admins for a nil user
+func (repo *FetchProfileRepo) Delete(ctx context.Context, userId *types.UserId, id int) error {
+	tag, err := repo.Pool.Exec(ctx, `
+		DELETE FROM fetch_profiles
+		WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2`, id, userId)
+	if err != nil {
+		return fmt.Errorf("delete fetch profile: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+// ForHost returns the profile to fetch the host with for the user: the user's own profile of
+// the host or of its closest parent domain, then the one set by the admins. It returns nil when
+// there is none.
+func (repo *FetchProfileRepo) ForHost(ctx context.Context, userId types.UserId, host string) (*fetcher.Profile, error) {
+	if repo == nil {
+		return nil, nil
+	}
+	var profile FetchProfile
+	var cookies []byte
+	err := repo.Pool.QueryRow(ctx, `
+		SELECT domain, user_agent, headers, cookies_encrypted, include_selectors, exclude_selectors
+		FROM fetch_profiles
+		WHERE (user_id = $1 OR user_id IS NULL)
+			AND ($2 = domain OR right($2, length(domain) + 1) = '.' || domain)
+		ORDER BY user_id IS NULL, length(domain) DESC
+		LIMIT 1`, userId, strings.ToLower(host),
+	).Scan(&profile.Domain, &profile.UserAgent, &profile.Headers, &cookies, &profile.IncludeSelectors, &profile.ExcludeSelectors)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get fetch profile: %w", err)
+	}
+	if cookies != nil {
+		// The profile is still used without its cookies, like after the key is removed
+		if !repo.CookiesEnabled() {
+			return profile.fetcherProfile(), fmt.Errorf("fetch profile of %s: %w", profile.Domain, errors.ErrCookiesDisabled)
+		}
+		plain, err := repo.Cipher.Open(cookies)
+		if err != nil {
+			return profile.fetcherProfile(), fmt.Errorf("decrypt cookies of %s: %w", profile.Domain, err)
+		}
+		profile.Cookies = string(plain)
+	}
+	return profile.fetcherProfile(), nil
+}
+
+func (p *FetchProfile) fetcherProfile() *fetcher.Profile {
+	return &fetcher.Profile{
+		Domain:    p.Domain,
+		UserAgent: p.UserAgent,
+		Headers:   p.Headers,
+		Cookies:   p.Cookies,
+		Include:   p.IncludeSelectors,
+		Exclude:   p.ExcludeSelectors,
+	}
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/arashthr/pensive/internal/document"
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/storage"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// File is the original document of a bookmark, like a PDF. The file is kept in the storage backend.
+type File struct {
+	BookmarkId  types.BookmarkId `db:"library_item_id"`
+	UserId      types.UserId
+	Filename    string
+	StorageKey  string
+	ContentType string
+	Size        int64
+	CreatedAt   time.Time
+}
+
+// HumanSize is the size of the file for display
+func (f File) HumanSize() string {
+	return Snapshot{Size: f.Size}.HumanSize()
+}
+
+type FileRepo struct {
+	Pool    *pgxpool.Pool
+	Storage storage.Storage
+}
+
+func fileKey(userId types.UserId, bookmarkId types.BookmarkId, filename string) string {
+	return fmt.Sprintf("files/%d/%s%s", userId, bookmarkId, strings.ToLower(path.Ext(filename)))
+}
+
+// Save stores the file of the bookmark, replacing an older one
+func (f *FileRepo) Save(ctx context.Context, userId types.UserId, bookmarkId types.BookmarkId, file document.File) error {
+	old, err := f.Get(bookmarkId)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		return err
+	}
+	contentType := document.DetectType(file)
+	filename := documentFilename(file.Name, contentType)
+	key := fileKey(userId, bookmarkId, filename)
+	size, err := f.Storage.Put(ctx, key, bytes.NewReader(file.Data))
+	if err != nil {
+		return fmt.Errorf("store file: %w", err)
+	}
+	_, err = f.Pool.Exec(ctx, `
+		INSERT INTO library_files (library_item_id, user_id, filename, storage_key, content_type, size)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (library_item_id) DO UPDATE
+		SET filename     = EXCLUDED.filename,
+		    storage_key  = EXCLUDED.storage_key,
+		    content_type = EXCLUDED.content_type,
+		    size         = EXCLUDED.size,
+		    created_at   = NOW()`,
+		bookmarkId, userId, filename, key, contentType, size)
+	if err != nil {
+		f.Storage.Delete(ctx, key)
+		return fmt.Errorf("save file: %w", err)
+	}
+	// The previous file had another extension
+	if old != nil && old.StorageKey != key {
+		if err := f.Storage.Delete(ctx, old.StorageKey); err != nil {
+			return fmt.Errorf("delete previous file: %w", err)
+		}
+	}
+	return nil
+}
+
+// documentFilename keeps the base name of the file and makes sure it has an extension of its type
+func documentFilename(name, contentType string) string {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		name = "document"
+	}
+	if path.Ext(name) == "" {
+		if ext := document.Extension(contentType); ext != "" {
+			name += ext
+		} else if extensions, _ := mime.ExtensionsByType(contentType); len(extensions) > 0 {
+			name += extensions[0]
+		}
+	}
+	return name
+}
+
+func (f *FileRepo) Get(bookmarkId types.BookmarkId) (*File, error) {
+	rows, err := f.Pool.Query(context.Background(), `
+		SELECT library_item_id, user_id, filename, storage_key, content_type, size, created_at
+		FROM library_files
+		WHERE library_item_id = $1`, bookmarkId)
+	if err != nil {
+		return nil, fmt.Errorf("query file: %w", err)
+	}
+	file, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[File])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, fmt.Errorf("collect file: %w", err)
+	}
+	return &file, nil
+}
+
+// Open returns the content of the file from the storage backend
+func (f *FileRepo) Open(ctx context.Context, file *File) (io.ReadCloser, error) {
+	content, err := f.Storage.Open(ctx, file.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	return content, nil
+}
+
+// Delete removes the file of the bookmark from the storage backend and the database
+func (f *FileRepo) Delete(ctx context.Context, bookmarkId types.BookmarkId) error {
+	file, err := f.Get(bookmarkId)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := f.Storage.Delete(ctx, file.StorageKey); err != nil {
+		return fmt.Errorf("delete file from storage: %w", err)
+	}
+	_, err = f.Pool.Exec(ctx, `DELETE FROM library_files WHERE library_item_id = $1`, bookmarkId)
+	if err != nil {
+		return fmt.Errorf("delete file: %w", err)
+	}
+	return nil
+}
+
+// DeleteAllByUserId removes the files of the user from the storage. The rows go away with the bookmarks.
+func (f *FileRepo) DeleteAllByUserId(ctx context.Context, userId types.UserId) error {
+	rows, err := f.Pool.Query(ctx, `SELECT storage_key FROM library_files WHERE user_id = $1`, userId)
+	if err != nil {
+		return fmt.Errorf("query user files: %w", err)
+	}
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("collect user files: %w", err)
+	}
+	for _, key := range keys {
+		if err := f.Storage.Delete(ctx, key); err != nil {
+			return fmt.Errorf("delete file from storage: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	HighlightsPageSize = 20
+	MaxHighlightLength = 5000  // Longest passage that can be highlighted (in characters)
+	MaxNoteLength      = 10000 // Longest note on a highlight or a bookmark (in characters)
+	anchorContextSize  = 32    // Characters of surrounding text kept to re-anchor a highlight
+)
+
+type Highlight struct {
+	Id          int
+	UserId      types.UserId
+	BookmarkId  types.BookmarkId `db:"library_item_id"`
+	Text        string
+	Note        string
+	Prefix      string
+	Suffix      string
+	StartOffset int
+	EndOffset   int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// HighlightWithBookmark is a highlight with the bookmark it belongs to, for library-wide listings
+type HighlightWithBookmark struct {
+	Highlight
+	Title string
+	Link  string
+}
+
+type HighlightRepo struct {
+	Pool *pgxpool.Pool
+}
+
+const highlightColumns = `h.id, h.user_id, h.library_item_id, h.text, h.note, h.prefix, h.suffix,
+	h.start_offset, h.end_offset, h.created_at, h.updated_at`
+
+// Create anchors the quote in the stored content of the bookmark and saves it as a highlight.
+// The quote can come from the rendered markdown, so it only has to match the content
+// up to punctuation, case and whitespace. prefix and suffix help to pick the right
+// occurrence when the quote appears more than once.
+func (h *HighlightRepo) Create(ctx context.Context, userId types.UserId, bookmarkId types.BookmarkId, quote, note, prefix, suffix string) (*Highlight, error) {
+	if utf8.RuneCountInString(quote) > MaxHighlightLength || utf8.RuneCountInString(note) > MaxNoteLength {
+		return nil, errors.ErrHighlightTooLong
+	}
+
+	var content string
+	err := h.Pool.QueryRow(ctx, `SELECT content FROM library_contents WHERE id = $1`, bookmarkId).Scan(&content)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, fmt.Errorf("get content to anchor highlight: %w", err)
+	}
+
+	start, end, ok := AnchorQuote(content, quote, prefix, suffix)
+	if !ok {
+		return nil, errors.ErrQuoteNotFound
+	}
+	runes := []rune(content)
+	highlight := Highlight{
+		UserId:      userId,
+		BookmarkId:  bookmarkId,
+		Text:        string(runes[start:end]),
+		Note:        strings.TrimSpace(note),
+		Prefix:      string(runes[max(0, start-anchorContextSize):start]),
+		Suffix:      string(runes[end:min(len(runes), end+anchorContextSize)]),
+		StartOffset: start,
+		EndOffset:   end,
+	}
+
+	err = h.Pool.QueryRow(ctx, `
+		INSERT INTO highlights (user_id, library_item_id, text, note, prefix, suffix, start_offset, end_offset, search_config)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, search_config FROM library_contents WHERE id = $2
+		RETURNING id, created_at, updated_at`,
+		highlight.UserId, highlight.BookmarkId, highlight.Text, highlight.Note, highlight.Prefix, highlight.Suffix,
+		highlight.StartOffset, highlight.EndOffset,
+	).Scan(&highlight.Id, &highlight.CreatedAt, &highlight.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert highlight: %w", err)
+	}
+	return &highlight, nil
+}
+
+func (h *HighlightRepo) Get(id int) (*Highlight, error) {
+	rows, err := h.Pool.Query(context.Background(), `
+		SELECT `+highlightColumns+`
+		FROM highlights h
+		WHERE h.id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query highlight by id: %w", err)
+	}
+	highlight, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Highlight])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, fmt.Errorf("collect highlight: %w", err)
+	}
+	return &highlight, nil
+}
+
+// GetForBookmark returns the highlights of a bookmark in reading order
+func (h *HighlightRepo) GetForBookmark(bookmarkId types.BookmarkId) ([]Highlight, error) {
+	rows, err := h.Pool.Query(context.Background(), `
+		SELECT `+highlightColumns+`
+		FROM highlights h
+		WHERE h.library_item_id = $1
+		ORDER BY h.start_offset`, bookmarkId)
+	if err != nil {
+		return nil, fmt.Errorf("query highlights for bookmark: %w", err)
+	}
+	highlights, err := pgx.CollectRows(rows, pgx.RowToStructByName[Highlight])
+	if err != nil {
+		return nil, fmt.Errorf("collect highlights for bookmark: %w", err)
+	}
+	return highlights, nil
+}
+
+// GetByUserId returns a page of the user's highlights across the library, newest first
+func (h *HighlightRepo) GetByUserId(userId types.UserId, page int) ([]HighlightWithBookmark, bool, error) {
+	if page <= 0 || page >= 100 {
+		return nil, false, fmt.Errorf("page number out of range")
+	}
+	page -= 1
+	// Fetch one more than the page size to know if there are more pages
+	rows, err := h.Pool.Query(context.Background(), `
+		SELECT `+highlightColumns+`, li.title, li.link
+		FROM highlights h
+		JOIN library_items li ON li.id = h.library_item_id
+		WHERE h.user_id = $1
+		ORDER BY h.created_at DESC, h.id DESC
+		LIMIT $2
+		OFFSET $3`, userId, HighlightsPageSize+1, page*HighlightsPageSize)
+	if err != nil {
+		return nil, false, fmt.Errorf("query highlights by user id: %w", err)
+	}
+	highlights, err := pgx.CollectRows(rows, pgx.RowToStructByName[HighlightWithBookmark])
+	if err != nil {
+		return nil, false, fmt.Errorf("collect highlights by user id: %w", err)
+	}
+	morePages := len(highlights) > HighlightsPageSize
+	if morePages {
+		highlights = highlights[:HighlightsPageSize]
+	}
+	return highlights, morePages, nil
+}
+
+// GetAllByUserId returns all the highlights of the user grouped by bookmark, for exports
+func (h *HighlightRepo) GetAllByUserId(userId types.UserId) ([]HighlightWithBookmark, error) {
+	rows, err := h.Pool.Query(context.Background(), `
+		SELECT `+highlightColumns+`, li.title, li.link
+		FROM highlights h
+		JOIN library_items li ON li.id = h.library_item_id
+		WHERE h.user_id = $1
+		ORDER BY li.created_at DESC, h.start_offset`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("query all highlights by user id: %w", err)
+	}
+	highlights, err := pgx.CollectRows(rows, pgx.RowToStructByName[HighlightWithBookmark])
+	if err != nil {
+		return nil, fmt.Errorf("collect all highlights by user id: %w", err)
+	}
+	return highlights, nil
+}
+
+func (h *HighlightRepo) UpdateNote(id int, note string) error {
+	if utf8.RuneCountInString(note) > MaxNoteLength {
+		return errors.ErrHighlightTooLong
+	}
+	_, err := h.Pool.Exec(context.Background(), `
+		UPDATE highlights SET note = $1, updated_at = NOW() WHERE id = $2`, strings.TrimSpace(note), id)
+	if err != nil {
+		return fmt.Errorf("update highlight note: %w", err)
+	}
+	return nil
+}
+
+func (h *HighlightRepo) Delete(id int) error {
+	_, err := h.Pool.Exec(context.Background(), `DELETE FROM highlights WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete highlight: %w", err)
+	}
+	return nil
+}
+
+// reanchorHighlights moves the highlights of the bookmark to where their text is in the new
+// content. Highlights whose text is gone keep their text and old position.
+func reanchorHighlights(ctx context.Context, tx pgx.Tx, bookmarkId types.BookmarkId, content string) error {
+	rows, err := tx.Query(ctx, `
+		SELECT `+highlightColumns+`
+		FROM highlights h
+		WHERE h.library_item_id = $1`, bookmarkId)
+	if err != nil {
+		return fmt.Errorf("query highlights to re-anchor: %w", err)
+	}
+	highlights, err := pgx.CollectRows(rows, pgx.RowToStructByName[Highlight])
+	if err != nil {
+		return fmt.Errorf("collect highlights to re-anchor: %w", err)
+	}
+
+	runes := []rune(content)
+	for _, h := range highlights {
+		start, end, ok := AnchorQuote(content, h.Text, h.Prefix, h.Suffix)
+		if !ok {
+			continue
+		}
+		_, err := tx.Exec(ctx, `
+			UPDATE highlights SET text = $1, prefix = $2, suffix = $3, start_offset = $4, end_offset = $5
+			WHERE id = $6`,
+			string(runes[start:end]),
+			string(runes[max(0, start-anchorContextSize):start]),
+			string(runes[end:min(len(runes), end+anchorContextSize)]),
+			start, end, h.Id)
+		if err != nil {
+			return fmt.Errorf("re-anchor highlight: %w", err)
+		}
+	}
+	return nil
+}
+
+// AnchorQuote finds the quote in the content and returns its rune offsets.
+// Only letters and digits are compared (case-insensitively), so a selection copied from
+// the rendered markdown matches the stored plain text. When the quote appears more than
+// once, the occurrence whose surroundings best match prefix and suffix wins.
+func AnchorQuote(content, quote, prefix, suffix string) (start, end int, ok bool) {
+	normContent, offsets := normalizeForAnchor(content)
+	normQuote, _ := normalizeForAnchor(quote)
+	if len(normQuote) == 0 {
+		return 0, 0, false
+	}
+	normPrefix, _ := normalizeForAnchor(prefix)
+	normSuffix, _ := normalizeForAnchor(suffix)
+
+	best, bestScore := -1, -1
+	for i := 0; i+len(normQuote) <= len(normContent); i++ {
+		if !runesHavePrefix(normContent[i:], normQuote) {
+			continue
+		}
+		score := commonSuffixLength(normContent[:i], normPrefix) +
+			commonPrefixLength(normContent[i+len(normQuote):], normSuffix)
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+	return offsets[best], offsets[best+len(normQuote)-1] + 1, true
+}
+
+// normalizeForAnchor keeps the lowercased letters and digits of s together with
+// their rune offsets in s
+func normalizeForAnchor(s string) ([]rune, []int) {
+	var normalized []rune
+	var offsets []int
+	i := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			normalized = append(normalized, unicode.ToLower(r))
+			offsets = append(offsets, i)
+		}
+		i++
+	}
+	return normalized, offsets
+}
+
+func runesHavePrefix(s, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i := range prefix {
+		if s[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func commonPrefixLength(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func commonSuffixLength(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
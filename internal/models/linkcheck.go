@@ -0,0 +1,188 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/fetcher"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	LinkBrokenAfterFailures  = 2 // Consecutive failed checks before a link is flagged as broken
+	linkCheckHealthyInterval = 30 * 24 * time.Hour
+	linkCheckRetryInterval   = 24 * time.Hour     // Grows with every consecutive failure
+	linkCheckBrokenInterval  = 7 * 24 * time.Hour // Broken links are still checked in case they come back
+	linkCheckNewBookmarkAge  = 24 * time.Hour     // New bookmarks were just fetched, no need to check them
+	linkCheckTimeout         = 30 * time.Second
+)
+
+// LinkCheck is the last probe of a saved link by the dead-link checker
+type LinkCheck struct {
+	BookmarkId          types.BookmarkId `db:"library_item_id"`
+	StatusCode          int              // 0 when the request failed before a response
+	FinalUrl            string
+	Redirects           int
+	Error               string
+	ConsecutiveFailures int
+	Broken              bool
+	CheckedAt           time.Time
+	NextCheckAt         time.Time
+}
+
+// DueLink is a saved link that is due for a check
+type DueLink struct {
+	Id   types.BookmarkId
+	Link string
+}
+
+type LinkCheckRepo struct {
+	Pool *pgxpool.Pool
+	// ArchiveURL points to an archived copy of a link. "{url}" is replaced by the link,
+	// otherwise the link is appended. Empty disables the fallback link.
+	ArchiveURL string
+}
+
+// ArchiveLink is where an archived copy of the link can be found when the original is gone
+func (r *LinkCheckRepo) ArchiveLink(link string) string {
+	if r.ArchiveURL == "" {
+		return ""
+	}
+	if strings.Contains(r.ArchiveURL, "{url}") {
+		return strings.ReplaceAll(r.ArchiveURL, "{url}", link)
+	}
+	return r.ArchiveURL + link
+}
+
+func (r *LinkCheckRepo) Get(bookmarkId types.BookmarkId) (*LinkCheck, error) {
+	rows, err := r.Pool.Query(context.Background(), `
+		SELECT library_item_id, status_code, final_url, redirects, error, consecutive_failures, broken, checked_at, next_check_at
+		FROM link_checks
+		WHERE library_item_id = $1`, bookmarkId)
+	if err != nil {
+		return nil, fmt.Errorf("query link check: %w", err)
+	}
+	check, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[LinkCheck])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, fmt.Errorf("collect link check: %w", err)
+	}
+	return &check, nil
+}
+
+// Due returns the links that were never checked or whose next check is due, the most overdue first
+func (r *LinkCheckRepo) Due(limit int) ([]DueLink, error) {
+	rows, err := r.Pool.Query(context.Background(), `
+		SELECT li.id, li.link
+		FROM library_items li
+		LEFT JOIN link_checks lc ON lc.library_item_id = li.id
+		WHERE li.link ~* '^https?://'
+		  AND (lc.next_check_at <= NOW()
+		       OR (lc.library_item_id IS NULL AND li.created_at < NOW() - make_interval(secs => $2)))
+		ORDER BY lc.next_check_at NULLS FIRST, li.created_at
+		LIMIT $1`, limit, linkCheckNewBookmarkAge.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("query due links: %w", err)
+	}
+	links, err := pgx.CollectRows(rows, pgx.RowToStructByName[DueLink])
+	if err != nil {
+		return nil, fmt.Errorf("collect due links: %w", err)
+	}
+	return links, nil
+}
+
+// Check probes the link and records the outcome. A link is flagged as broken after
+// LinkBrokenAfterFailures consecutive failed checks, so a short outage doesn't flag it.
+func (r *LinkCheckRepo) Check(ctx context.Context, bookmarkId types.BookmarkId, link string) (*LinkCheck, error) {
+	previous, err := r.Get(bookmarkId)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		return nil, err
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, linkCheckTimeout)
+	defer cancel()
+	check := probeLink(probeCtx, link)
+	if ctx.Err() != nil {
+		// Stopped while probing, the outcome says nothing about the link
+		return nil, ctx.Err()
+	}
+
+	check.BookmarkId = bookmarkId
+	if linkFailed(check.StatusCode, check.Error) {
+		check.ConsecutiveFailures = 1
+		if previous != nil {
+			check.ConsecutiveFailures = previous.ConsecutiveFailures + 1
+		}
+	}
+	check.Broken = check.ConsecutiveFailures >= LinkBrokenAfterFailures
+	check.CheckedAt = time.Now()
+	check.NextCheckAt = nextLinkCheck(check.ConsecutiveFailures, check.CheckedAt)
+
+	_, err = r.Pool.Exec(ctx, `
+		INSERT INTO link_checks
+		    (library_item_id, status_code, final_url, redirects, error, consecutive_failures, broken, checked_at, next_check_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (library_item_id) DO UPDATE
+		SET status_code          = EXCLUDED.status_code,
+		    final_url            = EXCLUDED.final_url,
+		    redirects            = EXCLUDED.redirects,
+		    error                = EXCLUDED.error,
+		    consecutive_failures = EXCLUDED.consecutive_failures,
+		    broken               = EXCLUDED.broken,
+		    checked_at           = EXCLUDED.checked_at,
+		    next_check_at        = EXCLUDED.next_check_at`,
+		bookmarkId, check.StatusCode, check.FinalUrl, check.Redirects, check.Error,
+		check.ConsecutiveFailures, check.Broken, check.CheckedAt, check.NextCheckAt)
+	if err != nil {
+		return nil, fmt.Errorf("save link check: %w", err)
+	}
+	return &check, nil
+}
+
+// probeLink fetches the link the same way pages are fetched when they are saved
+func probeLink(ctx context.Context, link string) LinkCheck {
+	page, redirects, err := fetcher.Links.Get(ctx, link)
+	check := LinkCheck{Redirects: redirects}
+	if err != nil {
+		var statusErr fetcher.StatusError
+		if errors.As(err, &statusErr) {
+			check.StatusCode = statusErr.StatusCode
+			check.FinalUrl = statusErr.URL
+		}
+		check.Error = err.Error()
+		return check
+	}
+	check.StatusCode = page.StatusCode
+	check.FinalUrl = page.URL.String()
+	return check
+}
+
+// linkFailed reports whether a check means the page may be gone. Answers that say more about
+// the checker than about the page, like rate limiting or bot protection, are not failures.
+func linkFailed(statusCode int, checkError string) bool {
+	if statusCode == 0 {
+		return checkError != ""
+	}
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return statusCode >= 400
+}
+
+// nextLinkCheck schedules the next check. Healthy links are checked rarely, failing links
+// are retried sooner to confirm or clear the failure.
+func nextLinkCheck(failures int, now time.Time) time.Time {
+	if failures == 0 {
+		return now.Add(linkCheckHealthyInterval)
+	}
+	return now.Add(min(time.Duration(failures)*linkCheckRetryInterval, linkCheckBrokenInterval))
+}
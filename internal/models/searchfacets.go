@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arashthr/pensive/internal/searchquery"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxFacetValues is the most values of each facet, the most common ones
+const maxFacetValues = 10
+
+// searchFacets counts the bookmarks matching the words and filters of a query by their site, tag,
+// source, month saved and language. The bookmarks are matched like the keyword search does: the
+// semantic search ranks every bookmark, so it has no matches of its own to count.
+func (model *BookmarkRepo) searchFacets(ctx context.Context, user *User, query searchquery.Query, filter types.BookmarkFilter) (*types.SearchFacets, error) {
+	byPattern := query.Unsegmented()
+	facets, err := model.countFacets(ctx, user, query, filter, byPattern)
+	if err != nil {
+		return nil, err
+	}
+	// Words not found with the full-text search are matched by pattern, like in keywordSearch
+	if facets.Total == 0 && query.HasText() && !byPattern {
+		return model.countFacets(ctx, user, query, filter, true)
+	}
+	return facets, nil
+}
+
+// facetCount is a row of the facets query, the total has no value
+type facetCount struct {
+	Facet string
+	Value string
+	Count int
+}
+
+func (model *BookmarkRepo) countFacets(ctx context.Context, user *User, query searchquery.Query, filter types.BookmarkFilter, byPattern bool) (*types.SearchFacets, error) {
+	conditions, args := filterConditions(filter, []any{user.ID, maxFacetValues})
+	queryConditions, args := searchConditions(query, args)
+	textConditions, args := matchConditions(query, byPattern, args)
+	rows, err := model.Pool.Query(ctx, `
+		WITH matches AS (
+			SELECT
+				li.id,
+				li.source,
+				li.created_at,
+				COALESCE(NULLIF(li.site_name, ''), regexp_replace(`+linkHost+`, '^www\.', '')) AS site,
+				NULLIF(split_part(replace(lower(COALESCE(li.article_lang, '')), '_', '-'), '-', 1), '') AS lang
+			FROM library_items li
+			JOIN library_contents lc ON lc.id = li.id
+			WHERE li.user_id = $1`+conditions+queryConditions+textConditions+`
+		)
+		SELECT 'total' AS facet, '' AS value, COUNT(*) AS count FROM matches
+		UNION ALL
+		(SELECT 'site', site, COUNT(*) FROM matches
+		WHERE site IS NOT NULL
+		GROUP BY site ORDER BY COUNT(*) DESC, site LIMIT $2)
+		UNION ALL
+		(SELECT 'tag', t.name, COUNT(*) FROM matches m
+		JOIN library_item_tags lit ON lit.library_item_id = m.id
+		JOIN tags t ON t.id = lit.tag_id
+		GROUP BY t.name ORDER BY COUNT(*) DESC, t.name LIMIT $2)
+		UNION ALL
+		(SELECT 'source', source, COUNT(*) FROM matches
+		GROUP BY source ORDER BY COUNT(*) DESC, source LIMIT $2)
+		UNION ALL
+		(SELECT 'month', to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM') AS month, COUNT(*) FROM matches
+		GROUP BY month ORDER BY month DESC LIMIT $2)
+		UNION ALL
+		(SELECT 'lang', lang, COUNT(*) FROM matches
+		WHERE lang IS NOT NULL
+		GROUP BY lang ORDER BY COUNT(*) DESC, lang LIMIT $2)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("count search facets: %w", err)
+	}
+
+	counts, err := pgx.CollectRows(rows, pgx.RowToStructByName[facetCount])
+	if err != nil {
+		return nil, fmt.Errorf("collect search facets: %w", err)
+	}
+
+	facets := &types.SearchFacets{}
+	for _, c := range counts {
+		value := types.FacetValue{Value: c.Value, Count: c.Count}
+		switch c.Facet {
+		case "total":
+			facets.Total = c.Count
+		case "site":
+			value.Filter = searchquery.Filter("site", c.Value)
+			facets.Sites = append(facets.Sites, value)
+		case "tag":
+			value.Filter = searchquery.Filter("tag", c.Value)
+			facets.Tags = append(facets.Tags, value)
+		case "source":
+			value.Filter = searchquery.Filter("source", c.Value)
+			facets.Sources = append(facets.Sources, value)
+		case "month":
+			month, err := time.Parse("2006-01", c.Value)
+			if err != nil {
+				return nil, fmt.Errorf("parse facet month: %w", err)
+			}
+			value.Filter = searchquery.MonthFilter(month)
+			facets.Months = append(facets.Months, value)
+		case "lang":
+			value.Filter = searchquery.Filter("lang", c.Value)
+			facets.Langs = append(facets.Langs, value)
+		}
+	}
+	return facets, nil
+}
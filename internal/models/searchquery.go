@@ -0,0 +1,176 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arashthr/pensive/internal/searchquery"
+)
+
+// linkHost is the host of the link of library_items as li, in lower case
+const linkHost = `lower(substring(li.link from '^[a-zA-Z][a-zA-Z0-9+.-]*://([^/:?#]+)'))`
+
+// searchConditions compiles the filters and excluded words of a parsed query into conditions
+// over library_items as li and library_contents as lc, like filterConditions. The values are
+// all passed as arguments.
+func searchConditions(q searchquery.Query, args []any) (string, []any) {
+	var conditions strings.Builder
+	arg := func(value any) int {
+		args = append(args, value)
+		return len(args)
+	}
+
+	// Excluded words are stemmed in the language of each bookmark, like the searched words
+	if query := excludedQuery(q); query != "" {
+		fmt.Fprintf(&conditions, `
+		AND NOT lc.search_vector @@ to_tsquery(lc.search_config, $%d)`, arg(query))
+	}
+	for _, excluded := range q.Excluded {
+		if searchquery.Unsegmented(excluded) {
+			n := arg(textPattern(excluded))
+			fmt.Fprintf(&conditions, `
+		AND NOT (li.title ILIKE $%d OR lc.content ILIKE $%d OR lc.note ILIKE $%d)`, n, n, n)
+		}
+	}
+
+	site := func(value string) string {
+		n := arg(value)
+		return fmt.Sprintf(`(%s = $%d OR %s LIKE '%%.' || $%d OR lower(li.site_name) = $%d)`, linkHost, n, linkHost, n, n)
+	}
+	if len(q.Site.Include) > 0 {
+		sites := make([]string, len(q.Site.Include))
+		for i, value := range q.Site.Include {
+			sites[i] = site(value)
+		}
+		fmt.Fprintf(&conditions, `
+		AND (%s)`, strings.Join(sites, " OR "))
+	}
+	for _, value := range q.Site.Exclude {
+		fmt.Fprintf(&conditions, `
+		AND NOT COALESCE(%s, FALSE)`, site(value))
+	}
+
+	tag := func(value string) string {
+		return fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM library_item_tags lit
+			JOIN tags t ON t.id = lit.tag_id
+			WHERE lit.library_item_id = li.id AND t.name = $%d)`, arg(value))
+	}
+	for _, value := range q.Tag.Include {
+		conditions.WriteString(`
+		AND ` + tag(value))
+	}
+	for _, value := range q.Tag.Exclude {
+		conditions.WriteString(`
+		AND NOT ` + tag(value))
+	}
+
+	if len(q.Source.Include) > 0 {
+		fmt.Fprintf(&conditions, `
+		AND li.source = ANY($%d)`, arg(q.Source.Include))
+	}
+	if len(q.Source.Exclude) > 0 {
+		fmt.Fprintf(&conditions, `
+		AND li.source <> ALL($%d)`, arg(q.Source.Exclude))
+	}
+
+	// Languages match with their regions, like en matching en-US
+	lang := func(values []string) string {
+		return fmt.Sprintf(`split_part(replace(lower(COALESCE(li.article_lang, '')), '_', '-'), '-', 1) = ANY($%d)`, arg(values))
+	}
+	if len(q.Lang.Include) > 0 {
+		conditions.WriteString(`
+		AND ` + lang(q.Lang.Include))
+	}
+	if len(q.Lang.Exclude) > 0 {
+		conditions.WriteString(`
+		AND NOT ` + lang(q.Lang.Exclude))
+	}
+
+	if !q.Before.IsZero() {
+		fmt.Fprintf(&conditions, `
+		AND li.created_at < $%d`, arg(q.Before))
+	}
+	if !q.After.IsZero() {
+		fmt.Fprintf(&conditions, `
+		AND li.created_at >= $%d`, arg(q.After))
+	}
+	if q.ReadingStatus != "" {
+		fmt.Fprintf(&conditions, `
+		AND li.reading_status = $%d`, arg(q.ReadingStatus))
+	}
+	if q.Starred {
+		conditions.WriteString(`
+		AND li.starred`)
+	}
+	return conditions.String(), args
+}
+
+// matchConditions are the conditions of the bookmarks having the words and phrases of a query,
+// in their content, note or highlights, with the full-text search or by pattern like the keyword
+// search
+func matchConditions(q searchquery.Query, byPattern bool, args []any) (string, []any) {
+	if !q.HasText() {
+		return "", args
+	}
+	if !byPattern {
+		args = append(args, textQuery(q))
+		n := len(args)
+		return fmt.Sprintf(`
+		AND (lc.search_vector @@ to_tsquery(lc.search_config, $%d) OR EXISTS (
+			SELECT 1 FROM highlights h
+			WHERE h.library_item_id = li.id AND h.search_vector @@ to_tsquery(h.search_config, $%d)))`, n, n), args
+	}
+	args = append(args, textPattern(q.Text()))
+	n := len(args)
+	return fmt.Sprintf(`
+		AND (
+			li.title ILIKE $%[1]d OR
+			li.excerpt ILIKE $%[1]d OR
+			lc.content ILIKE $%[1]d OR
+			lc.note ILIKE $%[1]d OR
+			COALESCE(li.ai_summary, '') ILIKE $%[1]d OR
+			COALESCE(li.ai_tags, '') ILIKE $%[1]d OR
+			EXISTS (
+				SELECT 1 FROM highlights h
+				WHERE h.library_item_id = li.id
+					AND (h.text ILIKE $%[1]d OR h.note ILIKE $%[1]d)
+			)
+		)`, n), args
+}
+
+// textQuery builds the tsquery matching all the words, by prefix, and phrases of the query. The
+// parser only keeps the letters, marks and digits of the words, so they are safe in the tsquery
+// syntax.
+func textQuery(q searchquery.Query) string {
+	var parts []string
+	for _, word := range q.Words {
+		parts = append(parts, word+":*")
+	}
+	for _, phrase := range q.Phrases {
+		parts = append(parts, phraseQuery(phrase))
+	}
+	return strings.Join(parts, " & ")
+}
+
+// excludedQuery builds the tsquery matching any of the excluded words and phrases of the query.
+// The unsegmented ones are matched by pattern in searchConditions.
+func excludedQuery(q searchquery.Query) string {
+	var parts []string
+	for _, excluded := range q.Excluded {
+		if !searchquery.Unsegmented(excluded) {
+			parts = append(parts, phraseQuery(excluded))
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// textPattern is the ILIKE pattern matching words in order, anywhere in a text. The words only
+// have letters, marks and digits, so they have no wildcards.
+func textPattern(text string) string {
+	return "%" + strings.ReplaceAll(text, " ", "%") + "%"
+}
+
+func phraseQuery(phrase string) string {
+	return "(" + strings.Join(strings.Fields(phrase), " <-> ") + ")"
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/snapshot"
+	"github.com/arashthr/pensive/internal/storage"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Snapshot is the offline copy of a saved page. The file is kept in the storage backend.
+type Snapshot struct {
+	BookmarkId  types.BookmarkId `db:"library_item_id"`
+	UserId      types.UserId
+	Format      types.SnapshotFormat
+	StorageKey  string
+	ContentType string
+	Size        int64
+	Assets      int
+	CreatedAt   time.Time
+}
+
+// Filename is the name of the snapshot file in downloads and exports
+func (s Snapshot) Filename() string {
+	return fmt.Sprintf("%s.%s", s.BookmarkId, s.Format)
+}
+
+// HumanSize is the size of the snapshot file for display
+func (s Snapshot) HumanSize() string {
+	switch {
+	case s.Size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(s.Size)/(1<<20))
+	case s.Size >= 1<<10:
+		return fmt.Sprintf("%d KB", s.Size>>10)
+	}
+	return fmt.Sprintf("%d B", s.Size)
+}
+
+type SnapshotRepo struct {
+	Pool    *pgxpool.Pool
+	Storage storage.Storage
+}
+
+func snapshotKey(userId types.UserId, bookmarkId types.BookmarkId, format types.SnapshotFormat) string {
+	return fmt.Sprintf("snapshots/%d/%s.%s", userId, bookmarkId, format)
+}
+
+// PreferredFormat returns the format the user picked for snapshots of new bookmarks
+func (s *SnapshotRepo) PreferredFormat(ctx context.Context, userId types.UserId) (types.SnapshotFormat, error) {
+	var format types.SnapshotFormat
+	err := s.Pool.QueryRow(ctx, `
+		SELECT snapshot_format FROM users WHERE id = $1`, userId).Scan(&format)
+	if err != nil {
+		return "", fmt.Errorf("get snapshot format: %w", err)
+	}
+	return format, nil
+}
+
+// SetPreferredFormat changes the format of the snapshots of new bookmarks of the user
+func (s *SnapshotRepo) SetPreferredFormat(userId types.UserId, format types.SnapshotFormat) error {
+	_, err := s.Pool.Exec(context.Background(), `
+		UPDATE users SET snapshot_format = $1 WHERE id = $2`, format, userId)
+	if err != nil {
+		return fmt.Errorf("set snapshot format: %w", err)
+	}
+	return nil
+}
+
+// Capture takes the snapshot of the page in the given format and stores it,
+// replacing an older snapshot of the bookmark
+func (s *SnapshotRepo) Capture(ctx context.Context, format types.SnapshotFormat, userId types.UserId, bookmarkId types.BookmarkId, link, fallbackHTML string) error {
+	if format != types.SnapshotFormatHTML && format != types.SnapshotFormatWARC {
+		return fmt.Errorf("unsupported snapshot format %q", format)
+	}
+	captured, err := snapshot.Capture(ctx, format, link, fallbackHTML)
+	if err != nil {
+		return fmt.Errorf("capture snapshot: %w", err)
+	}
+
+	old, err := s.Get(bookmarkId)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		return err
+	}
+	key := snapshotKey(userId, bookmarkId, format)
+	size, err := s.Storage.Put(ctx, key, bytes.NewReader(captured.Body))
+	if err != nil {
+		return fmt.Errorf("store snapshot: %w", err)
+	}
+	_, err = s.Pool.Exec(ctx, `
+		INSERT INTO library_snapshots (library_item_id, user_id, format, storage_key, content_type, size, assets)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (library_item_id) DO UPDATE
+		SET format       = EXCLUDED.format,
+		    storage_key  = EXCLUDED.storage_key,
+		    content_type = EXCLUDED.content_type,
+		    size         = EXCLUDED.size,
+		    assets       = EXCLUDED.assets,
+		    created_at   = NOW()`,
+		bookmarkId, userId, format, key, captured.ContentType, size, captured.Assets)
+	if err != nil {
+		// The bookmark may have been deleted while the page was captured
+		s.Storage.Delete(ctx, key)
+		return fmt.Errorf("save snapshot: %w", err)
+	}
+	// The previous snapshot was in another format
+	if old != nil && old.StorageKey != key {
+		if err := s.Storage.Delete(ctx, old.StorageKey); err != nil {
+			loggercontext.Logger(ctx).Warnw("delete previous snapshot", "error", err, "key", old.StorageKey)
+		}
+	}
+	loggercontext.Logger(ctx).Infow("snapshot captured", "bookmark_id", bookmarkId, "format", format, "size", size, "assets", captured.Assets)
+	return nil
+}
+
+func (s *SnapshotRepo) Get(bookmarkId types.BookmarkId) (*Snapshot, error) {
+	rows, err := s.Pool.Query(context.Background(), `
+		SELECT library_item_id, user_id, format, storage_key, content_type, size, assets, created_at
+		FROM library_snapshots
+		WHERE library_item_id = $1`, bookmarkId)
+	if err != nil {
+		return nil, fmt.Errorf("query snapshot: %w", err)
+	}
+	snap, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Snapshot])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, fmt.Errorf("collect snapshot: %w", err)
+	}
+	return &snap, nil
+}
+
+// GetAllByUserId returns the snapshots of all bookmarks of the user, for exports
+func (s *SnapshotRepo) GetAllByUserId(userId types.UserId) ([]Snapshot, error) {
+	rows, err := s.Pool.Query(context.Background(), `
+		SELECT library_item_id, user_id, format, storage_key, content_type, size, assets, created_at
+		FROM library_snapshots
+		WHERE user_id = $1
+		ORDER BY created_at`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("query user snapshots: %w", err)
+	}
+	snaps, err := pgx.CollectRows(rows, pgx.RowToStructByName[Snapshot])
+	if err != nil {
+		return nil, fmt.Errorf("collect user snapshots: %w", err)
+	}
+	return snaps, nil
+}
+
+// Open returns the file of the snapshot from the storage backend
+func (s *SnapshotRepo) Open(ctx context.Context, snap *Snapshot) (io.ReadCloser, error) {
+	file, err := s.Storage.Open(ctx, snap.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("open snapshot: %w", err)
+	}
+	return file, nil
+}
+
+// Delete removes the snapshot of the bookmark from the storage backend and the database
+func (s *SnapshotRepo) Delete(ctx context.Context, bookmarkId types.BookmarkId) error {
+	snap, err := s.Get(bookmarkId)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := s.Storage.Delete(ctx, snap.StorageKey); err != nil {
+		return fmt.Errorf("delete snapshot file: %w", err)
+	}
+	_, err = s.Pool.Exec(ctx, `DELETE FROM library_snapshots WHERE library_item_id = $1`, bookmarkId)
+	if err != nil {
+		return fmt.Errorf("delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// DeleteAllByUserId removes the snapshot files of the user. The rows go away with the bookmarks.
+func (s *SnapshotRepo) DeleteAllByUserId(ctx context.Context, userId types.UserId) error {
+	snaps, err := s.GetAllByUserId(userId)
+	if err != nil {
+		return err
+	}
+	for _, snap := range snaps {
+		if err := s.Storage.Delete(ctx, snap.StorageKey); err != nil {
+			return fmt.Errorf("delete snapshot file: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxLabelLength is the longest tag or collection name we accept (in characters)
+const MaxLabelLength = 50
+
+type Tag struct {
+	Name  string
+	Count int // Number of bookmarks with this tag
+}
+
+type TagRepo struct {
+	Pool *pgxpool.Pool
+}
+
+// NormalizeTagNames lowercases, trims and de-duplicates tag names.
+// Empty names are dropped; names that are too long or contain separators are rejected.
+func NormalizeTagNames(names []string) ([]string, error) {
+	return normalizeLabels(names, true)
+}
+
+func normalizeLabels(names []string, lowercase bool) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		if lowercase {
+			name = strings.ToLower(name)
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		// Commas separate labels in forms and the Telegram bot, pipes separate callback data
+		if utf8.RuneCountInString(name) > MaxLabelLength || strings.ContainsAny(name, ",|") {
+			return nil, errors.ErrInvalidLabel
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+// SplitLabels splits comma separated user input into label names
+func SplitLabels(input string) []string {
+	return strings.Split(input, ",")
+}
+
+// GetByUserId returns all the tags of a user with the number of bookmarks for each one
+func (t *TagRepo) GetByUserId(userId types.UserId) ([]Tag, error) {
+	rows, err := t.Pool.Query(context.Background(), `
+		SELECT t.name, COUNT(lit.library_item_id) AS count
+		FROM tags t
+		LEFT JOIN library_item_tags lit ON lit.tag_id = t.id
+		WHERE t.user_id = $1
+		GROUP BY t.id, t.name
+		ORDER BY t.name`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("query tags by user id: %w", err)
+	}
+	tags, err := pgx.CollectRows(rows, pgx.RowToStructByName[Tag])
+	if err != nil {
+		return nil, fmt.Errorf("collect tags: %w", err)
+	}
+	return tags, nil
+}
+
+// GetForBookmark returns the tag names attached to a bookmark
+func (t *TagRepo) GetForBookmark(bookmarkId types.BookmarkId) ([]string, error) {
+	rows, err := t.Pool.Query(context.Background(), `
+		SELECT t.name
+		FROM tags t
+		JOIN library_item_tags lit ON lit.tag_id = t.id
+		WHERE lit.library_item_id = $1
+		ORDER BY t.name`, bookmarkId)
+	if err != nil {
+		return nil, fmt.Errorf("query tags for bookmark: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("collect tags for bookmark: %w", err)
+	}
+	return names, nil
+}
+
+// AddToBookmark attaches the tags to the bookmark, creating the missing ones
+func (t *TagRepo) AddToBookmark(ctx context.Context, userId types.UserId, bookmarkId types.BookmarkId, names ...string) error {
+	names, err := NormalizeTagNames(names)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	tx, err := t.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := addTags(ctx, tx, userId, bookmarkId, names); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveFromBookmark detaches a tag from the bookmark. The tag itself is kept.
+func (t *TagRepo) RemoveFromBookmark(ctx context.Context, userId types.UserId, bookmarkId types.BookmarkId, name string) error {
+	_, err := t.Pool.Exec(ctx, `
+		DELETE FROM library_item_tags
+		WHERE library_item_id = $1
+		  AND tag_id = (SELECT id FROM tags WHERE user_id = $2 AND name = $3)`,
+		bookmarkId, userId, strings.ToLower(strings.TrimSpace(name)))
+	if err != nil {
+		return fmt.Errorf("remove tag from bookmark: %w", err)
+	}
+	return nil
+}
+
+// SetForBookmark replaces all the tags of the bookmark with the given ones
+func (t *TagRepo) SetForBookmark(ctx context.Context, userId types.UserId, bookmarkId types.BookmarkId, names []string) error {
+	names, err := NormalizeTagNames(names)
+	if err != nil {
+		return err
+	}
+
+	tx, err := t.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM library_item_tags WHERE library_item_id = $1`, bookmarkId)
+	if err != nil {
+		return fmt.Errorf("clear bookmark tags: %w", err)
+	}
+	if err := addTags(ctx, tx, userId, bookmarkId, names); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+func addTags(ctx context.Context, tx pgx.Tx, userId types.UserId, bookmarkId types.BookmarkId, names []string) error {
+	for _, name := range names {
+		_, err := tx.Exec(ctx, `
+			WITH tag AS (
+				INSERT INTO tags (user_id, name) VALUES ($1, $2)
+				ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+				RETURNING id
+			)
+			INSERT INTO library_item_tags (library_item_id, tag_id)
+			SELECT $3, id FROM tag
+			ON CONFLICT DO NOTHING`, userId, name, bookmarkId)
+		if err != nil {
+			return fmt.Errorf("add tag %q to bookmark: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SuggestedTags returns the AI generated tags that are not already attached to the bookmark
+func SuggestedTags(aiTags *string, current []string) []string {
+	if aiTags == nil {
+		return nil
+	}
+	existing := make(map[string]bool, len(current))
+	for _, name := range current {
+		existing[name] = true
+	}
+	var suggestions []string
+	for _, name := range SplitLabels(*aiTags) {
+		normalized, err := NormalizeTagNames([]string{name})
+		if err != nil || len(normalized) == 0 || existing[normalized[0]] {
+			continue
+		}
+		existing[normalized[0]] = true
+		suggestions = append(suggestions, normalized[0])
+	}
+	return suggestions
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/transcript"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// execer runs a statement on the pool or in a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// saveTranscript keeps the transcript of a video bookmark, replacing the previous one
+func saveTranscript(ctx context.Context, db execer, id types.BookmarkId, t *transcript.Transcript) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO library_transcripts (library_item_id, transcript)
+		VALUES ($1, $2)
+		ON CONFLICT (library_item_id) DO UPDATE SET transcript = EXCLUDED.transcript, created_at = NOW()`,
+		id, t)
+	if err != nil {
+		return fmt.Errorf("save transcript: %w", err)
+	}
+	return nil
+}
+
+// GetTranscript returns the transcript of a video bookmark, or errors.ErrNotFound
+func (model *BookmarkRepo) GetTranscript(id types.BookmarkId) (*transcript.Transcript, error) {
+	var t transcript.Transcript
+	err := model.Pool.QueryRow(context.Background(), `
+		SELECT transcript FROM library_transcripts WHERE library_item_id = $1`, id).Scan(&t)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, fmt.Errorf("get transcript: %w", err)
+	}
+	return &t, nil
+}
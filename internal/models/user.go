@@ -35,6 +35,7 @@ type User struct {
 	EmailVerified      bool
 	EmailVerifiedAt    *time.Time
 	CreatedAt          time.Time
+	SnapshotFormat     types.SnapshotFormat
 }
 
 type UserRepo struct {
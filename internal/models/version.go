@@ -0,0 +1,239 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/document"
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/arashthr/pensive/internal/validations"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	MaxContentVersions = 20               // Previous contents kept for each bookmark, the oldest go first
+	minRefreshInterval = 10 * time.Minute // A page is not fetched again sooner than this
+)
+
+// ContentVersion is a previous content of a bookmark, kept when the page was fetched again.
+// The content itself is loaded with GetVersionContent.
+type ContentVersion struct {
+	Id         int
+	BookmarkId types.BookmarkId `db:"library_item_id"`
+	Title      string
+	Excerpt    string
+	Size       int // Length of the content in characters
+	FetchedAt  time.Time
+}
+
+// ContentRefresh is the outcome of fetching the page of a bookmark again
+type ContentRefresh struct {
+	Changed   bool // False when the page has the same content as before, no version is kept then
+	FetchedAt time.Time
+}
+
+// RefreshContent fetches the page of the bookmark again and replaces its content. The previous
+// content is kept as a version. Search picks up the new content right away, and the AI data and
+// embedding are generated again in the background.
+func (model *BookmarkRepo) RefreshContent(ctx context.Context, bookmark *Bookmark) (*ContentRefresh, error) {
+	logger := loggercontext.Logger(ctx)
+
+	var fetchedAt time.Time
+	var oldTitle, oldContent string
+	err := model.Pool.QueryRow(ctx, `
+		SELECT title, content, fetched_at FROM library_contents WHERE id = $1`, bookmark.Id).
+		Scan(&oldTitle, &oldContent, &fetchedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, fmt.Errorf("get content to refresh: %w", err)
+	}
+	if time.Since(fetchedAt) < minRefreshInterval {
+		return nil, errors.ErrRefreshTooSoon
+	}
+	// An uploaded file has no page to fetch again
+	if document.IsLink(bookmark.Link) {
+		return nil, fmt.Errorf("%w: uploaded file", errors.ErrPageInaccessible)
+	}
+
+	article, err := model.fetchLink(ctx, bookmark.UserId, bookmark.Link)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errors.ErrPageInaccessible, err)
+	}
+	if article.Title == "" {
+		article.Title = oldTitle
+	}
+	if article.Excerpt == "" {
+		article.Excerpt = article.TextContent[:min(200, len(article.TextContent))]
+	}
+	if article.Image != "" {
+		if _, err := url.ParseRequestURI(article.Image); err != nil {
+			article.Image = ""
+		}
+	}
+
+	refresh := &ContentRefresh{
+		Changed:   article.TextContent != oldContent,
+		FetchedAt: time.Now(),
+	}
+	if !refresh.Changed {
+		_, err = model.Pool.Exec(ctx, `
+			UPDATE library_contents SET fetched_at = $1 WHERE id = $2`, refresh.FetchedAt, bookmark.Id)
+		if err != nil {
+			return nil, fmt.Errorf("update fetch time: %w", err)
+		}
+		logger.Infow("content unchanged", "bookmark_id", bookmark.Id)
+		return refresh, nil
+	}
+
+	tx, err := model.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO library_content_versions (library_item_id, title, excerpt, content, fetched_at)
+		SELECT id, title, excerpt, content, fetched_at FROM library_contents WHERE id = $1`, bookmark.Id)
+	if err != nil {
+		return nil, fmt.Errorf("keep content version: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		DELETE FROM library_content_versions
+		WHERE library_item_id = $1
+		  AND id NOT IN (
+		      SELECT id FROM library_content_versions
+		      WHERE library_item_id = $1
+		      ORDER BY fetched_at DESC
+		      LIMIT $2)`, bookmark.Id, MaxContentVersions)
+	if err != nil {
+		return nil, fmt.Errorf("delete old content versions: %w", err)
+	}
+
+	// The markdown was generated from the old content, it's generated again below
+	_, err = tx.Exec(ctx, `
+		UPDATE library_contents
+		SET title = $1, excerpt = $2, content = $3, ai_markdown = NULL, fetched_at = $4, simhash = $5
+		WHERE id = $6`,
+		validations.CleanUpText(article.Title), validations.CleanUpText(article.Excerpt), article.TextContent,
+		refresh.FetchedAt, fingerprint(article.TextContent), bookmark.Id)
+	if err != nil {
+		return nil, fmt.Errorf("update content: %w", err)
+	}
+	// The title is kept, the user may have changed it
+	_, err = tx.Exec(ctx, `
+		UPDATE library_items
+		SET excerpt = $1,
+		    image_url = COALESCE(NULLIF($2, ''), image_url),
+		    article_lang = COALESCE(NULLIF($3, ''), article_lang),
+		    site_name = COALESCE(NULLIF($4, ''), site_name),
+		    published_time = COALESCE($5, published_time),
+		    extraction_method = $6,
+		    fetch_backend = NULLIF($7, '')
+		WHERE id = $8`,
+		validations.CleanUpText(article.Excerpt), article.Image, article.Language, article.SiteName,
+		article.PublishedTime, article.extractionMethod(), article.Backend, bookmark.Id)
+	if err != nil {
+		return nil, fmt.Errorf("update bookmark after refresh: %w", err)
+	}
+	// The page may now be in another language, which changes how its words are searched
+	_, err = tx.Exec(ctx, `
+		UPDATE library_contents lc
+		SET search_config = search_config(li.article_lang)
+		FROM library_items li
+		WHERE li.id = lc.id AND lc.id = $1`, bookmark.Id)
+	if err != nil {
+		return nil, fmt.Errorf("update search config: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE highlights h
+		SET search_config = lc.search_config
+		FROM library_contents lc
+		WHERE lc.id = h.library_item_id AND h.library_item_id = $1`, bookmark.Id)
+	if err != nil {
+		return nil, fmt.Errorf("update highlights search config: %w", err)
+	}
+	if article.Transcript != nil {
+		if err := saveTranscript(ctx, tx, bookmark.Id, article.Transcript); err != nil {
+			return nil, err
+		}
+	} else {
+		_, err = tx.Exec(ctx, `DELETE FROM library_transcripts WHERE library_item_id = $1`, bookmark.Id)
+		if err != nil {
+			return nil, fmt.Errorf("delete transcript: %w", err)
+		}
+	}
+	if err := reanchorHighlights(ctx, tx, bookmark.Id, article.TextContent); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+	logger.Infow("content refreshed", "bookmark_id", bookmark.Id, "content_size", len(article.TextContent))
+	if article.File != nil && model.FileModel != nil {
+		if err := model.FileModel.Save(ctx, bookmark.UserId, bookmark.Id, *article.File); err != nil {
+			logger.Warnw("Failed to save original file", "error", err, "bookmark_id", bookmark.Id)
+		}
+	}
+
+	if model.AIAvailable() {
+		contentForMarkdown := article.TextContent
+		if article.Content != "" {
+			contentForMarkdown = article.Content
+		}
+		model.enqueueAIJob(ctx, bookmark.Id, AIJobKindEnrich, contentForMarkdown)
+	}
+	return refresh, nil
+}
+
+// ContentFetchedAt returns when the current content of the bookmark was fetched
+func (model *BookmarkRepo) ContentFetchedAt(id types.BookmarkId) (time.Time, error) {
+	var fetchedAt time.Time
+	err := model.Pool.QueryRow(context.Background(), `
+		SELECT fetched_at FROM library_contents WHERE id = $1`, id).Scan(&fetchedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, errors.ErrNotFound
+		}
+		return time.Time{}, fmt.Errorf("get content fetch time: %w", err)
+	}
+	return fetchedAt, nil
+}
+
+// GetVersions returns the previous contents of the bookmark, the newest first
+func (model *BookmarkRepo) GetVersions(id types.BookmarkId) ([]ContentVersion, error) {
+	rows, err := model.Pool.Query(context.Background(), `
+		SELECT id, library_item_id, title, excerpt, char_length(content) AS size, fetched_at
+		FROM library_content_versions
+		WHERE library_item_id = $1
+		ORDER BY fetched_at DESC`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query content versions: %w", err)
+	}
+	versions, err := pgx.CollectRows(rows, pgx.RowToStructByName[ContentVersion])
+	if err != nil {
+		return nil, fmt.Errorf("collect content versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetVersionContent returns the content of a previous version of the bookmark
+func (model *BookmarkRepo) GetVersionContent(id types.BookmarkId, versionId int) (string, error) {
+	var content string
+	err := model.Pool.QueryRow(context.Background(), `
+		SELECT content FROM library_content_versions
+		WHERE id = $1 AND library_item_id = $2`, versionId, id).Scan(&content)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.ErrNotFound
+		}
+		return "", fmt.Errorf("get content version: %w", err)
+	}
+	return content, nil
+}
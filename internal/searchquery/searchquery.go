@@ -0,0 +1,298 @@
+// Package searchquery parses the search queries of the web, the API and the Telegram bot, like
+// `"vector search" site:example.com tag:go -python after:2024-01 is:unread`
+//
+// Words match the bookmarks that have them, quoted words match them next to each other, and a
+// leading - excludes the bookmarks with a word, phrase or filter. Words are matched in the
+// language of each bookmark, and the words of Chinese, Japanese, Thai and the other scripts
+// without spaces are matched as parts of the text. The filters are:
+//
+//   - site: the domain of the link or the name of the site, including its subdomains
+//   - tag: a tag of the bookmark, all the tags are required
+//   - source: where the bookmark was saved from, like web, telegram, api or pocket
+//   - lang: the language of the page, like en or de
+//   - before: and after: a day, month or year saved, like 2024-03-15, 2024-03 or 2024. Both
+//     leave out the period itself: after:2024 is from 2025 on
+//   - is: unread, reading, archived or starred
+//
+// Unknown filters are searched as words.
+package searchquery
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/types"
+)
+
+// maxTerms is the most words, phrases and filters of a query
+const maxTerms = 32
+
+// Values are the values of a filter the bookmarks have, and the ones they don't
+type Values struct {
+	Include []string
+	Exclude []string
+}
+
+func (v *Values) add(value string, negated bool) {
+	if negated {
+		v.Exclude = append(v.Exclude, value)
+	} else {
+		v.Include = append(v.Include, value)
+	}
+}
+
+// Query is a parsed search query
+type Query struct {
+	Words    []string // Matched by prefix, like "learn" matching "learning"
+	Phrases  []string // Words following each other, separated by a space
+	Excluded []string // Words and phrases the bookmarks don't have
+	Site     Values
+	Tag      Values
+	Source   Values
+	Lang     Values
+	Before   time.Time // Zero when not set
+	After    time.Time // Zero when not set
+
+	ReadingStatus types.ReadingStatus
+	Starred       bool
+}
+
+// HasText reports whether the query has words or phrases to match, rather than only filters
+func (q Query) HasText() bool {
+	return len(q.Words) > 0 || len(q.Phrases) > 0
+}
+
+// IsEmpty reports whether the query matches nothing in particular
+func (q Query) IsEmpty() bool {
+	return !q.HasText() && len(q.Excluded) == 0 &&
+		len(q.Site.Include) == 0 && len(q.Site.Exclude) == 0 &&
+		len(q.Tag.Include) == 0 && len(q.Tag.Exclude) == 0 &&
+		len(q.Source.Include) == 0 && len(q.Source.Exclude) == 0 &&
+		len(q.Lang.Include) == 0 && len(q.Lang.Exclude) == 0 &&
+		q.Before.IsZero() && q.After.IsZero() && q.ReadingStatus == "" && !q.Starred
+}
+
+// Text is the words and phrases of the query, for the searches by meaning and by pattern
+func (q Query) Text() string {
+	return strings.Join(append(append([]string{}, q.Words...), q.Phrases...), " ")
+}
+
+// Unsegmented reports whether the words or phrases of the query are in a script without spaces
+// between the words
+func (q Query) Unsegmented() bool {
+	return Unsegmented(q.Text())
+}
+
+// Words splits a text into the words a query can match: the letters, marks and digits of any
+// script. Other characters only separate them, so the words are safe in a tsquery.
+func Words(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return words
+}
+
+// unsegmented are the scripts written without spaces between the words
+var unsegmented = []*unicode.RangeTable{
+	unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar,
+}
+
+// Unsegmented reports whether a text has a script without spaces between the words, like
+// Chinese or Thai. The full-text search can't split these texts into words, so they are matched
+// as parts of the text instead.
+func Unsegmented(text string) bool {
+	for _, r := range text {
+		if unicode.In(r, unsegmented...) {
+			return true
+		}
+	}
+	return false
+}
+
+// fields are the filters of the queries
+var fields = map[string]bool{
+	"site": true, "tag": true, "source": true, "lang": true, "before": true, "after": true, "is": true,
+}
+
+// Parse parses a search query. The errors are ErrInvalidSearchQuery, with what is wrong.
+func Parse(input string) (Query, error) {
+	var q Query
+	s := []rune(input)
+	terms := 0
+	for i := 0; i < len(s); {
+		if unicode.IsSpace(s[i]) {
+			i++
+			continue
+		}
+		if terms++; terms > maxTerms {
+			return Query{}, fmt.Errorf("%w: more than %d terms", errors.ErrInvalidSearchQuery, maxTerms)
+		}
+
+		negated := false
+		if s[i] == '-' && i+1 < len(s) && !unicode.IsSpace(s[i+1]) {
+			negated = true
+			i++
+		}
+		field := fieldAt(s[i:])
+		if field != "" {
+			i += len(field) + 1
+		}
+
+		var value string
+		quoted := false
+		if i < len(s) && s[i] == '"' {
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				end++
+			}
+			value, quoted = string(s[i+1:end]), true
+			i = end + 1
+		} else {
+			start := i
+			for i < len(s) && !unicode.IsSpace(s[i]) {
+				i++
+			}
+			value = string(s[start:i])
+		}
+
+		if err := q.add(field, strings.TrimSpace(value), quoted, negated); err != nil {
+			return Query{}, err
+		}
+	}
+	return q, nil
+}
+
+// fieldAt returns the filter at the start of s, like site in site:example.com
+func fieldAt(s []rune) string {
+	for i, r := range s {
+		if r == ':' {
+			if name := strings.ToLower(string(s[:i])); fields[name] {
+				return name
+			}
+			return ""
+		}
+		if !unicode.IsLetter(r) {
+			return ""
+		}
+	}
+	return ""
+}
+
+func (q *Query) add(field, value string, quoted, negated bool) error {
+	if field == "" {
+		words := Words(value)
+		switch {
+		case len(words) == 0:
+		case negated:
+			q.Excluded = append(q.Excluded, strings.Join(words, " "))
+		case quoted || len(words) > 1:
+			q.Phrases = append(q.Phrases, strings.Join(words, " "))
+		default:
+			q.Words = append(q.Words, words[0])
+		}
+		return nil
+	}
+
+	if value == "" {
+		return fmt.Errorf("%w: %s: needs a value", errors.ErrInvalidSearchQuery, field)
+	}
+	value = strings.ToLower(value)
+	switch field {
+	case "site":
+		site, err := parseSite(value)
+		if err != nil {
+			return err
+		}
+		q.Site.add(site, negated)
+	case "tag":
+		q.Tag.add(value, negated)
+	case "source":
+		q.Source.add(value, negated)
+	case "lang":
+		q.Lang.add(value, negated)
+	case "before", "after":
+		if negated {
+			return fmt.Errorf("%w: %s: can't be excluded", errors.ErrInvalidSearchQuery, field)
+		}
+		start, end, err := parsePeriod(value)
+		if err != nil {
+			return err
+		}
+		if field == "before" {
+			q.Before = start
+		} else {
+			q.After = end
+		}
+	case "is":
+		if negated {
+			return fmt.Errorf("%w: is: can't be excluded", errors.ErrInvalidSearchQuery)
+		}
+		if value == "starred" {
+			q.Starred = true
+			return nil
+		}
+		status, ok := types.ParseReadingStatus(value)
+		if !ok {
+			return fmt.Errorf("%w: is:%s should be is:unread, is:reading, is:archived or is:starred", errors.ErrInvalidSearchQuery, value)
+		}
+		q.ReadingStatus = status
+	}
+	return nil
+}
+
+// Filter writes a filter of a query, quoting the values with spaces, like site:"the verge"
+func Filter(field, value string) string {
+	value = strings.ReplaceAll(value, `"`, "")
+	if strings.ContainsFunc(value, unicode.IsSpace) {
+		return field + `:"` + value + `"`
+	}
+	return field + ":" + value
+}
+
+// MonthFilter writes the filters of a query keeping the bookmarks saved in the month starting at
+// month
+func MonthFilter(month time.Time) string {
+	return "after:" + month.AddDate(0, -1, 0).Format("2006-01") + " before:" + month.AddDate(0, 1, 0).Format("2006-01")
+}
+
+// parseSite returns the domain of a site: filter, which may be a link
+func parseSite(value string) (string, error) {
+	if strings.Contains(value, "://") {
+		link, err := url.Parse(value)
+		if err != nil || link.Hostname() == "" {
+			return "", fmt.Errorf("%w: invalid site %q", errors.ErrInvalidSearchQuery, value)
+		}
+		value = link.Hostname()
+	}
+	site := strings.TrimPrefix(strings.Trim(value, "./"), "www.")
+	if site == "" {
+		return "", fmt.Errorf("%w: invalid site %q", errors.ErrInvalidSearchQuery, value)
+	}
+	return site, nil
+}
+
+// parsePeriod returns the start and end of a day, month or year, in UTC
+func parsePeriod(value string) (time.Time, time.Time, error) {
+	for _, period := range []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	} {
+		if start, err := time.Parse(period.layout, value); err == nil {
+			return start, start.AddDate(period.years, period.months, period.days), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid date %q, use 2024-03-15, 2024-03 or 2024", errors.ErrInvalidSearchQuery, value)
+}
@@ -0,0 +1,63 @@
+// Package secrets encrypts the secrets users keep in the database, like the cookies of their
+// fetch profiles, with AES-GCM
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/arashthr/pensive/internal/rand"
+)
+
+// KeySize is the size of the key in bytes, for AES-256
+const KeySize = 32
+
+// Cipher encrypts and decrypts with one key
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher builds the cipher from the key encoded in base64, like the output of
+// `openssl rand -base64 32`
+func NewCipher(key string) (*Cipher, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("key should be %d bytes, got %d", KeySize, len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts the plaintext. The random nonce is prepended to the result.
+func (c *Cipher) Seal(plaintext []byte) ([]byte, error) {
+	nonce, err := rand.Bytes(c.aead.NonceSize())
+	if err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts what Seal encrypted
+func (c *Cipher) Open(sealed []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, fmt.Errorf("sealed data is too short")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return plaintext, nil
+}
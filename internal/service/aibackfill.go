@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/logging"
+	"github.com/arashthr/pensive/internal/models"
+)
+
+const aiBackfillInterval = 30 * time.Second
+
+// AIBackfill queues the bookmarks of the running backfills for the AI jobs worker, a batch per
+// user at a time so the backfills don't delay the processing of the newly saved bookmarks
+type AIBackfill struct {
+	AIBackfillModel *models.AIBackfillRepo
+	UserModel       *models.UserRepo
+}
+
+// Start runs the backfills until ctx is cancelled – call it in a goroutine.
+func (a *AIBackfill) Start(ctx context.Context) {
+	logger := logging.Logger.With("flow", "ai-backfill")
+	ctx = loggercontext.WithLogger(ctx, logger)
+	logger.Infow("Starting")
+
+	ticker := time.NewTicker(aiBackfillInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infow("Stopping")
+			return
+		case <-ticker.C:
+			a.runTick(ctx)
+		}
+	}
+}
+
+func (a *AIBackfill) runTick(ctx context.Context) {
+	logger := loggercontext.Logger(ctx)
+
+	userIds, err := a.AIBackfillModel.Running(ctx)
+	if err != nil {
+		logger.Errorw("get running ai backfills", "error", err)
+		return
+	}
+	for _, userId := range userIds {
+		if ctx.Err() != nil {
+			return
+		}
+		user, err := a.UserModel.Get(userId)
+		if err != nil {
+			if !errors.Is(err, errors.ErrNotFound) {
+				logger.Errorw("get user of ai backfill", "error", err, "user_id", userId)
+			}
+			continue
+		}
+		queued, err := a.AIBackfillModel.QueueBatch(ctx, user)
+		if err != nil {
+			logger.Errorw("queue ai backfill batch", "error", err, "user_id", userId)
+			continue
+		}
+		if queued > 0 {
+			logger.Infow("Queued bookmarks for AI backfill", "user_id", userId, "count", queued)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/logging"
+	"github.com/arashthr/pensive/internal/models"
+)
+
+const (
+	aiJobsInterval  = 5 * time.Second
+	aiJobsBatchSize = 3 // Jobs run at the same time, each calls the language model
+)
+
+// AIJobs runs the queued AI processing of the bookmarks: their summary, tags, markdown and
+// embedding. Failed jobs are retried with a backoff until they reach their max attempts.
+type AIJobs struct {
+	AIJobModel    *models.AIJobRepo
+	BookmarkModel *models.BookmarkRepo
+}
+
+// Start runs the worker until ctx is cancelled – call it in a goroutine.
+func (a *AIJobs) Start(ctx context.Context) {
+	logger := logging.Logger.With("flow", "ai-jobs")
+	ctx = loggercontext.WithLogger(ctx, logger)
+	logger.Infow("Starting")
+
+	ticker := time.NewTicker(aiJobsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infow("Stopping")
+			return
+		case <-ticker.C:
+			a.runTick(ctx)
+		}
+	}
+}
+
+func (a *AIJobs) runTick(ctx context.Context) {
+	logger := loggercontext.Logger(ctx)
+
+	if reaped, err := a.AIJobModel.ReapTimedOut(ctx); err != nil {
+		logger.Errorw("reap timed-out ai jobs", "error", err)
+	} else if reaped > 0 {
+		logger.Warnw("Reaped timed-out ai jobs", "count", reaped)
+	}
+
+	jobs, err := a.AIJobModel.Claim(ctx, aiJobsBatchSize)
+	if err != nil {
+		logger.Errorw("claim ai jobs", "error", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.process(ctx, job)
+		}()
+	}
+	wg.Wait()
+}
+
+func (a *AIJobs) process(ctx context.Context, job models.AIJob) {
+	logger := loggercontext.Logger(ctx).With("job_id", job.Id, "bookmark_id", job.BookmarkId, "kind", job.Kind, "attempt", job.Attempts)
+	jobCtx, cancel := context.WithTimeout(loggercontext.WithLogger(ctx, logger), models.AIJobTimeout)
+	defer cancel()
+
+	// The job is recorded even when the server is stopping, so it isn't left processing
+	recordCtx := context.WithoutCancel(ctx)
+	err := a.BookmarkModel.ProcessAIJob(jobCtx, job)
+	if err == nil {
+		if err := a.AIJobModel.Done(recordCtx, job); err != nil {
+			logger.Errorw("mark ai job done", "error", err)
+		}
+		return
+	}
+
+	logger.Warnw("AI job failed", "error", err)
+	failed, ferr := a.AIJobModel.Fail(recordCtx, job, err)
+	if ferr != nil {
+		logger.Errorw("mark ai job failed", "error", ferr)
+		return
+	}
+	if failed {
+		logger.Errorw("AI job failed for good", "error", err)
+		logging.Telegram.SendMessage(fmt.Sprintf("AI job %d (%s) of bookmark %s failed after %d attempts: %v", job.Id, job.Kind, job.BookmarkId, job.Attempts, err))
+	}
+}
@@ -1,15 +1,19 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
 	"github.com/arashthr/pensive/internal/auth/context/usercontext"
+	"github.com/arashthr/pensive/internal/document"
 	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/fetcher"
 	"github.com/arashthr/pensive/internal/models"
 	"github.com/arashthr/pensive/internal/types"
 	"github.com/arashthr/pensive/internal/validations"
@@ -17,7 +21,12 @@ import (
 )
 
 type Api struct {
-	BookmarkModel *models.BookmarkRepo
+	BookmarkModel   *models.BookmarkRepo
+	TagModel        *models.TagRepo
+	CollectionModel *models.CollectionRepo
+	HighlightModel  *models.HighlightRepo
+	LinkCheckModel  *models.LinkCheckRepo
+	AIJobModel      *models.AIJobRepo
 }
 
 type ErrorResponse struct {
@@ -26,10 +35,67 @@ type ErrorResponse struct {
 }
 
 type Bookmark struct {
-	Id      types.BookmarkId
-	Title   string
-	Link    string
-	Excerpt string
+	Id            types.BookmarkId
+	Title         string
+	Link          string
+	Excerpt       string
+	ReadingStatus types.ReadingStatus
+	Starred       bool
+	Broken        bool // The link stopped working
+}
+
+// BookmarkDetails is a bookmark with its user-defined organization and note
+type BookmarkDetails struct {
+	Bookmark
+	Tags          []string
+	Collections   []string
+	SuggestedTags []string `json:",omitempty"`
+	Note          string
+	LinkCheck     *LinkCheck    `json:",omitempty"` // Missing until the link is checked
+	AIProcessing  *AIProcessing `json:",omitempty"` // Missing when the bookmark is not processed by AI
+}
+
+// AIProcessing is the progress of the summary, tags, markdown and embedding of the bookmark
+type AIProcessing struct {
+	Status models.AIJobStatus // The failed jobs first, then the running, waiting and done ones
+	Jobs   []AIJob
+}
+
+// AIJob is a step of the AI processing of the bookmark
+type AIJob struct {
+	Kind          string
+	Status        models.AIJobStatus
+	Attempts      int
+	MaxAttempts   int
+	LastError     string     `json:",omitempty"`
+	NextAttemptAt *time.Time `json:",omitempty"` // Set when a failed attempt is retried later
+	CompletedAt   *time.Time `json:",omitempty"`
+}
+
+// LinkCheck is the last probe of the bookmark link by the dead-link checker
+type LinkCheck struct {
+	StatusCode  int    // 0 when the page could not be reached
+	FinalUrl    string `json:",omitempty"`
+	Redirects   int
+	Error       string `json:",omitempty"`
+	Broken      bool
+	CheckedAt   time.Time
+	ArchiveLink string `json:",omitempty"` // Where an archived copy of the page may be found
+}
+
+// CreatedBookmark is the response to saving a bookmark
+type CreatedBookmark struct {
+	Bookmark
+	PossibleDuplicate *DuplicateOf `json:",omitempty"` // Set when the content was already saved from another link
+}
+
+// DuplicateOf is a bookmark with nearly the same content as another one
+type DuplicateOf struct {
+	Id        types.BookmarkId
+	Title     string
+	Link      string
+	CreatedAt time.Time
+	Message   string // Ready to show to the user
 }
 
 // CheckBookmarkByLinkAPI checks if a bookmark exists by URL without creating it
@@ -106,7 +172,15 @@ func (a *Api) IndexAPI(w http.ResponseWriter, r *http.Request) {
 	user := usercontext.User(r.Context())
 	logger := loggercontext.Logger(r.Context())
 	page := validations.GetPageOffset(r.FormValue("page"))
-	bookmarks, _, _, err := a.BookmarkModel.GetByUserId(user.ID, page)
+	filter, err := bookmarkFilterFromRequest(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_FILTER",
+			Message: err.Error(),
+		})
+		return
+	}
+	bookmarks, _, _, err := a.BookmarkModel.GetByUserId(user.ID, page, filter)
 	if err != nil {
 		logger.Errorw("fetching bookmarks", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -126,14 +200,100 @@ func (a *Api) IndexAPI(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// UploadAPI saves an uploaded document (PDF, Markdown, HTML, EPUB or DOCX) as a bookmark. The
+// file is sent in the "file" field of a form, or as the body with the filename query parameter.
+//
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "The document"
+// @Param filename query string false "Name of the file sent as the body"
+// @Success 200 {object} CreatedBookmark
+// @Failure 400 {object} ErrorResponse "Invalid request body"
+// @Failure 403 {object} ErrorResponse "Unverified account limit reached"
+// @Failure 413 {object} ErrorResponse "File too large"
+// @Failure 415 {object} ErrorResponse "Unsupported file type"
+// @Failure 422 {object} ErrorResponse "No text in the file"
+// @Failure 429 {object} ErrorResponse "Daily bookmark limit exceeded"
+// @Failure 500 {object} ErrorResponse "Failed to save the document"
+// @Router /v1/api/documents [post]
+// @Router /v1/api/bookmarks/upload [post]
+func (a *Api) UploadAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := loggercontext.Logger(ctx)
+	user := usercontext.User(ctx)
+
+	file, err := uploadedFile(w, r)
+	if errors.Is(err, errors.ErrFileTooLarge) {
+		logger.Warnw("[api] uploaded file too large", "user_id", user.ID)
+		writeErrorResponse(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+			Code:    "FILE_TOO_LARGE",
+			Message: fmt.Sprintf("The file is larger than %d bytes", document.MaxFileSize),
+		})
+		return
+	} else if err != nil {
+		logger.Warnw("[api] reading uploaded file", "error", err, "user_id", user.ID)
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_REQUEST",
+			Message: fmt.Sprintf("Invalid upload: %v", err),
+		})
+		return
+	}
+
+	bookmark, err := a.BookmarkModel.CreateFromFile(ctx, user, models.Api, file)
+	if err != nil {
+		var status int
+		var resp ErrorResponse
+		switch {
+		case errors.Is(err, errors.ErrUnverifiedUserLimitExceeded):
+			status, resp = http.StatusForbidden, ErrorResponse{
+				Code:    "UNVERIFIED_USER_LIMIT_EXCEEDED",
+				Message: "Unverified account limit reached (10 bookmarks). Please verify your email to unlock unlimited bookmarks.",
+			}
+		case errors.Is(err, errors.ErrDailyLimitExceeded):
+			status, resp = http.StatusTooManyRequests, ErrorResponse{
+				Code:    "DAILY_LIMIT_EXCEEDED",
+				Message: "Daily bookmark limit exceeded. Upgrade to premium for 100 bookmarks/day.",
+			}
+		case errors.Is(err, errors.ErrFileTooLarge):
+			status, resp = http.StatusRequestEntityTooLarge, ErrorResponse{
+				Code:    "FILE_TOO_LARGE",
+				Message: fmt.Sprintf("The file is larger than %d bytes", document.MaxFileSize),
+			}
+		case errors.Is(err, errors.ErrUnsupportedFile):
+			status, resp = http.StatusUnsupportedMediaType, ErrorResponse{Code: "UNSUPPORTED_FILE", Message: err.Error()}
+		case errors.Is(err, errors.ErrNoText):
+			status, resp = http.StatusUnprocessableEntity, ErrorResponse{Code: "NO_TEXT", Message: err.Error()}
+		default:
+			logger.Errorw("[api] failed to create bookmark from file", "error", err, "user_id", user.ID)
+			writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Code:    "INTERNAL_ERROR",
+				Message: "api: Something went wrong",
+			})
+			return
+		}
+		logger.Warnw("[api] upload failed", "error", err, "user_id", user.ID)
+		writeErrorResponse(w, status, resp)
+		return
+	}
+	logger.Infow("[api] uploaded bookmark", "bookmarkId", bookmark.Id, "filename", file.Name)
+	err = writeResponse(w, CreatedBookmark{Bookmark: mapModelToBookmark(bookmark)})
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
 // CreateAPI handles the creation of a new bookmark.
 //
 // @Accept json
 // @Produce json
 // @Param data body struct{Link string; HtmlContent string; TextContent string; Title string; Excerpt string; Lang string; SiteName string; PublishedTime string; ImageUrl string} true "Bookmark link and content"
-// @Success 200 {object} Bookmark
+// @Success 200 {object} CreatedBookmark
 // @Failure 400 {object} ErrorResponse "Invalid request body or invalid URL"
+// @Failure 415 {object} ErrorResponse "The link is not a web page or a PDF"
+// @Failure 422 {object} ErrorResponse "The link points to a private address, redirects too many times or the page is too large"
 // @Failure 500 {object} ErrorResponse "Failed to create bookmark"
+// @Failure 502 {object} ErrorResponse "The page could not be fetched"
+// @Failure 504 {object} ErrorResponse "The page took too long to respond"
 // @Router /v1/api/bookmarks [post]
 func (a *Api) CreateAPI(w http.ResponseWriter, r *http.Request) {
 	var data types.CreateBookmarkRequest
@@ -181,6 +341,11 @@ func (a *Api) CreateAPI(w http.ResponseWriter, r *http.Request) {
 				Message: "Daily bookmark limit exceeded. Upgrade to premium for 100 bookmarks/day.",
 			})
 			return
+		} else if errors.Is(err, errors.ErrPageInaccessible) {
+			logger.Warnw("[api] page inaccessible", "error", err, "link", data.Link)
+			status, resp := fetchErrorResponse(err)
+			writeErrorResponse(w, status, resp)
+			return
 		}
 		logger.Errorw("[api] failed to create bookmark", "error", err)
 
@@ -191,7 +356,21 @@ func (a *Api) CreateAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	logger.Infow("[api] created bookmark", "bookmarkId", bookmark.Id)
-	err = writeResponse(w, mapModelToBookmark(bookmark))
+	created := CreatedBookmark{Bookmark: mapModelToBookmark(bookmark)}
+	duplicate, err := a.BookmarkModel.NearDuplicate(ctx, user.ID, bookmark.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("[api] get near duplicate", "error", err, "bookmark_id", bookmark.Id)
+	}
+	if duplicate != nil {
+		created.PossibleDuplicate = &DuplicateOf{
+			Id:        duplicate.Id,
+			Title:     duplicate.Title,
+			Link:      duplicate.Link,
+			CreatedAt: duplicate.CreatedAt,
+			Message:   fmt.Sprintf("This looks like \"%s\" you saved on %s", duplicate.Title, duplicate.CreatedAt.Format("Jan 2, 2006")),
+		}
+	}
+	err = writeResponse(w, created)
 	if err != nil {
 		logger.Errorw("write response", "error", err)
 	}
@@ -207,12 +386,30 @@ func (a *Api) GetAPI(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	err := writeResponse(w, mapModelToBookmark(bookmark))
+	details, err := a.bookmarkDetails(bookmark)
+	if err != nil {
+		logger.Errorw("[api] get bookmark details", "error", err, "bookmark_id", bookmark.Id)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	err = writeResponse(w, details)
 	if err != nil {
 		logger.Errorw("write response", "error", err)
 	}
 }
 
+// UpdateAPI updates the title, reading state, tags, collections and note of a bookmark.
+// Tags, collections and the note replace the existing ones when present in the body.
+//
+// @Accept json
+// @Produce json
+// @Param data body struct{Title string; ReadingStatus string; Starred bool; Tags []string; Collections []string; Note string} true "Fields to update"
+// @Success 200 {object} BookmarkDetails
+// @Failure 400 {object} ErrorResponse "Invalid request body or invalid tag name"
+// @Router /v1/api/bookmarks/{id} [put]
 func (a *Api) UpdateAPI(w http.ResponseWriter, r *http.Request) {
 	user := usercontext.User(r.Context())
 	logger := loggercontext.Logger(r.Context())
@@ -221,7 +418,14 @@ func (a *Api) UpdateAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	logger.Debugw("[api] updating bookmark", "bookmark_id", bookmark.Id, "user_id", user.ID)
-	var b Bookmark
+	var b struct {
+		Title         string
+		ReadingStatus *string
+		Starred       *bool
+		Tags          *[]string
+		Collections   *[]string
+		Note          *string
+	}
 	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
 		logger.Errorw("[api] decoding request body", "error", err)
 		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
@@ -230,10 +434,83 @@ func (a *Api) UpdateAPI(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	bookmark.Title = b.Title
-	err := a.BookmarkModel.Update(bookmark)
+	if b.Title != "" {
+		bookmark.Title = b.Title
+		err := a.BookmarkModel.Update(bookmark)
+		if err != nil {
+			logger.Errorw("[api] failed to update bookmark", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Code:    "UPDATE_BOOKMARK",
+				Message: fmt.Sprintf("Failed to update bookmark: %v", err),
+			})
+			return
+		}
+	}
+
+	if b.ReadingStatus != nil {
+		status, ok := types.ParseReadingStatus(*b.ReadingStatus)
+		if !ok {
+			writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+				Code:    "INVALID_STATUS",
+				Message: fmt.Sprintf("Invalid reading status: %q", *b.ReadingStatus),
+			})
+			return
+		}
+		if err := a.BookmarkModel.SetReadingStatus(bookmark.Id, status); err != nil {
+			logger.Errorw("[api] failed to set reading status", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Code:    "UPDATE_BOOKMARK",
+				Message: fmt.Sprintf("Failed to update bookmark: %v", err),
+			})
+			return
+		}
+		bookmark.ReadingStatus = status
+	}
+	if b.Starred != nil {
+		if err := a.BookmarkModel.SetStarred(bookmark.Id, *b.Starred); err != nil {
+			logger.Errorw("[api] failed to set starred", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Code:    "UPDATE_BOOKMARK",
+				Message: fmt.Sprintf("Failed to update bookmark: %v", err),
+			})
+			return
+		}
+		bookmark.Starred = *b.Starred
+	}
+	if b.Note != nil {
+		if err := a.BookmarkModel.SetNote(r.Context(), bookmark.Id, *b.Note); err != nil {
+			if errors.Is(err, errors.ErrNoteTooLong) {
+				writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+					Code:    "NOTE_TOO_LONG",
+					Message: fmt.Sprintf("Notes can be at most %d characters", models.MaxNoteLength),
+				})
+				return
+			}
+			logger.Errorw("[api] failed to set note", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Code:    "UPDATE_BOOKMARK",
+				Message: fmt.Sprintf("Failed to update bookmark: %v", err),
+			})
+			return
+		}
+	}
+
+	var err error
+	if b.Tags != nil {
+		err = a.TagModel.SetForBookmark(r.Context(), user.ID, bookmark.Id, *b.Tags)
+	}
+	if err == nil && b.Collections != nil {
+		err = a.CollectionModel.SetForBookmark(r.Context(), user.ID, bookmark.Id, *b.Collections)
+	}
 	if err != nil {
-		logger.Errorw("[api] failed to update bookmark", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+		if errors.Is(err, errors.ErrInvalidLabel) {
+			writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+				Code:    "INVALID_TAG",
+				Message: fmt.Sprintf("Tag and collection names must be at most %d characters and cannot contain ',' or '|'", models.MaxLabelLength),
+			})
+			return
+		}
+		logger.Errorw("[api] failed to update bookmark labels", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
 		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
 			Code:    "UPDATE_BOOKMARK",
 			Message: fmt.Sprintf("Failed to update bookmark: %v", err),
@@ -241,7 +518,459 @@ func (a *Api) UpdateAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	logger.Infow("[api] updated bookmark", "bookmark_id", bookmark.Id, "user_id", user.ID)
-	err = writeResponse(w, mapModelToBookmark(bookmark))
+
+	details, err := a.bookmarkDetails(bookmark)
+	if err != nil {
+		logger.Errorw("[api] get bookmark details", "error", err, "bookmark_id", bookmark.Id)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	err = writeResponse(w, details)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+// TagsAPI lists the tags of the user with the number of bookmarks for each one
+//
+// @Produce json
+// @Success 200 {object} struct{Tags []models.Tag}
+// @Router /v1/api/tags [get]
+func (a *Api) TagsAPI(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+	tags, err := a.TagModel.GetByUserId(user.ID)
+	if err != nil {
+		logger.Errorw("[api] get tags", "error", err, "user_id", user.ID)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	var data struct {
+		Tags []models.Tag
+	}
+	data.Tags = append(make([]models.Tag, 0, len(tags)), tags...)
+	err = writeResponse(w, data)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+// CollectionsAPI lists the collections of the user with the number of bookmarks in each one
+//
+// @Produce json
+// @Success 200 {object} struct{Collections []models.Collection}
+// @Router /v1/api/collections [get]
+func (a *Api) CollectionsAPI(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+	collections, err := a.CollectionModel.GetByUserId(user.ID)
+	if err != nil {
+		logger.Errorw("[api] get collections", "error", err, "user_id", user.ID)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	var data struct {
+		Collections []models.Collection
+	}
+	data.Collections = append(make([]models.Collection, 0, len(collections)), collections...)
+	err = writeResponse(w, data)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+// HighlightRequest is the body to create a highlight. Prefix and suffix are the text right
+// before and after the selection, used to find the right occurrence of a repeated passage.
+type HighlightRequest struct {
+	Link   string
+	Text   string
+	Note   string
+	Prefix string
+	Suffix string
+}
+
+// BookmarkHighlightsAPI lists the highlights of a bookmark in reading order
+//
+// @Produce json
+// @Success 200 {object} struct{Highlights []models.Highlight}
+// @Router /v1/api/bookmarks/{id}/highlights [get]
+func (a *Api) BookmarkHighlightsAPI(w http.ResponseWriter, r *http.Request) {
+	bookmark := a.getBookmark(w, r, userMustOwnBookmark)
+	if bookmark == nil {
+		return
+	}
+	a.writeBookmarkHighlights(w, r, bookmark)
+}
+
+// CreateBookmarkHighlightAPI highlights a passage of a bookmark
+//
+// @Accept json
+// @Produce json
+// @Param data body HighlightRequest true "Highlighted text and note"
+// @Success 200 {object} models.Highlight
+// @Failure 422 {object} ErrorResponse "The text is not in the bookmark content"
+// @Router /v1/api/bookmarks/{id}/highlights [post]
+func (a *Api) CreateBookmarkHighlightAPI(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	bookmark := a.getBookmark(w, r, userMustOwnBookmark)
+	if bookmark == nil {
+		return
+	}
+	var data HighlightRequest
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		logger.Errorw("[api] decoding request body", "error", err)
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_REQUEST",
+			Message: fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+	a.createHighlight(w, r, bookmark, data)
+}
+
+// RefreshAPI fetches the page of the bookmark again. The previous content is kept as a version,
+// and search and the AI data are updated against the new content.
+//
+// @Produce json
+// @Success 200 {object} struct{Bookmark BookmarkDetails; Changed bool; FetchedAt time.Time}
+// @Failure 429 {object} ErrorResponse "The content was refreshed moments ago"
+// @Failure 502 {object} ErrorResponse "The page could not be fetched"
+// @Failure 504 {object} ErrorResponse "The page took too long to respond"
+// @Router /v1/api/bookmarks/{id}/refresh [post]
+func (a *Api) RefreshAPI(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+	bookmark := a.getBookmark(w, r, userMustOwnBookmark)
+	if bookmark == nil {
+		return
+	}
+	refresh, err := a.BookmarkModel.RefreshContent(r.Context(), bookmark)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrRefreshTooSoon):
+			writeErrorResponse(w, http.StatusTooManyRequests, ErrorResponse{
+				Code:    "REFRESH_TOO_SOON",
+				Message: "The content was refreshed moments ago, try again later",
+			})
+		case errors.Is(err, errors.ErrPageInaccessible):
+			logger.Warnw("[api] refresh content: page inaccessible", "error", err, "bookmark_id", bookmark.Id)
+			status, resp := fetchErrorResponse(err)
+			resp.Message += ", the saved content is kept"
+			writeErrorResponse(w, status, resp)
+		default:
+			logger.Errorw("[api] refresh content", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Code:    "INTERNAL_ERROR",
+				Message: "api: Something went wrong",
+			})
+		}
+		return
+	}
+	logger.Infow("[api] content refreshed", "bookmark_id", bookmark.Id, "user_id", user.ID, "changed", refresh.Changed)
+
+	// The excerpt and metadata may have changed
+	bookmark, err = a.BookmarkModel.GetById(bookmark.Id)
+	if err != nil {
+		logger.Errorw("[api] get refreshed bookmark", "error", err, "bookmark_id", chi.URLParam(r, "id"))
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	details, err := a.bookmarkDetails(bookmark)
+	if err != nil {
+		logger.Errorw("[api] get bookmark details", "error", err, "bookmark_id", bookmark.Id)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	var data struct {
+		Bookmark  BookmarkDetails
+		Changed   bool // False when the page has not changed, no version is kept then
+		FetchedAt time.Time
+	}
+	data.Bookmark = details
+	data.Changed = refresh.Changed
+	data.FetchedAt = refresh.FetchedAt
+	err = writeResponse(w, &data)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+// RetryAIProcessingAPI runs the failed AI processing of the bookmark again, or processes it again
+// from its saved content when nothing failed
+//
+// @Produce json
+// @Success 200 {object} BookmarkDetails
+// @Failure 503 {object} ErrorResponse "AI processing is not available"
+// @Router /v1/api/bookmarks/{id}/ai/retry [post]
+func (a *Api) RetryAIProcessingAPI(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+	bookmark := a.getBookmark(w, r, userMustOwnBookmark)
+	if bookmark == nil {
+		return
+	}
+	if err := a.BookmarkModel.RetryAIProcessing(r.Context(), bookmark.Id); err != nil {
+		if errors.Is(err, errors.ErrAIUnavailable) {
+			writeErrorResponse(w, http.StatusServiceUnavailable, ErrorResponse{
+				Code:    "AI_UNAVAILABLE",
+				Message: "AI processing is not available",
+			})
+			return
+		}
+		logger.Errorw("[api] retry AI processing", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	logger.Infow("[api] AI processing retried", "bookmark_id", bookmark.Id, "user_id", user.ID)
+	details, err := a.bookmarkDetails(bookmark)
+	if err != nil {
+		logger.Errorw("[api] get bookmark details", "error", err, "bookmark_id", bookmark.Id)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	err = writeResponse(w, &details)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+// VersionsAPI lists the previous contents of the bookmark, the newest first
+//
+// @Produce json
+// @Success 200 {object} struct{Versions []models.ContentVersion}
+// @Router /v1/api/bookmarks/{id}/versions [get]
+func (a *Api) VersionsAPI(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	bookmark := a.getBookmark(w, r, userMustOwnBookmark)
+	if bookmark == nil {
+		return
+	}
+	versions, err := a.BookmarkModel.GetVersions(bookmark.Id)
+	if err != nil {
+		logger.Errorw("[api] get content versions", "error", err, "bookmark_id", bookmark.Id)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	var data struct {
+		Versions []models.ContentVersion
+	}
+	data.Versions = append(make([]models.ContentVersion, 0, len(versions)), versions...)
+	err = writeResponse(w, &data)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+// HighlightsAPI lists the highlights of the user. With the `url` parameter, it returns the
+// highlights of the bookmark saved for that page so the extension can restore them.
+//
+// @Produce json
+// @Param url query string false "Link of a saved bookmark"
+// @Param page query int false "Page number when listing all highlights"
+// @Success 200 {object} struct{Highlights []models.HighlightWithBookmark; MorePages bool}
+// @Router /v1/api/highlights [get]
+func (a *Api) HighlightsAPI(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+
+	if link := r.URL.Query().Get("url"); link != "" {
+		bookmark, err := a.BookmarkModel.GetByLink(user.ID, link)
+		if err != nil {
+			if errors.Is(err, errors.ErrNotFound) {
+				writeErrorResponse(w, http.StatusNotFound, ErrorResponse{
+					Code:    "NOT_FOUND",
+					Message: fmt.Sprintf("Bookmark not found: %s", link),
+				})
+				return
+			}
+			logger.Errorw("[api] get bookmark by link", "error", err, "link", link)
+			writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Code:    "INTERNAL_ERROR",
+				Message: "api: Something went wrong",
+			})
+			return
+		}
+		a.writeBookmarkHighlights(w, r, bookmark)
+		return
+	}
+
+	page := validations.GetPageOffset(r.FormValue("page"))
+	highlights, morePages, err := a.HighlightModel.GetByUserId(user.ID, page)
+	if err != nil {
+		logger.Errorw("[api] get highlights", "error", err, "user_id", user.ID)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	var data struct {
+		Highlights []models.HighlightWithBookmark
+		MorePages  bool
+	}
+	data.Highlights = append(make([]models.HighlightWithBookmark, 0, len(highlights)), highlights...)
+	data.MorePages = morePages
+	err = writeResponse(w, data)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+// CreateHighlightAPI highlights a passage of the saved bookmark with the given link.
+// It is used by the extension, which only knows the URL of the current page.
+//
+// @Accept json
+// @Produce json
+// @Param data body HighlightRequest true "Link of the bookmark, highlighted text and note"
+// @Success 200 {object} models.Highlight
+// @Failure 404 {object} ErrorResponse "The page is not saved"
+// @Failure 422 {object} ErrorResponse "The text is not in the bookmark content"
+// @Router /v1/api/highlights [post]
+func (a *Api) CreateHighlightAPI(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+	var data HighlightRequest
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		logger.Errorw("[api] decoding request body", "error", err)
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_REQUEST",
+			Message: fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+	if !validations.IsURLValid(data.Link) {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_URL",
+			Message: fmt.Sprintf("Invalid URL: %v", data.Link),
+		})
+		return
+	}
+	bookmark, err := a.BookmarkModel.GetByLink(user.ID, data.Link)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			writeErrorResponse(w, http.StatusNotFound, ErrorResponse{
+				Code:    "NOT_FOUND",
+				Message: fmt.Sprintf("Bookmark not found: %s", data.Link),
+			})
+			return
+		}
+		logger.Errorw("[api] get bookmark by link", "error", err, "link", data.Link)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	a.createHighlight(w, r, bookmark, data)
+}
+
+// UpdateHighlightAPI changes the note of a highlight
+//
+// @Accept json
+// @Produce json
+// @Param data body struct{Note string} true "New note"
+// @Success 200 {object} models.Highlight
+// @Router /v1/api/highlights/{highlightId} [put]
+func (a *Api) UpdateHighlightAPI(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+	highlight := a.getHighlight(w, r)
+	if highlight == nil {
+		return
+	}
+	var data struct {
+		Note string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		logger.Errorw("[api] decoding request body", "error", err)
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_REQUEST",
+			Message: fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+	err := a.HighlightModel.UpdateNote(highlight.Id, data.Note)
+	if err != nil {
+		if errors.Is(err, errors.ErrHighlightTooLong) {
+			writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+				Code:    "HIGHLIGHT_TOO_LONG",
+				Message: fmt.Sprintf("Notes can be at most %d characters", models.MaxNoteLength),
+			})
+			return
+		}
+		logger.Errorw("[api] failed to update highlight", "error", err, "highlight_id", highlight.Id, "user_id", user.ID)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "UPDATE_HIGHLIGHT",
+			Message: fmt.Sprintf("Failed to update highlight: %v", err),
+		})
+		return
+	}
+	updated, err := a.HighlightModel.Get(highlight.Id)
+	if err != nil {
+		logger.Errorw("[api] get updated highlight", "error", err, "highlight_id", highlight.Id)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	err = writeResponse(w, updated)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+// DeleteHighlightAPI deletes a highlight and its note
+//
+// @Produce json
+// @Success 200 {object} struct{Id int}
+// @Router /v1/api/highlights/{highlightId} [delete]
+func (a *Api) DeleteHighlightAPI(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+	highlight := a.getHighlight(w, r)
+	if highlight == nil {
+		return
+	}
+	err := a.HighlightModel.Delete(highlight.Id)
+	if err != nil {
+		logger.Errorw("[api] failed to delete highlight", "error", err, "highlight_id", highlight.Id, "user_id", user.ID)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "DELETE_HIGHLIGHT",
+			Message: fmt.Sprintf("Failed to delete highlight: %v", err),
+		})
+		return
+	}
+	logger.Infow("[api] deleted highlight", "highlight_id", highlight.Id, "user_id", user.ID)
+	var data struct {
+		Id int `json:"id"`
+	}
+	data.Id = highlight.Id
+	err = writeResponse(w, &data)
 	if err != nil {
 		logger.Errorw("write response", "error", err)
 	}
@@ -302,9 +1031,16 @@ func (a *Api) DeleteAPI(w http.ResponseWriter, r *http.Request) {
 
 // SearchAPI handles the search for bookmarks based on a query.
 // @Produce json
-// @Param query query string true "Search query"
+// @Param query query string true "Search query, with filters like site:example.com, tag:go, source:telegram, lang:en, before:2024-03, after:2024, is:unread, \"phrases\" and -excluded words"
+// @Param mode query string false "hybrid (default), keyword or semantic. Searches fall back to keyword without embeddings"
+// @Param cursor query string false "NextCursor of the previous page, empty for the first page"
+// @Param tag query string false "Only bookmarks with this tag"
+// @Param collection query string false "Only bookmarks in this collection"
+// @Param status query string false "Only bookmarks with this reading status (unread, reading, archived)"
+// @Param starred query bool false "Only starred bookmarks"
+// @Param broken query bool false "Only bookmarks whose link stopped working"
 // @Success 200 {object} bookmarkSearchResult `json:"bookmarks"`
-// @Failure 400 {object} ErrorResponse "Query is required"
+// @Failure 400 {object} ErrorResponse "Query is required, or the query, mode or cursor is invalid"
 // @Failure 500 {object} ErrorResponse "Something went wrong"
 // @Router /v1/api/bookmarks/search [get]
 func (a *Api) SearchAPI(w http.ResponseWriter, r *http.Request) {
@@ -317,7 +1053,39 @@ func (a *Api) SearchAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	results, err := a.BookmarkModel.Search(user, query)
+	filter, err := bookmarkFilterFromRequest(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_FILTER",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	mode, ok := types.ParseSearchMode(r.FormValue("mode"))
+	if !ok {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_MODE",
+			Message: "Mode should be hybrid, keyword or semantic",
+		})
+		return
+	}
+
+	page, err := a.BookmarkModel.Search(r.Context(), user, query, filter, mode, r.FormValue("cursor"))
+	if errors.Is(err, errors.ErrInvalidSearchQuery) {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_QUERY",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, errors.ErrInvalidSearchCursor) {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_CURSOR",
+			Message: "Cursor should be the NextCursor of a previous page",
+		})
+		return
+	}
 	if err != nil {
 		logger.Errorw("searching bookmarks", "error", err)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
@@ -325,9 +1093,15 @@ func (a *Api) SearchAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var data struct {
-		Bookmarks []types.BookmarkSearchResult
+		Bookmarks  []types.BookmarkSearchResult
+		Mode       types.SearchMode    // The mode used, keyword when the search fell back to it
+		NextCursor string              // Passed as cursor for the next page, empty on the last page
+		Facets     *types.SearchFacets // Only on the first page
 	}
-	for _, r := range results {
+	data.Mode = page.Mode
+	data.NextCursor = page.NextCursor
+	data.Facets = page.Facets
+	for _, r := range page.Results {
 		data.Bookmarks = append(data.Bookmarks, types.BookmarkSearchResult{
 			Id:        r.Id,
 			Title:     r.Title,
@@ -438,6 +1212,28 @@ func writeResponse(w http.ResponseWriter, data interface{}) error {
 	return nil
 }
 
+// fetchErrorResponse describes why the page of a link could not be fetched
+func fetchErrorResponse(err error) (int, ErrorResponse) {
+	var statusErr fetcher.StatusError
+	switch {
+	case errors.Is(err, errors.ErrBlockedAddress):
+		return http.StatusUnprocessableEntity, ErrorResponse{Code: "BLOCKED_ADDRESS", Message: "The link points to a private or local address"}
+	case errors.Is(err, errors.ErrTooManyRedirects):
+		return http.StatusUnprocessableEntity, ErrorResponse{Code: "TOO_MANY_REDIRECTS", Message: fmt.Sprintf("The link redirects more than %d times", fetcher.MaxRedirects)}
+	case errors.Is(err, errors.ErrPageTooLarge):
+		return http.StatusUnprocessableEntity, ErrorResponse{Code: "PAGE_TOO_LARGE", Message: "The page is larger than the size limit"}
+	case errors.Is(err, errors.ErrUnsupportedContent):
+		return http.StatusUnsupportedMediaType, ErrorResponse{Code: "UNSUPPORTED_CONTENT_TYPE", Message: "The link is not a web page or a PDF"}
+	case errors.Is(err, errors.ErrFetchTimeout):
+		return http.StatusGatewayTimeout, ErrorResponse{Code: "FETCH_TIMEOUT", Message: "The page took too long to respond"}
+	case errors.Is(err, errors.ErrInvalidUrl):
+		return http.StatusBadRequest, ErrorResponse{Code: "INVALID_URL", Message: "The link is not a valid web address"}
+	case errors.As(err, &statusErr):
+		return http.StatusBadGateway, ErrorResponse{Code: "PAGE_INACCESSIBLE", Message: fmt.Sprintf("The page answered with status %d", statusErr.StatusCode)}
+	}
+	return http.StatusBadGateway, ErrorResponse{Code: "PAGE_INACCESSIBLE", Message: "The page could not be fetched"}
+}
+
 func writeErrorResponse(w http.ResponseWriter, statusCode int, errResp ErrorResponse) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(statusCode)
@@ -449,11 +1245,176 @@ func writeErrorResponse(w http.ResponseWriter, statusCode int, errResp ErrorResp
 	return nil
 }
 
+func (a *Api) bookmarkDetails(b *models.Bookmark) (BookmarkDetails, error) {
+	tags, err := a.TagModel.GetForBookmark(b.Id)
+	if err != nil {
+		return BookmarkDetails{}, err
+	}
+	collections, err := a.CollectionModel.GetForBookmark(b.Id)
+	if err != nil {
+		return BookmarkDetails{}, err
+	}
+	note, err := a.BookmarkModel.GetNote(b.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		return BookmarkDetails{}, err
+	}
+	details := BookmarkDetails{
+		Bookmark:      mapModelToBookmark(b),
+		Tags:          append(make([]string, 0, len(tags)), tags...),
+		Collections:   append(make([]string, 0, len(collections)), collections...),
+		SuggestedTags: models.SuggestedTags(b.AITags, tags),
+		Note:          note,
+	}
+	check, err := a.LinkCheckModel.Get(b.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		return BookmarkDetails{}, err
+	}
+	if check != nil {
+		details.Broken = check.Broken
+		details.LinkCheck = &LinkCheck{
+			StatusCode:  check.StatusCode,
+			FinalUrl:    check.FinalUrl,
+			Redirects:   check.Redirects,
+			Error:       check.Error,
+			Broken:      check.Broken,
+			CheckedAt:   check.CheckedAt,
+			ArchiveLink: a.LinkCheckModel.ArchiveLink(b.Link),
+		}
+	}
+	if a.AIJobModel != nil {
+		jobs, err := a.AIJobModel.ForBookmark(context.Background(), b.Id)
+		if err != nil {
+			return BookmarkDetails{}, err
+		}
+		details.AIProcessing = aiProcessing(jobs)
+	}
+	return details, nil
+}
+
+func aiProcessing(jobs []models.AIJob) *AIProcessing {
+	if len(jobs) == 0 {
+		return nil
+	}
+	processing := &AIProcessing{Status: models.AIProcessingStatus(jobs)}
+	for _, job := range jobs {
+		j := AIJob{
+			Kind:        job.Kind,
+			Status:      job.Status,
+			Attempts:    job.Attempts,
+			MaxAttempts: job.MaxAttempts,
+			CompletedAt: job.CompletedAt,
+		}
+		if job.LastError != nil {
+			j.LastError = *job.LastError
+		}
+		if job.Status == models.AIJobStatusPending && job.Attempts > 0 {
+			j.NextAttemptAt = &job.RunAt
+		}
+		processing.Jobs = append(processing.Jobs, j)
+	}
+	return processing
+}
+
 func mapModelToBookmark(b *models.Bookmark) Bookmark {
 	return Bookmark{
-		Id:      b.Id,
-		Title:   b.Title,
-		Link:    b.Link,
-		Excerpt: b.Excerpt,
+		Id:            b.Id,
+		Title:         b.Title,
+		Link:          b.Link,
+		Excerpt:       b.Excerpt,
+		ReadingStatus: b.ReadingStatus,
+		Starred:       b.Starred,
+		Broken:        b.Broken,
+	}
+}
+
+func (a *Api) writeBookmarkHighlights(w http.ResponseWriter, r *http.Request, bookmark *models.Bookmark) {
+	logger := loggercontext.Logger(r.Context())
+	highlights, err := a.HighlightModel.GetForBookmark(bookmark.Id)
+	if err != nil {
+		logger.Errorw("[api] get bookmark highlights", "error", err, "bookmark_id", bookmark.Id)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return
+	}
+	var data struct {
+		Highlights []models.Highlight
+	}
+	data.Highlights = append(make([]models.Highlight, 0, len(highlights)), highlights...)
+	err = writeResponse(w, data)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+func (a *Api) createHighlight(w http.ResponseWriter, r *http.Request, bookmark *models.Bookmark, data HighlightRequest) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+	if strings.TrimSpace(data.Text) == "" {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Code:    "INVALID_REQUEST",
+			Message: "Text is required",
+		})
+		return
+	}
+	highlight, err := a.HighlightModel.Create(r.Context(), user.ID, bookmark.Id, data.Text, data.Note, data.Prefix, data.Suffix)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrQuoteNotFound), errors.Is(err, errors.ErrNotFound):
+			writeErrorResponse(w, http.StatusUnprocessableEntity, ErrorResponse{
+				Code:    "QUOTE_NOT_FOUND",
+				Message: "The highlighted text could not be found in the saved content of the bookmark",
+			})
+		case errors.Is(err, errors.ErrHighlightTooLong):
+			writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+				Code:    "HIGHLIGHT_TOO_LONG",
+				Message: fmt.Sprintf("Highlights can be at most %d characters and notes at most %d characters", models.MaxHighlightLength, models.MaxNoteLength),
+			})
+		default:
+			logger.Errorw("[api] failed to create highlight", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+				Code:    "CREATE_HIGHLIGHT",
+				Message: fmt.Sprintf("Failed to create highlight: %v", err),
+			})
+		}
+		return
+	}
+	logger.Infow("[api] created highlight", "highlight_id", highlight.Id, "bookmark_id", bookmark.Id, "user_id", user.ID)
+	err = writeResponse(w, highlight)
+	if err != nil {
+		logger.Errorw("write response", "error", err)
+	}
+}
+
+// getHighlight loads the highlight in the URL and makes sure it belongs to the user
+func (a *Api) getHighlight(w http.ResponseWriter, r *http.Request) *models.Highlight {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+	id := chi.URLParam(r, "highlightId")
+	highlightId, err := strconv.Atoi(id)
+	if err != nil {
+		writeErrorResponse(w, http.StatusNotFound, ErrorResponse{
+			Code:    "NOT_FOUND",
+			Message: fmt.Sprintf("Highlight not found: %s", id),
+		})
+		return nil
+	}
+	highlight, err := a.HighlightModel.Get(highlightId)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("[api] get highlight", "error", err, "highlight_id", highlightId)
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "api: Something went wrong",
+		})
+		return nil
+	}
+	if err != nil || highlight.UserId != user.ID {
+		writeErrorResponse(w, http.StatusNotFound, ErrorResponse{
+			Code:    "NOT_FOUND",
+			Message: fmt.Sprintf("Highlight not found: %s", id),
+		})
+		return nil
 	}
+	return highlight
 }
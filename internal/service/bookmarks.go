@@ -1,16 +1,24 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
 	"time"
 
 	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
 	"github.com/arashthr/pensive/internal/auth/context/usercontext"
+	"github.com/arashthr/pensive/internal/document"
 	"github.com/arashthr/pensive/internal/errors"
 	"github.com/arashthr/pensive/internal/logging"
 	"github.com/arashthr/pensive/internal/models"
+	"github.com/arashthr/pensive/internal/textdiff"
+	"github.com/arashthr/pensive/internal/transcript"
 	"github.com/arashthr/pensive/internal/types"
 	"github.com/arashthr/pensive/internal/validations"
 	"github.com/arashthr/pensive/web"
@@ -24,30 +32,42 @@ type Bookmarks struct {
 		Show                 web.Template
 		Markdown             web.Template
 		MarkdownNotAvailable web.Template
+		Versions             web.Template
 	}
-	BookmarkModel *models.BookmarkRepo
+	BookmarkModel   *models.BookmarkRepo
+	TagModel        *models.TagRepo
+	CollectionModel *models.CollectionRepo
+	HighlightModel  *models.HighlightRepo
+	SnapshotModel   *models.SnapshotRepo
+	FileModel       *models.FileRepo
+	LinkCheckModel  *models.LinkCheckRepo
+	AIJobModel      *models.AIJobRepo
 }
 
 func (b Bookmarks) New(w http.ResponseWriter, r *http.Request) {
 	var data struct {
-		Title string
-		Link  string
+		Title       string
+		Link        string
+		MaxFileSize string
 	}
 	data.Title = "New Bookmark"
 	data.Link = r.FormValue("link")
+	data.MaxFileSize = models.Snapshot{Size: document.MaxFileSize}.HumanSize()
 	b.Templates.New.Execute(w, r, data)
 }
 
 func (b Bookmarks) Create(w http.ResponseWriter, r *http.Request) {
 	var data struct {
-		Title  string
-		UserId types.UserId
-		Link   string
+		Title       string
+		UserId      types.UserId
+		Link        string
+		MaxFileSize string
 	}
 	ctx := r.Context()
 	logger := loggercontext.Logger(ctx)
 	user := usercontext.User(ctx)
 	data.Title = "New Bookmark"
+	data.MaxFileSize = models.Snapshot{Size: document.MaxFileSize}.HumanSize()
 	data.UserId = user.ID
 	data.Link = r.FormValue("link")
 	logger.Debugw("creating bookmark", "link", data.Link, "user_id", data.UserId)
@@ -67,6 +87,10 @@ func (b Bookmarks) Create(w http.ResponseWriter, r *http.Request) {
 		} else if errors.Is(err, errors.ErrDailyLimitExceeded) {
 			message = "Daily bookmark limit exceeded. Upgrade to premium for 100 bookmarks/day."
 			logger.Warnw("bookmark creation blocked: daily limit", "user_id", data.UserId)
+		} else if errors.Is(err, errors.ErrPageInaccessible) {
+			_, resp := fetchErrorResponse(err)
+			message = resp.Message
+			logger.Warnw("bookmark creation failed: page inaccessible", "error", err, "user_id", data.UserId)
 		} else {
 			message = err.Error()
 			logger.Errorw("bookmark creation failed", "error", err, "user_id", data.UserId)
@@ -85,13 +109,168 @@ func (b Bookmarks) Create(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, editPath, http.StatusFound)
 }
 
-func (b Bookmarks) Edit(w http.ResponseWriter, r *http.Request) {
+// Upload handles POST /bookmarks/upload and saves an uploaded document as a bookmark
+func (b Bookmarks) Upload(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		Title       string
+		Link        string
+		MaxFileSize string
+	}
+	ctx := r.Context()
+	logger := loggercontext.Logger(ctx)
+	user := usercontext.User(ctx)
+	data.Title = "New Bookmark"
+	data.MaxFileSize = models.Snapshot{Size: document.MaxFileSize}.HumanSize()
+
+	file, err := uploadedFile(w, r)
+	var bookmark *models.Bookmark
+	if err == nil {
+		bookmark, err = b.BookmarkModel.CreateFromFile(ctx, user, models.WebSource, file)
+	}
+	if err != nil {
+		var message string
+		switch {
+		case errors.Is(err, errors.ErrUnverifiedUserLimitExceeded):
+			message = "Unverified account limit reached (10 bookmarks). Please verify your email to unlock unlimited bookmarks."
+		case errors.Is(err, errors.ErrDailyLimitExceeded):
+			message = "Daily bookmark limit exceeded. Upgrade to premium for 100 bookmarks/day."
+		case errors.Is(err, errors.ErrUnsupportedFile):
+			message = "Only PDF, Markdown, HTML, EPUB and Word (DOCX) files can be uploaded."
+		case errors.Is(err, errors.ErrFileTooLarge):
+			message = fmt.Sprintf("The file is too large, the limit is %s.", data.MaxFileSize)
+		case errors.Is(err, errors.ErrNoText):
+			message = "No text was found in the file. Scanned documents are not supported yet."
+		default:
+			message = "The file could not be read."
+		}
+		logger.Warnw("bookmark upload failed", "error", err, "user_id", user.ID)
+		b.Templates.New.Execute(w, r, data, web.NavbarMessage{
+			Message: message,
+			IsError: true,
+		})
+		return
+	}
+
+	logger.Infow("bookmark uploaded", "bookmark_id", bookmark.Id, "filename", file.Name, "user_id", user.ID)
+	http.Redirect(w, r, fmt.Sprintf("/bookmarks/%s", bookmark.Id), http.StatusFound)
+}
+
+// uploadedFile reads the document in the "file" field of a multipart form. Other requests send
+// the file as the body, named by the filename query parameter or the Content-Disposition header.
+func uploadedFile(w http.ResponseWriter, r *http.Request) (document.File, error) {
+	// Leaves room for the other fields of the form
+	r.Body = http.MaxBytesReader(w, r.Body, document.MaxFileSize+1<<20)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+		return uploadedBody(r)
+	}
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return document.File{}, errors.ErrFileTooLarge
+		}
+		return document.File{}, fmt.Errorf("parse multipart form: %w", err)
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return document.File{}, fmt.Errorf("get form file: %w", err)
+	}
+	defer file.Close()
+	if header.Size > document.MaxFileSize {
+		return document.File{}, errors.ErrFileTooLarge
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return document.File{}, fmt.Errorf("read uploaded file: %w", err)
+	}
+	return document.File{
+		Name:        header.Filename,
+		ContentType: header.Header.Get("Content-Type"),
+		Data:        data,
+	}, nil
+}
+
+// uploadedBody reads the document sent as the body of the request
+func uploadedBody(r *http.Request) (document.File, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return document.File{}, errors.ErrFileTooLarge
+		}
+		return document.File{}, fmt.Errorf("read request body: %w", err)
+	}
+	if len(data) > document.MaxFileSize {
+		return document.File{}, errors.ErrFileTooLarge
+	}
+	if len(data) == 0 {
+		return document.File{}, fmt.Errorf("empty request body")
+	}
+	name := r.URL.Query().Get("filename")
+	if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); name == "" && err == nil {
+		name = params["filename"]
+	}
+	if name != "" {
+		// Only the name is kept of a path
+		name = path.Base(name)
+	}
+	return document.File{
+		Name:        name,
+		ContentType: r.Header.Get("Content-Type"),
+		Data:        data,
+	}, nil
+}
+
+// DownloadFile handles GET /bookmarks/{id}/file and sends the original document of the bookmark
+func (b Bookmarks) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	logger := loggercontext.Logger(r.Context())
 	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
 	if err != nil {
 		return
 	}
+	file, err := b.FileModel.Get(bookmark.Id)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		logger.Errorw("get bookmark file", "error", err, "bookmark_id", bookmark.Id)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	content, err := b.FileModel.Open(r.Context(), file)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			logger.Warnw("bookmark file is missing", "bookmark_id", bookmark.Id, "key", file.StorageKey)
+			http.NotFound(w, r)
+			return
+		}
+		logger.Errorw("open bookmark file", "error", err, "bookmark_id", bookmark.Id)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	// Documents may carry scripts, they are downloaded rather than shown from our origin
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Security-Policy", snapshotCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.FormatInt(file.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	if _, err := io.Copy(w, content); err != nil {
+		logger.Warnw("copy file to response", "error", err, "bookmark_id", bookmark.Id)
+	}
+}
+
+func (b Bookmarks) Edit(w http.ResponseWriter, r *http.Request) {
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+	b.renderEdit(w, r, bookmark)
+}
 
+func (b Bookmarks) renderEdit(w http.ResponseWriter, r *http.Request, bookmark *models.Bookmark, navMsgs ...web.NavbarMessage) {
+	logger := loggercontext.Logger(r.Context())
 	user := usercontext.User(r.Context())
 
 	var data struct {
@@ -107,6 +286,32 @@ func (b Bookmarks) Edit(w http.ResponseWriter, r *http.Request) {
 		AIExcerpt string
 		AITags    string
 		IsPremium bool
+		// Reading state
+		ReadingStatus types.ReadingStatus
+		Starred       bool
+		// User-defined organization
+		Tags           []string
+		SuggestedTags  []string
+		Collections    []string
+		AllCollections []models.Collection
+		Highlights     []models.Highlight
+		Note           string
+		Snapshot       *models.Snapshot
+		// Documents like PDFs keep their original file, uploaded ones have no page
+		File     *models.File
+		Uploaded bool
+		// Dead-link checker
+		LinkCheck   *models.LinkCheck
+		ArchiveLink string
+		// Content refreshes
+		ContentFetchedAt time.Time
+		Versions         int
+		// Another bookmark with nearly the same content
+		Duplicate *models.DuplicateMatch
+		// AI processing of the summary, tags, markdown and embedding
+		AIAvailable bool
+		AIStatus    models.AIJobStatus
+		AIJobs      []models.AIJob
 	}
 	host := validations.ExtractHostname(bookmark.Link)
 	logger.Infow("editing bookmark", "url", host, "user_id", user.ID)
@@ -121,6 +326,8 @@ func (b Bookmarks) Edit(w http.ResponseWriter, r *http.Request) {
 	data.CreatedAt = bookmark.CreatedAt
 	data.Thumbnail = bookmark.ImageUrl
 	data.IsPremium = user.IsSubscriptionPremium()
+	data.ReadingStatus = bookmark.ReadingStatus
+	data.Starred = bookmark.Starred
 
 	logger.Infow("Subscription status", "status", user.SubscriptionStatus, "is_premium", data.IsPremium, "user_id", user.ID)
 
@@ -135,7 +342,67 @@ func (b Bookmarks) Edit(w http.ResponseWriter, r *http.Request) {
 		data.AITags = *bookmark.AITags
 	}
 
-	b.Templates.Edit.Execute(w, r, data)
+	tags, err := b.TagModel.GetForBookmark(bookmark.Id)
+	if err != nil {
+		logger.Errorw("get bookmark tags", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.Tags = tags
+	data.SuggestedTags = models.SuggestedTags(bookmark.AITags, tags)
+
+	collections, err := b.CollectionModel.GetForBookmark(bookmark.Id)
+	if err != nil {
+		logger.Errorw("get bookmark collections", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.Collections = collections
+	data.AllCollections, err = b.CollectionModel.GetByUserId(user.ID)
+	if err != nil {
+		logger.Errorw("get user collections", "error", err, "user_id", user.ID)
+	}
+	data.Highlights, err = b.HighlightModel.GetForBookmark(bookmark.Id)
+	if err != nil {
+		logger.Errorw("get bookmark highlights", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.Note, err = b.BookmarkModel.GetNote(bookmark.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("get bookmark note", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.Snapshot, err = b.SnapshotModel.Get(bookmark.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("get bookmark snapshot", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.File, err = b.FileModel.Get(bookmark.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("get bookmark file", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.Uploaded = document.IsLink(bookmark.Link)
+	data.LinkCheck, err = b.LinkCheckModel.Get(bookmark.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("get bookmark link check", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.ArchiveLink = b.LinkCheckModel.ArchiveLink(bookmark.Link)
+	data.ContentFetchedAt, err = b.BookmarkModel.ContentFetchedAt(bookmark.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("get content fetch time", "error", err, "bookmark_id", bookmark.Id)
+	}
+	versions, err := b.BookmarkModel.GetVersions(bookmark.Id)
+	if err != nil {
+		logger.Errorw("get content versions", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.Versions = len(versions)
+	data.Duplicate, err = b.BookmarkModel.NearDuplicate(r.Context(), user.ID, bookmark.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("get near duplicate", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.AIAvailable = b.BookmarkModel.AIAvailable()
+	if data.AIAvailable {
+		data.AIJobs, err = b.AIJobModel.ForBookmark(r.Context(), bookmark.Id)
+		if err != nil {
+			logger.Errorw("get ai jobs", "error", err, "bookmark_id", bookmark.Id)
+		}
+		data.AIStatus = models.AIProcessingStatus(data.AIJobs)
+	}
+
+	b.Templates.Edit.Execute(w, r, data, navMsgs...)
 }
 
 func (b Bookmarks) Update(w http.ResponseWriter, r *http.Request) {
@@ -155,25 +422,283 @@ func (b Bookmarks) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	logger.Infow("bookmark updated", "bookmark_id", bookmark.Id, "user_id", user.ID)
-	data := struct {
-		Link      string
-		Title     string
-		Id        types.BookmarkId
-		Excerpt   string
-		CreatedAt time.Time
-	}{
-		Link:      bookmark.Link,
-		Title:     bookmark.Title,
-		Id:        bookmark.Id,
-		Excerpt:   bookmark.Excerpt,
-		CreatedAt: bookmark.CreatedAt,
-	}
-	b.Templates.Edit.Execute(w, r, data, web.NavbarMessage{
+	b.renderEdit(w, r, bookmark, web.NavbarMessage{
 		Message: "Bookmark updated",
 		IsError: false,
 	})
 }
 
+// UpdateState handles POST /bookmarks/{id}/state to change the reading status or star the bookmark
+func (b Bookmarks) UpdateState(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	if status := r.FormValue("status"); status != "" {
+		readingStatus, ok := types.ParseReadingStatus(status)
+		if !ok {
+			http.Error(w, "Invalid reading status", http.StatusBadRequest)
+			return
+		}
+		if err := b.BookmarkModel.SetReadingStatus(bookmark.Id, readingStatus); err != nil {
+			logger.Errorw("set reading status failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		logger.Infow("reading status updated", "bookmark_id", bookmark.Id, "user_id", user.ID, "status", readingStatus)
+	}
+	if starred := r.FormValue("starred"); starred != "" {
+		if err := b.BookmarkModel.SetStarred(bookmark.Id, starred == "true"); err != nil {
+			logger.Errorw("set starred failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		logger.Infow("starred updated", "bookmark_id", bookmark.Id, "user_id", user.ID, "starred", starred)
+	}
+	http.Redirect(w, r, fmt.Sprintf("/bookmarks/%s", bookmark.Id), http.StatusFound)
+}
+
+// UpdateNote handles POST /bookmarks/{id}/note to replace the personal note on the bookmark
+func (b Bookmarks) UpdateNote(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	err = b.BookmarkModel.SetNote(r.Context(), bookmark.Id, r.FormValue("note"))
+	if err != nil {
+		if errors.Is(err, errors.ErrNoteTooLong) {
+			b.renderEdit(w, r, bookmark, web.NavbarMessage{
+				Message: fmt.Sprintf("Notes can be at most %d characters", models.MaxNoteLength),
+				IsError: true,
+			})
+			return
+		}
+		logger.Errorw("update note failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("note updated", "bookmark_id", bookmark.Id, "user_id", user.ID)
+	http.Redirect(w, r, fmt.Sprintf("/bookmarks/%s", bookmark.Id), http.StatusFound)
+}
+
+// AddTags handles POST /bookmarks/{id}/tags with comma separated tags
+func (b Bookmarks) AddTags(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	tags := models.SplitLabels(r.FormValue("tags"))
+	err = b.TagModel.AddToBookmark(r.Context(), user.ID, bookmark.Id, tags...)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidLabel) {
+			b.renderEdit(w, r, bookmark, web.NavbarMessage{
+				Message: fmt.Sprintf("Tags must be at most %d characters and cannot contain '|'", models.MaxLabelLength),
+				IsError: true,
+			})
+			return
+		}
+		logger.Errorw("add tags failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("tags added", "bookmark_id", bookmark.Id, "user_id", user.ID, "tags", tags)
+	http.Redirect(w, r, fmt.Sprintf("/bookmarks/%s", bookmark.Id), http.StatusFound)
+}
+
+// RemoveTag handles POST /bookmarks/{id}/tags/delete
+func (b Bookmarks) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	tag := r.FormValue("tag")
+	err = b.TagModel.RemoveFromBookmark(r.Context(), user.ID, bookmark.Id, tag)
+	if err != nil {
+		logger.Errorw("remove tag failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("tag removed", "bookmark_id", bookmark.Id, "user_id", user.ID, "tag", tag)
+	http.Redirect(w, r, fmt.Sprintf("/bookmarks/%s", bookmark.Id), http.StatusFound)
+}
+
+// AddToCollection handles POST /bookmarks/{id}/collections
+func (b Bookmarks) AddToCollection(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	collection := r.FormValue("collection")
+	err = b.CollectionModel.AddToBookmark(r.Context(), user.ID, bookmark.Id, collection)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidLabel) {
+			b.renderEdit(w, r, bookmark, web.NavbarMessage{
+				Message: fmt.Sprintf("Collection names must be at most %d characters and cannot contain ',' or '|'", models.MaxLabelLength),
+				IsError: true,
+			})
+			return
+		}
+		logger.Errorw("add to collection failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("added to collection", "bookmark_id", bookmark.Id, "user_id", user.ID, "collection", collection)
+	http.Redirect(w, r, fmt.Sprintf("/bookmarks/%s", bookmark.Id), http.StatusFound)
+}
+
+// RemoveFromCollection handles POST /bookmarks/{id}/collections/delete
+func (b Bookmarks) RemoveFromCollection(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	collection := r.FormValue("collection")
+	err = b.CollectionModel.RemoveFromBookmark(r.Context(), user.ID, bookmark.Id, collection)
+	if err != nil {
+		logger.Errorw("remove from collection failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("removed from collection", "bookmark_id", bookmark.Id, "user_id", user.ID, "collection", collection)
+	http.Redirect(w, r, fmt.Sprintf("/bookmarks/%s", bookmark.Id), http.StatusFound)
+}
+
+// AddHighlight handles POST /bookmarks/{id}/highlights with the selected text and an optional note
+func (b Bookmarks) AddHighlight(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	highlight, err := b.HighlightModel.Create(r.Context(), user.ID, bookmark.Id,
+		r.FormValue("text"), r.FormValue("note"), r.FormValue("prefix"), r.FormValue("suffix"))
+	if err != nil {
+		var message string
+		switch {
+		case errors.Is(err, errors.ErrQuoteNotFound), errors.Is(err, errors.ErrNotFound):
+			message = "The selected text could not be found in the saved content of this bookmark"
+		case errors.Is(err, errors.ErrHighlightTooLong):
+			message = fmt.Sprintf("Highlights can be at most %d characters and notes at most %d characters", models.MaxHighlightLength, models.MaxNoteLength)
+		default:
+			logger.Errorw("add highlight failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return
+		}
+		b.renderEdit(w, r, bookmark, web.NavbarMessage{
+			Message: message,
+			IsError: true,
+		})
+		return
+	}
+	logger.Infow("highlight added", "bookmark_id", bookmark.Id, "user_id", user.ID, "highlight_id", highlight.Id)
+	http.Redirect(w, r, highlightRedirectPath(r, bookmark.Id), http.StatusFound)
+}
+
+// UpdateHighlight handles POST /bookmarks/{id}/highlights/{highlightId} to change the note of a highlight
+func (b Bookmarks) UpdateHighlight(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, highlight, err := b.getHighlight(w, r)
+	if err != nil {
+		return
+	}
+
+	err = b.HighlightModel.UpdateNote(highlight.Id, r.FormValue("note"))
+	if err != nil {
+		if errors.Is(err, errors.ErrHighlightTooLong) {
+			b.renderEdit(w, r, bookmark, web.NavbarMessage{
+				Message: fmt.Sprintf("Notes can be at most %d characters", models.MaxNoteLength),
+				IsError: true,
+			})
+			return
+		}
+		logger.Errorw("update highlight failed", "error", err, "highlight_id", highlight.Id, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("highlight updated", "bookmark_id", bookmark.Id, "user_id", user.ID, "highlight_id", highlight.Id)
+	http.Redirect(w, r, highlightRedirectPath(r, bookmark.Id), http.StatusFound)
+}
+
+// DeleteHighlight handles POST /bookmarks/{id}/highlights/{highlightId}/delete
+func (b Bookmarks) DeleteHighlight(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, highlight, err := b.getHighlight(w, r)
+	if err != nil {
+		return
+	}
+
+	err = b.HighlightModel.Delete(highlight.Id)
+	if err != nil {
+		logger.Errorw("delete highlight failed", "error", err, "highlight_id", highlight.Id, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("highlight deleted", "bookmark_id", bookmark.Id, "user_id", user.ID, "highlight_id", highlight.Id)
+	http.Redirect(w, r, highlightRedirectPath(r, bookmark.Id), http.StatusFound)
+}
+
+// getHighlight loads the bookmark and the highlight in the URL and checks that both belong to the user
+func (b Bookmarks) getHighlight(w http.ResponseWriter, r *http.Request) (*models.Bookmark, *models.Highlight, error) {
+	logger := loggercontext.Logger(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	highlightId, err := strconv.Atoi(chi.URLParam(r, "highlightId"))
+	if err != nil {
+		http.Error(w, "Highlight not found", http.StatusNotFound)
+		return nil, nil, err
+	}
+	highlight, err := b.HighlightModel.Get(highlightId)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			http.Error(w, "Highlight not found", http.StatusNotFound)
+			return nil, nil, err
+		}
+		logger.Errorw("get highlight", "error", err, "highlight_id", highlightId)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return nil, nil, err
+	}
+	if highlight.BookmarkId != bookmark.Id {
+		http.Error(w, "Highlight not found", http.StatusNotFound)
+		return nil, nil, fmt.Errorf("highlight %d does not belong to bookmark %s", highlight.Id, bookmark.Id)
+	}
+	return bookmark, highlight, nil
+}
+
+// highlightRedirectPath sends the user back to the page the highlight was changed from
+func highlightRedirectPath(r *http.Request, id types.BookmarkId) string {
+	switch r.FormValue("from") {
+	case "markdown":
+		return fmt.Sprintf("/bookmarks/%s/markdown", id)
+	case "highlights":
+		return "/highlights"
+	}
+	return fmt.Sprintf("/bookmarks/%s", id)
+}
+
 func (b Bookmarks) Delete(w http.ResponseWriter, r *http.Request) {
 	logger := loggercontext.Logger(r.Context())
 	user := usercontext.User(r.Context())
@@ -193,6 +718,249 @@ func (b Bookmarks) Delete(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/home", http.StatusFound)
 }
 
+// snapshotCSP keeps the snapshot from running scripts or loading anything from the network.
+// Snapshots are served from our own origin, so they must not be able to act on behalf of the user.
+const snapshotCSP = "sandbox; default-src 'none'; img-src data:; style-src 'unsafe-inline' data:; font-src data:; media-src data:"
+
+// ViewSnapshot handles GET /bookmarks/{id}/snapshot and serves the offline copy of the page.
+// WARC snapshots are downloaded instead, they need a replay tool to be viewed.
+func (b Bookmarks) ViewSnapshot(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+	snap, err := b.SnapshotModel.Get(bookmark.Id)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		logger.Errorw("get bookmark snapshot", "error", err, "bookmark_id", bookmark.Id)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	file, err := b.SnapshotModel.Open(r.Context(), snap)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			logger.Warnw("snapshot file is missing", "bookmark_id", bookmark.Id, "key", snap.StorageKey)
+			http.NotFound(w, r)
+			return
+		}
+		logger.Errorw("open bookmark snapshot", "error", err, "bookmark_id", bookmark.Id)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", snap.ContentType)
+	w.Header().Set("Content-Security-Policy", snapshotCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.FormatInt(snap.Size, 10))
+	disposition := "inline"
+	if snap.Format != types.SnapshotFormatHTML || r.FormValue("download") == "true" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`%s; filename="%s"`, disposition, snap.Filename()))
+	if _, err := io.Copy(w, file); err != nil {
+		logger.Warnw("copy snapshot to response", "error", err, "bookmark_id", bookmark.Id)
+	}
+}
+
+// CaptureSnapshot handles POST /bookmarks/{id}/snapshot and takes a new snapshot of the page
+// in the background, for bookmarks without one or to refresh an old one
+func (b Bookmarks) CaptureSnapshot(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	// Asking for a snapshot overrides turning them off for new bookmarks
+	format, err := b.SnapshotModel.PreferredFormat(r.Context(), user.ID)
+	if err != nil {
+		logger.Errorw("get snapshot format", "error", err, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if format == types.SnapshotFormatNone {
+		format = types.SnapshotFormatHTML
+	}
+
+	// The request context is canceled once the response is sent
+	ctx := loggercontext.WithLogger(context.Background(), logger)
+	go func() {
+		if err := b.SnapshotModel.Capture(ctx, format, user.ID, bookmark.Id, bookmark.Link, ""); err != nil {
+			logger.Warnw("capture snapshot failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+		}
+	}()
+	logger.Infow("snapshot requested", "bookmark_id", bookmark.Id, "user_id", user.ID)
+	b.renderEdit(w, r, bookmark, web.NavbarMessage{
+		Message: "Capturing the page, the offline copy will be ready in a minute",
+		IsError: false,
+	})
+}
+
+// RefreshContent handles POST /bookmarks/{id}/refresh and fetches the page again. The previous
+// content is kept as a version that can be compared with the new one.
+func (b Bookmarks) RefreshContent(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	refresh, err := b.BookmarkModel.RefreshContent(r.Context(), bookmark)
+	if err != nil {
+		var message string
+		switch {
+		case errors.Is(err, errors.ErrRefreshTooSoon):
+			message = "The content was refreshed a few minutes ago, try again later"
+		case errors.Is(err, errors.ErrPageInaccessible):
+			logger.Warnw("refresh content: page inaccessible", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			_, resp := fetchErrorResponse(err)
+			message = resp.Message + ", the saved content is kept"
+		default:
+			logger.Errorw("refresh content failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+			message = "Something went wrong while refreshing the content"
+		}
+		b.renderEdit(w, r, bookmark, web.NavbarMessage{Message: message, IsError: true})
+		return
+	}
+	logger.Infow("content refreshed", "bookmark_id", bookmark.Id, "user_id", user.ID, "changed", refresh.Changed)
+	if !refresh.Changed {
+		b.renderEdit(w, r, bookmark, web.NavbarMessage{
+			Message: "The page has not changed since it was saved",
+			IsError: false,
+		})
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/bookmarks/%s/versions", bookmark.Id), http.StatusFound)
+}
+
+// RetryAIProcessing handles POST /bookmarks/{id}/ai/retry and runs the failed AI processing of
+// the bookmark again, or processes it again when nothing failed
+func (b Bookmarks) RetryAIProcessing(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	if err := b.BookmarkModel.RetryAIProcessing(r.Context(), bookmark.Id); err != nil {
+		message := "Something went wrong while retrying the AI processing"
+		if errors.Is(err, errors.ErrAIUnavailable) {
+			message = "AI processing is not available"
+		} else {
+			logger.Errorw("retry AI processing failed", "error", err, "bookmark_id", bookmark.Id, "user_id", user.ID)
+		}
+		b.renderEdit(w, r, bookmark, web.NavbarMessage{Message: message, IsError: true})
+		return
+	}
+	logger.Infow("AI processing retried", "bookmark_id", bookmark.Id, "user_id", user.ID)
+	b.renderEdit(w, r, bookmark, web.NavbarMessage{
+		Message: "The AI processing will run again shortly",
+		IsError: false,
+	})
+}
+
+// versionDiffContext is the number of unchanged paragraphs shown around the changes
+const versionDiffContext = 2
+
+// Versions handles GET /bookmarks/{id}/versions and shows the changes between a previous content
+// of the bookmark and the one that replaced it. Without ?version the latest change is shown.
+func (b Bookmarks) Versions(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
+	if err != nil {
+		return
+	}
+
+	var data struct {
+		Id               types.BookmarkId
+		Title            string
+		Link             string
+		CurrentFetchedAt time.Time
+		Versions         []models.ContentVersion
+		// The compared contents: a version and the one that replaced it
+		Selected    int
+		FromFetched time.Time
+		ToFetched   time.Time
+		ToCurrent   bool
+		Diff        []textdiff.Line
+		Stats       textdiff.Stats
+	}
+	data.Id = bookmark.Id
+	data.Title = bookmark.Title
+	data.Link = bookmark.Link
+	data.CurrentFetchedAt, err = b.BookmarkModel.ContentFetchedAt(bookmark.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("get content fetch time", "error", err, "bookmark_id", bookmark.Id)
+	}
+	data.Versions, err = b.BookmarkModel.GetVersions(bookmark.Id)
+	if err != nil {
+		logger.Errorw("get content versions", "error", err, "bookmark_id", bookmark.Id)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if len(data.Versions) == 0 {
+		b.Templates.Versions.Execute(w, r, data)
+		return
+	}
+
+	// Versions are newest first, so the one that replaced version i is version i-1
+	selected := 0
+	if v := r.FormValue("version"); v != "" {
+		versionId, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+		selected = -1
+		for i, version := range data.Versions {
+			if version.Id == versionId {
+				selected = i
+			}
+		}
+		if selected < 0 {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	from := data.Versions[selected]
+	fromContent, err := b.BookmarkModel.GetVersionContent(bookmark.Id, from.Id)
+	if err != nil {
+		logger.Errorw("get content version", "error", err, "bookmark_id", bookmark.Id, "version_id", from.Id)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	var toContent string
+	if selected == 0 {
+		data.ToCurrent = true
+		data.ToFetched = data.CurrentFetchedAt
+		toContent, err = b.BookmarkModel.GetBookmarkContent(bookmark.Id)
+	} else {
+		to := data.Versions[selected-1]
+		data.ToFetched = to.FetchedAt
+		toContent, err = b.BookmarkModel.GetVersionContent(bookmark.Id, to.Id)
+	}
+	if err != nil {
+		logger.Errorw("get content to compare", "error", err, "bookmark_id", bookmark.Id)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	diff := textdiff.Diff(fromContent, toContent)
+	data.Selected = from.Id
+	data.FromFetched = from.FetchedAt
+	data.Stats = textdiff.Count(diff)
+	data.Diff = textdiff.Compact(diff, versionDiffContext)
+	b.Templates.Versions.Execute(w, r, data)
+}
+
 // GetFullBookmark handles GET /v1/bookmarks/{id}/full and returns the full content of a bookmark.
 func (b Bookmarks) GetFullBookmark(w http.ResponseWriter, r *http.Request) {
 	logger := loggercontext.Logger(r.Context())
@@ -247,7 +1015,8 @@ func (b Bookmarks) GetFullBookmark(w http.ResponseWriter, r *http.Request) {
 	logger.Debugw("full bookmark served", "bookmark_id", bookmark.Id)
 }
 
-// GetBookmarkMarkdown handles GET /bookmarks/{id}/markdown and returns the AI-generated markdown content
+// GetBookmarkMarkdown handles GET /bookmarks/{id}/markdown and returns the AI-generated markdown content.
+// Videos show their transcript, with timestamps that play the video from there.
 func (b Bookmarks) GetBookmarkMarkdown(w http.ResponseWriter, r *http.Request) {
 	logger := loggercontext.Logger(r.Context())
 	bookmark, err := b.getBookmark(w, r, userMustOwnBookmark)
@@ -256,8 +1025,13 @@ func (b Bookmarks) GetBookmarkMarkdown(w http.ResponseWriter, r *http.Request) {
 	}
 	logger.Debugw("get bookmark markdown", "bookmark_id", bookmark.Id)
 
+	videoTranscript, err := b.BookmarkModel.GetTranscript(bookmark.Id)
+	if err != nil && !errors.Is(err, errors.ErrNotFound) {
+		logger.Errorw("get bookmark transcript", "error", err, "bookmark_id", bookmark.Id)
+	}
+	// A video without markdown still has its transcript to show
 	markdownContent, err := b.BookmarkModel.GetBookmarkMarkdown(bookmark.Id)
-	if err != nil {
+	if err != nil && !(errors.Is(err, errors.ErrNotFound) && videoTranscript != nil) {
 		if errors.Is(err, errors.ErrNotFound) {
 			logger.Debugw("bookmark markdown not available", "bookmark_id", bookmark.Id)
 			// If no markdown content is found, show a user-friendly message
@@ -281,11 +1055,22 @@ func (b Bookmarks) GetBookmarkMarkdown(w http.ResponseWriter, r *http.Request) {
 		Title           string
 		Link            string
 		MarkdownContent string
+		Transcript      *transcript.Transcript
+		Sections        []transcript.Section
+		Highlights      []models.Highlight
 	}
 	data.Id = bookmark.Id
 	data.Title = bookmark.Title
 	data.Link = bookmark.Link
 	data.MarkdownContent = markdownContent
+	if videoTranscript != nil {
+		data.Transcript = videoTranscript
+		data.Sections = videoTranscript.Sections()
+	}
+	data.Highlights, err = b.HighlightModel.GetForBookmark(bookmark.Id)
+	if err != nil {
+		logger.Errorw("get bookmark highlights", "error", err, "bookmark_id", bookmark.Id)
+	}
 
 	b.Templates.Markdown.Execute(w, r, data)
 }
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/auth/context/usercontext"
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/models"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/arashthr/pensive/web"
+)
+
+type Duplicates struct {
+	Templates struct {
+		Index web.Template
+	}
+	BookmarkModel *models.BookmarkRepo
+}
+
+// Index handles GET /duplicates and lists the bookmarks that are likely the same article
+// saved from different links
+func (d Duplicates) Index(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	d.render(w, r, user)
+}
+
+func (d Duplicates) render(w http.ResponseWriter, r *http.Request, user *models.User, navMsgs ...web.NavbarMessage) {
+	logger := loggercontext.Logger(r.Context())
+
+	// Bookmarks saved before fingerprints existed are fingerprinted on the first visit
+	if err := d.BookmarkModel.FingerprintMissing(r.Context(), user.ID); err != nil {
+		logger.Errorw("fingerprint bookmarks", "error", err, "user_id", user.ID)
+	}
+	pairs, err := d.BookmarkModel.GetDuplicates(user.ID)
+	if err != nil {
+		logger.Errorw("get duplicates", "error", err, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	// Each side of a pair can be kept, with the other one merged into it
+	type side struct {
+		Id        types.BookmarkId
+		Title     string
+		Link      string
+		SiteName  string
+		CreatedAt time.Time
+		OtherId   types.BookmarkId
+	}
+	type pairItem struct {
+		models.DuplicatePair
+		Sides [2]side
+	}
+	data := struct {
+		Title string
+		Pairs []pairItem
+	}{
+		Title: "Possible duplicates",
+	}
+	for _, p := range pairs {
+		data.Pairs = append(data.Pairs, pairItem{
+			DuplicatePair: p,
+			Sides: [2]side{
+				{Id: p.Id, Title: p.Title, Link: p.Link, SiteName: p.SiteName, CreatedAt: p.CreatedAt, OtherId: p.OtherId},
+				{Id: p.OtherId, Title: p.OtherTitle, Link: p.OtherLink, SiteName: p.OtherSiteName, CreatedAt: p.OtherCreatedAt, OtherId: p.Id},
+			},
+		})
+	}
+	d.Templates.Index.Execute(w, r, data, navMsgs...)
+}
+
+// Merge handles POST /duplicates/merge. The notes, highlights, tags and collections of the
+// removed bookmark are moved to the kept one.
+func (d Duplicates) Merge(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	keep, remove, ok := d.getPair(w, r, "keep", "remove")
+	if !ok {
+		return
+	}
+	if err := d.BookmarkModel.Merge(r.Context(), keep.Id, remove.Id); err != nil {
+		logger.Errorw("merge bookmarks", "error", err, "keep_id", keep.Id, "remove_id", remove.Id, "user_id", user.ID)
+		d.render(w, r, user, web.NavbarMessage{Message: "Something went wrong while merging", IsError: true})
+		return
+	}
+	logger.Infow("bookmarks merged", "keep_id", keep.Id, "remove_id", remove.Id, "user_id", user.ID)
+	if r.FormValue("from") == "bookmark" {
+		http.Redirect(w, r, "/bookmarks/"+string(keep.Id), http.StatusFound)
+		return
+	}
+	d.render(w, r, user, web.NavbarMessage{Message: "Bookmarks merged", IsError: false})
+}
+
+// Dismiss handles POST /duplicates/dismiss, for bookmarks that are not the same article
+func (d Duplicates) Dismiss(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	user := usercontext.User(r.Context())
+	bookmark, other, ok := d.getPair(w, r, "id", "other")
+	if !ok {
+		return
+	}
+	if err := d.BookmarkModel.DismissDuplicate(r.Context(), bookmark.Id, other.Id); err != nil {
+		logger.Errorw("dismiss duplicate", "error", err, "bookmark_id", bookmark.Id, "other_id", other.Id, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if r.FormValue("from") == "bookmark" {
+		http.Redirect(w, r, "/bookmarks/"+string(bookmark.Id), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/duplicates", http.StatusFound)
+}
+
+// getPair loads the two bookmarks named by the form fields and checks the user owns both
+func (d Duplicates) getPair(w http.ResponseWriter, r *http.Request, field, otherField string) (*models.Bookmark, *models.Bookmark, bool) {
+	logger := loggercontext.Logger(r.Context())
+	var pair [2]*models.Bookmark
+	for i, name := range []string{field, otherField} {
+		bookmark, err := d.BookmarkModel.GetById(types.BookmarkId(r.FormValue(name)))
+		if err != nil {
+			if errors.Is(err, errors.ErrNotFound) {
+				http.Error(w, "Bookmark not found", http.StatusNotFound)
+				return nil, nil, false
+			}
+			logger.Errorw("get bookmark", "error", err, "id", r.FormValue(name))
+			http.Error(w, "Something went wrong", http.StatusInternalServerError)
+			return nil, nil, false
+		}
+		if err := userMustOwnBookmark(w, r, bookmark); err != nil {
+			return nil, nil, false
+		}
+		pair[i] = bookmark
+	}
+	if pair[0].Id == pair[1].Id {
+		http.Error(w, "Pick two different bookmarks", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	return pair[0], pair[1], true
+}
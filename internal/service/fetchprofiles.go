@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// FetchProfiles manages the fetch profiles set by the admins, which apply to every user
+type FetchProfiles struct {
+	FetchProfileModel *models.FetchProfileRepo
+}
+
+// FetchProfileRequest is the fetch profile of a domain. Saving a domain again replaces its profile.
+type FetchProfileRequest struct {
+	Domain           string
+	UserAgent        string
+	Headers          map[string]string
+	Cookies          string // Like the Cookie header, stored encrypted
+	IncludeSelectors string
+	ExcludeSelectors string
+}
+
+// FetchProfileResponse is a fetch profile, without its cookies
+type FetchProfileResponse struct {
+	Id               int
+	Domain           string
+	UserAgent        string
+	Headers          map[string]string
+	HasCookies       bool
+	IncludeSelectors string
+	ExcludeSelectors string
+	UpdatedAt        time.Time
+}
+
+// List handles GET /admin/fetch-profiles
+func (f FetchProfiles) List(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	profiles, err := f.FetchProfileModel.List(r.Context(), nil)
+	if err != nil {
+		logger.Errorw("list admin fetch profiles", "error", err)
+		http.Error(w, "Failed to list fetch profiles", http.StatusInternalServerError)
+		return
+	}
+	response := make([]FetchProfileResponse, 0, len(profiles))
+	for _, p := range profiles {
+		response = append(response, fetchProfileResponse(p))
+	}
+	writeResponse(w, response)
+}
+
+// Save handles POST /admin/fetch-profiles
+func (f FetchProfiles) Save(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	var req FetchProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	profile := models.FetchProfile{
+		Domain:           req.Domain,
+		UserAgent:        req.UserAgent,
+		Headers:          req.Headers,
+		Cookies:          req.Cookies,
+		IncludeSelectors: req.IncludeSelectors,
+		ExcludeSelectors: req.ExcludeSelectors,
+	}
+	if err := f.FetchProfileModel.Save(r.Context(), &profile); err != nil {
+		if errors.Is(err, errors.ErrInvalidFetchProfile) || errors.Is(err, errors.ErrCookiesDisabled) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Errorw("save admin fetch profile", "error", err, "domain", req.Domain)
+		http.Error(w, "Failed to save fetch profile", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("saved admin fetch profile", "domain", profile.Domain)
+	writeResponse(w, fetchProfileResponse(profile))
+}
+
+// Delete handles DELETE /admin/fetch-profiles/{id}
+func (f FetchProfiles) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := loggercontext.Logger(r.Context())
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid fetch profile id", http.StatusBadRequest)
+		return
+	}
+	if err := f.FetchProfileModel.Delete(r.Context(), nil, id); err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			http.Error(w, fmt.Sprintf("fetch profile %d not found", id), http.StatusNotFound)
+			return
+		}
+		logger.Errorw("delete admin fetch profile", "error", err, "id", id)
+		http.Error(w, "Failed to delete fetch profile", http.StatusInternalServerError)
+		return
+	}
+	logger.Infow("deleted admin fetch profile", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func fetchProfileResponse(p models.FetchProfile) FetchProfileResponse {
+	return FetchProfileResponse{
+		Id:               p.Id,
+		Domain:           p.Domain,
+		UserAgent:        p.UserAgent,
+		Headers:          p.Headers,
+		HasCookies:       p.HasCookies,
+		IncludeSelectors: p.IncludeSelectors,
+		ExcludeSelectors: p.ExcludeSelectors,
+		UpdatedAt:        p.UpdatedAt,
+	}
+}
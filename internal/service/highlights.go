@@ -0,0 +1,63 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/auth/context/usercontext"
+	"github.com/arashthr/pensive/internal/models"
+	"github.com/arashthr/pensive/internal/types"
+	"github.com/arashthr/pensive/internal/validations"
+	"github.com/arashthr/pensive/web"
+)
+
+type Highlights struct {
+	Templates struct {
+		Index web.Template
+	}
+	HighlightModel *models.HighlightRepo
+}
+
+// Index handles GET /highlights and lists the highlights of the user across the library
+func (h Highlights) Index(w http.ResponseWriter, r *http.Request) {
+	user := usercontext.User(r.Context())
+	logger := loggercontext.Logger(r.Context())
+
+	page := validations.GetPageOffset(r.FormValue("page"))
+	highlights, morePages, err := h.HighlightModel.GetByUserId(user.ID, page)
+	if err != nil {
+		logger.Errorw("get highlights", "error", err, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	type highlightItem struct {
+		models.HighlightWithBookmark
+		Hostname  string
+		CreatedAt string
+	}
+	data := struct {
+		Title      string
+		Highlights []highlightItem
+		Pages      types.PagesData
+		MorePages  bool
+		Filter     types.BookmarkFilter // Always empty, the pagination links expect it
+	}{
+		Title: "Highlights",
+		Pages: types.PagesData{
+			Previous: page - 1,
+			Current:  page,
+			Next:     page + 1,
+		},
+		MorePages: morePages,
+	}
+	for _, highlight := range highlights {
+		data.Highlights = append(data.Highlights, highlightItem{
+			HighlightWithBookmark: highlight,
+			Hostname:              validations.ExtractHostname(highlight.Link),
+			CreatedAt:             highlight.Highlight.CreatedAt.Format("Jan 02, 2006"),
+		})
+	}
+
+	h.Templates.Index.Execute(w, r, data)
+}
@@ -1,11 +1,13 @@
 package service
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
 	"github.com/arashthr/pensive/internal/auth/context/usercontext"
+	"github.com/arashthr/pensive/internal/errors"
 	"github.com/arashthr/pensive/internal/models"
 	"github.com/arashthr/pensive/internal/types"
 	"github.com/arashthr/pensive/internal/validations"
@@ -19,7 +21,9 @@ type Home struct {
 		SearchResults web.Template
 		ChatAnswer    web.Template
 	}
-	BookmarkModel *models.BookmarkRepo
+	BookmarkModel   *models.BookmarkRepo
+	TagModel        *models.TagRepo
+	CollectionModel *models.CollectionRepo
 }
 
 func (h Home) Index(w http.ResponseWriter, r *http.Request) {
@@ -28,7 +32,12 @@ func (h Home) Index(w http.ResponseWriter, r *http.Request) {
 	logger.Debugw("home index", "user_id", user.ID)
 
 	page := validations.GetPageOffset(r.FormValue("page"))
-	paginatedData, err := h.getPaginatedBookmarksData(user, page)
+	filter, err := bookmarkFilterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	paginatedData, err := h.getPaginatedBookmarksData(user, page, filter)
 	if err != nil {
 		logger.Errorw("failed to get paginated bookmarks", "error", err, "user_id", user.ID)
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
@@ -46,6 +55,10 @@ func (h Home) Index(w http.ResponseWriter, r *http.Request) {
 		HasBookmarksAtAll    bool
 		RemainingBookmarks   int
 		RemainingAIQuestions int
+		Filter               types.BookmarkFilter
+		Tags                 []models.Tag
+		Collections          []models.Collection
+		SemanticSearch       bool
 	}{
 		Title:             "Home",
 		IsUserPremium:     user.IsSubscriptionPremium(),
@@ -55,6 +68,17 @@ func (h Home) Index(w http.ResponseWriter, r *http.Request) {
 		Bookmarks:         paginatedData.Bookmarks,
 		Count:             paginatedData.Count,
 		HasBookmarksAtAll: paginatedData.HasBookmarksAtAll,
+		Filter:            filter,
+		SemanticSearch:    h.BookmarkModel.SemanticSearchAvailable(),
+	}
+
+	data.Tags, err = h.TagModel.GetByUserId(user.ID)
+	if err != nil {
+		logger.Warnw("failed to get tags", "error", err, "user_id", user.ID)
+	}
+	data.Collections, err = h.CollectionModel.GetByUserId(user.ID)
+	if err != nil {
+		logger.Warnw("failed to get collections", "error", err, "user_id", user.ID)
 	}
 
 	// Get remaining bookmarks for unverified users
@@ -86,10 +110,20 @@ func (h Home) Search(w http.ResponseWriter, r *http.Request) {
 	query := r.FormValue("query")
 	logger.Debugw("search", "user_id", user.ID, "query", query)
 	page := validations.GetPageOffset(r.FormValue("page"))
+	filter, err := bookmarkFilterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mode, ok := types.ParseSearchMode(r.FormValue("mode"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid search mode: %q", r.FormValue("mode")), http.StatusBadRequest)
+		return
+	}
 
 	if query == "" {
 		// Return paginated bookmarks when no query
-		data, err := h.getPaginatedBookmarksData(user, page)
+		data, err := h.getPaginatedBookmarksData(user, page, filter)
 		if err != nil {
 			logger.Errorw("failed to get paginated bookmarks", "error", err, "user_id", user.ID)
 			http.Error(w, "Something went wrong", http.StatusInternalServerError)
@@ -100,21 +134,42 @@ func (h Home) Search(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	results, err := h.BookmarkModel.Search(user, query)
-	if err != nil {
-		logger.Errorw("failed to search bookmarks", "error", err, "user_id", user.ID)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
-		return
-	}
-
 	var data struct {
 		Bookmarks  []types.BookmarkSearchResult
 		Query      string
 		HasResults bool
+		// Set when the search fell back to keywords
+		KeywordFallback bool
+		// What is wrong with an invalid query
+		Error string
+		// Set for the next pages, which are added to the results already shown
+		Cursor     string
+		NextCursor string
+		Facets     *types.SearchFacets
 	}
-
 	data.Query = query
-	for _, r := range results {
+	data.Cursor = r.FormValue("cursor")
+
+	results, err := h.BookmarkModel.Search(r.Context(), user, query, filter, mode, data.Cursor)
+	if errors.Is(err, errors.ErrInvalidSearchCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, errors.ErrInvalidSearchQuery) {
+		data.Error = err.Error()
+		h.Templates.SearchResults.Execute(w, r, data)
+		return
+	}
+	if err != nil {
+		logger.Errorw("failed to search bookmarks", "error", err, "user_id", user.ID)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	data.KeywordFallback = results.Mode != mode
+	data.NextCursor = results.NextCursor
+	data.Facets = results.Facets
+	for _, r := range results.Results {
 		data.Bookmarks = append(data.Bookmarks, types.BookmarkSearchResult{
 			Id:        r.Id,
 			Title:     r.Title,
@@ -231,8 +286,8 @@ func (h Home) AskQuestion(w http.ResponseWriter, r *http.Request) {
 }
 
 // getPaginatedBookmarksData fetches paginated bookmarks and returns the data structure
-func (h Home) getPaginatedBookmarksData(user *models.User, page int) (types.PaginatedBookmarksType, error) {
-	bookmarks, count, morePages, err := h.BookmarkModel.GetByUserId(user.ID, page)
+func (h Home) getPaginatedBookmarksData(user *models.User, page int, filter types.BookmarkFilter) (types.PaginatedBookmarksType, error) {
+	bookmarks, count, morePages, err := h.BookmarkModel.GetByUserId(user.ID, page, filter)
 	if err != nil {
 		return types.PaginatedBookmarksType{}, err
 	}
@@ -245,6 +300,7 @@ func (h Home) getPaginatedBookmarksData(user *models.User, page int) (types.Pagi
 		Next:     page + 1,
 	}
 	data.MorePages = morePages
+	data.Filter = filter
 
 	for _, b := range bookmarks {
 		excerpt := b.Excerpt
@@ -253,14 +309,35 @@ func (h Home) getPaginatedBookmarksData(user *models.User, page int) (types.Pagi
 		}
 		cleanedExcerpt := validations.CleanUpText(excerpt)
 		data.Bookmarks = append(data.Bookmarks, types.BookmarkListItem{
-			Id:        b.Id,
-			Title:     b.Title,
-			Link:      b.Link,
-			CreatedAt: b.CreatedAt.Format("Jan 02"),
-			Excerpt:   cleanedExcerpt,
+			Id:            b.Id,
+			Title:         b.Title,
+			Link:          b.Link,
+			CreatedAt:     b.CreatedAt.Format("Jan 02"),
+			Excerpt:       cleanedExcerpt,
+			ReadingStatus: b.ReadingStatus,
+			Starred:       b.Starred,
+			Broken:        b.Broken,
 		})
 	}
 
 	data.HasBookmarksAtAll = len(data.Bookmarks) > 0
 	return data, nil
 }
+
+// bookmarkFilterFromRequest reads the listing filters from the query parameters
+func bookmarkFilterFromRequest(r *http.Request) (types.BookmarkFilter, error) {
+	filter := types.BookmarkFilter{
+		Tag:        strings.TrimSpace(r.FormValue("tag")),
+		Collection: strings.TrimSpace(r.FormValue("collection")),
+		Starred:    r.FormValue("starred") == "true",
+		Broken:     r.FormValue("broken") == "true",
+	}
+	if status := r.FormValue("status"); status != "" {
+		readingStatus, ok := types.ParseReadingStatus(status)
+		if !ok {
+			return types.BookmarkFilter{}, fmt.Errorf("invalid reading status: %q", status)
+		}
+		filter.ReadingStatus = readingStatus
+	}
+	return filter, nil
+}
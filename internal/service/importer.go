@@ -2,6 +2,7 @@ package service
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
 	"github.com/arashthr/pensive/internal/auth/context/usercontext"
+	"github.com/arashthr/pensive/internal/errors"
 	"github.com/arashthr/pensive/internal/logging"
 	"github.com/arashthr/pensive/internal/models"
 	"github.com/arashthr/pensive/internal/service/importer"
@@ -29,6 +31,8 @@ type Importer struct {
 	}
 	ImportJobModel *models.ImportJobRepo
 	BookmarkModel  *models.BookmarkRepo
+	HighlightModel *models.HighlightRepo
+	SnapshotModel  *models.SnapshotRepo
 }
 
 // PocketImport displays the import/export page
@@ -189,9 +193,36 @@ func (p Importer) ProcessExport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Highlights go in a separate file so the bookmarks CSV stays importable
+	highlights, err := p.HighlightModel.GetAllByUserId(user.ID)
+	if err != nil {
+		logger.Errorw("get highlights for export", "error", err)
+		http.Error(w, "Failed to retrieve highlights", http.StatusInternalServerError)
+		return
+	}
+	files := map[string]string{"part_000000.csv": csvPath} // Exact name expected by importer
+	if len(highlights) > 0 {
+		highlightsPath := filepath.Join(tempDir, "highlights.csv")
+		err = p.createHighlightsCSV(highlightsPath, highlights)
+		if err != nil {
+			logger.Errorw("create highlights CSV file", "error", err)
+			http.Error(w, "Failed to create export file", http.StatusInternalServerError)
+			return
+		}
+		files["highlights.csv"] = highlightsPath
+	}
+
+	// Offline copies of the pages go in their own folder, named by bookmark id
+	snapshots, err := p.SnapshotModel.GetAllByUserId(user.ID)
+	if err != nil {
+		logger.Errorw("get snapshots for export", "error", err)
+		http.Error(w, "Failed to retrieve offline copies", http.StatusInternalServerError)
+		return
+	}
+
 	// Create ZIP file
 	zipPath := filepath.Join(tempDir, "bookmarks_export.zip")
-	err = p.createZip(zipPath, csvPath)
+	err = p.createZip(r.Context(), zipPath, files, snapshots)
 	if err != nil {
 		logger.Errorw("create ZIP file", "error", err)
 		http.Error(w, "Failed to create export archive", http.StatusInternalServerError)
@@ -231,7 +262,7 @@ func (p Importer) ProcessExport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logger.Infow("export completed successfully", "user_id", user.ID, "bookmark_count", len(bookmarks), "file_size", fileInfo.Size())
+	logger.Infow("export completed successfully", "user_id", user.ID, "bookmark_count", len(bookmarks), "highlight_count", len(highlights), "snapshot_count", len(snapshots), "file_size", fileInfo.Size())
 }
 
 // getAllBookmarksForUser retrieves all bookmarks for a user by paginating through all pages
@@ -241,7 +272,7 @@ func (p Importer) getAllBookmarksForUser(userID types.UserId) ([]models.Bookmark
 	page := 1
 
 	for {
-		bookmarks, _, morePages, err := p.BookmarkModel.GetByUserId(userID, page)
+		bookmarks, _, morePages, err := p.BookmarkModel.GetByUserId(userID, page, types.BookmarkFilter{})
 		if err != nil {
 			return nil, fmt.Errorf("get bookmarks page %d: %w", page, err)
 		}
@@ -281,7 +312,7 @@ func (p Importer) createCSV(csvPath string, bookmarks []models.Bookmark) error {
 			bookmark.Link,
 			bookmark.Title,
 			strconv.FormatInt(bookmark.CreatedAt.Unix(), 10), // Unix timestamp
-			"archive", // Status - using "archive" so it gets imported
+			pocketStatus(bookmark.ReadingStatus),
 		}
 
 		if err := writer.Write(record); err != nil {
@@ -292,8 +323,49 @@ func (p Importer) createCSV(csvPath string, bookmarks []models.Bookmark) error {
 	return nil
 }
 
-// createZip creates a ZIP file containing the CSV
-func (p Importer) createZip(zipPath, csvPath string) error {
+// pocketStatus maps the reading status to the Pocket `status` column. Pocket has no "reading" state.
+func pocketStatus(status types.ReadingStatus) string {
+	if status == types.ReadingStatusArchived {
+		return "archive"
+	}
+	return "unread"
+}
+
+// createHighlightsCSV creates a CSV file with the highlights and notes of the user
+func (p Importer) createHighlightsCSV(csvPath string, highlights []models.HighlightWithBookmark) error {
+	file, err := os.Create(csvPath)
+	if err != nil {
+		return fmt.Errorf("create highlights CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"url", "title", "highlight", "note", "time_added"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("write highlights CSV header: %w", err)
+	}
+
+	for _, highlight := range highlights {
+		record := []string{
+			highlight.Link,
+			highlight.Title,
+			highlight.Text,
+			highlight.Note,
+			strconv.FormatInt(highlight.CreatedAt.Unix(), 10),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("write highlights CSV record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// createZip creates a ZIP file containing the given files, keyed by their name in the archive,
+// and the snapshot files under snapshots/
+func (p Importer) createZip(ctx context.Context, zipPath string, files map[string]string, snapshots []models.Snapshot) error {
 	zipFile, err := os.Create(zipPath)
 	if err != nil {
 		return fmt.Errorf("create ZIP file: %w", err)
@@ -303,8 +375,49 @@ func (p Importer) createZip(zipPath, csvPath string) error {
 	zipWriter := zip.NewWriter(zipFile)
 	defer zipWriter.Close()
 
-	// Add CSV file to ZIP
-	csvFile, err := os.Open(csvPath)
+	for name, path := range files {
+		if err := addFileToZip(zipWriter, name, path); err != nil {
+			return err
+		}
+	}
+
+	for _, snap := range snapshots {
+		if err := p.addSnapshotToZip(ctx, zipWriter, snap); err != nil {
+			// A missing file should not fail the whole export
+			if errors.Is(err, errors.ErrNotFound) {
+				loggercontext.Logger(ctx).Warnw("snapshot file is missing", "bookmark_id", snap.BookmarkId, "key", snap.StorageKey)
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p Importer) addSnapshotToZip(ctx context.Context, zipWriter *zip.Writer, snap models.Snapshot) error {
+	file, err := p.SnapshotModel.Open(ctx, &snap)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer, err := zipWriter.CreateHeader(&zip.FileHeader{
+		Name:     "snapshots/" + snap.Filename(),
+		Method:   zip.Deflate,
+		Modified: snap.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("create ZIP entry: %w", err)
+	}
+	if _, err := io.Copy(writer, file); err != nil {
+		return fmt.Errorf("copy snapshot to ZIP: %w", err)
+	}
+	return nil
+}
+
+func addFileToZip(zipWriter *zip.Writer, name, path string) error {
+	csvFile, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("open CSV file: %w", err)
 	}
@@ -320,7 +433,7 @@ func (p Importer) createZip(zipPath, csvPath string) error {
 	if err != nil {
 		return fmt.Errorf("create file header: %w", err)
 	}
-	header.Name = "part_000000.csv" // Exact name expected by importer
+	header.Name = name
 	header.Method = zip.Deflate
 
 	// Add file to ZIP
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/errors"
 	"github.com/arashthr/pensive/internal/logging"
 	"github.com/arashthr/pensive/internal/models"
 	"github.com/arashthr/pensive/internal/types"
@@ -167,11 +168,21 @@ func (p *ImportProcessor) processPocketImport(ctx context.Context, tx pgx.Tx, jo
 		// Validate URL
 		if !validations.IsURLValid(item.URL) {
 			logger.Debugw("skipping invalid URL", "url", item.URL)
-		} else if item.Status == "archive" {
+		} else if existing, err := p.BookmarkModel.GetByLink(user.ID, item.URL); err == nil {
+			// The reading status of the saved bookmarks may have changed since the export
+			logger.Debugw("skipping saved bookmark", "url", item.URL, "bookmark_id", existing.Id)
+		} else {
+			if !errors.Is(err, errors.ErrNotFound) {
+				logger.Warnw("get bookmark by link", "error", err, "url", item.URL)
+			}
 			// Do not apply the premium status to the import
-			_, err := p.BookmarkModel.Create(ctx, item.URL, user, models.Pocket)
+			bookmark, err := p.BookmarkModel.Create(ctx, item.URL, user, models.Pocket)
 			if err != nil {
 				logger.Errorw("create bookmark failed", "error", err, "url", item.URL)
+			} else if status := pocketReadingStatus(item.Status); status != bookmark.ReadingStatus {
+				if err := p.BookmarkModel.SetReadingStatus(bookmark.Id, status); err != nil {
+					logger.Errorw("set reading status failed", "error", err, "url", item.URL)
+				}
 			}
 		}
 		importedCount++
@@ -184,6 +195,14 @@ func (p *ImportProcessor) processPocketImport(ctx context.Context, tx pgx.Tx, jo
 	return nil
 }
 
+// pocketReadingStatus maps the Pocket `status` column (unread or archive) to a reading status
+func pocketReadingStatus(status string) types.ReadingStatus {
+	if strings.EqualFold(status, "archive") {
+		return types.ReadingStatusArchived
+	}
+	return types.ReadingStatusUnread
+}
+
 // parsePocketCSV parses the Pocket CSV file and returns items
 func (p *ImportProcessor) parsePocketCSV(ctx context.Context, csvFile *zip.File) ([]PocketItem, error) {
 	logger := loggercontext.Logger(ctx)
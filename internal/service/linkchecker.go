@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/arashthr/pensive/internal/auth/context/loggercontext"
+	"github.com/arashthr/pensive/internal/logging"
+	"github.com/arashthr/pensive/internal/models"
+	"github.com/arashthr/pensive/internal/validations"
+)
+
+const (
+	linkCheckerInterval    = 15 * time.Minute
+	linkCheckerBatchSize   = 200
+	linkCheckerHosts       = 4                // Hosts checked at the same time
+	linkCheckerHostDelay   = 10 * time.Second // Pause between two requests to the same host
+	linkCheckerLinksByHost = 20               // Links of one host in a batch, the rest wait for the next tick
+)
+
+// LinkChecker periodically probes saved links to find the ones that stopped working
+type LinkChecker struct {
+	LinkCheckModel *models.LinkCheckRepo
+}
+
+// Start runs the checker until ctx is cancelled – call it in a goroutine.
+func (c *LinkChecker) Start(ctx context.Context) {
+	logger := logging.Logger.With("flow", "link-checker")
+	ctx = loggercontext.WithLogger(ctx, logger)
+	logger.Infow("Starting")
+
+	ticker := time.NewTicker(linkCheckerInterval)
+	defer ticker.Stop()
+
+	for {
+		c.runTick(ctx)
+		select {
+		case <-ctx.Done():
+			logger.Infow("Stopping")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runTick checks a batch of due links. Links are grouped by host: hosts are checked in parallel,
+// but the links of a host one after the other with a pause in between.
+func (c *LinkChecker) runTick(ctx context.Context) {
+	logger := loggercontext.Logger(ctx)
+
+	due, err := c.LinkCheckModel.Due(linkCheckerBatchSize)
+	if err != nil {
+		logger.Errorw("get due links", "error", err)
+		return
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	byHost := map[string][]models.DueLink{}
+	for _, link := range due {
+		host := validations.ExtractHostname(link.Link)
+		if len(byHost[host]) < linkCheckerLinksByHost {
+			byHost[host] = append(byHost[host], link)
+		}
+	}
+	logger.Infow("Checking links", "count", len(due), "hosts", len(byHost))
+
+	var wg sync.WaitGroup
+	hosts := make(chan struct{}, linkCheckerHosts)
+	for host, links := range byHost {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case hosts <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-hosts }()
+			c.checkHost(ctx, host, links)
+		}()
+	}
+	wg.Wait()
+}
+
+func (c *LinkChecker) checkHost(ctx context.Context, host string, links []models.DueLink) {
+	logger := loggercontext.Logger(ctx)
+	for i, link := range links {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(linkCheckerHostDelay):
+			}
+		}
+		check, err := c.LinkCheckModel.Check(ctx, link.Id, link.Link)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Errorw("check link", "error", err, "bookmark_id", link.Id, "host", host)
+			continue
+		}
+		if check.Broken {
+			logger.Infow("Link is broken", "bookmark_id", link.Id, "host", host, "status", check.StatusCode, "error", check.Error)
+		}
+	}
+}
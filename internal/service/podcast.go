@@ -19,13 +19,13 @@ import (
 	"github.com/arashthr/pensive/internal/auth/context/usercontext"
 	"github.com/arashthr/pensive/internal/config"
 	"github.com/arashthr/pensive/internal/errors"
+	"github.com/arashthr/pensive/internal/llm"
 	"github.com/arashthr/pensive/internal/logging"
 	"github.com/arashthr/pensive/internal/models"
 	"github.com/arashthr/pensive/internal/types"
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
-	"google.golang.org/genai"
 )
 
 const (
@@ -63,7 +63,7 @@ type Podcast struct {
 	PodcastScheduleRepo *models.PodcastScheduleRepo
 	UserRepo            *models.UserRepo
 	EmailService        *EmailService
-	GenAIClient         *genai.Client
+	LLM                 llm.LLM
 	GCPProjectID        string
 	ServiceAccountPath  string // path to service-account.json; used in prod
 	TelegramToken       string
@@ -394,7 +394,7 @@ func (p *Podcast) processDailySchedule(ctx context.Context, s models.PodcastSche
 // The API hard-limits at 4000; we use 3500 for a comfortable margin.
 const ttsChunkMaxBytes = 3500
 
-// articleBreakMarker is the token Gemini places between article sections in the script.
+// articleBreakMarker is the token the language model places between article sections in the script.
 // It is replaced with a paragraph break before TTS so the voice pauses naturally.
 const articleBreakMarker = "[ARTICLE_BREAK]"
 
@@ -834,10 +834,10 @@ func (p *Podcast) triggerAndDeliver(userID types.UserId, userEmail, channel stri
 	logger.Infow("Manual trigger complete", "channel", channel)
 }
 
-// maxMarkdownCharsPerArticle caps the content per article sent to Gemini for script generation.
+// maxMarkdownCharsPerArticle caps the content per article sent to the language model for script generation.
 const maxMarkdownCharsPerArticle = 6000
 
-// generatePodcastScript calls Gemini to write a ready-to-read podcast script (~10 min / ~1400 words).
+// generatePodcastScript calls the language model to write a ready-to-read podcast script (~10 min / ~1400 words).
 // It also fetches all titles from the period to build the opening date + period overview.
 func (p *Podcast) generatePodcastScript(ctx context.Context, userID types.UserId, articles []models.PodcastArticle, days int) (string, error) {
 	podcastLogger := logging.Logger.With("flow", "podcast", "user_id", userID)
@@ -845,8 +845,8 @@ func (p *Podcast) generatePodcastScript(ctx context.Context, userID types.UserId
 		"article_count", len(articles),
 		"days", days,
 		)
-	if p.GenAIClient == nil {
-		return "", fmt.Errorf("GenAI client not initialised")
+	if p.LLM == nil {
+		return "", fmt.Errorf("language model not initialised")
 	}
 
 	// Fetch every title from the period for the opening overview (non-fatal if it fails).
@@ -954,19 +954,15 @@ This is an audio processing marker — it will never be spoken. Place it:
 	prompt.WriteString(`Output ONLY the finished spoken script with [ARTICLE_BREAK] markers between sections — no stage directions, markdown headers, or meta-commentary.
 `)
 
-	podcastLogger.Infow("sending prompt to Gemini", "prompt_size", prompt.Len())
+	podcastLogger.Infow("sending prompt to the language model", "prompt_size", prompt.Len(),
+		"provider", p.LLM.Name(), "model", p.LLM.Model(llm.TaskPodcast))
 	start := time.Now()
-	result, err := p.GenAIClient.Models.GenerateContent(
-		ctx,
-		"gemini-3-flash-preview",
-		genai.Text(prompt.String()),
-		nil,
-	)
+	result, err := p.LLM.Generate(ctx, llm.TaskPodcast, prompt.String())
 	if err != nil {
-		return "", fmt.Errorf("gemini script generation: %w", err)
+		return "", fmt.Errorf("script generation: %w", err)
 	}
-	script := strings.TrimSpace(result.Text())
-	podcastLogger.Infow("Gemini script generation complete",
+	script := strings.TrimSpace(result)
+	podcastLogger.Infow("script generation complete",
 		"elapsed", time.Since(start).Round(time.Millisecond).String(),
 		"script_length", len(script),
 		"approx_words", len(strings.Fields(script)),
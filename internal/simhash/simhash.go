@@ -0,0 +1,48 @@
+// Package simhash fingerprints the text of a page so copies of the same article can be found
+// even when they differ a little, like a syndicated copy with another footer
+package simhash
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const (
+	shingleSize = 3  // Words hashed together, so the order of the words counts
+	minWords    = 50 // Shorter texts have too little content to tell pages apart
+)
+
+// Fingerprint returns the 64-bit simhash of the text. Similar texts have fingerprints that
+// differ in few bits. It returns 0 when the text is too short to be fingerprinted.
+func Fingerprint(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < minWords {
+		return 0
+	}
+
+	var weights [64]int
+	h := fnv.New64a()
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h.Reset()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
@@ -0,0 +1,327 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const maxImportDepth = 3 // How deep stylesheet @imports are followed
+
+var (
+	cssURLRe    = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+	cssImportRe = regexp.MustCompile(`@import\s+(?:url\(\s*['"]?([^'")\s]+)['"]?\s*\)|['"]([^'"]+)['"])[^;]*;`)
+)
+
+// singleFile turns the page into one HTML file: assets are inlined as data URIs, scripts and
+// event handlers are removed and the remaining links point to the original site
+func (c *capture) singleFile(ctx context.Context, page []byte, pageURL *url.URL) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("parse page: %w", err)
+	}
+
+	base := pageURL
+	if href := findBaseHref(doc); href != "" {
+		if resolved, err := pageURL.Parse(href); err == nil {
+			base = resolved
+		}
+	}
+
+	c.rewrite(ctx, doc, base)
+	markSnapshot(doc, pageURL)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, fmt.Errorf("render snapshot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func findBaseHref(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Base {
+		return attr(n, "href")
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if href := findBaseHref(child); href != "" {
+			return href
+		}
+	}
+	return ""
+}
+
+// rewrite walks the document and inlines or removes everything that would load from the network
+func (c *capture) rewrite(ctx context.Context, n *html.Node, base *url.URL) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode && removedElement(child) {
+			n.RemoveChild(child)
+			child = next
+			continue
+		}
+		if child.Type == html.ElementNode {
+			c.rewriteElement(ctx, child, base)
+		}
+		c.rewrite(ctx, child, base)
+		child = next
+	}
+}
+
+// removedElement reports elements that have no place in a static copy of the page
+func removedElement(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Script, atom.Base, atom.Iframe, atom.Frame, atom.Object, atom.Embed:
+		return true
+	case atom.Meta:
+		equiv := strings.ToLower(attr(n, "http-equiv"))
+		return equiv == "refresh" || equiv == "content-security-policy" || attr(n, "charset") != "" ||
+			strings.Contains(strings.ToLower(attr(n, "content")), "charset=")
+	case atom.Link:
+		rel := strings.ToLower(attr(n, "rel"))
+		return strings.Contains(rel, "preload") || strings.Contains(rel, "prefetch") ||
+			strings.Contains(rel, "preconnect") || strings.Contains(rel, "dns-prefetch") ||
+			strings.Contains(rel, "manifest")
+	}
+	return false
+}
+
+func (c *capture) rewriteElement(ctx context.Context, n *html.Node, base *url.URL) {
+	// Event handlers and javascript: links would run in the snapshot
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		if strings.HasPrefix(key, "on") || strings.HasPrefix(strings.ToLower(strings.TrimSpace(a.Val)), "javascript:") {
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+
+	if style := attr(n, "style"); style != "" {
+		setAttr(n, "style", c.inlineCSS(ctx, style, base, 0))
+	}
+
+	switch n.DataAtom {
+	case atom.Img:
+		// Lazy loaded images keep the real source in a data attribute
+		src := attr(n, "src")
+		for _, lazy := range []string{"data-src", "data-lazy-src", "data-original"} {
+			if value := attr(n, lazy); value != "" && (src == "" || strings.HasPrefix(src, "data:")) {
+				src = value
+			}
+		}
+		removeAttr(n, "srcset")
+		removeAttr(n, "sizes")
+		removeAttr(n, "loading")
+		if src == "" {
+			return
+		}
+		if !c.inlineAttr(ctx, n, "src", src, base) {
+			if link := srcsetFirst(attr(n, "data-srcset")); link != "" {
+				c.inlineAttr(ctx, n, "src", link, base)
+			}
+		}
+	case atom.Source:
+		// The fallback <img> of the picture is inlined instead
+		if n.Parent != nil && n.Parent.DataAtom == atom.Picture {
+			removeAttr(n, "srcset")
+		} else if src := attr(n, "src"); src != "" {
+			setAttr(n, "src", absolute(base, src))
+		}
+	case atom.Input:
+		if strings.EqualFold(attr(n, "type"), "image") {
+			c.inlineAttr(ctx, n, "src", attr(n, "src"), base)
+		}
+	case atom.Video, atom.Audio, atom.Track:
+		if src := attr(n, "src"); src != "" {
+			setAttr(n, "src", absolute(base, src))
+		}
+		if poster := attr(n, "poster"); poster != "" {
+			c.inlineAttr(ctx, n, "poster", poster, base)
+		}
+	case atom.Link:
+		rel := strings.ToLower(attr(n, "rel"))
+		href := attr(n, "href")
+		switch {
+		case strings.Contains(rel, "stylesheet") && href != "":
+			c.inlineStylesheet(ctx, n, href, base)
+		case strings.Contains(rel, "icon") && href != "":
+			c.inlineAttr(ctx, n, "href", href, base)
+		case href != "":
+			setAttr(n, "href", absolute(base, href))
+		}
+	case atom.Style:
+		if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+			n.FirstChild.Data = c.inlineCSS(ctx, n.FirstChild.Data, base, 0)
+		}
+	case atom.A, atom.Area:
+		if href := attr(n, "href"); href != "" && !strings.HasPrefix(href, "#") {
+			setAttr(n, "href", absolute(base, href))
+		}
+	case atom.Form:
+		if action := attr(n, "action"); action != "" {
+			setAttr(n, "action", absolute(base, action))
+		}
+	}
+}
+
+// inlineAttr replaces the attribute with the asset as a data URI, or with the absolute URL if
+// the asset could not be captured
+func (c *capture) inlineAttr(ctx context.Context, n *html.Node, key, link string, base *url.URL) bool {
+	if link == "" || strings.HasPrefix(link, "data:") {
+		return false
+	}
+	resolved := absolute(base, link)
+	a, ok := c.fetchAsset(ctx, resolved)
+	if !ok {
+		setAttr(n, key, resolved)
+		return false
+	}
+	setAttr(n, key, dataURI(a))
+	return true
+}
+
+// inlineStylesheet replaces a <link rel="stylesheet"> with a <style> element holding the
+// stylesheet and its assets
+func (c *capture) inlineStylesheet(ctx context.Context, n *html.Node, href string, base *url.URL) {
+	resolved := absolute(base, href)
+	a, ok := c.fetchAsset(ctx, resolved)
+	if !ok {
+		setAttr(n, "href", resolved)
+		return
+	}
+	cssURL, err := url.Parse(resolved)
+	if err != nil {
+		return
+	}
+	style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+	if media := attr(n, "media"); media != "" {
+		style.Attr = append(style.Attr, html.Attribute{Key: "media", Val: media})
+	}
+	style.AppendChild(&html.Node{Type: html.TextNode, Data: c.inlineCSS(ctx, string(a.body), cssURL, 0)})
+	n.Parent.InsertBefore(style, n)
+	n.Parent.RemoveChild(n)
+}
+
+// inlineCSS inlines the imports, fonts and images referenced by the stylesheet.
+// Relative references are resolved against the URL of the stylesheet.
+func (c *capture) inlineCSS(ctx context.Context, css string, base *url.URL, depth int) string {
+	css = cssImportRe.ReplaceAllStringFunc(css, func(match string) string {
+		groups := cssImportRe.FindStringSubmatch(match)
+		link := groups[1] + groups[2]
+		if depth >= maxImportDepth {
+			return ""
+		}
+		resolved := absolute(base, link)
+		a, ok := c.fetchAsset(ctx, resolved)
+		importURL, err := url.Parse(resolved)
+		if !ok || err != nil {
+			return ""
+		}
+		return c.inlineCSS(ctx, string(a.body), importURL, depth+1)
+	})
+	return cssURLRe.ReplaceAllStringFunc(css, func(match string) string {
+		groups := cssURLRe.FindStringSubmatch(match)
+		link := strings.TrimSpace(groups[1] + groups[2] + groups[3])
+		if link == "" || strings.HasPrefix(link, "data:") || strings.HasPrefix(link, "#") {
+			return match
+		}
+		resolved := absolute(base, link)
+		a, ok := c.fetchAsset(ctx, resolved)
+		if !ok {
+			return fmt.Sprintf(`url("%s")`, resolved)
+		}
+		return fmt.Sprintf(`url("%s")`, dataURI(a))
+	})
+}
+
+// markSnapshot adds the charset and a comment with the origin of the snapshot, like browsers do
+// when saving a page
+func markSnapshot(doc *html.Node, pageURL *url.URL) {
+	head := findElement(doc, atom.Head)
+	if head == nil {
+		return
+	}
+	meta := &html.Node{Type: html.ElementNode, Data: "meta", DataAtom: atom.Meta,
+		Attr: []html.Attribute{{Key: "charset", Val: "utf-8"}}}
+	head.InsertBefore(meta, head.FirstChild)
+
+	comment := &html.Node{Type: html.CommentNode,
+		Data: fmt.Sprintf(" Saved from %s on %s ", pageURL.String(), time.Now().UTC().Format(time.RFC3339))}
+	if root := findElement(doc, atom.Html); root != nil {
+		doc.InsertBefore(comment, root)
+	}
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func dataURI(a asset) string {
+	contentType := a.contentType
+	// Only the media type is needed, and a charset parameter would need escaping
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(a.body)
+}
+
+// srcsetFirst returns the first candidate of a srcset attribute
+func srcsetFirst(srcset string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(srcset), ",")
+	link, _, _ := strings.Cut(strings.TrimSpace(first), " ")
+	return link
+}
+
+func absolute(base *url.URL, link string) string {
+	resolved, err := base.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return link
+	}
+	return resolved.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func removeAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != key {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
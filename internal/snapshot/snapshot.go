@@ -0,0 +1,175 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arashthr/pensive/internal/fetcher"
+	"github.com/arashthr/pensive/internal/types"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	MaxPageSize   = 10 << 20 // Largest page that is captured
+	MaxAssetSize  = 5 << 20  // Larger images, stylesheets and fonts are left out of the snapshot
+	MaxAssets     = 150      // Assets after this many are left out of the snapshot
+	MaxTotalSize  = 50 << 20 // Budget of the page and all of its assets together
+	fetchTimeout  = 20 * time.Second
+	captureBudget = 2 * time.Minute
+	userAgent     = "Mozilla/5.0"
+)
+
+// Snapshot is the self-contained copy of a page, ready to be stored
+type Snapshot struct {
+	Format      types.SnapshotFormat
+	ContentType string
+	Body        []byte
+	Assets      int // Number of assets captured with the page
+}
+
+// exchange is a response captured while taking the snapshot, kept for the WARC records
+type exchange struct {
+	url      string
+	date     time.Time
+	response *http.Response
+	body     []byte
+}
+
+// capture fetches a page and its assets within the size and count limits
+type capture struct {
+	client    *http.Client
+	exchanges []exchange
+	assets    map[string]asset // Cache so each asset is fetched once
+	fetched   int
+	total     int64
+}
+
+type asset struct {
+	contentType string
+	body        []byte
+}
+
+// Capture fetches the page at link and builds the snapshot in the given format.
+// If fetching the page fails, fallbackHTML (for example the HTML sent by the browser extension)
+// is used for the single file format. WARC needs the real responses and fails instead.
+func Capture(ctx context.Context, format types.SnapshotFormat, link string, fallbackHTML string) (*Snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, captureBudget)
+	defer cancel()
+
+	c := &capture{
+		client: fetcher.NewClient(fetchTimeout),
+		assets: map[string]asset{},
+	}
+
+	pageURL, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("parse snapshot link: %w", err)
+	}
+	page, finalURL, err := c.fetchPage(ctx, link)
+	if err != nil {
+		if format != types.SnapshotFormatHTML || fallbackHTML == "" {
+			return nil, fmt.Errorf("fetch page for snapshot: %w", err)
+		}
+		page = []byte(fallbackHTML)
+	} else {
+		pageURL = finalURL
+	}
+
+	body, err := c.singleFile(ctx, page, pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("build single file snapshot: %w", err)
+	}
+	if format == types.SnapshotFormatWARC {
+		body, err = c.warc(link)
+		if err != nil {
+			return nil, fmt.Errorf("build WARC snapshot: %w", err)
+		}
+		return &Snapshot{Format: format, ContentType: "application/warc", Body: body, Assets: len(c.exchanges) - 1}, nil
+	}
+	return &Snapshot{Format: format, ContentType: "text/html; charset=utf-8", Body: body, Assets: len(c.assets)}, nil
+}
+
+// fetchPage downloads the page and converts it to UTF-8
+func (c *capture) fetchPage(ctx context.Context, link string) ([]byte, *url.URL, error) {
+	resp, body, err := c.get(ctx, link, MaxPageSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, nil, fmt.Errorf("page is not HTML: %s", contentType)
+	}
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("detect page encoding: %w", err)
+	}
+	utf8Body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode page: %w", err)
+	}
+	return utf8Body, resp.Request.URL, nil
+}
+
+// fetchAsset downloads an asset once, and reports false if it is unavailable or over the limits
+func (c *capture) fetchAsset(ctx context.Context, link string) (asset, bool) {
+	if cached, ok := c.assets[link]; ok {
+		return cached, cached.body != nil
+	}
+	if c.fetched >= MaxAssets || c.total >= MaxTotalSize || ctx.Err() != nil {
+		return asset{}, false
+	}
+	c.fetched++
+	resp, body, err := c.get(ctx, link, MaxAssetSize)
+	if err != nil {
+		c.assets[link] = asset{}
+		return asset{}, false
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		contentType = http.DetectContentType(body)
+	}
+	a := asset{contentType: contentType, body: body}
+	c.assets[link] = a
+	return a, true
+}
+
+// get performs a GET request, records the exchange and enforces the size limits
+func (c *capture) get(ctx context.Context, link string, limit int64) (*http.Response, []byte, error) {
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, nil, fmt.Errorf("unsupported URL %q", link)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	req.Header.Set("Accept", "*/*")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("perform request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read response body: %w", err)
+	}
+	if int64(len(body)) > limit || c.total+int64(len(body)) > MaxTotalSize {
+		return nil, nil, fmt.Errorf("response of %s is too large", link)
+	}
+	c.total += int64(len(body))
+	c.exchanges = append(c.exchanges, exchange{url: resp.Request.URL.String(), date: time.Now().UTC(), response: resp, body: body})
+	return resp, body, nil
+}
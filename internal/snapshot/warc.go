@@ -0,0 +1,62 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http/httputil"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const warcSoftware = "Pensive"
+
+// warc writes the captured responses as WARC 1.1 records, one warcinfo record followed by a
+// response record for the page and each of its assets
+func (c *capture) warc(link string) ([]byte, error) {
+	var buf bytes.Buffer
+	info := fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.1\r\nisPartOf: %s\r\n", warcSoftware, link)
+	writeWARCRecord(&buf, map[string]string{
+		"WARC-Type":    "warcinfo",
+		"Content-Type": "application/warc-fields",
+	}, time.Now().UTC(), []byte(info))
+
+	for _, e := range c.exchanges {
+		// The body was already read, and Go removed the transfer and content encodings
+		e.response.Body = io.NopCloser(bytes.NewReader(e.body))
+		e.response.ContentLength = int64(len(e.body))
+		e.response.TransferEncoding = nil
+		e.response.Header.Del("Content-Encoding")
+		e.response.Header.Del("Content-Length")
+		dump, err := httputil.DumpResponse(e.response, true)
+		if err != nil {
+			return nil, fmt.Errorf("dump response of %s: %w", e.url, err)
+		}
+		digest := sha1.Sum(e.body)
+		writeWARCRecord(&buf, map[string]string{
+			"WARC-Type":           "response",
+			"WARC-Target-URI":     e.url,
+			"WARC-Payload-Digest": "sha1:" + base32.StdEncoding.EncodeToString(digest[:]),
+			"Content-Type":        "application/http;msgtype=response",
+		}, e.date, dump)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeWARCRecord(w io.Writer, headers map[string]string, date time.Time, block []byte) {
+	fmt.Fprintf(w, "WARC/1.1\r\n")
+	fmt.Fprintf(w, "WARC-Record-ID: <urn:uuid:%s>\r\n", uuid.NewString())
+	fmt.Fprintf(w, "WARC-Date: %s\r\n", date.Format(time.RFC3339))
+	// Fixed order keeps the records readable
+	for _, key := range []string{"WARC-Type", "WARC-Target-URI", "WARC-Payload-Digest", "Content-Type"} {
+		if value, ok := headers[key]; ok {
+			fmt.Fprintf(w, "%s: %s\r\n", key, value)
+		}
+	}
+	fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(block))
+	w.Write(block)
+	fmt.Fprintf(w, "\r\n\r\n")
+}
@@ -0,0 +1,166 @@
+// Package textdiff compares two versions of the text of a page paragraph by paragraph
+package textdiff
+
+import "strings"
+
+// maxCells bounds the comparison table. Texts that differ in more paragraphs than that are
+// shown as fully replaced instead of being compared.
+const maxCells = 4_000_000
+
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+	Skip // Unchanged paragraphs left out by Compact
+)
+
+// Line is a paragraph of the diff. Skipped counts the paragraphs a Skip line stands for.
+type Line struct {
+	Op      Op
+	Text    string
+	Skipped int
+}
+
+func (l Line) Removed() bool   { return l.Op == Delete }
+func (l Line) Added() bool     { return l.Op == Insert }
+func (l Line) Collapsed() bool { return l.Op == Skip }
+
+// Stats counts the paragraphs that were added and removed
+type Stats struct {
+	Added   int
+	Removed int
+}
+
+// Paragraphs splits the text in its non-empty lines, without the surrounding whitespace
+func Paragraphs(text string) []string {
+	var paragraphs []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
+
+// Diff returns the paragraphs of old and new in order, marking the ones only in old as
+// deleted and the ones only in new as inserted
+func Diff(old, new string) []Line {
+	a, b := Paragraphs(old), Paragraphs(new)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, p := range a[:prefix] {
+		lines = append(lines, Line{Op: Equal, Text: p})
+	}
+	lines = append(lines, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, p := range a[len(a)-suffix:] {
+		lines = append(lines, Line{Op: Equal, Text: p})
+	}
+	return lines
+}
+
+// diffMiddle compares the paragraphs with a longest common subsequence table
+func diffMiddle(a, b []string) []Line {
+	var lines []Line
+	if len(a)*len(b) > maxCells || len(a) == 0 || len(b) == 0 {
+		for _, p := range a {
+			lines = append(lines, Line{Op: Delete, Text: p})
+		}
+		for _, p := range b {
+			lines = append(lines, Line{Op: Insert, Text: p})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	cols := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*cols)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*cols+j] = lcs[(i+1)*cols+j+1] + 1
+			} else {
+				lcs[i*cols+j] = max(lcs[(i+1)*cols+j], lcs[i*cols+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, Line{Op: Equal, Text: a[i]})
+			i++
+			j++
+		case lcs[(i+1)*cols+j] >= lcs[i*cols+j+1]:
+			lines = append(lines, Line{Op: Delete, Text: a[i]})
+			i++
+		default:
+			lines = append(lines, Line{Op: Insert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, Line{Op: Delete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, Line{Op: Insert, Text: b[j]})
+	}
+	return lines
+}
+
+// Compact keeps the changed paragraphs with context unchanged paragraphs around them, and
+// replaces the other unchanged paragraphs with Skip lines
+func Compact(lines []Line, context int) []Line {
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if line.Op == Equal {
+			continue
+		}
+		for k := max(0, i-context); k < min(len(lines), i+context+1); k++ {
+			keep[k] = true
+		}
+	}
+
+	var compact []Line
+	skipped := 0
+	for i, line := range lines {
+		if line.Op != Equal || keep[i] {
+			if skipped > 0 {
+				compact = append(compact, Line{Op: Skip, Skipped: skipped})
+				skipped = 0
+			}
+			compact = append(compact, line)
+			continue
+		}
+		skipped++
+	}
+	if skipped > 0 {
+		compact = append(compact, Line{Op: Skip, Skipped: skipped})
+	}
+	return compact
+}
+
+// Count returns how many paragraphs were added and removed
+func Count(lines []Line) Stats {
+	var stats Stats
+	for _, line := range lines {
+		switch line.Op {
+		case Insert:
+			stats.Added++
+		case Delete:
+			stats.Removed++
+		}
+	}
+	return stats
+}
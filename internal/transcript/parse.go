@@ -0,0 +1,167 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	cueTagRe  = regexp.MustCompile(`<[^>]*>`)
+	spacesRe  = regexp.MustCompile(`\s+`)
+	newlineRe = regexp.MustCompile(`\r\n?`)
+)
+
+// Parse reads captions in WebVTT, SRT or YouTube timed text (XML) format
+func Parse(data []byte) ([]Segment, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(data)
+	var segments []Segment
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		var err error
+		if segments, err = ParseTimedText(trimmed); err != nil {
+			return nil, err
+		}
+	} else {
+		// WebVTT and SRT only differ in the header and the decimal separator
+		segments = ParseCues(data)
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no captions found")
+	}
+	return segments, nil
+}
+
+// ParseCues reads WebVTT or SRT captions. Lines repeated from the previous cue, as in the
+// rolling automatic captions of YouTube, are dropped. Cues with a malformed timing are skipped,
+// the rest of the file is still read.
+func ParseCues(data []byte) []Segment {
+	text := newlineRe.ReplaceAllString(string(data), "\n")
+	var segments []Segment
+	var previous string
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		// Headers, notes, styles and the SRT indexes have no timing
+		if timing == -1 {
+			continue
+		}
+		start, end, err := parseTiming(lines[timing])
+		if err != nil {
+			continue
+		}
+		var parts []string
+		for _, line := range lines[timing+1:] {
+			line = cleanCueText(line)
+			if line == "" || line == previous {
+				continue
+			}
+			parts = append(parts, line)
+			previous = line
+		}
+		if len(parts) > 0 {
+			segments = append(segments, Segment{Start: start, End: end, Text: strings.Join(parts, " ")})
+		}
+	}
+	return segments
+}
+
+// timedText has the elements of both the format 3 (<timedtext><body><p t="ms" d="ms">) and
+// the legacy format (<transcript><text start="s" dur="s">) of YouTube
+type timedText struct {
+	Paragraphs []struct {
+		T    int64  `xml:"t,attr"`
+		D    int64  `xml:"d,attr"`
+		Text string `xml:",innerxml"`
+	} `xml:"body>p"`
+	Texts []struct {
+		Start float64 `xml:"start,attr"`
+		Dur   float64 `xml:"dur,attr"`
+		Text  string  `xml:",chardata"`
+	} `xml:"text"`
+}
+
+// ParseTimedText reads the XML captions of YouTube
+func ParseTimedText(data []byte) ([]Segment, error) {
+	var tt timedText
+	if err := xml.Unmarshal(data, &tt); err != nil {
+		return nil, fmt.Errorf("parse timed text: %w", err)
+	}
+	var segments []Segment
+	for _, p := range tt.Paragraphs {
+		// The inner XML keeps the entities, and the text may be escaped once more
+		text := cleanCueText(html.UnescapeString(p.Text))
+		if text == "" {
+			continue
+		}
+		start := time.Duration(p.T) * time.Millisecond
+		segments = append(segments, Segment{Start: start, End: start + time.Duration(p.D)*time.Millisecond, Text: text})
+	}
+	for _, t := range tt.Texts {
+		text := cleanCueText(t.Text)
+		if text == "" {
+			continue
+		}
+		start := seconds(t.Start)
+		segments = append(segments, Segment{Start: start, End: start + seconds(t.Dur), Text: text})
+	}
+	return segments, nil
+}
+
+// cleanCueText removes the styling tags, inline timestamps and entities of a caption line
+func cleanCueText(line string) string {
+	line = cueTagRe.ReplaceAllString(line, "")
+	line = html.UnescapeString(line)
+	return strings.TrimSpace(spacesRe.ReplaceAllString(line, " "))
+}
+
+// parseTiming reads "00:01:02.500 --> 00:01:04.000 align:start", the settings are ignored
+func parseTiming(line string) (time.Duration, time.Duration, error) {
+	from, to, _ := strings.Cut(line, "-->")
+	fields := strings.Fields(to)
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("cue timing without end: %q", line)
+	}
+	start, err := parseClock(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseClock(fields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseClock reads [hh:]mm:ss[.mmm], with a comma as the decimal separator in SRT
+func parseClock(s string) (time.Duration, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	var total float64
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		total = total*60 + v
+	}
+	return seconds(total), nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond)
+}
@@ -0,0 +1,136 @@
+package transcript
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func readFixture(t *testing.T, file string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func ms(n int64) time.Duration { return time.Duration(n) * time.Millisecond }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file string
+		want []Segment
+	}{
+		{
+			// Byte order mark, CRLF line endings, a header, a note, a style block, cue identifiers and tags
+			file: "captions_bom_crlf.vtt",
+			want: []Segment{
+				{Start: ms(1000), End: ms(4500), Text: "Welcome to the show."},
+				{Start: ms(4500), End: ms(8250), Text: "Today we talk about Go & Postgres."},
+				{Start: time.Hour + 2*time.Minute + ms(3004), End: time.Hour + 2*time.Minute + ms(5000), Text: "See you next time."},
+			},
+		},
+		{
+			// Rolling automatic captions repeat the previous line in each cue
+			file: "captions_asr.vtt",
+			want: []Segment{
+				{Start: ms(160), End: ms(2470), Text: "so today we"},
+				{Start: ms(2480), End: ms(5110), Text: "are going to cook"},
+				{Start: ms(5120), End: ms(7000), Text: "pasta"},
+			},
+		},
+		{
+			file: "captions.srt",
+			want: []Segment{
+				{Start: ms(1000), End: ms(3250), Text: "Hello there."},
+				{Start: ms(3250), End: ms(6000), Text: "This is SRT, with two lines."},
+				{Start: ms(60500), End: ms(62000), Text: "The end."},
+			},
+		},
+		{
+			file: "captions_malformed.vtt",
+			want: []Segment{
+				{Start: ms(1000), End: ms(2000), Text: "First cue."},
+				{Start: ms(4000), End: ms(5000), Text: "Last cue."},
+			},
+		},
+		{
+			// The text is escaped twice, the empty paragraphs are dropped
+			file: "timedtext_format3.xml",
+			want: []Segment{
+				{Start: ms(1200), End: ms(3500), Text: "Hello & welcome"},
+				{Start: ms(3500), End: ms(5000), Text: "to the channel"},
+				{Start: ms(65000), End: ms(67000), Text: "It's done"},
+			},
+		},
+		{
+			file: "timedtext_legacy.xml",
+			want: []Segment{
+				{Start: ms(500), End: ms(2750), Text: "Hello & welcome"},
+				{Start: ms(2750), End: ms(4250), Text: "to the channel"},
+				{Start: ms(61123), End: ms(63123), Text: "It's done"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := Parse(readFixture(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%s) =\n%+v\nwant\n%+v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"header only", "WEBVTT\n\nNOTE nothing here\n"},
+		{"only malformed cues", "WEBVTT\n\n1:2:3:4 --> 00:01.000\nText\n"},
+		{"broken xml", "<transcript><text start=\"1\">"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if segments, err := Parse([]byte(tt.data)); err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", tt.data, segments)
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "00:01.500", want: ms(1500)},
+		{in: "01:02:03.004", want: time.Hour + 2*time.Minute + ms(3004)},
+		{in: "00:00:01,250", want: ms(1250)},
+		{in: "1:05", want: 65 * time.Second},
+		{in: "90", wantErr: true},
+		{in: "1:2:3:4", wantErr: true},
+		{in: "00:-1.000", wantErr: true},
+		{in: "aa:bb", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseClock(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseClock(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseClock(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package transcript
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Track is a caption file of a <video> element
+type Track struct {
+	URL      string
+	Language string
+	Label    string
+	Video    string // Source of the video the captions are for
+}
+
+// Tracks finds the caption tracks of the <video> elements of the page. Relative links are
+// resolved against the link of the page.
+func Tracks(page []byte, pageURL *url.URL) []Track {
+	tokenizer := html.NewTokenizer(bytes.NewReader(page))
+	var tracks []Track
+	var video string
+	inVideo := false
+	resolve := func(link string) string {
+		link = strings.TrimSpace(link)
+		if link == "" {
+			return ""
+		}
+		u, err := pageURL.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ""
+		}
+		return u.String()
+	}
+	for {
+		tt := tokenizer.Next()
+		switch tt {
+		case html.ErrorToken:
+			return tracks
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "video" {
+				inVideo = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				attrs[string(key)] = string(value)
+			}
+			switch string(name) {
+			case "video":
+				inVideo = tt == html.StartTagToken
+				video = resolve(attrs["src"])
+			case "source":
+				if inVideo && video == "" {
+					video = resolve(attrs["src"])
+				}
+			case "track":
+				// A track without kind is subtitles
+				kind := strings.ToLower(attrs["kind"])
+				if !inVideo || (kind != "" && kind != "captions" && kind != "subtitles") {
+					continue
+				}
+				if link := resolve(attrs["src"]); link != "" {
+					tracks = append(tracks, Track{URL: link, Language: attrs["srclang"], Label: attrs["label"], Video: video})
+				}
+			}
+		}
+	}
+}
+
+// PickTrack returns the track in the language, the first track otherwise
+func PickTrack(tracks []Track, language string) *Track {
+	if len(tracks) == 0 {
+		return nil
+	}
+	for i, t := range tracks {
+		if language != "" && strings.HasPrefix(strings.ToLower(t.Language), strings.ToLower(language)) {
+			return &tracks[i]
+		}
+	}
+	return &tracks[0]
+}
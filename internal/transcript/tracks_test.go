@@ -0,0 +1,43 @@
+package transcript
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestTracks(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/talks/go.html")
+	got := Tracks(readFixture(t, "video_page.html"), pageURL)
+	// Chapters, scripts and the tracks outside of a <video> are left out
+	want := []Track{
+		{URL: "https://example.com/media/talk.en.vtt", Language: "en", Label: "English", Video: "https://example.com/media/talk.mp4"},
+		{URL: "https://cdn.example.com/talk.fr.vtt", Language: "fr", Label: "Français", Video: "https://example.com/media/talk.mp4"},
+		{URL: "https://example.com/talks/clip.vtt", Language: "es-MX", Video: "https://example.com/talks/clip.webm"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tracks =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestPickTrack(t *testing.T) {
+	tracks := []Track{{Language: "en"}, {Language: "fr"}, {Language: "es-MX"}}
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"fr", "fr"},
+		{"es", "es-MX"},
+		{"ES", "es-MX"},
+		{"de", "en"},
+		{"", "en"},
+	}
+	for _, tt := range tests {
+		if got := PickTrack(tracks, tt.language); got == nil || got.Language != tt.want {
+			t.Errorf("PickTrack(%q) = %+v, want %q", tt.language, got, tt.want)
+		}
+	}
+	if got := PickTrack(nil, "en"); got != nil {
+		t.Errorf("PickTrack without tracks = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,178 @@
+// Package transcript parses the captions of videos (WebVTT, SRT and YouTube timed text) and
+// turns them into readable text, split by the chapters of the video
+package transcript
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	paragraphSpan    = 30 * time.Second // A paragraph ends at the first sentence end after this long
+	maxParagraphSpan = 45 * time.Second // Captions without punctuation are cut at this length
+	minChapters      = 3                // Like YouTube, fewer timestamps in a description are not chapters
+)
+
+// Providers of the videos, they link to a time of the video differently
+const (
+	ProviderYouTube = "youtube"
+	ProviderHTML5   = "html5" // A <video> element with <track> captions
+)
+
+// Segment is a caption shown on the video from Start to End
+type Segment struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+	Text  string        `json:"text"`
+}
+
+// Chapter is a part of the video, as listed in its description
+type Chapter struct {
+	Start time.Duration `json:"start"`
+	Title string        `json:"title"`
+}
+
+// Transcript is the captions of a video
+type Transcript struct {
+	Provider string    `json:"provider"`
+	VideoURL string    `json:"video_url"`           // The page of the video, or the video file for HTML5 videos
+	EmbedURL string    `json:"embed_url,omitempty"` // Player that can be embedded in a page
+	Language string    `json:"language,omitempty"`
+	Segments []Segment `json:"segments"`
+	Chapters []Chapter `json:"chapters,omitempty"`
+}
+
+// Paragraph is consecutive segments joined together
+type Paragraph struct {
+	Start time.Duration
+	Text  string
+}
+
+// Section is the paragraphs of a chapter. Title is empty when the video has no chapters.
+type Section struct {
+	Title      string
+	Start      time.Duration
+	Paragraphs []Paragraph
+}
+
+// Timestamp is the start of the paragraph, like 1:02:03 or 2:03
+func (p Paragraph) Timestamp() string { return Timestamp(p.Start) }
+
+// Seconds is the start of the paragraph in seconds, to seek the player
+func (p Paragraph) Seconds() int { return int(p.Start.Seconds()) }
+
+func (s Section) Timestamp() string { return Timestamp(s.Start) }
+
+func (s Section) Seconds() int { return int(s.Start.Seconds()) }
+
+// Sections joins the segments into paragraphs, grouped by chapter
+func (t *Transcript) Sections() []Section {
+	var sections []Section
+	chapter := 0
+	var current *Section
+	var paragraph []string
+	var paragraphStart time.Duration
+	flush := func() {
+		if len(paragraph) > 0 && current != nil {
+			current.Paragraphs = append(current.Paragraphs, Paragraph{Start: paragraphStart, Text: strings.Join(paragraph, " ")})
+		}
+		paragraph = nil
+	}
+	for _, s := range t.Segments {
+		if current == nil || chapter < len(t.Chapters) && s.Start >= t.Chapters[chapter].Start {
+			flush()
+			section := Section{Start: s.Start}
+			// A segment may jump over short chapters, the last one started is used
+			for chapter < len(t.Chapters) && s.Start >= t.Chapters[chapter].Start {
+				section.Title = t.Chapters[chapter].Title
+				section.Start = t.Chapters[chapter].Start
+				chapter++
+			}
+			sections = append(sections, section)
+			current = &sections[len(sections)-1]
+		}
+		if len(paragraph) == 0 {
+			paragraphStart = s.Start
+		}
+		paragraph = append(paragraph, s.Text)
+		span := s.End - paragraphStart
+		if span >= maxParagraphSpan || span >= paragraphSpan && endsSentence(s.Text) {
+			flush()
+		}
+	}
+	flush()
+	return sections
+}
+
+// Text is the transcript as plain text, with the chapter titles and a timestamp before each paragraph
+func (t *Transcript) Text() string {
+	var b strings.Builder
+	for _, section := range t.Sections() {
+		if section.Title != "" {
+			fmt.Fprintf(&b, "%s\n\n", section.Title)
+		}
+		for _, p := range section.Paragraphs {
+			fmt.Fprintf(&b, "[%s] %s\n\n", p.Timestamp(), p.Text)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// TimeLink returns the link that plays the video from the given time
+func (t *Transcript) TimeLink(at time.Duration) string {
+	seconds := int(at.Seconds())
+	switch t.Provider {
+	case ProviderYouTube:
+		sep := "?"
+		if strings.Contains(t.VideoURL, "?") {
+			sep = "&"
+		}
+		return fmt.Sprintf("%s%st=%ds", t.VideoURL, sep, seconds)
+	default:
+		// Media fragment, supported by the browsers for video files
+		return fmt.Sprintf("%s#t=%d", t.VideoURL, seconds)
+	}
+}
+
+// Timestamp formats the time like 1:02:03, or 2:03 under an hour
+func Timestamp(d time.Duration) string {
+	total := int(d.Seconds())
+	h, m, s := total/3600, total/60%60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+func endsSentence(text string) bool {
+	text = strings.TrimRight(text, `"')]”’ `)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") || strings.HasSuffix(text, "!")
+}
+
+var chapterRe = regexp.MustCompile(`^\s*[\[(]?((?:\d{1,2}:)?\d{1,2}:\d{2})[\])]?\s*(?:[-–—:|]\s*)?(.+?)\s*$`)
+
+// ParseChapters finds the chapters in the description of a video: lines starting with a
+// timestamp, the first one at 0:00, in increasing order
+func ParseChapters(description string) []Chapter {
+	var chapters []Chapter
+	for _, line := range strings.Split(description, "\n") {
+		m := chapterRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		start, err := parseClock(m[1])
+		if err != nil {
+			continue
+		}
+		if len(chapters) > 0 && start <= chapters[len(chapters)-1].Start {
+			continue
+		}
+		chapters = append(chapters, Chapter{Start: start, Title: m[2]})
+	}
+	if len(chapters) < minChapters || chapters[0].Start != 0 {
+		return nil
+	}
+	return chapters
+}
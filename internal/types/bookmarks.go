@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type BookmarkId string
 
@@ -12,8 +15,66 @@ const (
 	ExtractionMethodReadability     ExtractionMethod = "client-readability"
 	ExtractionMethodReadabilityHTML ExtractionMethod = "client-readability-html"
 	ExtractionMethodHTML            ExtractionMethod = "client-html"
+	ExtractionMethodSiteExtractor   ExtractionMethod = "server-site-extractor" // From the API of the site
+	ExtractionMethodDocument        ExtractionMethod = "server-document"       // From a file like a PDF
+)
+
+// ReadingStatus is where a bookmark is in the reading flow
+type ReadingStatus string
+
+const (
+	ReadingStatusUnread   ReadingStatus = "unread"
+	ReadingStatusReading  ReadingStatus = "reading"
+	ReadingStatusArchived ReadingStatus = "archived"
+)
+
+// ParseReadingStatus validates a reading status coming from user input
+func ParseReadingStatus(s string) (ReadingStatus, bool) {
+	switch status := ReadingStatus(strings.ToLower(strings.TrimSpace(s))); status {
+	case ReadingStatusUnread, ReadingStatusReading, ReadingStatusArchived:
+		return status, true
+	}
+	return "", false
+}
+
+// SnapshotFormat is how the offline copy of a saved page is captured
+type SnapshotFormat string
+
+const (
+	SnapshotFormatNone SnapshotFormat = "none"
+	SnapshotFormatHTML SnapshotFormat = "html" // Single HTML file with the assets inlined
+	SnapshotFormatWARC SnapshotFormat = "warc"
+)
+
+// ParseSnapshotFormat validates a snapshot format coming from user input
+func ParseSnapshotFormat(s string) (SnapshotFormat, bool) {
+	switch format := SnapshotFormat(strings.ToLower(strings.TrimSpace(s))); format {
+	case SnapshotFormatNone, SnapshotFormatHTML, SnapshotFormatWARC:
+		return format, true
+	}
+	return "", false
+}
+
+// SearchMode is how a query is matched to the bookmarks
+type SearchMode string
+
+const (
+	SearchModeHybrid   SearchMode = "hybrid"   // Keyword and semantic rankings fused together
+	SearchModeKeyword  SearchMode = "keyword"  // Words of the query, with the full-text search
+	SearchModeSemantic SearchMode = "semantic" // Meaning of the query, with the embeddings
 )
 
+// ParseSearchMode validates a search mode coming from user input. The default is hybrid.
+func ParseSearchMode(s string) (SearchMode, bool) {
+	switch mode := SearchMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return SearchModeHybrid, true
+	case SearchModeHybrid, SearchModeKeyword, SearchModeSemantic:
+		return mode, true
+	}
+	return "", false
+}
+
 type BookmarkSearchResult struct {
 	Id        BookmarkId
 	Title     string
@@ -24,12 +85,33 @@ type BookmarkSearchResult struct {
 	CreatedAt time.Time
 }
 
+// SearchFacets count the bookmarks matching the words and filters of a search by the values they
+// have, so the results can be narrowed down to one of them
+type SearchFacets struct {
+	Total   int // Bookmarks matching the words and filters of the search
+	Sites   []FacetValue
+	Tags    []FacetValue
+	Sources []FacetValue
+	Months  []FacetValue // Newest first, the other facets have their most common values first
+	Langs   []FacetValue
+}
+
+// FacetValue is a value of a facet and how many bookmarks have it
+type FacetValue struct {
+	Value  string
+	Count  int
+	Filter string // Filter of the search query narrowing the results to the value, like site:example.com
+}
+
 type BookmarkListItem struct {
-	Id        BookmarkId
-	Title     string
-	Link      string
-	CreatedAt string
-	Excerpt   string
+	Id            BookmarkId
+	Title         string
+	Link          string
+	CreatedAt     string
+	Excerpt       string
+	ReadingStatus ReadingStatus
+	Starred       bool
+	Broken        bool
 }
 
 type PagesData struct {
@@ -44,6 +126,21 @@ type PaginatedBookmarksType struct {
 	Bookmarks         []BookmarkListItem
 	Count             int
 	HasBookmarksAtAll bool
+	Filter            BookmarkFilter
+}
+
+// BookmarkFilter narrows bookmark listings and searches.
+// Empty fields are ignored.
+type BookmarkFilter struct {
+	Tag           string
+	Collection    string
+	ReadingStatus ReadingStatus
+	Starred       bool
+	Broken        bool // Only bookmarks whose link stopped working
+}
+
+func (f BookmarkFilter) IsEmpty() bool {
+	return f.Tag == "" && f.Collection == "" && f.ReadingStatus == "" && !f.Starred && !f.Broken
 }
 
 type CreateBookmarkRequest struct {
@@ -0,0 +1,89 @@
+import dns from 'node:dns';
+import http from 'node:http';
+import https from 'node:https';
+import net from 'node:net';
+
+// The ranges that are not public internet addresses, like Blocked in internal/fetcher
+const blocked = new net.BlockList();
+[
+    ['0.0.0.0', 8],       // This network
+    ['10.0.0.0', 8],      // Private
+    ['100.64.0.0', 10],   // Carrier-grade NAT
+    ['127.0.0.0', 8],     // Loopback
+    ['169.254.0.0', 16],  // Link-local
+    ['172.16.0.0', 12],   // Private
+    ['192.0.0.0', 24],    // IETF protocol assignments
+    ['192.0.2.0', 24],    // Documentation
+    ['192.168.0.0', 16],  // Private
+    ['198.18.0.0', 15],   // Benchmarking
+    ['198.51.100.0', 24], // Documentation
+    ['203.0.113.0', 24],  // Documentation
+    ['224.0.0.0', 4],     // Multicast
+    ['240.0.0.0', 4],     // Reserved, and the broadcast address
+].forEach(([address, prefix]) => blocked.addSubnet(address, prefix, 'ipv4'));
+[
+    ['::', 128],          // Unspecified
+    ['::1', 128],         // Loopback
+    ['64:ff9b::', 96],    // NAT64, it embeds an IPv4 address
+    ['64:ff9b:1::', 48],  // Local-use NAT64
+    ['2001:db8::', 32],   // Documentation
+    ['fc00::', 7],        // Unique local
+    ['fe80::', 10],       // Link-local
+    ['ff00::', 8],        // Multicast
+].forEach(([address, prefix]) => blocked.addSubnet(address, prefix, 'ipv6'));
+
+export class BlockedAddressError extends Error {
+    constructor(address) {
+        super(`Link points to a private or local address: ${address}`);
+        // node-fetch only keeps the code of the errors of the connections
+        this.code = 'EBLOCKEDADDRESS';
+        this.address = address;
+    }
+}
+
+// isBlocked tells if the IP address is not a public internet address
+export function isBlocked(address) {
+    // IPv4 addresses mapped to IPv6 are checked as IPv4
+    const mapped = /^::ffff:(\d+\.\d+\.\d+\.\d+)$/i.exec(address);
+    if (mapped) {
+        address = mapped[1];
+    }
+    const family = net.isIP(address);
+    if (family === 0) {
+        return true;
+    }
+    return blocked.check(address, family === 4 ? 'ipv4' : 'ipv6');
+}
+
+// checkHost fails for hosts that are a blocked IP address, the names are checked when they are
+// resolved by publicLookup
+export function checkHost(url) {
+    const host = url.hostname.replace(/^\[(.*)\]$/, '$1');
+    if (net.isIP(host) !== 0 && isBlocked(host)) {
+        throw new BlockedAddressError(host);
+    }
+}
+
+// publicLookup resolves like dns.lookup, and fails when the host resolves to a blocked address.
+// It is used by the agents on every connection, so it applies to the redirects too.
+function publicLookup(hostname, options, callback) {
+    dns.lookup(hostname, options, (err, address, family) => {
+        if (err) {
+            return callback(err);
+        }
+        const addresses = Array.isArray(address) ? address : [{ address, family }];
+        const found = addresses.find((a) => isBlocked(a.address));
+        if (found) {
+            return callback(new BlockedAddressError(found.address));
+        }
+        callback(null, address, family);
+    });
+}
+
+const httpAgent = new http.Agent({ keepAlive: true, lookup: publicLookup });
+const httpsAgent = new https.Agent({ keepAlive: true, lookup: publicLookup });
+
+// publicAgent is the agent option of node-fetch that only connects to public addresses
+export function publicAgent(url) {
+    return url.protocol === 'http:' ? httpAgent : httpsAgent;
+}
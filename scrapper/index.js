@@ -2,34 +2,79 @@ import express from 'express';
 import fetch from 'node-fetch';
 import { JSDOM } from 'jsdom';
 import { Readability } from '@mozilla/readability';
+import { checkHost, publicAgent } from './address.js';
+
+const maxRedirects = 10;
+
+const defaultHeaders = {
+    'User-Agent': 'Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.3',
+    'Accept-Language': 'en-US,en;q=0.9',
+    'Accept': 'text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8',
+    'Connection': 'keep-alive',
+    'Upgrade-Insecure-Requests': '1',
+};
+
+// fetchPage follows the redirects itself, so with publicOnly every hop is checked against the
+// private addresses. The headers of the profile are only sent to the host of the link.
+async function fetchPage(link, headers, publicOnly) {
+    let url = new URL(link);
+    const host = url.host;
+    for (let redirects = 0; ; redirects++) {
+        if (url.protocol !== 'http:' && url.protocol !== 'https:') {
+            throw new Error(`Unsupported link ${url}`);
+        }
+        if (publicOnly) {
+            checkHost(url);
+        }
+        const response = await fetch(url, {
+            headers: url.host === host ? { ...defaultHeaders, ...headers } : defaultHeaders,
+            redirect: 'manual',
+            agent: publicOnly ? publicAgent : undefined,
+        });
+        const location = response.headers.get('location');
+        if (response.status < 300 || response.status >= 400 || !location) {
+            return { response, url: url.toString() };
+        }
+        if (redirects >= maxRedirects) {
+            throw new Error(`Too many redirects for ${link}`);
+        }
+        url = new URL(location, url);
+    }
+}
 
 const app = express();
 app.use(express.json());
 
 app.post('/fetch', async (req, res) => {
-    const { url } = req.body;
-    console.log("url:", req.body);
+    // headers come from the fetch profile of the site, with its user agent and cookies. With
+    // publicOnly the private addresses are refused, for the link and each of its redirects.
+    const { url, headers = {}, publicOnly = false } = req.body;
+    // Only the link is logged, the headers may hold the cookies of the profile
+    console.log("url:", url);
 
     try {
-        const response = await fetch(url, {
-            headers: {
-            'User-Agent': 'Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.3',
-            'Accept-Language': 'en-US,en;q=0.9',
-            'Accept': 'text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8',
-            'Connection': 'keep-alive',
-            'Upgrade-Insecure-Requests': '1'
-            }
-        });
+        const { response, url: finalURL } = await fetchPage(url, headers, publicOnly);
+        if (!response.ok) {
+            return res.status(502).send(`The page answered with status ${response.status}`);
+        }
         const data = await response.text();
-        const dom = new JSDOM(data);
+        // The URL after the redirects, so relative links are resolved against the right page
+        const dom = new JSDOM(data, { url: finalURL });
         const document = dom.window.document;
 
         const reader = new Readability(document)
         const article = reader.parse()
+        if (!article) {
+            return res.status(422).send('No article found in the page');
+        }
 
-        res.status(200).send(article);
+        res.status(200).send({ ...article, url: finalURL });
     } catch (err) {
         console.error(err)
+        // The server tells the blocked links from the other errors by the status
+        if (err.code === 'EBLOCKEDADDRESS') {
+            return res.status(403).send(err.message);
+        }
         res.status(500).send('Error fetching the URL');
     }
 });
@@ -55,6 +100,7 @@ app.use((_, res) => {
     res.status(404).send('Not Found');
 });
 
-app.listen(3000, () => {
-    console.log('Server is listening on port 3000');
+const port = process.env.PORT || 3000;
+app.listen(port, () => {
+    console.log(`Server is listening on port ${port}`);
 });